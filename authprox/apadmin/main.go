@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/url"
 	"os"
+	"time"
 
 	cli "github.com/urfave/cli"
 	"go.dedis.ch/cothority/v3"
@@ -14,6 +17,7 @@ import (
 	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/cfgpath"
@@ -32,16 +36,93 @@ var cmds = cli.Commands{
 			},
 			cli.StringFlag{
 				Name:  "type",
-				Usage: "the type of validator: oidc (OpenID Connect)",
+				Usage: "the type of validator: oidc (OpenID Connect), saml, ldap or jwt",
 				Value: "oidc",
 			},
 			cli.StringFlag{
 				Name:  "issuer",
-				Usage: "the URL of the identity provider (for OpenID Connect, this is the Issuer URL)",
+				Usage: "the identity provider: the Issuer URL for oidc, the entity ID for saml, the ldap:// or ldaps:// URL for ldap, the iss claim for jwt",
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "PEM file with the certificate of the IdP for saml, or the public key the tokens are signed with for jwt",
+			},
+			cli.StringFlag{
+				Name:  "sp",
+				Usage: "the entity ID of the service provider the saml assertions are issued for",
+			},
+			cli.StringFlag{
+				Name:  "acs",
+				Usage: "the URL of the assertion consumer service of the service provider for saml",
+			},
+			cli.StringFlag{
+				Name:  "bind-dn",
+				Usage: "template of the bind DN for ldap, where %s is the user, e.g. uid=%s,ou=people,dc=example,dc=com",
+			},
+			cli.BoolFlag{
+				Name:  "dkg",
+				Usage: "create the key with a distributed key generation among the proxies instead of sending them shares",
+			},
+			cli.IntFlag{
+				Name:  "threshold",
+				Usage: "with --dkg, the number of proxies needed to sign, by default n - (n-1)/3",
 			},
 		},
 		Action: add,
 	},
+	{
+		Name:      "authorize-reshare",
+		Usage:     "authorize the share of a proxy to be reshared",
+		ArgsUsage: "private.toml of the proxy",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "type",
+				Usage: "the type of validator",
+				Value: "oidc",
+			},
+			cli.StringFlag{
+				Name:  "issuer",
+				Usage: "the identity provider",
+			},
+			cli.StringFlag{
+				Name:  "new-roster",
+				Usage: "the roster of the proxies which will hold the new shares",
+			},
+			cli.IntFlag{
+				Name:  "threshold",
+				Usage: "the new number of proxies needed to sign, by default n - (n-1)/3",
+			},
+		},
+		Action: authorizeReshare,
+	},
+	{
+		Name:  "reshare",
+		Usage: "move the key of an identity provider to new proxies or a new threshold, keeping its public key",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "roster, r",
+				Usage: "the roster of the proxies holding the key",
+			},
+			cli.StringFlag{
+				Name:  "new-roster",
+				Usage: "the roster of the proxies which will hold the new shares",
+			},
+			cli.StringFlag{
+				Name:  "type",
+				Usage: "the type of validator",
+				Value: "oidc",
+			},
+			cli.StringFlag{
+				Name:  "issuer",
+				Usage: "the identity provider",
+			},
+			cli.IntFlag{
+				Name:  "threshold",
+				Usage: "the new number of proxies needed to sign, by default n - (n-1)/3",
+			},
+		},
+		Action: reshare,
+	},
 	{
 		Name:  "show",
 		Usage: "show the enrollments of external identity providers",
@@ -119,7 +200,7 @@ func show(c *cli.Context) error {
 	}
 
 	for _, x := range resp.Enrollments {
-		fmt.Println(x.Type, x.Issuer, x.Public)
+		fmt.Println(x.Type, x.Issuer, x.Public, x.Threshold)
 	}
 	return nil
 }
@@ -130,6 +211,11 @@ func add(c *cli.Context) error {
 		return errors.New("--issuer flag is required")
 	}
 
+	config, err := validatorConfig(c)
+	if err != nil {
+		return err
+	}
+
 	// Load the roster
 	fn := c.String("roster")
 	if fn == "" {
@@ -145,6 +231,28 @@ func add(c *cli.Context) error {
 		return err
 	}
 
+	if c.Bool("dkg") {
+		cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
+		req := &authprox.EnrollDKGRequest{
+			Type:      c.String("type"),
+			Issuer:    is,
+			Roster:    *roster,
+			Threshold: c.Int("threshold"),
+			Config:    config,
+		}
+		resp := &authprox.EnrollDKGResponse{}
+		err := cl.SendProtobuf(roster.List[0], req, resp)
+		if err != nil {
+			return fmt.Errorf("cannot enroll with %v: %v", roster.List[0], err)
+		}
+		fmt.Fprintln(c.App.Writer, "External provider enrolled. Use identities of this form:")
+		fmt.Fprintf(c.App.Writer, "\tproxy:%v:user@example.com\n", resp.Public)
+		return nil
+	}
+	if c.Int("threshold") != 0 {
+		return errors.New("--threshold can only be used with --dkg")
+	}
+
 	// Get all the key material ready.
 
 	// n: how many auth proxies will be holding shares
@@ -176,6 +284,7 @@ func add(c *cli.Context) error {
 			Participants: pubs,
 			LongPri:      lpri,
 			LongPubs:     lPubCommits,
+			Config:       config,
 		}
 		resp := &authprox.EnrollResponse{}
 		err := cl.SendProtobuf(s, req, resp)
@@ -189,6 +298,138 @@ func add(c *cli.Context) error {
 	return nil
 }
 
+func authorizeReshare(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return errors.New("please give: private.toml")
+	}
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	newRoster, err := loadRoster(c.String("new-roster"), "--new-roster")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := app.LoadCothority(c.Args().First())
+	if err != nil {
+		return err
+	}
+	si, err := cfg.GetServerIdentity()
+	if err != nil {
+		return err
+	}
+
+	req := &authprox.AuthorizeReshareRequest{
+		Type:      c.String("type"),
+		Issuer:    is,
+		NewRoster: *newRoster,
+		Threshold: c.Int("threshold"),
+		Timestamp: time.Now().Unix(),
+	}
+	req.Signature, err = schnorr.Sign(cothority.Suite, si.GetPrivate(), req.Hash())
+	if err != nil {
+		return err
+	}
+	cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
+	err = cl.SendProtobuf(si, req, &authprox.AuthorizeReshareResponse{})
+	if err != nil {
+		return fmt.Errorf("cannot authorize on %v: %v", si, err)
+	}
+	fmt.Fprintln(c.App.Writer, "Resharing authorized on", si.Address)
+	return nil
+}
+
+func reshare(c *cli.Context) error {
+	is := c.String("issuer")
+	if is == "" {
+		return errors.New("--issuer flag is required")
+	}
+	roster, err := loadRoster(c.String("roster"), "--roster")
+	if err != nil {
+		return err
+	}
+	newRoster, err := loadRoster(c.String("new-roster"), "--new-roster")
+	if err != nil {
+		return err
+	}
+
+	cl := onet.NewClient(cothority.Suite, authprox.ServiceName)
+	req := &authprox.ReshareRequest{
+		Type:      c.String("type"),
+		Issuer:    is,
+		OldRoster: *roster,
+		NewRoster: *newRoster,
+		Threshold: c.Int("threshold"),
+	}
+	resp := &authprox.ReshareResponse{}
+	err = cl.SendProtobuf(newRoster.List[0], req, resp)
+	if err != nil {
+		return fmt.Errorf("cannot reshare with %v: %v", newRoster.List[0], err)
+	}
+	fmt.Fprintln(c.App.Writer, "Key reshared, the identities are unchanged:")
+	fmt.Fprintf(c.App.Writer, "\tproxy:%v:user@example.com\n", resp.Public)
+	return nil
+}
+
+// validatorConfig returns the configuration of the validator, depending on
+// its type.
+func validatorConfig(c *cli.Context) ([]byte, error) {
+	switch c.String("type") {
+	case "saml":
+		buf, err := readKey(c)
+		if err != nil {
+			return nil, err
+		}
+		if c.String("sp") == "" {
+			return nil, errors.New("--sp flag is required")
+		}
+		if c.String("acs") == "" {
+			return nil, errors.New("--acs flag is required")
+		}
+		return json.Marshal(&authprox.SAMLConfig{
+			Certificates: string(buf),
+			EntityID:     c.String("sp"),
+			ACS:          c.String("acs"),
+		})
+	case "jwt":
+		return readKey(c)
+	case "ldap":
+		dn := c.String("bind-dn")
+		if dn == "" {
+			return nil, errors.New("--bind-dn flag is required")
+		}
+		return []byte(dn), nil
+	}
+	return nil, nil
+}
+
+// readKey reads the PEM file given by the key flag.
+func readKey(c *cli.Context) ([]byte, error) {
+	fn := c.String("key")
+	if fn == "" {
+		return nil, errors.New("--key flag is required")
+	}
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read key %v: %v", fn, err)
+	}
+	return buf, nil
+}
+
+// loadRoster reads the roster in the file given by the flag.
+func loadRoster(fn, flag string) (*onet.Roster, error) {
+	if fn == "" {
+		return nil, fmt.Errorf("%v flag is required", flag)
+	}
+	in, err := os.Open(fn)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open roster %v: %v", fn, err)
+	}
+	defer in.Close()
+	return readRoster(in)
+}
+
 func readRoster(r io.Reader) (*onet.Roster, error) {
 	group, err := app.ReadGroupDescToml(r)
 	if err != nil {
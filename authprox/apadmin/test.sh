@@ -21,6 +21,7 @@ main(){
 	run BCSetup
 
 	run testAdd
+	run testDKG
 	stopTest
 }
 
@@ -40,6 +41,16 @@ testAdd(){
 	testOK ./apadmin add --roster public.toml -issuer https://oauth.dedis.ch
 	testGrep https://oauth.dedis.ch ./apadmin show --roster public.toml
 
+	# Validators that need a configuration.
+	testOK ./apadmin add --roster public.toml -type ldap -issuer ldap://ldap.example.com -bind-dn "uid=%s,dc=example,dc=com"
+	testGrep ldap://ldap.example.com ./apadmin show --roster public.toml
+	testFail ./apadmin add --roster public.toml -type ldap -issuer ldap://other.example.com
+	testFail ./apadmin add --roster public.toml -type ldap -issuer ldap://other.example.com -bind-dn "dc=example,dc=com"
+	testFail ./apadmin add --roster public.toml -type jwt -issuer https://tokens.example.com
+	echo "not a key" > key.pem
+	testFail ./apadmin add --roster public.toml -type jwt -issuer https://tokens.example.com -key key.pem
+	testNGrep tokens.example.com ./apadmin show --roster public.toml
+
 	# Check that secrets are not sent unencrypted.
 	cat > tcp.toml << %%
 [[servers]]
@@ -53,4 +64,20 @@ testAdd(){
 
 }
 
+testDKG(){
+	runCoBG 1 2 3
+	testOK ./apadmin add --roster public.toml --dkg -issuer https://dkg.example.com
+	testGrep https://dkg.example.com ./apadmin show --roster public.toml
+	testFail ./apadmin add --roster public.toml --dkg -issuer https://dkg.example.com
+	testFail ./apadmin add --roster public.toml --dkg -threshold 4 -issuer https://dkg4.example.com
+
+	# Lower the threshold, keeping the same key.
+	testFail ./apadmin reshare --roster public.toml --new-roster public.toml -issuer https://dkg.example.com -threshold 2
+	for i in 1 2 3; do
+		testOK ./apadmin authorize-reshare co$i/private.toml --new-roster public.toml -issuer https://dkg.example.com -threshold 2
+	done
+	testOK ./apadmin reshare --roster public.toml --new-roster public.toml -issuer https://dkg.example.com -threshold 2
+	testGrep "https://dkg.example.com .* 2" ./apadmin show --roster public.toml
+}
+
 main
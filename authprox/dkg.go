@@ -0,0 +1,492 @@
+package authprox
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
+	"go.dedis.ch/kyber/v3"
+	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// The protocols have their own names, so that they are not intercepted by
+// other services using the DKG protocol.
+const (
+	dkgProto     = "authprox_dkg"
+	reshareProto = "authprox_reshare"
+)
+
+// dkgTimeout is how long the leader waits for the DKG to finish.
+var dkgTimeout = 20 * time.Second
+
+// reshareMaxSkew is how far the timestamp of an authorization may be from
+// the time of the proxy.
+const reshareMaxSkew = time.Minute
+
+// reshareAuthValidity is how long an authorization to reshare is kept.
+const reshareAuthValidity = 10 * time.Minute
+
+// dkgConfig is sent to all the nodes of the DKG and resharing protocols.
+// For a resharing, the roster of the protocol is made of the NewNodes first
+// nodes, which will hold the new shares, followed by the old nodes which
+// are not in the new roster.
+type dkgConfig struct {
+	Type         string
+	Issuer       string
+	Config       []byte
+	NewNodes     int
+	Threshold    int
+	OldNodes     []kyber.Point
+	OldThreshold int
+	Commits      []kyber.Point
+}
+
+// reshareAuth is a resharing authorized by the administrator of the proxy.
+type reshareAuth struct {
+	publics   []kyber.Point
+	threshold int
+	expires   time.Time
+}
+
+// Hash returns the hash signed by the administrator of the proxy.
+func (r *AuthorizeReshareRequest) Hash() []byte {
+	h := sha256.New()
+	writeString(h, r.Type)
+	writeString(h, r.Issuer)
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(r.Threshold))
+	h.Write(b)
+	for _, p := range r.NewRoster.Publics() {
+		_, _ = p.MarshalTo(h)
+	}
+	binary.LittleEndian.PutUint64(b, uint64(r.Timestamp))
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func writeString(h io.Writer, s string) {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(len(s)))
+	h.Write(b)
+	h.Write([]byte(s))
+}
+
+// EnrollDKG creates the key of the type and issuer with a distributed key
+// generation among the proxies of the roster.
+func (s *service) EnrollDKG(req *EnrollDKGRequest) (*EnrollDKGResponse, error) {
+	if req == nil {
+		return nil, errors.New("no request")
+	}
+	n := len(req.Roster.List)
+	if n < 2 {
+		return nil, errors.New("need at least 2 proxies")
+	}
+	if !req.Roster.List[0].Equal(s.ServerIdentity()) {
+		return nil, errors.New("must be sent to the first node of the roster")
+	}
+	t, err := resolveThreshold(req.Threshold, n)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkConfig(req.Type, req.Issuer, req.Config); err != nil {
+		return nil, err
+	}
+	if _, err := s.find(req.Type, req.Issuer); err == nil {
+		return nil, fmt.Errorf("enrollment already exists for type:issuer %v:%v", req.Type, req.Issuer)
+	}
+
+	tree := req.Roster.GenerateNaryTreeWithRoot(n, s.ServerIdentity())
+	if tree == nil {
+		return nil, errors.New("could not create tree")
+	}
+	setupDKG, err := s.startDKG(dkgProto, tree, &dkgConfig{
+		Type:      req.Type,
+		Issuer:    req.Issuer,
+		Config:    req.Config,
+		NewNodes:  n,
+		Threshold: t,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-setupDKG.Finished:
+		shared, dks, err := setupDKG.SharedSecret()
+		if err != nil {
+			return nil, err
+		}
+		err = s.store(req.Type, req.Issuer, &dssConfig{
+			Participants: req.Roster.Publics(),
+			LongPri:      *dks.Share,
+			LongPubs:     dks.Commits,
+			Config:       req.Config,
+			Threshold:    t,
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+		return &EnrollDKGResponse{Public: shared.X}, nil
+	case <-time.After(dkgTimeout):
+		return nil, errors.New("dkg didn't finish in time")
+	}
+}
+
+// AuthorizeReshare allows the share of this proxy to be reshared. It must be
+// signed with the private key of the proxy.
+func (s *service) AuthorizeReshare(req *AuthorizeReshareRequest) (*AuthorizeReshareResponse, error) {
+	if req == nil {
+		return nil, errors.New("no request")
+	}
+	if _, err := s.find(req.Type, req.Issuer); err != nil {
+		return nil, fmt.Errorf("cannot find key: %v", err)
+	}
+	ts := time.Unix(req.Timestamp, 0)
+	if d := time.Since(ts); d > reshareMaxSkew || d < -reshareMaxSkew {
+		return nil, errors.New("timestamp is too far off")
+	}
+	err := schnorr.Verify(cothority.Suite, s.ServerIdentity().Public, req.Hash(), req.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	t, err := resolveThreshold(req.Threshold, len(req.NewRoster.List))
+	if err != nil {
+		return nil, err
+	}
+	k, err := protobuf.Encode(&ti{T: req.Type, I: req.Issuer})
+	if err != nil {
+		return nil, err
+	}
+
+	s.reshareMutex.Lock()
+	defer s.reshareMutex.Unlock()
+	s.reshares[string(k)] = reshareAuth{
+		publics:   req.NewRoster.Publics(),
+		threshold: t,
+		expires:   time.Now().Add(reshareAuthValidity),
+	}
+	return &AuthorizeReshareResponse{}, nil
+}
+
+// consumeReshare returns an error if the resharing has not been authorized.
+// An authorization can only be used once.
+func (s *service) consumeReshare(typ, issuer string, publics []kyber.Point, t int) error {
+	k, err := protobuf.Encode(&ti{T: typ, I: issuer})
+	if err != nil {
+		return err
+	}
+	s.reshareMutex.Lock()
+	defer s.reshareMutex.Unlock()
+	auth, ok := s.reshares[string(k)]
+	if !ok || time.Now().After(auth.expires) {
+		return errors.New("resharing has not been authorized")
+	}
+	if auth.threshold != t || !equalPoints(auth.publics, publics) {
+		return errors.New("resharing differs from the authorized one")
+	}
+	delete(s.reshares, string(k))
+	return nil
+}
+
+// Reshare moves the key of the type and issuer to a new roster and
+// threshold, keeping the same public key.
+func (s *service) Reshare(req *ReshareRequest) (*ReshareResponse, error) {
+	if req == nil {
+		return nil, errors.New("no request")
+	}
+	n := len(req.NewRoster.List)
+	if n < 2 {
+		return nil, errors.New("need at least 2 proxies")
+	}
+	if !req.NewRoster.List[0].Equal(s.ServerIdentity()) {
+		return nil, errors.New("must be sent to the first node of the new roster")
+	}
+	t, err := resolveThreshold(req.Threshold, n)
+	if err != nil {
+		return nil, err
+	}
+	old, err := s.find(req.Type, req.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key: %v", err)
+	}
+	if !equalPoints(req.OldRoster.Publics(), old.Participants) {
+		return nil, errors.New("the old roster is not the one holding the key")
+	}
+	newPubs := req.NewRoster.Publics()
+	if err := s.consumeReshare(req.Type, req.Issuer, newPubs, t); err != nil {
+		return nil, err
+	}
+
+	// The nodes which will hold a share come first, as the DKG sends the
+	// deals to the nodes in the order of the tree.
+	list := append([]*network.ServerIdentity{}, req.NewRoster.List...)
+	for _, si := range req.OldRoster.List {
+		if !containsPoint(newPubs, si.Public) {
+			list = append(list, si)
+		}
+	}
+	roster := onet.NewRoster(list)
+	if roster == nil {
+		return nil, errors.New("invalid roster")
+	}
+	tree := roster.GenerateNaryTreeWithRoot(len(list), s.ServerIdentity())
+	if tree == nil {
+		return nil, errors.New("could not create tree")
+	}
+	cfg := &dkgConfig{
+		Type:         req.Type,
+		Issuer:       req.Issuer,
+		Config:       old.Config,
+		NewNodes:     n,
+		Threshold:    t,
+		OldNodes:     old.Participants,
+		OldThreshold: old.threshold(),
+		Commits:      old.LongPubs,
+	}
+	setupDKG, err := s.startDKG(reshareProto, tree, cfg, old)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-setupDKG.Finished:
+		shared, err := s.storeReshared(setupDKG, cfg, newPubs)
+		if err != nil {
+			return nil, err
+		}
+		return &ReshareResponse{Public: shared.X}, nil
+	case <-time.After(dkgTimeout):
+		return nil, errors.New("resharing didn't finish in time")
+	}
+}
+
+// startDKG starts the DKG, or the resharing if old is not nil, as the root
+// of the tree.
+func (s *service) startDKG(name string, tree *onet.Tree, cfg *dkgConfig,
+	old *dssConfig) (*dkgprotocol.Setup, error) {
+	buf, err := protobuf.Encode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	pi, err := s.CreateProtocol(name, tree)
+	if err != nil {
+		return nil, err
+	}
+	setupDKG := pi.(*dkgprotocol.Setup)
+	setupDKG.Wait = true
+	setupDKG.KeyPair = s.keyPair()
+	setupDKG.Threshold = uint32(cfg.Threshold)
+	if err := setupDKG.SetConfig(&onet.GenericConfig{Data: buf}); err != nil {
+		return nil, err
+	}
+	if old != nil {
+		setupDKG.NewDKG = s.newReshareDKG(cfg, tree.Roster, old)
+	}
+	if err := setupDKG.Start(); err != nil {
+		return nil, err
+	}
+	return setupDKG, nil
+}
+
+// newReshareDKG returns the constructor of the resharing DKG. old is nil if
+// this node does not hold a share yet.
+func (s *service) newReshareDKG(cfg *dkgConfig, roster *onet.Roster,
+	old *dssConfig) func() (*dkg.DistKeyGenerator, error) {
+	c := &dkg.Config{
+		Suite:        cothority.Suite,
+		Longterm:     s.ServerIdentity().GetPrivate(),
+		OldNodes:     cfg.OldNodes,
+		NewNodes:     roster.Publics()[:cfg.NewNodes],
+		Threshold:    cfg.Threshold,
+		OldThreshold: cfg.OldThreshold,
+	}
+	if old != nil {
+		c.Share = &dkg.DistKeyShare{
+			Commits: old.LongPubs,
+			Share:   &old.LongPri,
+		}
+	} else {
+		c.PublicCoeffs = cfg.Commits
+	}
+	return func() (*dkg.DistKeyGenerator, error) {
+		return dkg.NewDistKeyHandler(c)
+	}
+}
+
+// storeReshared stores the new share of this node, after checking the
+// public key did not change.
+func (s *service) storeReshared(setupDKG *dkgprotocol.Setup, cfg *dkgConfig,
+	newPubs []kyber.Point) (*dkgprotocol.SharedSecret, error) {
+	shared, dks, err := setupDKG.SharedSecret()
+	if err != nil {
+		return nil, err
+	}
+	if !shared.X.Equal(cfg.Commits[0]) {
+		return nil, errors.New("the reshared public key is different")
+	}
+	err = s.store(cfg.Type, cfg.Issuer, &dssConfig{
+		Participants: newPubs,
+		LongPri:      *dks.Share,
+		LongPubs:     dks.Commits,
+		Config:       cfg.Config,
+		Threshold:    cfg.Threshold,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+	return shared, nil
+}
+
+// NewProtocol sets up the DKG and resharing protocols on the nodes which
+// are not the root, and stores the share once they are done.
+func (s *service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfig) (onet.ProtocolInstance, error) {
+	switch tn.ProtocolName() {
+	case dkgProto, reshareProto:
+	default:
+		return nil, nil
+	}
+	if conf == nil {
+		return nil, errors.New("missing config")
+	}
+	var cfg dkgConfig
+	err := protobuf.DecodeWithConstructors(conf.Data, &cfg, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, err
+	}
+	n := len(tn.Roster().List)
+	if cfg.NewNodes < 2 || cfg.NewNodes > n {
+		return nil, errors.New("invalid number of new nodes")
+	}
+	if _, err := resolveThreshold(cfg.Threshold, cfg.NewNodes); err != nil {
+		return nil, err
+	}
+	newPubs := tn.Roster().Publics()[:cfg.NewNodes]
+	me := s.ServerIdentity().Public
+	old, findErr := s.find(cfg.Type, cfg.Issuer)
+
+	pi, err := dkgprotocol.NewSetup(tn)
+	if err != nil {
+		return nil, err
+	}
+	setupDKG := pi.(*dkgprotocol.Setup)
+	setupDKG.KeyPair = s.keyPair()
+
+	if tn.ProtocolName() == dkgProto {
+		if err := s.checkConfig(cfg.Type, cfg.Issuer, cfg.Config); err != nil {
+			return nil, err
+		}
+		if findErr == nil {
+			return nil, fmt.Errorf("enrollment already exists for type:issuer %v:%v", cfg.Type, cfg.Issuer)
+		}
+		go s.waitDKG(setupDKG, func() error {
+			_, dks, err := setupDKG.SharedSecret()
+			if err != nil {
+				return err
+			}
+			return s.store(cfg.Type, cfg.Issuer, &dssConfig{
+				Participants: newPubs,
+				LongPri:      *dks.Share,
+				LongPubs:     dks.Commits,
+				Config:       cfg.Config,
+				Threshold:    cfg.Threshold,
+			}, false)
+		})
+		return pi, nil
+	}
+
+	// Resharing
+	if len(cfg.Commits) == 0 {
+		return nil, errors.New("missing commits")
+	}
+	inOld := containsPoint(cfg.OldNodes, me)
+	inNew := containsPoint(newPubs, me)
+	if inOld {
+		if findErr != nil {
+			return nil, fmt.Errorf("cannot find key: %v", findErr)
+		}
+		if !equalPoints(old.Participants, cfg.OldNodes) ||
+			!old.LongPubs[0].Equal(cfg.Commits[0]) {
+			return nil, errors.New("the old nodes do not hold this key")
+		}
+		if err := s.consumeReshare(cfg.Type, cfg.Issuer, newPubs, cfg.Threshold); err != nil {
+			return nil, err
+		}
+	} else {
+		if findErr == nil {
+			return nil, fmt.Errorf("enrollment already exists for type:issuer %v:%v", cfg.Type, cfg.Issuer)
+		}
+		old = nil
+	}
+	setupDKG.NewDKG = s.newReshareDKG(&cfg, tn.Roster(), old)
+	go s.waitDKG(setupDKG, func() error {
+		if !inNew {
+			return s.remove(cfg.Type, cfg.Issuer)
+		}
+		_, err := s.storeReshared(setupDKG, &cfg, newPubs)
+		return err
+	})
+	return pi, nil
+}
+
+// waitDKG calls done once the DKG finished.
+func (s *service) waitDKG(setupDKG *dkgprotocol.Setup, done func() error) {
+	select {
+	case <-setupDKG.Finished:
+		if err := done(); err != nil {
+			log.Error(s.ServerIdentity(), err)
+		}
+	case <-time.After(dkgTimeout):
+		log.Error(s.ServerIdentity(), "dkg didn't finish in time")
+	}
+}
+
+func (s *service) keyPair() *key.Pair {
+	return &key.Pair{
+		Public:  s.ServerIdentity().Public,
+		Private: s.ServerIdentity().GetPrivate(),
+	}
+}
+
+// resolveThreshold returns the default threshold if t is 0, and checks it
+// can be used with n nodes.
+func resolveThreshold(t, n int) (int, error) {
+	if t == 0 {
+		t = threshold(n)
+	}
+	if t < 2 || t > n {
+		return 0, fmt.Errorf("threshold must be between 2 and %v", n)
+	}
+	return t, nil
+}
+
+func containsPoint(list []kyber.Point, p kyber.Point) bool {
+	for _, q := range list {
+		if q.Equal(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalPoints(a, b []kyber.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
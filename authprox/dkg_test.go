@@ -0,0 +1,138 @@
+package authprox
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/onet/v3"
+)
+
+// Test_EnrollDKGAndReshare creates a key with a DKG, signs with it, and
+// reshares it to a roster with one proxy less and one proxy more.
+func Test_EnrollDKGAndReshare(t *testing.T) {
+	local := onet.NewLocalTestT(cothority.Suite, t)
+	defer local.CloseAll()
+	hosts, all, _ := local.GenTree(6, true)
+	var services []*service
+	for _, sv := range local.GetServices(hosts, authProxID) {
+		s := sv.(*service)
+		s.registerValidator("dummy", &valid{})
+		services = append(services, s)
+	}
+	roster := onet.NewRoster(all.List[:5])
+
+	// Only the first node of the roster can start the DKG.
+	_, err := services[1].EnrollDKG(&EnrollDKGRequest{Type: "dummy", Roster: *roster})
+	require.Error(t, err)
+	_, err = services[0].EnrollDKG(&EnrollDKGRequest{Type: "dummy", Roster: *roster,
+		Threshold: 6})
+	require.Error(t, err)
+
+	resp, err := services[0].EnrollDKG(&EnrollDKGRequest{Type: "dummy", Roster: *roster})
+	require.NoError(t, err)
+	pub := resp.Public
+	waitEnrolled(t, services[:5], pub, 4)
+	verifyProxySig(t, services[:5], pub, 4)
+
+	_, err = services[0].EnrollDKG(&EnrollDKGRequest{Type: "dummy", Roster: *roster})
+	require.Error(t, err)
+
+	// Reshare from the nodes 0..4 to the nodes 1..5, with a threshold of 3.
+	newRoster := onet.NewRoster(all.List[1:])
+	reshare := &ReshareRequest{Type: "dummy", OldRoster: *roster,
+		NewRoster: *newRoster, Threshold: 3}
+	_, err = services[1].Reshare(reshare)
+	require.Error(t, err, "resharing without authorization")
+
+	authorize := func(i int, ts time.Time) error {
+		req := &AuthorizeReshareRequest{Type: "dummy", NewRoster: *newRoster,
+			Threshold: 3, Timestamp: ts.Unix()}
+		sig, err := schnorr.Sign(cothority.Suite,
+			hosts[i].ServerIdentity.GetPrivate(), req.Hash())
+		require.NoError(t, err)
+		req.Signature = sig
+		_, err = services[i].AuthorizeReshare(req)
+		return err
+	}
+	require.Error(t, authorize(0, time.Now().Add(-time.Hour)))
+	require.Error(t, authorize(5, time.Now()), "no share to reshare")
+	bad := &AuthorizeReshareRequest{Type: "dummy", NewRoster: *newRoster,
+		Threshold: 3, Timestamp: time.Now().Unix()}
+	bad.Signature, err = schnorr.Sign(cothority.Suite,
+		hosts[1].ServerIdentity.GetPrivate(), bad.Hash())
+	require.NoError(t, err)
+	_, err = services[0].AuthorizeReshare(bad)
+	require.Error(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, authorize(i, time.Now()))
+	}
+	_, err = services[2].Reshare(reshare)
+	require.Error(t, err)
+	rr, err := services[1].Reshare(reshare)
+	require.NoError(t, err)
+	require.True(t, rr.Public.Equal(pub))
+	waitEnrolled(t, services[1:], pub, 3)
+	require.Eventually(t, func() bool {
+		_, err := services[0].find("dummy", "")
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The authorizations have been used.
+	_, err = services[1].Reshare(reshare)
+	require.Error(t, err)
+
+	verifyProxySig(t, services[1:], pub, 3)
+}
+
+// waitEnrolled waits for all services to store the key.
+func waitEnrolled(t *testing.T, services []*service, pub kyber.Point, T int) {
+	for _, s := range services {
+		require.Eventually(t, func() bool {
+			resp, err := s.Enrollments(&EnrollmentsRequest{Types: []string{"dummy"}})
+			require.NoError(t, err)
+			return len(resp.Enrollments) == 1 &&
+				resp.Enrollments[0].Public.Equal(pub) &&
+				resp.Enrollments[0].Threshold == T
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+}
+
+// verifyProxySig signs with the first T services and verifies the
+// signature against the public key.
+func verifyProxySig(t *testing.T, services []*service, pub kyber.Point, T int) {
+	rPri := share.NewPriPoly(cothority.Suite, T, nil, cothority.Suite.RandomStream())
+	rShares := rPri.Shares(len(services))
+	rPub := rPri.Commit(nil)
+	_, rPubCommits := rPub.Info()
+
+	var partials []*share.PriShare
+	for i, s := range services[:T] {
+		resp, err := s.Signature(&SignatureRequest{
+			Type:     "dummy",
+			Message:  zero64[:],
+			RandPri:  PriShare{I: rShares[i].I, V: rShares[i].V},
+			RandPubs: rPubCommits,
+		})
+		require.NoError(t, err)
+		partials = append(partials, &share.PriShare{
+			I: resp.PartialSignature.Partial.I,
+			V: resp.PartialSignature.Partial.V,
+		})
+	}
+	gamma, err := share.RecoverSecret(cothority.Suite, partials, T, len(services))
+	require.NoError(t, err)
+
+	var buff bytes.Buffer
+	_, _ = rPub.Commit().MarshalTo(&buff)
+	_, _ = gamma.MarshalTo(&buff)
+	id := darc.IdentityProxy{Public: pub, Data: "dummy-claim"}
+	require.NoError(t, id.Verify(zero64[:], buff.Bytes()))
+}
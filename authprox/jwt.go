@@ -0,0 +1,80 @@
+package authprox
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// jwtLeeway is the clock skew accepted when checking the validity of a JWT.
+const jwtLeeway = time.Minute
+
+// jwtValidator checks JWTs signed by a key enrolled together with the issuer,
+// for systems that issue tokens without an OpenID Connect discovery endpoint.
+// The config is the PEM encoded public key: RSA, ECDSA or Ed25519.
+type jwtValidator struct{}
+
+func (j *jwtValidator) CheckConfig(issuer string, config []byte) error {
+	if issuer == "" {
+		return errors.New("the issuer must be the iss claim of the tokens")
+	}
+	_, err := parsePublicKey(config)
+	return err
+}
+
+func (j *jwtValidator) FindClaim(issuer string, config []byte, input []byte) (string, string, error) {
+	key, err := parsePublicKey(config)
+	if err != nil {
+		return "", "", err
+	}
+	tok, err := jwt.ParseSigned(string(input))
+	if err != nil {
+		return "", "", fmt.Errorf("could not parse the token: %v", err)
+	}
+
+	var std jwt.Claims
+	var claims struct {
+		Email string `json:"email"`
+		Nonce string `json:"nonce"`
+	}
+	if err := tok.Claims(key, &std, &claims); err != nil {
+		return "", "", fmt.Errorf("could not verify the token: %v", err)
+	}
+	// Tokens without an expiry would be valid forever.
+	if std.Expiry == nil {
+		return "", "", errors.New("the token has no expiry")
+	}
+	err = std.ValidateWithLeeway(jwt.Expected{Issuer: issuer, Time: time.Now()},
+		jwtLeeway)
+	if err != nil {
+		return "", "", err
+	}
+
+	claim := claims.Email
+	if claim == "" {
+		claim = std.Subject
+	}
+	if claim == "" {
+		return "", "", errors.New("the token has neither an email nor a subject")
+	}
+	return claim, claims.Nonce, nil
+}
+
+func parsePublicKey(config []byte) (interface{}, error) {
+	block, _ := pem.Decode(config)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -0,0 +1,93 @@
+package authprox
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// jwtIssuer is a stand-in for a system issuing signed JWTs.
+type jwtIssuer struct {
+	key    *ecdsa.PrivateKey
+	signer jose.Signer
+}
+
+func newJWTIssuer(t *testing.T) *jwtIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+	require.NoError(t, err)
+	return &jwtIssuer{key: key, signer: signer}
+}
+
+func (j *jwtIssuer) publicPEM(t *testing.T) []byte {
+	buf, err := x509.MarshalPKIXPublicKey(&j.key.PublicKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: buf})
+}
+
+func (j *jwtIssuer) token(t *testing.T, std jwt.Claims, custom interface{}) []byte {
+	b := jwt.Signed(j.signer).Claims(std)
+	if custom != nil {
+		b = b.Claims(custom)
+	}
+	tok, err := b.CompactSerialize()
+	require.NoError(t, err)
+	return []byte(tok)
+}
+
+func TestJWTValidator(t *testing.T) {
+	is := newJWTIssuer(t)
+	key := is.publicPEM(t)
+	iss := "https://tokens.example.com"
+
+	v := &jwtValidator{}
+	require.NoError(t, v.CheckConfig(iss, key))
+	require.Error(t, v.CheckConfig(iss, []byte("no key")))
+	require.Error(t, v.CheckConfig("", key))
+
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	email := struct {
+		Email string `json:"email"`
+		Nonce string `json:"nonce"`
+	}{"alice@example.com", "1234"}
+	claim, hash, err := v.FindClaim(iss, key, is.token(t,
+		jwt.Claims{Issuer: iss, Subject: "alice", Expiry: exp}, email))
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", claim)
+	require.Equal(t, "1234", hash)
+
+	claim, _, err = v.FindClaim(iss, key, is.token(t,
+		jwt.Claims{Issuer: iss, Subject: "alice", Expiry: exp}, nil))
+	require.NoError(t, err)
+	require.Equal(t, "alice", claim)
+
+	// Wrong issuer, expired, no expiry, and no subject.
+	_, _, err = v.FindClaim(iss, key, is.token(t,
+		jwt.Claims{Issuer: "other", Subject: "alice", Expiry: exp}, nil))
+	require.Error(t, err)
+	_, _, err = v.FindClaim(iss, key, is.token(t,
+		jwt.Claims{Issuer: iss, Subject: "alice",
+			Expiry: jwt.NewNumericDate(time.Now().Add(-time.Hour))}, nil))
+	require.Error(t, err)
+	_, _, err = v.FindClaim(iss, key, is.token(t,
+		jwt.Claims{Issuer: iss, Subject: "alice"}, nil))
+	require.Error(t, err)
+	_, _, err = v.FindClaim(iss, key, is.token(t,
+		jwt.Claims{Issuer: iss, Expiry: exp}, nil))
+	require.Error(t, err)
+
+	// Signed by another key.
+	other := newJWTIssuer(t)
+	_, _, err = v.FindClaim(iss, key, other.token(t,
+		jwt.Claims{Issuer: iss, Subject: "alice", Expiry: exp}, nil))
+	require.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package authprox
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapTimeout is how long the connection to the LDAP server may take.
+const ldapTimeout = 10 * time.Second
+
+// LDAPAuthInfo is the authentication info of the ldap validator, sent as
+// JSON.
+type LDAPAuthInfo struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// ldapValidator checks a user and a password with a simple bind to an LDAP
+// server. The issuer is the URL of the server, ldap:// or ldaps://, and the
+// config is the template of the bind DN, where %s is replaced by the user,
+// for example "uid=%s,ou=people,dc=example,dc=com". The claim is the user.
+type ldapValidator struct{}
+
+func (l *ldapValidator) CheckConfig(issuer string, config []byte) error {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return errors.New("the issuer must be an ldap:// or ldaps:// URL")
+	}
+	if strings.Count(string(config), "%s") != 1 {
+		return errors.New("the bind DN template must contain %s exactly once")
+	}
+	return nil
+}
+
+func (l *ldapValidator) FindClaim(issuer string, config []byte, input []byte) (string, string, error) {
+	if err := l.CheckConfig(issuer, config); err != nil {
+		return "", "", err
+	}
+	var ai LDAPAuthInfo
+	if err := json.Unmarshal(input, &ai); err != nil {
+		return "", "", fmt.Errorf("could not decode the auth info: %v", err)
+	}
+	if err := checkLDAPUser(ai.User); err != nil {
+		return "", "", err
+	}
+	// An empty password would be an unauthenticated bind, which succeeds
+	// for any user.
+	if ai.Password == "" {
+		return "", "", errors.New("empty password")
+	}
+
+	conn, err := dialLDAP(issuer)
+	if err != nil {
+		return "", "", fmt.Errorf("could not connect to %v: %v", issuer, err)
+	}
+	defer conn.Close()
+
+	dn := strings.Replace(string(config), "%s", ai.User, 1)
+	if err := conn.Bind(dn, ai.Password); err != nil {
+		return "", "", fmt.Errorf("bind failed: %v", err)
+	}
+	return ai.User, "", nil
+}
+
+// dialLDAP connects to the server with a deadline on the connection, so that
+// a server that does not answer cannot block the signature.
+func dialLDAP(issuer string) (*ldap.Conn, error) {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "ldaps" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+	}
+	d := &net.Dialer{Timeout: ldapTimeout}
+	var c net.Conn
+	if u.Scheme == "ldaps" {
+		c, err = tls.DialWithDialer(d, "tcp", host,
+			&tls.Config{ServerName: u.Hostname()})
+	} else {
+		c, err = d.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetDeadline(time.Now().Add(ldapTimeout)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	conn := ldap.NewConn(c, u.Scheme == "ldaps")
+	conn.Start()
+	return conn, nil
+}
+
+// checkLDAPUser refuses users that would change the structure of the bind
+// DN.
+func checkLDAPUser(user string) error {
+	if user == "" {
+		return errors.New("empty user")
+	}
+	if strings.ContainsAny(user, ",+\"\\<>;=#\x00") ||
+		strings.TrimSpace(user) != user {
+		return errors.New("invalid characters in user")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package authprox
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/require"
+)
+
+// ldapServer is a stand-in LDAP server that only knows simple binds.
+type ldapServer struct {
+	l     net.Listener
+	users map[string]string
+}
+
+func newLDAPServer(t *testing.T, users map[string]string) *ldapServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &ldapServer{l: l, users: users}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *ldapServer) url() string {
+	return "ldap://" + s.l.Addr().String()
+}
+
+func (s *ldapServer) serve(c net.Conn) {
+	defer c.Close()
+	for {
+		p, err := ber.ReadPacket(c)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id := p.Children[0].Value.(int64)
+		op := p.Children[1]
+		if op.Tag != 0 {
+			// Unbind, or anything else we do not know about.
+			return
+		}
+		dn := op.Children[1].Value.(string)
+		password := op.Children[2].Data.String()
+
+		// 0 is success, 49 is invalidCredentials.
+		result := 49
+		if pw, ok := s.users[dn]; ok && pw == password {
+			result = 0
+		}
+		resp := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		resp.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+		bind := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 1, nil, "Bind Response")
+		bind.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, result, "resultCode"))
+		bind.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		bind.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		resp.AppendChild(bind)
+		if _, err := c.Write(resp.Bytes()); err != nil {
+			return
+		}
+	}
+}
+
+func TestLDAPValidator(t *testing.T) {
+	s := newLDAPServer(t, map[string]string{
+		"uid=alice,ou=people,dc=example,dc=com": "secret",
+	})
+	defer s.l.Close()
+	dn := []byte("uid=%s,ou=people,dc=example,dc=com")
+
+	v := &ldapValidator{}
+	require.NoError(t, v.CheckConfig(s.url(), dn))
+	require.Error(t, v.CheckConfig("https://example.com", dn))
+	require.Error(t, v.CheckConfig(s.url(), []byte("uid=alice")))
+
+	ai := func(user, pw string) []byte {
+		buf, err := json.Marshal(LDAPAuthInfo{User: user, Password: pw})
+		require.NoError(t, err)
+		return buf
+	}
+	claim, hash, err := v.FindClaim(s.url(), dn, ai("alice", "secret"))
+	require.NoError(t, err)
+	require.Equal(t, "alice", claim)
+	require.Equal(t, "", hash)
+
+	_, _, err = v.FindClaim(s.url(), dn, ai("alice", "wrong"))
+	require.Error(t, err)
+	_, _, err = v.FindClaim(s.url(), dn, ai("alice", ""))
+	require.Error(t, err)
+	_, _, err = v.FindClaim(s.url(), dn, ai("bob", "secret"))
+	require.Error(t, err)
+	// Injecting a different DN is refused before talking to the server.
+	_, _, err = v.FindClaim(s.url(), dn, ai("x,uid=alice", "secret"))
+	require.Error(t, err)
+	_, _, err = v.FindClaim(s.url(), dn, []byte("alice:secret"))
+	require.Error(t, err)
+}
@@ -24,7 +24,7 @@ type issuer struct {
 	ctx    context.Context
 }
 
-func (o *oidcValidator) FindClaim(issuerStr string, input []byte) (string, string, error) {
+func (o *oidcValidator) FindClaim(issuerStr string, config []byte, input []byte) (string, string, error) {
 	o.Lock()
 	defer o.Unlock()
 
@@ -2,27 +2,91 @@ package authprox
 
 import (
 	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3"
 )
 
 // PROTOSTART
 // package authprox;
+// import "onet.proto";
+//
 // option java_package = "ch.epfl.dedis.lib.proto";
 // option java_outer_classname = "AuthProxProto";
 
 // EnrollRequest is the request sent to this service to enroll
 // a user, authenticated by a certain type of external authentication.
+// Config holds the configuration the validator of the type needs to check
+// the authentication info of this issuer: the JSON SAMLConfig of a SAML
+// identity provider, the bind DN template of an LDAP server, or the PEM
+// public key the JWTs are signed with.
 type EnrollRequest struct {
 	Type         string
 	Issuer       string
 	Participants []kyber.Point
 	LongPri      PriShare
 	LongPubs     []kyber.Point
+	Config       []byte
 }
 
 // EnrollResponse is returned when an enrollment has been done correctly.
 type EnrollResponse struct {
 }
 
+// EnrollDKGRequest asks the authentication proxies of the roster to create
+// the key of the type and issuer with a distributed key generation, so that
+// the secret key never exists in one place. It must be sent to the first node
+// of the roster. If Threshold is 0, it is n - (n-1)/3.
+type EnrollDKGRequest struct {
+	Type      string
+	Issuer    string
+	Roster    onet.Roster
+	Threshold int
+	Config    []byte
+}
+
+// EnrollDKGResponse holds the public key created by the distributed key
+// generation.
+type EnrollDKGResponse struct {
+	Public kyber.Point
+}
+
+// AuthorizeReshareRequest is sent by the administrator of an authentication
+// proxy to allow its share of the key of the type and issuer to be reshared
+// to the new roster and threshold. It is signed by the private key of the
+// proxy over the Hash of the request, and is only valid for a short time.
+type AuthorizeReshareRequest struct {
+	Type      string
+	Issuer    string
+	NewRoster onet.Roster
+	Threshold int
+	Timestamp int64
+	Signature []byte
+}
+
+// AuthorizeReshareResponse is returned when the resharing has been
+// authorized.
+type AuthorizeReshareResponse struct {
+}
+
+// ReshareRequest asks the authentication proxies to reshare the key of the
+// type and issuer from the old roster to a new roster and threshold, keeping
+// the same public key. It must be sent to the first node of the new roster,
+// which must hold a share of the key. All the nodes of the old roster must
+// be online, and every node holding a share must have authorized the
+// resharing. If Threshold is 0, it is n - (n-1)/3.
+type ReshareRequest struct {
+	Type      string
+	Issuer    string
+	OldRoster onet.Roster
+	NewRoster onet.Roster
+	Threshold int
+}
+
+// ReshareResponse holds the public key, which is the same as before the
+// resharing.
+type ReshareResponse struct {
+	Public kyber.Point
+}
+
 // SignatureRequest is the request sent to this service to request that
 // the Authentication Proxy check the authentication information and
 // generate a signature connecting some information identifying the
@@ -70,9 +134,11 @@ type EnrollmentsResponse struct {
 	Enrollments []EnrollmentInfo
 }
 
-// EnrollmentInfo is public info about an enrollment.
+// EnrollmentInfo is public info about an enrollment. Threshold is the
+// number of partial signatures needed to create a signature.
 type EnrollmentInfo struct {
-	Type   string
-	Issuer string
-	Public kyber.Point
+	Type      string
+	Issuer    string
+	Public    kyber.Point
+	Threshold int
 }
@@ -0,0 +1,293 @@
+package authprox
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// samlLeeway is the clock skew accepted when checking the conditions of an
+// assertion.
+const samlLeeway = time.Minute
+
+// samlBearer is the method of the subject confirmation of the Web Browser SSO
+// profile.
+const samlBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+// SAMLConfig is the config of the saml validator, sent as JSON.
+type SAMLConfig struct {
+	// Certificates are the PEM encoded signing certificates of the IdP.
+	Certificates string `json:"certificates"`
+	// EntityID is the entity ID of the service provider, which must be
+	// the audience of the assertions.
+	EntityID string `json:"entity_id"`
+	// ACS is the URL of the assertion consumer service of the service
+	// provider, which must be the recipient of the assertions.
+	ACS string `json:"acs"`
+}
+
+// samlValidator checks SAML 2.0 assertions signed by an identity provider.
+// The issuer is the entity ID of the identity provider, and the config is a
+// SAMLConfig. The auth info is the SAMLResponse, either as XML or base64
+// encoded like in the HTTP-POST binding. Either the response or the assertion
+// must be signed, and the assertion must be issued for the service provider
+// of the config. The claim is the NameID of the subject of the assertion.
+//
+// The proxies don't see the authentication requests of the service provider,
+// so InResponseTo is not checked: an assertion for the service provider can
+// be used until it expires.
+type samlValidator struct{}
+
+func (s *samlValidator) CheckConfig(issuer string, config []byte) error {
+	if issuer == "" {
+		return errors.New("the issuer must be the entity ID of the IdP")
+	}
+	_, _, err := parseSAMLConfig(config)
+	return err
+}
+
+func (s *samlValidator) FindClaim(issuer string, config []byte, input []byte) (string, string, error) {
+	conf, certs, err := parseSAMLConfig(config)
+	if err != nil {
+		return "", "", err
+	}
+	input = bytes.TrimSpace(input)
+	if len(input) > 0 && input[0] != '<' {
+		input, err = base64.StdEncoding.DecodeString(string(input))
+		if err != nil {
+			return "", "", fmt.Errorf("could not decode the response: %v", err)
+		}
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(input); err != nil {
+		return "", "", fmt.Errorf("could not parse the response: %v", err)
+	}
+	root := doc.Root()
+	if root == nil {
+		return "", "", errors.New("empty response")
+	}
+
+	// Only the elements returned by Validate are covered by the signature,
+	// so the assertion must be taken from them.
+	ctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: certs,
+	})
+	var assertion *etree.Element
+	switch root.Tag {
+	case "Response":
+		if resp, err := ctx.Validate(root); err == nil {
+			if err := checkSAMLIssuer(resp, issuer, false); err != nil {
+				return "", "", err
+			}
+			assertion, err = singleChild(resp, "Assertion")
+			if err != nil {
+				return "", "", err
+			}
+		} else {
+			a, err := singleChild(root, "Assertion")
+			if err != nil {
+				return "", "", err
+			}
+			assertion, err = ctx.Validate(a)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid signature: %v", err)
+			}
+		}
+		if dest := root.SelectAttrValue("Destination", ""); dest != "" &&
+			dest != conf.ACS {
+			return "", "", fmt.Errorf("wrong destination %v", dest)
+		}
+		status := root.FindElement("./Status/StatusCode")
+		if status == nil || status.SelectAttrValue("Value", "") !=
+			"urn:oasis:names:tc:SAML:2.0:status:Success" {
+			return "", "", errors.New("the response is not a success")
+		}
+	case "Assertion":
+		assertion, err = ctx.Validate(root)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid signature: %v", err)
+		}
+	default:
+		return "", "", errors.New("neither a response nor an assertion")
+	}
+
+	if err := checkSAMLIssuer(assertion, issuer, true); err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	if err := checkSAMLConditions(assertion, conf.EntityID, now); err != nil {
+		return "", "", err
+	}
+	if err := checkSAMLSubject(assertion, conf.ACS, now); err != nil {
+		return "", "", err
+	}
+	nameID := assertion.FindElement("./Subject/NameID")
+	if nameID == nil || nameID.Text() == "" {
+		return "", "", errors.New("the assertion has no subject")
+	}
+	return nameID.Text(), "", nil
+}
+
+// checkSAMLIssuer makes sure the element is issued by the enrolled issuer.
+// The issuer is optional in a response, but mandatory in an assertion.
+func checkSAMLIssuer(el *etree.Element, issuer string, mandatory bool) error {
+	is := el.FindElement("./Issuer")
+	if is == nil {
+		if mandatory {
+			return errors.New("missing issuer")
+		}
+		return nil
+	}
+	if is.Text() != issuer {
+		return fmt.Errorf("wrong issuer %v", is.Text())
+	}
+	return nil
+}
+
+// checkSAMLConditions makes sure the assertion is valid at the given time,
+// for the audience. Assertions without an end of validity are refused, as
+// they could be replayed forever, and so are assertions without an audience,
+// as they could have been issued for any service provider.
+func checkSAMLConditions(assertion *etree.Element, audience string, now time.Time) error {
+	cond := assertion.FindElement("./Conditions")
+	if cond == nil {
+		return errors.New("the assertion has no conditions")
+	}
+	if nb := cond.SelectAttrValue("NotBefore", ""); nb != "" {
+		t, err := time.Parse(time.RFC3339, nb)
+		if err != nil {
+			return fmt.Errorf("invalid NotBefore: %v", err)
+		}
+		if now.Add(samlLeeway).Before(t) {
+			return errors.New("the assertion is not yet valid")
+		}
+	}
+	if err := checkSAMLExpiry(cond, now); err != nil {
+		return err
+	}
+
+	// Every restriction must be met, and one of the audiences of a
+	// restriction is enough to meet it.
+	restrictions := cond.FindElements("./AudienceRestriction")
+	if len(restrictions) == 0 {
+		return errors.New("the assertion has no audience")
+	}
+	for _, r := range restrictions {
+		found := false
+		for _, a := range r.FindElements("./Audience") {
+			if a.Text() == audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("the assertion is for another audience")
+		}
+	}
+	return nil
+}
+
+// checkSAMLSubject makes sure the subject of the assertion can be confirmed
+// as a bearer at the given time, by the assertion consumer service.
+func checkSAMLSubject(assertion *etree.Element, acs string, now time.Time) error {
+	err := errors.New("the subject has no bearer confirmation")
+	for _, sc := range assertion.FindElements("./Subject/SubjectConfirmation") {
+		if sc.SelectAttrValue("Method", "") != samlBearer {
+			continue
+		}
+		data := sc.FindElement("./SubjectConfirmationData")
+		if data == nil {
+			err = errors.New("the bearer confirmation has no data")
+			continue
+		}
+		if r := data.SelectAttrValue("Recipient", ""); r != acs {
+			err = fmt.Errorf("wrong recipient %v", r)
+			continue
+		}
+		if err = checkSAMLExpiry(data, now); err != nil {
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+// checkSAMLExpiry makes sure the mandatory NotOnOrAfter of the element is not
+// passed.
+func checkSAMLExpiry(el *etree.Element, now time.Time) error {
+	na := el.SelectAttrValue("NotOnOrAfter", "")
+	if na == "" {
+		return fmt.Errorf("%v has no NotOnOrAfter", el.Tag)
+	}
+	t, err := time.Parse(time.RFC3339, na)
+	if err != nil {
+		return fmt.Errorf("invalid NotOnOrAfter: %v", err)
+	}
+	if !now.Add(-samlLeeway).Before(t) {
+		return fmt.Errorf("%v expired", el.Tag)
+	}
+	return nil
+}
+
+func singleChild(el *etree.Element, tag string) (*etree.Element, error) {
+	var found []*etree.Element
+	for _, c := range el.ChildElements() {
+		if c.Tag == tag {
+			found = append(found, c)
+		}
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("need exactly one %v, got %v", tag, len(found))
+	}
+	return found[0], nil
+}
+
+// parseSAMLConfig decodes the config, and the certificates it holds.
+func parseSAMLConfig(config []byte) (*SAMLConfig, []*x509.Certificate, error) {
+	var conf SAMLConfig
+	if err := json.Unmarshal(config, &conf); err != nil {
+		return nil, nil, fmt.Errorf("could not decode the config: %v", err)
+	}
+	if conf.EntityID == "" {
+		return nil, nil, errors.New("the entity ID of the service provider is missing")
+	}
+	if conf.ACS == "" {
+		return nil, nil, errors.New("the URL of the assertion consumer service is missing")
+	}
+	certs, err := parseCertificates([]byte(conf.Certificates))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &conf, certs, nil
+}
+
+func parseCertificates(config []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, config = pem.Decode(config)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,179 @@
+package authprox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	samlSP  = "https://sp.example.com"
+	samlACS = "https://sp.example.com/acs"
+)
+
+// samlIdP is a stand-in for a SAML identity provider.
+type samlIdP struct {
+	entityID string
+	ks       dsig.X509KeyStore
+}
+
+func (idp *samlIdP) config(t *testing.T) []byte {
+	buf, err := json.Marshal(&SAMLConfig{Certificates: string(idp.certPEM(t)),
+		EntityID: samlSP, ACS: samlACS})
+	require.NoError(t, err)
+	return buf
+}
+
+func (idp *samlIdP) certPEM(t *testing.T) []byte {
+	_, cert, err := idp.ks.GetKeyPair()
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+}
+
+func (idp *samlIdP) assertion(issuer, nameID string, notOnOrAfter time.Time) *etree.Element {
+	return idp.assertionFor(samlSP, samlACS, issuer, nameID, notOnOrAfter)
+}
+
+// assertionFor returns an assertion for the audience, to be consumed by the
+// recipient.
+func (idp *samlIdP) assertionFor(audience, recipient, issuer, nameID string,
+	notOnOrAfter time.Time) *etree.Element {
+	a := etree.NewElement("saml:Assertion")
+	a.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	a.CreateAttr("ID", "_assertion")
+	a.CreateAttr("Version", "2.0")
+	a.CreateElement("saml:Issuer").SetText(issuer)
+	subject := a.CreateElement("saml:Subject")
+	subject.CreateElement("saml:NameID").SetText(nameID)
+	sc := subject.CreateElement("saml:SubjectConfirmation")
+	sc.CreateAttr("Method", samlBearer)
+	data := sc.CreateElement("saml:SubjectConfirmationData")
+	data.CreateAttr("Recipient", recipient)
+	data.CreateAttr("NotOnOrAfter", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	cond := a.CreateElement("saml:Conditions")
+	cond.CreateAttr("NotBefore", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+	if !notOnOrAfter.IsZero() {
+		cond.CreateAttr("NotOnOrAfter", notOnOrAfter.UTC().Format(time.RFC3339))
+	}
+	cond.CreateElement("saml:AudienceRestriction").CreateElement(
+		"saml:Audience").SetText(audience)
+	return a
+}
+
+func (idp *samlIdP) sign(t *testing.T, el *etree.Element) *etree.Element {
+	signed, err := dsig.NewDefaultSigningContext(idp.ks).SignEnveloped(el)
+	require.NoError(t, err)
+	return signed
+}
+
+func (idp *samlIdP) response(assertion *etree.Element) *etree.Element {
+	r := etree.NewElement("samlp:Response")
+	r.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	r.CreateAttr("ID", "_response")
+	r.CreateAttr("Version", "2.0")
+	r.CreateAttr("Destination", samlACS)
+	r.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr(
+		"Value", "urn:oasis:names:tc:SAML:2.0:status:Success")
+	r.AddChild(assertion)
+	return r
+}
+
+func toBytes(t *testing.T, el *etree.Element) []byte {
+	doc := etree.NewDocument()
+	doc.SetRoot(el)
+	buf, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	return buf
+}
+
+func TestSAMLValidator(t *testing.T) {
+	idp := &samlIdP{entityID: "https://idp.example.com",
+		ks: dsig.RandomKeyStoreForTest()}
+	cert := idp.config(t)
+	valid := time.Now().Add(time.Hour)
+
+	v := &samlValidator{}
+	require.NoError(t, v.CheckConfig(idp.entityID, cert))
+	require.Error(t, v.CheckConfig(idp.entityID, idp.certPEM(t)))
+	for _, conf := range []SAMLConfig{
+		{Certificates: "no cert", EntityID: samlSP, ACS: samlACS},
+		{Certificates: string(idp.certPEM(t)), ACS: samlACS},
+		{Certificates: string(idp.certPEM(t)), EntityID: samlSP},
+	} {
+		buf, err := json.Marshal(&conf)
+		require.NoError(t, err)
+		require.Error(t, v.CheckConfig(idp.entityID, buf))
+	}
+
+	find := func(in []byte) (string, error) {
+		claim, hash, err := v.FindClaim(idp.entityID, cert, in)
+		require.Equal(t, "", hash)
+		return claim, err
+	}
+
+	// A signed assertion, alone, in a response, and base64 encoded.
+	signed := idp.sign(t, idp.assertion(idp.entityID, "alice@example.com", valid))
+	claim, err := find(toBytes(t, signed))
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", claim)
+	resp := toBytes(t, idp.response(signed.Copy()))
+	claim, err = find(resp)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", claim)
+	claim, err = find([]byte(base64.StdEncoding.EncodeToString(resp)))
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", claim)
+
+	// A signed response with an unsigned assertion.
+	claim, err = find(toBytes(t, idp.sign(t, idp.response(
+		idp.assertion(idp.entityID, "bob@example.com", valid)))))
+	require.NoError(t, err)
+	require.Equal(t, "bob@example.com", claim)
+
+	// Unsigned, tampered, wrong issuer, expired and eternal assertions.
+	_, err = find(toBytes(t, idp.response(
+		idp.assertion(idp.entityID, "alice@example.com", valid))))
+	require.Error(t, err)
+	tampered := signed.Copy()
+	tampered.FindElement("./Subject/NameID").SetText("eve@example.com")
+	_, err = find(toBytes(t, tampered))
+	require.Error(t, err)
+	_, err = find(toBytes(t, idp.sign(t, idp.assertion("https://other.example.com",
+		"alice@example.com", valid))))
+	require.Error(t, err)
+	_, err = find(toBytes(t, idp.sign(t, idp.assertion(idp.entityID,
+		"alice@example.com", time.Now().Add(-time.Hour)))))
+	require.Error(t, err)
+	_, err = find(toBytes(t, idp.sign(t, idp.assertion(idp.entityID,
+		"alice@example.com", time.Time{}))))
+	require.Error(t, err)
+
+	// For another service provider, or to be consumed somewhere else.
+	_, err = find(toBytes(t, idp.sign(t, idp.assertionFor("https://other.example.com",
+		samlACS, idp.entityID, "alice@example.com", valid))))
+	require.Error(t, err)
+	_, err = find(toBytes(t, idp.sign(t, idp.assertionFor(samlSP,
+		"https://other.example.com/acs", idp.entityID, "alice@example.com", valid))))
+	require.Error(t, err)
+	noAudience := idp.assertion(idp.entityID, "alice@example.com", valid)
+	noAudience.FindElement("./Conditions").RemoveChild(
+		noAudience.FindElement("./Conditions/AudienceRestriction"))
+	_, err = find(toBytes(t, idp.sign(t, noAudience)))
+	require.Error(t, err)
+	elsewhere := idp.response(signed.Copy())
+	elsewhere.SelectAttr("Destination").Value = "https://other.example.com/acs"
+	_, err = find(toBytes(t, elsewhere))
+	require.Error(t, err)
+
+	// Signed by another IdP.
+	other := &samlIdP{entityID: idp.entityID, ks: dsig.RandomKeyStoreForTest()}
+	_, err = find(toBytes(t, other.sign(t, other.assertion(idp.entityID,
+		"alice@example.com", valid))))
+	require.Error(t, err)
+}
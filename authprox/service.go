@@ -8,8 +8,11 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
 
 	"go.dedis.ch/cothority/v3"
+	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
+	"go.dedis.ch/cothority/v3/keystore"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/sign/dss"
@@ -32,6 +35,11 @@ type service struct {
 	validators map[string]Validator
 	db         *bbolt.DB
 	bucket     []byte
+
+	// reshares holds the resharings authorized by the administrator,
+	// indexed by the encoded type and issuer.
+	reshares     map[string]reshareAuth
+	reshareMutex sync.Mutex
 }
 
 func init() {
@@ -40,8 +48,15 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err, "could not register")
 	}
+	_, err = onet.GlobalProtocolRegister(dkgProto, dkgprotocol.NewSetup)
+	log.ErrFatal(err)
+	_, err = onet.GlobalProtocolRegister(reshareProto, dkgprotocol.NewSetup)
+	log.ErrFatal(err)
 	network.RegisterMessages(
 		&EnrollRequest{}, &EnrollResponse{},
+		&EnrollDKGRequest{}, &EnrollDKGResponse{},
+		&AuthorizeReshareRequest{}, &AuthorizeReshareResponse{},
+		&ReshareRequest{}, &ReshareResponse{},
 		&SignatureRequest{}, &SignatureResponse{},
 		&EnrollmentsRequest{}, &EnrollmentsResponse{}, &EnrollmentInfo{},
 		&ti{}, &dssConfig{},
@@ -50,9 +65,16 @@ func init() {
 
 // A Validator is able to check the provided authInfo with respect to
 // the thrid-party authentication system. Extracts the user-id and the
-// (optional) hash of the message from the auth info.
+// (optional) hash of the message from the auth info. The config is the one
+// given when the issuer was enrolled.
 type Validator interface {
-	FindClaim(issuer string, authInfo []byte) (claim string, hash string, err error)
+	FindClaim(issuer string, config []byte, authInfo []byte) (claim string, hash string, err error)
+}
+
+// A ConfigChecker is a Validator that checks the configuration of an issuer
+// before it is enrolled.
+type ConfigChecker interface {
+	CheckConfig(issuer string, config []byte) error
 }
 
 func (s *service) registerValidator(t string, v Validator) {
@@ -79,6 +101,17 @@ type dssConfig struct {
 	Participants []kyber.Point
 	LongPri      share.PriShare
 	LongPubs     []kyber.Point
+	Config       []byte
+	// Threshold is 0 for enrollments done before it could be chosen.
+	Threshold int
+}
+
+// threshold returns the number of partial signatures needed.
+func (c *dssConfig) threshold() int {
+	if c.Threshold == 0 {
+		return threshold(len(c.Participants))
+	}
+	return c.Threshold
 }
 
 func (s *service) find(typ, issuer string) (*dssConfig, error) {
@@ -104,15 +137,109 @@ func (s *service) find(typ, issuer string) (*dssConfig, error) {
 		return nil, err
 	}
 
+	return decodeConfig(buf)
+}
+
+// encodeConfig encodes the config, and seals it if the keystore of the
+// conode is unlocked, as it holds the share of the private key.
+func encodeConfig(cfg *dssConfig) ([]byte, error) {
+	buf, err := protobuf.Encode(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return keystore.Seal(buf)
+}
+
+// decodeConfig decodes a config stored by encodeConfig.
+func decodeConfig(buf []byte) (*dssConfig, error) {
+	buf, err := keystore.Open(buf)
+	if err != nil {
+		return nil, err
+	}
 	var out dssConfig
 	err = protobuf.DecodeWithConstructors(buf, &out, network.DefaultConstructors(cothority.Suite))
 	if err != nil {
 		return nil, err
 	}
-
 	return &out, nil
 }
 
+// sealConfigs seals the configs that have been stored before the keystore of
+// the conode has been unlocked. The plaintext configs stay in the free pages
+// of the database until it is compacted.
+func (s *service) sealConfigs() error {
+	if !keystore.Unlocked() {
+		return nil
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return errors.New("nil bucket")
+		}
+		sealed := map[string][]byte{}
+		err := b.ForEach(func(k, v []byte) error {
+			if keystore.IsSealed(v) {
+				return nil
+			}
+			v2, err := keystore.Seal(v)
+			sealed[string(k)] = v2
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range sealed {
+			if err := b.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		if len(sealed) > 0 {
+			keystore.SealedInPlace()
+		}
+		return nil
+	})
+}
+
+// store saves the config of the type and issuer. If replace is false, it
+// refuses to overwrite an existing enrollment.
+func (s *service) store(typ, issuer string, cfg *dssConfig, replace bool) error {
+	k, err := protobuf.Encode(&ti{T: typ, I: issuer})
+	if err != nil {
+		return err
+	}
+	v, err := encodeConfig(cfg)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		// Need to do the find inside of the Update tx, or else it is racy
+		// with respect to other writers.
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return errors.New("nil bucket")
+		}
+		if ret := b.Get(k); ret != nil && !replace {
+			return fmt.Errorf("enrollment already exists for type:issuer %v:%v", typ, issuer)
+		}
+		return b.Put(k, v)
+	})
+}
+
+// remove deletes the enrollment of the type and issuer.
+func (s *service) remove(typ, issuer string) error {
+	k, err := protobuf.Encode(&ti{T: typ, I: issuer})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return errors.New("nil bucket")
+		}
+		return b.Delete(k)
+	})
+}
+
 // Enrollments returns a list of enrollments. The result list is filtered by
 // the Types and Issuers lists in the request. Empty filter lists are treated as
 // "match all". The result is the AND or Types and Issers, and within one list,
@@ -142,8 +269,7 @@ func (s *service) Enrollments(req *EnrollmentsRequest) (*EnrollmentsResponse, er
 			}
 
 			// Decode public key
-			var out dssConfig
-			err = protobuf.DecodeWithConstructors(v2, &out, network.DefaultConstructors(cothority.Suite))
+			out, err := decodeConfig(v2)
 			if err != nil {
 				return err
 			}
@@ -177,9 +303,10 @@ func (s *service) Enrollments(req *EnrollmentsRequest) (*EnrollmentsResponse, er
 			}
 
 			resp.Enrollments = append(resp.Enrollments, EnrollmentInfo{
-				Type:   ti0.T,
-				Issuer: ti0.I,
-				Public: out.LongPubs[0],
+				Type:      ti0.T,
+				Issuer:    ti0.I,
+				Public:    out.LongPubs[0],
+				Threshold: out.threshold(),
 			})
 		}
 		return nil
@@ -198,37 +325,20 @@ func (s *service) Enroll(req *EnrollRequest) (*EnrollResponse, error) {
 	// as admins, require AuthInfo in EnrollmentRequest, and if the validated
 	// claim is in the admin list, allow.
 
-	// Prepare the stuff to be written
-	k, err := protobuf.Encode(&ti{T: req.Type, I: req.Issuer})
-	if err != nil {
-		return nil, err
-	}
-	lpri := share.PriShare{
-		I: req.LongPri.I,
-		V: req.LongPri.V,
-	}
-	v, err := protobuf.Encode(&dssConfig{
-		Participants: req.Participants,
-		LongPri:      lpri,
-		LongPubs:     req.LongPubs,
-	})
-	if err != nil {
+	if err := s.checkConfig(req.Type, req.Issuer, req.Config); err != nil {
 		return nil, err
 	}
 
 	// Write the type/claim -> dssConfg into the database.
-	err = s.db.Update(func(tx *bbolt.Tx) error {
-		// Need to do the find inside of the Update tx, or else it is racy
-		// with respect to other writers.
-		b := tx.Bucket(s.bucket)
-		if b == nil {
-			return errors.New("nil bucket")
-		}
-		if ret := b.Get(k); ret != nil {
-			return fmt.Errorf("enrollment already exists for type:issuer %v:%v", req.Type, req.Issuer)
-		}
-		return b.Put(k, v)
-	})
+	err := s.store(req.Type, req.Issuer, &dssConfig{
+		Participants: req.Participants,
+		LongPri: share.PriShare{
+			I: req.LongPri.I,
+			V: req.LongPri.V,
+		},
+		LongPubs: req.LongPubs,
+		Config:   req.Config,
+	}, false)
 	if err != nil {
 		return nil, err
 	}
@@ -236,6 +346,18 @@ func (s *service) Enroll(req *EnrollRequest) (*EnrollResponse, error) {
 	return &EnrollResponse{}, nil
 }
 
+// checkConfig refuses configurations the validator will not be able to use.
+func (s *service) checkConfig(typ, issuer string, config []byte) error {
+	if v, ok := s.validators[typ]; ok {
+		if cc, ok := v.(ConfigChecker); ok {
+			if err := cc.CheckConfig(issuer, config); err != nil {
+				return fmt.Errorf("invalid config for %v: %v", typ, err)
+			}
+		}
+	}
+	return nil
+}
+
 // Signature will verify the authentication information in
 // the request, according to the rules specific to that authentication type.
 // If the information is valid, it will then generate a partial signature
@@ -254,8 +376,14 @@ func (s *service) Signature(req *SignatureRequest) (*SignatureResponse, error) {
 		return nil, err
 	}
 
+	// Find the config that is associated with the issuer.
+	dsscfg, err := s.find(req.Type, req.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key: %v", err)
+	}
+
 	// Use the validator to extract a claim from the auth info.
-	claim, hashStr, err := validator.FindClaim(req.Issuer, req.AuthInfo)
+	claim, hashStr, err := validator.FindClaim(req.Issuer, dsscfg.Config, req.AuthInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -290,12 +418,6 @@ func (s *service) Signature(req *SignatureRequest) (*SignatureResponse, error) {
 	h.Write(req.Message)
 	msg2 := h.Sum(nil)
 
-	// Find the config that is associated with the issuer.
-	dsscfg, err := s.find(req.Type, req.Issuer)
-	if err != nil {
-		return nil, fmt.Errorf("cannot find key: %v", err)
-	}
-
 	// Make the signature.
 	suite := suites.MustFind("ed25519")
 	rpi := share.PriShare{
@@ -310,7 +432,7 @@ func (s *service) Signature(req *SignatureRequest) (*SignatureResponse, error) {
 		dsscfg.Participants,
 		&dks{dsscfg.LongPri, dsscfg.LongPubs},
 		&dks{rpi, req.RandPubs},
-		msg2, threshold(len(dsscfg.Participants)))
+		msg2, dsscfg.threshold())
 	if err != nil {
 		return nil, err
 	}
@@ -338,18 +460,28 @@ func newService(c *onet.Context) (onet.Service, error) {
 		validators:       make(map[string]Validator),
 		db:               db,
 		bucket:           bucket,
+		reshares:         make(map[string]reshareAuth),
 	}
 	if err := s.RegisterHandlers(
 		s.Enroll,
 		s.Signature,
 		s.Enrollments,
+		s.EnrollDKG,
+		s.AuthorizeReshare,
+		s.Reshare,
 	); err != nil {
 		log.ErrFatal(err, "Could not register handlers.")
 	}
 
 	// Register validators here
 	s.registerValidator("oidc", &oidcValidator{})
+	s.registerValidator("saml", &samlValidator{})
+	s.registerValidator("ldap", &ldapValidator{})
+	s.registerValidator("jwt", &jwtValidator{})
 
+	if err := s.sealConfigs(); err != nil {
+		return nil, fmt.Errorf("sealing enrollments: %v", err)
+	}
 	return s, nil
 }
 
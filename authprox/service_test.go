@@ -6,10 +6,12 @@ import (
 	"encoding/hex"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/keystore"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/kyber/v3/sign/dss"
@@ -17,6 +19,9 @@ import (
 	"go.dedis.ch/kyber/v3/suites"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	bbolt "go.etcd.io/bbolt"
+	"gopkg.in/square/go-jose.v2/jwt"
 )
 
 func TestMain(m *testing.M) {
@@ -28,7 +33,7 @@ type valid struct {
 	err error
 }
 
-func (e *valid) FindClaim(issuer string, ai []byte) (string, string, error) {
+func (e *valid) FindClaim(issuer string, config []byte, ai []byte) (string, string, error) {
 	// For the hash, return the ai itself, unparsed. This allows max flexability
 	// for testing different cases.
 	return "dummy-claim", string(ai), e.err
@@ -215,6 +220,115 @@ func Test_EnrollAndSign(t *testing.T) {
 	require.Equal(t, 1, len(resp.Enrollments))
 }
 
+// Test_EnrollConfig enrolls a JWT issuer with its key, and makes sure the
+// key is used to check the tokens.
+func Test_EnrollConfig(t *testing.T) {
+	suite := suites.MustFind("ed25519")
+	e := newEnv(t)
+	defer e.local.CloseAll()
+	s := e.services[0]
+
+	pubs := make([]kyber.Point, len(e.services))
+	for i := range pubs {
+		pubs[i] = e.roster.List[i].Public
+	}
+	lPri := share.NewPriPoly(suite, 1, nil, cothority.Suite.RandomStream())
+	_, lPubCommits := lPri.Commit(nil).Info()
+	rPri := share.NewPriPoly(suite, 1, nil, cothority.Suite.RandomStream())
+	_, rPubCommits := rPri.Commit(nil).Info()
+
+	is := newJWTIssuer(t)
+	iss := "https://tokens.example.com"
+	req := &EnrollRequest{
+		Type:         "jwt",
+		Issuer:       iss,
+		Participants: pubs,
+		LongPri:      PriShare{I: 0, V: lPri.Shares(1)[0].V},
+		LongPubs:     lPubCommits,
+		Config:       []byte("not a key"),
+	}
+	_, err := s.Enroll(req)
+	require.Error(t, err)
+	req.Config = is.publicPEM(t)
+	_, err = s.Enroll(req)
+	require.NoError(t, err)
+
+	sig := &SignatureRequest{
+		Type:     "jwt",
+		Issuer:   iss,
+		Message:  zero64[:],
+		RandPri:  PriShare{I: 0, V: rPri.Shares(1)[0].V},
+		RandPubs: rPubCommits,
+	}
+	exp := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	sig.AuthInfo = is.token(t, jwt.Claims{Issuer: iss, Subject: "alice",
+		Expiry: exp}, nil)
+	_, err = s.Signature(sig)
+	require.NoError(t, err)
+
+	sig.AuthInfo = newJWTIssuer(t).token(t, jwt.Claims{Issuer: iss,
+		Subject: "alice", Expiry: exp}, nil)
+	_, err = s.Signature(sig)
+	require.Error(t, err)
+}
+
+func Test_EnrollSealed(t *testing.T) {
+	suite := suites.MustFind("ed25519")
+	e := newEnv(t)
+	defer e.local.CloseAll()
+	defer keystore.Lock()
+	s := e.services[0]
+
+	pubs := make([]kyber.Point, len(e.services))
+	for i := range pubs {
+		pubs[i] = e.roster.List[i].Public
+	}
+	lPri := share.NewPriPoly(suite, 1, nil, cothority.Suite.RandomStream())
+	_, lPubCommits := lPri.Commit(nil).Info()
+	enroll := func(iss string) {
+		_, err := s.Enroll(&EnrollRequest{
+			Type:         "jwt",
+			Issuer:       iss,
+			Participants: pubs,
+			LongPri:      PriShare{I: 0, V: lPri.Shares(1)[0].V},
+			LongPubs:     lPubCommits,
+			Config:       newJWTIssuer(t).publicPEM(t),
+		})
+		require.NoError(t, err)
+	}
+	sealed := func(iss string) bool {
+		k, err := protobuf.Encode(&ti{T: "jwt", I: iss})
+		require.NoError(t, err)
+		var v []byte
+		require.NoError(t, s.db.View(func(tx *bbolt.Tx) error {
+			v = append(v, tx.Bucket(s.bucket).Get(k)...)
+			return nil
+		}))
+		return keystore.IsSealed(v)
+	}
+
+	// Enrollments done before the keystore is unlocked are sealed once the
+	// service starts with the passphrase.
+	enroll("https://old.example.com")
+	require.False(t, sealed("https://old.example.com"))
+	keystore.Unlock([]byte("passphrase"))
+	require.NoError(t, s.sealConfigs())
+	require.True(t, sealed("https://old.example.com"))
+	enroll("https://new.example.com")
+	require.True(t, sealed("https://new.example.com"))
+
+	cfg, err := s.find("jwt", "https://old.example.com")
+	require.NoError(t, err)
+	require.True(t, cfg.LongPri.V.Equal(lPri.Shares(1)[0].V))
+	resp, err := s.Enrollments(&EnrollmentsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(resp.Enrollments))
+
+	keystore.Lock()
+	_, err = s.find("jwt", "https://old.example.com")
+	require.Error(t, err)
+}
+
 func TestService_SignatureErrors(t *testing.T) {
 	e := newEnv(t)
 	defer e.local.CloseAll()
@@ -0,0 +1,78 @@
+package beacon
+
+import (
+	"bytes"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3"
+	"golang.org/x/xerrors"
+)
+
+// Client is a structure to communicate with the beacon service.
+type Client struct {
+	*onet.Client
+}
+
+// NewClient instantiates a new beacon.Client.
+func NewClient() *Client {
+	return &Client{Client: onet.NewClient(cothority.Suite, ServiceName)}
+}
+
+// Setup asks the first node of the roster to create a new beacon with all
+// nodes of the roster. If threshold is 0, 2/3 of the nodes need to sign
+// every round. The request must come from localhost of the first node.
+func (c *Client) Setup(roster *onet.Roster, threshold int,
+	interval time.Duration) (*SetupReply, error) {
+	reply := &SetupReply{}
+	err := c.SendProtobuf(roster.List[0], &Setup{
+		Roster:    *roster,
+		Threshold: threshold,
+		Interval:  interval,
+	}, reply)
+	if err != nil {
+		return nil, xerrors.Errorf("sending setup: %v", err)
+	}
+	return reply, nil
+}
+
+// GetConfig returns the configuration of the beacon, which is stored in the
+// genesis block of the beacon chain.
+func (c *Client) GetConfig(roster *onet.Roster, id skipchain.SkipBlockID) (*Config, error) {
+	genesis, err := skipchain.NewClient().GetSingleBlock(roster, id)
+	if err != nil {
+		return nil, xerrors.Errorf("getting genesis block: %v", err)
+	}
+	if !bytes.Equal(genesis.CalculateHash(), id) {
+		return nil, xerrors.New("got wrong genesis block")
+	}
+	if !isBeacon(genesis) {
+		return nil, xerrors.New("not a beacon chain")
+	}
+	return decodeConfig(genesis.Data)
+}
+
+// GetRound fetches the round with the given index from a node of the roster
+// and verifies it against the public key of the beacon. If index is 0, the
+// latest round is returned.
+func (c *Client) GetRound(roster *onet.Roster, id skipchain.SkipBlockID,
+	public kyber.Point, index uint64) (*Round, error) {
+	reply := &GetRoundReply{}
+	_, err := c.SendProtobufParallel(roster.List, &GetRound{
+		ID:    id,
+		Index: index,
+	}, reply, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("getting round: %v", err)
+	}
+	if index > 0 && reply.Round.Index != index {
+		return nil, xerrors.Errorf("got round %d instead of %d",
+			reply.Round.Index, index)
+	}
+	if err := reply.Round.Verify(public); err != nil {
+		return nil, xerrors.Errorf("invalid round: %v", err)
+	}
+	return &reply.Round, nil
+}
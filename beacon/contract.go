@@ -0,0 +1,166 @@
+package beacon
+
+import (
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/beacon/protocol"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractBeaconID denotes a contract that stores the latest round of a
+// beacon.
+const ContractBeaconID = "beacon"
+
+func init() {
+	err := byzcoin.RegisterGlobalContract(ContractBeaconID, contractBeaconFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
+}
+
+// ContractBeacon stores the public key and the latest round of a beacon.
+// Anybody allowed by the darc can update it with a newer round, as every
+// round is verified against the public key of the beacon.
+//
+// Accepted instructions:
+//   - spawn:beacon creates a new instance from the "value" argument
+//   - invoke:beacon.update stores the newer round of the "round" argument
+type ContractBeacon struct {
+	byzcoin.BasicContract
+	Value
+}
+
+func contractBeaconFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractBeacon{}
+	v, err := decodeValue(in)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal beacon: %v", err)
+	}
+	c.Value = *v
+	return c, nil
+}
+
+// Spawn creates a new beacon instance. The round of the value is optional,
+// but must be valid if it is given.
+func (c ContractBeacon) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		err = xerrors.Errorf("getting values: %v", err)
+		return
+	}
+
+	buf := inst.Spawn.Args.Search("value")
+	v, err := decodeValue(buf)
+	if err != nil {
+		err = xerrors.Errorf("couldn't unmarshal value: %v", err)
+		return
+	}
+	if v.Public == nil {
+		err = xerrors.New("missing public key")
+		return
+	}
+	if v.Round.Index > 0 {
+		if err = v.Round.Verify(v.Public); err != nil {
+			err = xerrors.Errorf("invalid round: %v", err)
+			return
+		}
+	}
+
+	var id byzcoin.InstanceID
+	if rst.GetVersion() >= byzcoin.VersionPreID {
+		id, err = inst.DeriveIDArg("", "preID")
+		if err != nil {
+			err = xerrors.Errorf("couldn't get ID for instance: %v", err)
+			return
+		}
+	} else {
+		id = inst.DeriveID("")
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, id, ContractBeaconID, buf,
+			darcID),
+	}
+	return
+}
+
+// Invoke updates the latest round of the beacon. The new round must be
+// newer than the stored one and correctly signed by the beacon.
+func (c ContractBeacon) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		err = xerrors.Errorf("getting values: %v", err)
+		return
+	}
+
+	switch inst.Invoke.Command {
+	case "update":
+		var round Round
+		err = protobuf.Decode(inst.Invoke.Args.Search("round"), &round)
+		if err != nil {
+			err = xerrors.Errorf("couldn't unmarshal round: %v", err)
+			return
+		}
+		if round.Index <= c.Round.Index {
+			err = xerrors.Errorf("round %d is not newer than round %d",
+				round.Index, c.Round.Index)
+			return
+		}
+		if err = round.Verify(c.Public); err != nil {
+			err = xerrors.Errorf("invalid round: %v", err)
+			return
+		}
+		c.Round = round
+		var buf []byte
+		buf, err = protobuf.Encode(&c.Value)
+		if err != nil {
+			err = xerrors.Errorf("encoding value: %v", err)
+			return
+		}
+		sc = []byzcoin.StateChange{
+			byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
+				ContractBeaconID, buf, darcID),
+		}
+		return
+	default:
+		err = xerrors.New("beacon contract can only update")
+		return
+	}
+}
+
+// LatestRound returns the latest round stored in the beacon instance. As it
+// only reads the given state, it can be used by other contracts to get a
+// random value that is the same on all nodes.
+func LatestRound(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) (*Round, error) {
+	buf, _, cid, _, err := rst.GetValues(id.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("getting values: %v", err)
+	}
+	if cid != ContractBeaconID {
+		return nil, xerrors.New("not a beacon instance")
+	}
+	v, err := decodeValue(buf)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding value: %v", err)
+	}
+	if v.Round.Index == 0 {
+		return nil, xerrors.New("no round stored yet")
+	}
+	return &v.Round, nil
+}
+
+func decodeValue(buf []byte) (*Value, error) {
+	v := &Value{}
+	err := protobuf.DecodeWithConstructors(buf, v,
+		network.DefaultConstructors(protocol.Suite))
+	return v, cothority.ErrorOrNil(err, "decoding value")
+}
@@ -0,0 +1,68 @@
+package beacon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/beacon/protocol"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/protobuf"
+)
+
+func TestContractBeacon_Invoke(t *testing.T) {
+	rost := byzcoin.NewROSTSimul()
+	private, public := bls.NewKeyPair(protocol.Suite, protocol.Suite.RandomStream())
+	sign := func(r *Round) {
+		var err error
+		r.Signature, err = bls.Sign(protocol.Suite, private, r.Message())
+		require.NoError(t, err)
+	}
+
+	cb := ContractBeacon{Value: Value{Public: public}}
+	cbID, err := rost.CreateRandomInstance(ContractBeaconID, &cb.Value, nil)
+	require.NoError(t, err)
+	_, err = LatestRound(rost, cbID)
+	require.Error(t, err)
+
+	round1 := Round{Index: 1}
+	sign(&round1)
+	round2 := Round{Index: 2, Previous: round1.Signature}
+	sign(&round2)
+
+	update := func(c *ContractBeacon, r Round) error {
+		buf, err := protobuf.Encode(&r)
+		require.NoError(t, err)
+		scs, _, err := c.Invoke(rost, byzcoin.Instruction{
+			InstanceID: cbID,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractBeaconID,
+				Command:    "update",
+				Args:       byzcoin.Arguments{{Name: "round", Value: buf}},
+			}}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = rost.StoreAllToReplica(scs)
+		require.NoError(t, err)
+		return nil
+	}
+	load := func() *ContractBeacon {
+		buf, _, _, _, err := rost.GetValues(cbID.Slice())
+		require.NoError(t, err)
+		c, err := contractBeaconFromBytes(buf)
+		require.NoError(t, err)
+		return c.(*ContractBeacon)
+	}
+
+	wrong := round2
+	wrong.Signature = round1.Signature
+	require.Error(t, update(load(), wrong))
+	require.NoError(t, update(load(), round2))
+	require.Error(t, update(load(), round1))
+
+	latest, err := LatestRound(rost, cbID)
+	require.NoError(t, err)
+	require.Equal(t, round2.Index, latest.Index)
+	require.Equal(t, round2.Randomness(), latest.Randomness())
+}
@@ -0,0 +1,85 @@
+package beacon
+
+import (
+	"sync"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/keystore"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
+	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+const dbVersion = 1
+
+// storageKey reflects the data we're storing - we could store more
+// than one structure.
+var storageKey = []byte("storage")
+
+// storage is used to save the shares of the beacons.
+type storage struct {
+	// Shares are indexed by the distributed public key of the beacon.
+	Shares map[string]*dkg.DistKeyShare
+	// Chains are the beacons led by this node.
+	Chains []skipchain.SkipBlockID
+
+	sync.Mutex
+}
+
+// shareKey returns the index of the share in the storage.
+func shareKey(public kyber.Point) string {
+	return public.String()
+}
+
+// saves all data.
+func (s *Service) save() error {
+	s.storage.Lock()
+	defer s.storage.Unlock()
+	err := keystore.Save(s, storageKey, s.storage)
+	if err != nil {
+		log.Error("Couldn't save data:", err)
+		return xerrors.Errorf("saving data: %v", err)
+	}
+	return nil
+}
+
+// Tries to load the configuration and updates the data in the service
+// if it finds a valid config-file.
+func (s *Service) tryLoad() error {
+	s.storage = &storage{}
+	ver, err := s.LoadVersion()
+	if err != nil {
+		return xerrors.Errorf("loading configuration: %v", err)
+	}
+
+	// Make sure we don't have any unallocated maps.
+	defer func() {
+		if len(s.storage.Shares) == 0 {
+			s.storage.Shares = make(map[string]*dkg.DistKeyShare)
+		}
+	}()
+
+	// In the future, we'll make database upgrades below.
+	if ver < dbVersion {
+		// There is no version 0. Save empty storage and update version number.
+		if err = s.save(); err != nil {
+			return xerrors.Errorf("saving storage: %v", err)
+		}
+		return cothority.ErrorOrNil(s.SaveVersion(dbVersion), "saving version")
+	}
+	msg, err := keystore.Load(s, storageKey)
+	if err != nil {
+		return xerrors.Errorf("loading storage: %v", err)
+	}
+	if msg == nil {
+		return nil
+	}
+	var ok bool
+	s.storage, ok = msg.(*storage)
+	if !ok {
+		return xerrors.New("data of wrong type")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package beacon
+
+import (
+	"time"
+
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/onet/v3"
+)
+
+// PROTOSTART
+// type :skipchain.SkipBlockID:bytes
+// package beacon;
+// import "onet.proto";
+//
+// option java_package = "ch.epfl.dedis.lib.proto";
+// option java_outer_classname = "BeaconProto";
+
+// ***
+// Common structures
+// ***
+
+// Config is stored in the genesis block of a beacon chain and holds
+// everything needed to verify the rounds.
+type Config struct {
+	// Public is the distributed public key of the beacon. It is a point
+	// on the G2 curve of bn256.
+	Public kyber.Point
+	// Threshold is the number of signature shares needed to create a
+	// round.
+	Threshold int
+	// Interval is the time between two rounds.
+	Interval time.Duration
+}
+
+// Round is stored in every block of a beacon chain, except the genesis
+// block. The signature of a round is a threshold BLS signature on the
+// signature of the previous round and the index of this round.
+type Round struct {
+	// Index is the round number, starting at 1.
+	Index uint64
+	// Previous is the signature of the previous round, or empty for the
+	// first round.
+	Previous []byte
+	// Signature is the BLS signature on the message of the round.
+	Signature []byte
+}
+
+// Value is the data stored in a beacon instance on ByzCoin.
+type Value struct {
+	// Public is the distributed public key of the beacon.
+	Public kyber.Point
+	// Round is the latest round that has been stored.
+	Round Round
+}
+
+// ***
+// These are the messages used in the API-calls
+// ***
+
+// Setup asks the first node of the roster to run a distributed key
+// generation and to start a new beacon chain.
+type Setup struct {
+	Roster onet.Roster
+	// Threshold is the number of shares needed to sign a round. If it is
+	// 0, a threshold of 2/3 of the nodes is used.
+	Threshold int `protobuf:"opt"`
+	// Interval is the time between two rounds.
+	Interval time.Duration
+}
+
+// SetupReply is returned once the distributed key is created and the
+// genesis block of the beacon is stored.
+type SetupReply struct {
+	// ID is the ID of the beacon chain.
+	ID skipchain.SkipBlockID
+	// Public is the distributed public key of the beacon.
+	Public kyber.Point
+}
+
+// GetRound asks for a round of a beacon.
+type GetRound struct {
+	ID skipchain.SkipBlockID
+	// Index of the round to return. If it is 0, the latest round is
+	// returned.
+	Index uint64 `protobuf:"opt"`
+}
+
+// GetRoundReply returns the requested round.
+type GetRoundReply struct {
+	Round Round
+}
@@ -0,0 +1,6 @@
+// Package protocol contains the threshold signing protocol of the beacon. The
+// distributed key itself is created using the dkg/pedersen protocol.
+//
+// Please see the README for more details -
+// https://github.com/dedis/cothority/blob/master/beacon/README.md.
+package protocol
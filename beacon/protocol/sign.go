@@ -0,0 +1,205 @@
+package protocol
+
+/*
+The sign-protocol collects threshold BLS signature shares from the nodes of
+a beacon and recovers the full signature, which is the randomness of a round.
+*/
+
+import (
+	"sync"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/tbls"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+// Suite is the pairing suite used for the distributed key and the
+// signatures of the beacon.
+var Suite = pairing.NewSuiteBn256()
+
+func init() {
+	onet.GlobalProtocolRegister(NameSign, NewSign)
+}
+
+// Sign collects the signature shares of the children and recovers the full
+// signature. Before calling `Start`, Public, Message, Poly and Share must be
+// initialized by the caller.
+type Sign struct {
+	*onet.TreeNodeInstance
+	Public    kyber.Point    // Public is the distributed public key
+	Poly      *share.PubPoly // Poly is used to verify the signature shares
+	Message   []byte         // Message is the message to sign
+	Threshold int            // How many shares are needed to recover the signature
+	Timeout   time.Duration  // How long the root waits for the shares
+	// VerificationData is given to the VerifyRequest and has to hold
+	// everything needed to verify the request is valid.
+	VerificationData []byte
+	// Share returns the private share of this node. It must be set by the
+	// service on all nodes.
+	Share GetShare
+	// Verify can be set by the service to decide whether or not to sign
+	// the message.
+	Verify VerifyRequest
+	// Finished receives a 'true'-value when the protocol finished
+	// successfully, or 'false' if not enough shares have been collected.
+	Finished chan bool
+	// Signature is the recovered BLS signature.
+	Signature []byte
+	// private fields
+	shares   [][]byte
+	failures int
+	timeout  *time.Timer
+	doneOnce sync.Once
+}
+
+// NewSign initialises the structure for use in one round.
+func NewSign(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	o := &Sign{
+		TreeNodeInstance: n,
+		Finished:         make(chan bool, 1),
+		Threshold:        len(n.Roster().List) - (len(n.Roster().List)-1)/3,
+		Timeout:          time.Minute,
+	}
+
+	err := o.RegisterHandlers(o.signRequest, o.signReply)
+	if err != nil {
+		return nil, xerrors.Errorf("registering handlers: %v", err)
+	}
+	return o, nil
+}
+
+// Start signs the message and asks all children for their share.
+func (o *Sign) Start() error {
+	log.Lvl3("Starting Protocol")
+	if o.Public == nil || o.Poly == nil || o.Share == nil {
+		o.finish(false)
+		return xerrors.New("please initialize Public, Poly and Share first")
+	}
+	sr := &SignRequest{
+		Public:           o.Public,
+		Message:          o.Message,
+		VerificationData: o.VerificationData,
+	}
+	if o.Verify != nil && !o.Verify(sr) {
+		o.finish(false)
+		return xerrors.New("refused to sign")
+	}
+	sig, err := o.sign(sr)
+	if err != nil {
+		o.finish(false)
+		return xerrors.Errorf("signing: %v", err)
+	}
+	o.shares = append(o.shares, sig)
+	if len(o.shares) >= o.Threshold {
+		return cothority.ErrorOrNil(o.recover(), "recovering signature")
+	}
+
+	o.timeout = time.AfterFunc(o.Timeout, func() {
+		log.Lvl1("beacon sign protocol timeout")
+		o.finish(false)
+	})
+	errs := o.Broadcast(sr)
+	if len(errs) > len(o.Roster().List)-o.Threshold {
+		log.Errorf("Some nodes failed with error(s) %v", errs)
+		o.finish(false)
+		return xerrors.New("too many nodes failed in broadcast")
+	}
+	return nil
+}
+
+// signRequest is received by every child to give its signature share.
+func (o *Sign) signRequest(r structSignRequest) error {
+	log.Lvl3(o.Name() + ": starting sign")
+	defer o.Done()
+
+	if o.Verify != nil && !o.Verify(&r.SignRequest) {
+		log.Lvl2(o.ServerIdentity(), "refused to sign")
+		return cothority.ErrorOrNil(o.SendToParent(&SignReply{}),
+			"sending SignReply to parent")
+	}
+	sig, err := o.sign(&r.SignRequest)
+	if err != nil {
+		log.Lvl2(o.ServerIdentity(), "couldn't sign:", err)
+		return cothority.ErrorOrNil(o.SendToParent(&SignReply{}),
+			"sending SignReply to parent")
+	}
+	return cothority.ErrorOrNil(o.SendToParent(&SignReply{Share: sig}),
+		"sending SignReply to parent")
+}
+
+// signReply is the root-node waiting for enough shares to recover the
+// signature.
+func (o *Sign) signReply(sr structSignReply) error {
+	if len(o.shares) >= o.Threshold {
+		// Signature already recovered.
+		return nil
+	}
+	if len(sr.Share) == 0 {
+		log.Lvl2("Node", sr.ServerIdentity, "refused to reply")
+		o.failure()
+		return nil
+	}
+	if err := tbls.Verify(Suite, o.Poly, o.Message, sr.Share); err != nil {
+		log.Lvl1("Received invalid share from node", sr.ServerIdentity)
+		o.failure()
+		return nil
+	}
+	o.shares = append(o.shares, sr.Share)
+	if len(o.shares) >= o.Threshold {
+		return cothority.ErrorOrNil(o.recover(), "recovering signature")
+	}
+
+	// If we are leaving by here it means that we do not have enough
+	// replies yet. Either another reply, enough failures or the timeout
+	// will eventually trigger the finish().
+	return nil
+}
+
+func (o *Sign) sign(sr *SignRequest) ([]byte, error) {
+	priv, err := o.Share(sr.Public)
+	if err != nil {
+		return nil, xerrors.Errorf("getting share: %v", err)
+	}
+	sig, err := tbls.Sign(Suite, priv, sr.Message)
+	return sig, cothority.ErrorOrNil(err, "signing message")
+}
+
+func (o *Sign) recover() error {
+	n := len(o.Roster().List)
+	sig, err := tbls.Recover(Suite, o.Poly, o.Message, o.shares, o.Threshold, n)
+	if err != nil {
+		o.finish(false)
+		return err
+	}
+	o.Signature = sig
+	o.finish(true)
+	return nil
+}
+
+func (o *Sign) failure() {
+	o.failures++
+	if o.failures > len(o.Roster().List)-o.Threshold {
+		log.Lvl2(o.ServerIdentity(), "couldn't get enough shares")
+		o.finish(false)
+	}
+}
+
+func (o *Sign) finish(result bool) {
+	if o.timeout != nil {
+		o.timeout.Stop()
+	}
+	select {
+	case o.Finished <- result:
+		// succeeded
+	default:
+		// would have blocked because some other call to finish()
+		// beat us.
+	}
+	o.doneOnce.Do(func() { o.Done() })
+}
@@ -0,0 +1,57 @@
+package protocol
+
+/*
+Sign_struct holds all messages for the threshold signing protocol of the
+beacon.
+*/
+
+import (
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// NameSign can be used from other packages to refer to this protocol.
+const NameSign = "BeaconSign"
+
+func init() {
+	network.RegisterMessages(&SignRequest{}, &SignReply{})
+}
+
+// VerifyRequest is a callback-function that can be set by a service.
+// Whenever a signature request is received, this function will be called
+// and its return-value used to determine whether or not to sign the
+// message.
+type VerifyRequest func(sr *SignRequest) bool
+
+// GetShare is a callback-function that returns the private share of the
+// node for the given distributed public key.
+type GetShare func(public kyber.Point) (*share.PriShare, error)
+
+// SignRequest asks a node for its signature share on a message.
+type SignRequest struct {
+	// Public is the distributed public key used to find the share.
+	Public kyber.Point
+	// Message is the message to be signed.
+	Message []byte
+	// VerificationData can be any slice of bytes, so that each node can
+	// verify if the signature request is valid or not.
+	VerificationData []byte
+}
+
+type structSignRequest struct {
+	*onet.TreeNode
+	SignRequest
+}
+
+// SignReply returns the threshold BLS signature share from one node. An
+// empty share means that the node refused to sign.
+type SignReply struct {
+	Share []byte
+}
+
+type structSignReply struct {
+	*onet.TreeNode
+	SignReply
+}
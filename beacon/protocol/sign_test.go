@@ -0,0 +1,91 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+func TestSign(t *testing.T) {
+	for _, nbrNodes := range []int{1, 4, 7} {
+		log.Lvlf1("Signing with %d nodes", nbrNodes)
+		runSign(t, nbrNodes, 0)
+	}
+}
+
+// TestSign_Refuse makes sure that the signature is recovered as long as
+// enough nodes reply, and that the protocol fails otherwise.
+func TestSign_Refuse(t *testing.T) {
+	runSign(t, 7, 2)
+	runSign(t, 7, 3)
+}
+
+func runSign(t *testing.T, nbrNodes, refuse int) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer local.CloseAll()
+	srvs, roster, _ := local.GenTree(nbrNodes, true)
+	tree := roster.GenerateNaryTreeWithRoot(nbrNodes, srvs[0].ServerIdentity)
+
+	threshold := nbrNodes - (nbrNodes-1)/3
+	priPoly := share.NewPriPoly(Suite.G2(), threshold, nil, Suite.RandomStream())
+	pubPoly := priPoly.Commit(Suite.G2().Point().Base())
+	shares := priPoly.Shares(nbrNodes)
+	msg := []byte("round 1")
+
+	name := "beacon_sign_test"
+	for i, srv := range srvs {
+		priv := shares[i]
+		refused := i > 0 && i <= refuse
+		_, err := srv.ProtocolRegister(name, func(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+			pi, err := NewSign(n)
+			if err != nil {
+				return nil, err
+			}
+			sign := pi.(*Sign)
+			sign.Share = func(public kyber.Point) (*share.PriShare, error) {
+				if !public.Equal(pubPoly.Commit()) {
+					return nil, xerrors.New("unknown public key")
+				}
+				return priv, nil
+			}
+			sign.Verify = func(sr *SignRequest) bool {
+				return !refused
+			}
+			return sign, nil
+		})
+		require.NoError(t, err)
+	}
+
+	pi, err := local.CreateProtocol(name, tree)
+	require.NoError(t, err)
+	sign := pi.(*Sign)
+	sign.Public = pubPoly.Commit()
+	sign.Poly = pubPoly
+	sign.Message = msg
+	sign.Timeout = 5 * time.Second
+	require.NoError(t, sign.Start())
+
+	select {
+	case success := <-sign.Finished:
+		if refuse > nbrNodes-threshold {
+			require.False(t, success)
+			return
+		}
+		require.True(t, success)
+		require.NoError(t, bls.Verify(Suite, pubPoly.Commit(), msg, sign.Signature))
+	case <-time.After(10 * time.Second):
+		t.Fatal("Didn't finish in time")
+	}
+}
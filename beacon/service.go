@@ -0,0 +1,563 @@
+// Package beacon implements a distributed randomness beacon. A roster runs a
+// distributed key generation once, and then the first node of the roster
+// asks for threshold BLS signatures on the round numbers in regular
+// intervals. Every round is signed over the signature of the previous round,
+// and stored in a skipchain, so that anybody can verify any round using the
+// distributed public key found in the genesis block.
+//
+// The ByzCoin contract "beacon" stores the latest round of a beacon, so that
+// other contracts can read the random value deterministically.
+//
+// For more details, see
+// https://github.com/dedis/cothority/tree/master/beacon/README.md
+package beacon
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/beacon/protocol"
+	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// Used for tests
+var beaconID onet.ServiceID
+
+// ServiceName of the beacon service.
+const ServiceName = "Beacon"
+
+// nameDKG is the distributed key generation protocol on the pairing suite.
+const nameDKG = "BeaconDKG"
+
+// propagationTimeout is how long the system waits for the DKG to finish
+const propagationTimeout = 20 * time.Second
+
+// signTimeout is how long the leader waits for the shares of a round.
+const signTimeout = 10 * time.Second
+
+// VerifierID checks that the rounds stored in a beacon chain are correctly
+// signed and chained.
+var VerifierID = skipchain.VerifierID(uuid.NewV5(uuid.NamespaceURL, "beacon"))
+
+// verifiers are used for the beacon chains.
+var verifiers = []skipchain.VerifierID{skipchain.VerifyBase, VerifierID}
+
+var allowInsecureAdmin = false
+
+func init() {
+	var err error
+	_, err = onet.GlobalProtocolRegister(nameDKG, newDKG)
+	log.ErrFatal(err)
+	beaconID, err = onet.RegisterNewService(ServiceName, newService)
+	log.ErrFatal(err)
+	network.RegisterMessages(&storage{})
+
+	// The loopback check makes Java testing not work, because Java client
+	// commands come from outside of the docker container. The Java testing
+	// Docker container runs with this variable set.
+	if os.Getenv("COTHORITY_ALLOW_INSECURE_ADMIN") != "" {
+		log.Warn("COTHORITY_ALLOW_INSECURE_ADMIN is set; Beacon admin actions allowed from the public network.")
+		allowInsecureAdmin = true
+	}
+}
+
+// newDKG returns a DKG protocol using a fresh key pair on the pairing suite,
+// as the network keys are not on the same curve.
+func newDKG(n *onet.TreeNodeInstance) (onet.ProtocolInstance, error) {
+	return dkgprotocol.CustomSetup(n, protocol.Suite, key.NewKeyPair(protocol.Suite))
+}
+
+// Service is our beacon-service. It stores the shares of all beacons this
+// node participates in, and creates the rounds of the beacons it leads.
+type Service struct {
+	*onet.ServiceProcessor
+	storage *storage
+	// stopBeacons holds a channel for every beacon led by this node,
+	// indexed by the ID of the beacon chain.
+	stopBeacons    map[string]chan bool
+	stopBeaconsMut sync.Mutex
+	beaconsWG      sync.WaitGroup
+}
+
+// ProcessClientRequest implements onet.Service. We override the version
+// we normally get from embeddeding onet.ServiceProcessor in order to
+// hook it and get a look at the http.Request.
+func (s *Service) ProcessClientRequest(req *http.Request, path string, buf []byte) ([]byte, *onet.StreamingTunnel, error) {
+	if !allowInsecureAdmin && path == "Setup" {
+		h, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("splitting host port: %v", err)
+		}
+		ip := net.ParseIP(h)
+		if !ip.IsLoopback() {
+			return nil, nil, xerrors.New("setup is only allowed on loopback")
+		}
+	}
+	return s.ServiceProcessor.ProcessClientRequest(req, path, buf)
+}
+
+// Setup runs a distributed key generation with all nodes of the roster,
+// stores the genesis block of the new beacon chain and starts creating
+// rounds. It must be sent to the first node of the roster and can only be
+// called from localhost, except if COTHORITY_ALLOW_INSECURE_ADMIN is set.
+func (s *Service) Setup(req *Setup) (*SetupReply, error) {
+	n := len(req.Roster.List)
+	if n < 2 {
+		return nil, xerrors.New("need at least two nodes")
+	}
+	if !req.Roster.List[0].Equal(s.ServerIdentity()) {
+		return nil, xerrors.New("setup must be sent to the first node of the roster")
+	}
+	threshold := req.Threshold
+	if threshold == 0 {
+		threshold = n - (n-1)/3
+	}
+	if threshold < 1 || threshold > n {
+		return nil, xerrors.Errorf("threshold must be between 1 and %d", n)
+	}
+	if req.Interval <= 0 {
+		return nil, xerrors.New("interval must be positive")
+	}
+
+	tree := req.Roster.GenerateNaryTreeWithRoot(n, s.ServerIdentity())
+	if tree == nil {
+		return nil, xerrors.New("error while generating tree")
+	}
+	pi, err := s.CreateProtocol(nameDKG, tree)
+	if err != nil {
+		return nil, xerrors.Errorf("creating dkg protocol: %v", err)
+	}
+	setupDKG := pi.(*dkgprotocol.Setup)
+	setupDKG.Wait = true
+	setupDKG.Threshold = uint32(threshold)
+	if err := pi.Start(); err != nil {
+		return nil, xerrors.Errorf("starting dkg protocol: %v", err)
+	}
+
+	log.Lvl3("Started DKG-protocol - waiting for done", n)
+	var dks *dkg.DistKeyShare
+	select {
+	case <-setupDKG.Finished:
+		dks, err = setupDKG.DKG.DistKeyShare()
+		if err != nil {
+			return nil, xerrors.Errorf("getting key share: %v", err)
+		}
+	case <-time.After(propagationTimeout):
+		return nil, xerrors.New("dkg didn't finish in time")
+	}
+	if err := s.storeShare(dks); err != nil {
+		return nil, xerrors.Errorf("storing share: %v", err)
+	}
+
+	config := Config{
+		Public:    dks.Public(),
+		Threshold: threshold,
+		Interval:  req.Interval,
+	}
+	genesis, err := s.createGenesis(&req.Roster, config)
+	if err != nil {
+		return nil, xerrors.Errorf("creating genesis block: %v", err)
+	}
+
+	s.storage.Lock()
+	s.storage.Chains = append(s.storage.Chains, genesis.Hash)
+	s.storage.Unlock()
+	if err := s.save(); err != nil {
+		return nil, xerrors.Errorf("saving data: %v", err)
+	}
+	s.startBeacon(genesis.Hash, config.Interval)
+	log.Lvlf2("%v created beacon %x with public key %v", s.ServerIdentity(),
+		genesis.Hash, config.Public)
+
+	return &SetupReply{
+		ID:     genesis.Hash,
+		Public: config.Public,
+	}, nil
+}
+
+// GetRound returns the requested round of a beacon, or the latest round if
+// the index is 0.
+func (s *Service) GetRound(req *GetRound) (*GetRoundReply, error) {
+	db := s.skService().GetDB()
+	genesis := db.GetByID(req.ID)
+	if genesis == nil || !isBeacon(genesis) {
+		return nil, xerrors.New("unknown beacon")
+	}
+
+	var sb *skipchain.SkipBlock
+	if req.Index == 0 {
+		latest, err := db.GetLatestByID(req.ID)
+		if err != nil {
+			return nil, xerrors.Errorf("getting latest block: %v", err)
+		}
+		sb = latest
+	} else {
+		reply, err := s.skService().GetSingleBlockByIndex(
+			&skipchain.GetSingleBlockByIndex{Genesis: req.ID, Index: int(req.Index)})
+		if err != nil {
+			return nil, xerrors.Errorf("round %d not found: %v", req.Index, err)
+		}
+		sb = reply.SkipBlock
+	}
+	if sb.Index == 0 {
+		return nil, xerrors.New("no round available yet")
+	}
+
+	round, err := decodeRound(sb.Data)
+	if err != nil {
+		return nil, xerrors.Errorf("getting round: %v", err)
+	}
+	return &GetRoundReply{Round: *round}, nil
+}
+
+// NewProtocol intercepts the DKG and the signing protocols to give them
+// access to the shares of this node.
+func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfig) (onet.ProtocolInstance, error) {
+	log.Lvl3(s.ServerIdentity(), tn.ProtocolName(), conf)
+	switch tn.ProtocolName() {
+	case nameDKG:
+		pi, err := newDKG(tn)
+		if err != nil {
+			return nil, xerrors.Errorf("setting up dkg: %v", err)
+		}
+		setupDKG := pi.(*dkgprotocol.Setup)
+		go func() {
+			<-setupDKG.Finished
+			dks, err := setupDKG.DKG.DistKeyShare()
+			if err != nil {
+				log.Error(err)
+				return
+			}
+			if err := s.storeShare(dks); err != nil {
+				log.Error(err)
+			}
+		}()
+		return pi, nil
+	case protocol.NameSign:
+		pi, err := protocol.NewSign(tn)
+		if err != nil {
+			return nil, xerrors.Errorf("setting up sign protocol: %v", err)
+		}
+		sign := pi.(*protocol.Sign)
+		sign.Share = s.getShare
+		sign.Verify = s.verifySignRequest
+		return sign, nil
+	}
+	return nil, nil
+}
+
+// TestClose stops the creation of new rounds. It is exported because we need
+// it in tests, it should not be used in non-test code outside of this
+// package.
+func (s *Service) TestClose() {
+	s.stopBeaconsMut.Lock()
+	for id, stop := range s.stopBeacons {
+		close(stop)
+		delete(s.stopBeacons, id)
+	}
+	s.stopBeaconsMut.Unlock()
+	s.beaconsWG.Wait()
+}
+
+// createGenesis stores the genesis block of a new beacon chain.
+func (s *Service) createGenesis(roster *onet.Roster, config Config) (*skipchain.SkipBlock, error) {
+	if err := config.sanityCheck(len(roster.List)); err != nil {
+		return nil, xerrors.Errorf("invalid config: %v", err)
+	}
+	buf, err := protobuf.Encode(&config)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding config: %v", err)
+	}
+	block := skipchain.NewSkipBlock()
+	block.Roster = roster
+	block.BaseHeight = 4
+	block.MaximumHeight = 32
+	block.VerifierIDs = verifiers
+	block.Data = buf
+	reply, err := s.skService().StoreSkipBlockInternal(&skipchain.StoreSkipBlock{
+		NewBlock: block,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("storing block: %v", err)
+	}
+	return reply.Latest, nil
+}
+
+// startBeacon creates a new round every interval, until the beacon is
+// stopped.
+func (s *Service) startBeacon(id skipchain.SkipBlockID, interval time.Duration) {
+	s.stopBeaconsMut.Lock()
+	defer s.stopBeaconsMut.Unlock()
+	if _, ok := s.stopBeacons[string(id)]; ok {
+		return
+	}
+	stop := make(chan bool)
+	s.stopBeacons[string(id)] = stop
+	s.beaconsWG.Add(1)
+	go func() {
+		defer s.beaconsWG.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+				if err := s.createRound(id); err != nil {
+					log.Errorf("%v couldn't create round for beacon %x: %v",
+						s.ServerIdentity(), id, err)
+				}
+			}
+		}
+	}()
+}
+
+// createRound signs the next round of the beacon and stores it in a new
+// block.
+func (s *Service) createRound(id skipchain.SkipBlockID) error {
+	db := s.skService().GetDB()
+	config, err := s.getConfig(id)
+	if err != nil {
+		return xerrors.Errorf("getting config: %v", err)
+	}
+	latest, err := db.GetLatestByID(id)
+	if err != nil {
+		return xerrors.Errorf("getting latest block: %v", err)
+	}
+	var previous *Round
+	round := Round{Index: uint64(latest.Index) + 1}
+	if latest.Index > 0 {
+		previous, err = decodeRound(latest.Data)
+		if err != nil {
+			return xerrors.Errorf("getting previous round: %v", err)
+		}
+		round.Previous = previous.Signature
+	}
+
+	round.Signature, err = s.signRound(latest.Roster, config, round, previous)
+	if err != nil {
+		return xerrors.Errorf("signing round: %v", err)
+	}
+	buf, err := protobuf.Encode(&round)
+	if err != nil {
+		return xerrors.Errorf("encoding round: %v", err)
+	}
+
+	block := latest.Copy()
+	block.GenesisID = block.SkipChainID()
+	block.Index++
+	block.Data = buf
+	_, err = s.skService().StoreSkipBlockInternal(&skipchain.StoreSkipBlock{
+		NewBlock:          block,
+		TargetSkipChainID: latest.SkipChainID(),
+	})
+	if err != nil {
+		return xerrors.Errorf("storing block: %v", err)
+	}
+	log.Lvlf3("%v stored round %d of beacon %x", s.ServerIdentity(),
+		round.Index, id)
+	return nil
+}
+
+// signRound runs the signing protocol on the given round.
+func (s *Service) signRound(roster *onet.Roster, config *Config, round Round,
+	previous *Round) ([]byte, error) {
+	dks := s.getDistKeyShare(config.Public)
+	if dks == nil {
+		return nil, xerrors.New("don't have a share for this beacon")
+	}
+	vd, err := protobuf.Encode(&signData{Round: round, Previous: previous})
+	if err != nil {
+		return nil, xerrors.Errorf("encoding verification data: %v", err)
+	}
+
+	tree := roster.GenerateNaryTreeWithRoot(len(roster.List), s.ServerIdentity())
+	if tree == nil {
+		return nil, xerrors.New("error while generating tree")
+	}
+	pi, err := s.CreateProtocol(protocol.NameSign, tree)
+	if err != nil {
+		return nil, xerrors.Errorf("creating sign protocol: %v", err)
+	}
+	sign := pi.(*protocol.Sign)
+	sign.Public = config.Public
+	sign.Poly = share.NewPubPoly(protocol.Suite.G2(), nil, dks.Commits)
+	sign.Message = round.Message()
+	sign.Threshold = config.Threshold
+	sign.Timeout = signTimeout
+	sign.VerificationData = vd
+	sign.Share = s.getShare
+	sign.Verify = s.verifySignRequest
+	if err := sign.Start(); err != nil {
+		return nil, xerrors.Errorf("starting sign protocol: %v", err)
+	}
+	if !<-sign.Finished {
+		return nil, xerrors.New("couldn't collect enough shares")
+	}
+	return sign.Signature, nil
+}
+
+// verifySignRequest makes sure that the round to be signed follows a
+// correctly signed previous round.
+func (s *Service) verifySignRequest(sr *protocol.SignRequest) bool {
+	var data signData
+	if err := protobuf.Decode(sr.VerificationData, &data); err != nil {
+		log.Lvl2(s.ServerIdentity(), "couldn't decode verification data:", err)
+		return false
+	}
+	if !bytes.Equal(sr.Message, data.Round.Message()) {
+		log.Lvl2(s.ServerIdentity(), "message doesn't match round")
+		return false
+	}
+	if err := data.Round.Follows(data.Previous); err != nil {
+		log.Lvl2(s.ServerIdentity(), "invalid round:", err)
+		return false
+	}
+	if data.Previous != nil {
+		if err := data.Previous.Verify(sr.Public); err != nil {
+			log.Lvl2(s.ServerIdentity(), "invalid previous round:", err)
+			return false
+		}
+	}
+	return true
+}
+
+// verifyBlock is the skipchain verification function for beacon chains.
+func (s *Service) verifyBlock(newID []byte, sb *skipchain.SkipBlock) bool {
+	if err := s.checkBlock(sb); err != nil {
+		log.Lvl2(s.ServerIdentity(), "refusing beacon block:", err)
+		return false
+	}
+	return true
+}
+
+func (s *Service) checkBlock(sb *skipchain.SkipBlock) error {
+	if sb.Index == 0 {
+		config, err := decodeConfig(sb.Data)
+		if err != nil {
+			return xerrors.Errorf("getting config: %v", err)
+		}
+		return config.sanityCheck(len(sb.Roster.List))
+	}
+
+	db := s.skService().GetDB()
+	genesis := db.GetByID(sb.GenesisID)
+	if genesis == nil {
+		return xerrors.New("unknown genesis block")
+	}
+	config, err := decodeConfig(genesis.Data)
+	if err != nil {
+		return xerrors.Errorf("getting config: %v", err)
+	}
+	if equal, err := genesis.Roster.Equal(sb.Roster); err != nil || !equal {
+		return xerrors.New("the roster of a beacon cannot change")
+	}
+	round, err := decodeRound(sb.Data)
+	if err != nil {
+		return xerrors.Errorf("getting round: %v", err)
+	}
+	if round.Index != uint64(sb.Index) {
+		return xerrors.New("round index doesn't match block index")
+	}
+	var previous *Round
+	if sb.Index > 1 {
+		prev := db.GetByID(sb.BackLinkIDs[0])
+		if prev == nil {
+			return xerrors.New("unknown previous block")
+		}
+		previous, err = decodeRound(prev.Data)
+		if err != nil {
+			return xerrors.Errorf("getting previous round: %v", err)
+		}
+	}
+	if err := round.Follows(previous); err != nil {
+		return xerrors.Errorf("checking chain: %v", err)
+	}
+	return cothority.ErrorOrNil(round.Verify(config.Public), "verifying round")
+}
+
+// getConfig returns the configuration stored in the genesis block of the
+// beacon.
+func (s *Service) getConfig(id skipchain.SkipBlockID) (*Config, error) {
+	genesis := s.skService().GetDB().GetByID(id)
+	if genesis == nil {
+		return nil, xerrors.New("unknown beacon")
+	}
+	return decodeConfig(genesis.Data)
+}
+
+func (s *Service) storeShare(dks *dkg.DistKeyShare) error {
+	s.storage.Lock()
+	s.storage.Shares[shareKey(dks.Public())] = dks
+	s.storage.Unlock()
+	return s.save()
+}
+
+func (s *Service) getDistKeyShare(public kyber.Point) *dkg.DistKeyShare {
+	s.storage.Lock()
+	defer s.storage.Unlock()
+	return s.storage.Shares[shareKey(public)]
+}
+
+func (s *Service) getShare(public kyber.Point) (*share.PriShare, error) {
+	dks := s.getDistKeyShare(public)
+	if dks == nil {
+		return nil, xerrors.New("unknown public key")
+	}
+	return dks.PriShare(), nil
+}
+
+func (s *Service) skService() *skipchain.Service {
+	return s.Service(skipchain.ServiceName).(*skipchain.Service)
+}
+
+func isBeacon(genesis *skipchain.SkipBlock) bool {
+	for _, v := range genesis.VerifierIDs {
+		if v.Equal(VerifierID) {
+			return true
+		}
+	}
+	return false
+}
+
+func newService(c *onet.Context) (onet.Service, error) {
+	s := &Service{
+		ServiceProcessor: onet.NewServiceProcessor(c),
+		stopBeacons:      make(map[string]chan bool),
+	}
+	if err := s.RegisterHandlers(s.Setup, s.GetRound); err != nil {
+		return nil, xerrors.New("couldn't register messages")
+	}
+	if err := s.tryLoad(); err != nil {
+		log.Error(err)
+		return nil, xerrors.Errorf("loading configuration: %v", err)
+	}
+	if err := skipchain.RegisterVerification(c, VerifierID, s.verifyBlock); err != nil {
+		return nil, xerrors.Errorf("registering verification: %v", err)
+	}
+
+	// Restart the beacons led by this node.
+	for _, id := range s.storage.Chains {
+		config, err := s.getConfig(id)
+		if err != nil {
+			log.Errorf("couldn't restart beacon %x: %v", id, err)
+			continue
+		}
+		s.startBeacon(id, config.Interval)
+	}
+	return s, nil
+}
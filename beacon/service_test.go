@@ -0,0 +1,125 @@
+package beacon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+// TestService_Setup creates a beacon and checks that the rounds can be
+// fetched and verified.
+func TestService_Setup(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(4, true)
+	cl := NewClient()
+
+	err := cl.SendProtobuf(roster.List[1], &Setup{Roster: *roster,
+		Interval: time.Second}, &SetupReply{})
+	require.Error(t, err)
+	_, err = cl.Setup(roster, 5, time.Second)
+	require.Error(t, err)
+
+	reply, err := cl.Setup(roster, 0, 500*time.Millisecond)
+	require.NoError(t, err)
+
+	config, err := cl.GetConfig(roster, reply.ID)
+	require.NoError(t, err)
+	require.True(t, config.Public.Equal(reply.Public))
+	require.Equal(t, 3, config.Threshold)
+
+	var latest *Round
+	for i := 0; i < 20; i++ {
+		latest, err = cl.GetRound(roster, reply.ID, config.Public, 0)
+		if err == nil && latest.Index >= 3 {
+			break
+		}
+		time.Sleep(config.Interval)
+	}
+	require.NoError(t, err)
+	require.True(t, latest.Index >= 3)
+
+	var previous *Round
+	for i := uint64(1); i <= 3; i++ {
+		round, err := cl.GetRound(roster, reply.ID, config.Public, i)
+		require.NoError(t, err)
+		require.NoError(t, round.Follows(previous))
+		require.Len(t, round.Randomness(), 32)
+		if previous != nil {
+			require.NotEqual(t, previous.Randomness(), round.Randomness())
+		}
+		previous = round
+	}
+
+	_, err = cl.GetRound(roster, reply.ID, config.Public, latest.Index+100)
+	require.Error(t, err)
+	_, err = cl.GetRound(roster, skipchain.SkipBlockID("unknown"), config.Public, 1)
+	require.Error(t, err)
+}
+
+// TestService_VerifyBlock makes sure that the nodes refuse rounds that are
+// not signed by the beacon.
+func TestService_VerifyBlock(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	servers, roster, _ := local.GenTree(3, true)
+	services := local.GetServices(servers, beaconID)
+	cl := NewClient()
+
+	reply, err := cl.Setup(roster, 0, time.Hour)
+	require.NoError(t, err)
+	s := services[1].(*Service)
+	config, err := s.getConfig(reply.ID)
+	require.NoError(t, err)
+
+	genesis := s.skService().GetDB().GetByID(reply.ID)
+	require.NotNil(t, genesis)
+	require.NoError(t, s.checkBlock(genesis))
+
+	round := Round{Index: 1}
+	leader := services[0].(*Service)
+	round.Signature, err = leader.signRound(roster, config, round, nil)
+	require.NoError(t, err)
+	require.NoError(t, round.Verify(config.Public))
+
+	block := genesis.Copy()
+	block.GenesisID = genesis.Hash
+	block.Index = 1
+	block.BackLinkIDs = []skipchain.SkipBlockID{genesis.Hash}
+	block.Data, err = protobuf.Encode(&round)
+	require.NoError(t, err)
+	require.NoError(t, s.checkBlock(block))
+
+	wrong := round
+	wrong.Signature = append([]byte{}, round.Signature...)
+	wrong.Signature[0] ^= 1
+	block.Data, err = protobuf.Encode(&wrong)
+	require.NoError(t, err)
+	require.Error(t, s.checkBlock(block))
+
+	wrong = round
+	wrong.Index = 2
+	block.Data, err = protobuf.Encode(&wrong)
+	require.NoError(t, err)
+	require.Error(t, s.checkBlock(block))
+
+	// A node refuses to sign a round that doesn't follow a valid round.
+	next := Round{Index: 2, Previous: round.Signature}
+	bad := round
+	bad.Previous = []byte("something else")
+	_, err = leader.signRound(roster, config, next, &bad)
+	require.Error(t, err)
+	next.Signature, err = leader.signRound(roster, config, next, &round)
+	require.NoError(t, err)
+	require.NoError(t, next.Verify(config.Public))
+}
@@ -0,0 +1,104 @@
+package beacon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/beacon/protocol"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+func init() {
+	network.RegisterMessages(Setup{}, SetupReply{},
+		GetRound{}, GetRoundReply{})
+}
+
+// Message returns the message that is signed by the beacon for this round.
+func (r Round) Message() []byte {
+	h := sha256.New()
+	h.Write(r.Previous)
+	binary.Write(h, binary.LittleEndian, r.Index)
+	return h.Sum(nil)
+}
+
+// Randomness returns the random value of this round, which is the hash of
+// the signature.
+func (r Round) Randomness() []byte {
+	h := sha256.Sum256(r.Signature)
+	return h[:]
+}
+
+// Verify checks the signature of the round against the distributed public
+// key of the beacon.
+func (r Round) Verify(public kyber.Point) error {
+	if r.Index == 0 {
+		return xerrors.New("round 0 doesn't exist")
+	}
+	return cothority.ErrorOrNil(bls.Verify(protocol.Suite, public, r.Message(),
+		r.Signature), "verifying signature")
+}
+
+// Follows checks that the round is the successor of the previous round,
+// which must be nil for the first round. It doesn't verify the signatures.
+func (r Round) Follows(previous *Round) error {
+	if previous == nil {
+		if r.Index != 1 || len(r.Previous) != 0 {
+			return xerrors.New("first round must have index 1 and no previous signature")
+		}
+		return nil
+	}
+	if r.Index != previous.Index+1 {
+		return xerrors.Errorf("round %d doesn't follow round %d", r.Index,
+			previous.Index)
+	}
+	if !bytes.Equal(r.Previous, previous.Signature) {
+		return xerrors.New("previous signature doesn't match")
+	}
+	return nil
+}
+
+// sanityCheck makes sure the configuration can be used for a roster of n
+// nodes.
+func (c Config) sanityCheck(n int) error {
+	if c.Public == nil {
+		return xerrors.New("missing public key")
+	}
+	if c.Threshold < 1 || c.Threshold > n {
+		return xerrors.Errorf("threshold must be between 1 and %d", n)
+	}
+	if c.Interval <= 0 {
+		return xerrors.New("interval must be positive")
+	}
+	return nil
+}
+
+// signData is sent to all nodes when a round is signed, so that they can
+// verify that the round follows a valid previous round.
+type signData struct {
+	Round    Round
+	Previous *Round
+}
+
+func decodeConfig(buf []byte) (*Config, error) {
+	config := &Config{}
+	err := protobuf.DecodeWithConstructors(buf, config,
+		network.DefaultConstructors(protocol.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding config: %v", err)
+	}
+	return config, nil
+}
+
+func decodeRound(buf []byte) (*Round, error) {
+	round := &Round{}
+	if err := protobuf.Decode(buf, round); err != nil {
+		return nil, xerrors.Errorf("decoding round: %v", err)
+	}
+	return round, nil
+}
@@ -718,6 +718,23 @@ func (c *Client) ResolveInstanceID(darcID darc.ID, name string) (InstanceID, err
 	return reply.InstanceID, cothority.ErrorOrNil(err, "request failed")
 }
 
+// ResolveDID returns the document of a 'did:byzcoin' DID. If blockIndex is
+// bigger than 0, the document is returned as it was after this block.
+func (c *Client) ResolveDID(did string, blockIndex int) (*ResolveDIDResponse, error) {
+	req := ResolveDID{
+		SkipChainID: c.ID,
+		DID:         did,
+		BlockIndex:  blockIndex,
+	}
+	reply := &ResolveDIDResponse{}
+
+	_, err := c.SendProtobufParallel(c.Roster.List, &req, reply, c.options)
+	if err != nil {
+		return nil, cothority.ErrorOrNil(err, "request failed")
+	}
+	return reply, nil
+}
+
 // WaitPropagation contacts all nodes in the cl.Roster until they all
 // have the same latest block. If there is an error when calling
 // `GetProof`, the error will be ignored. This helps when waiting
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+func coinInvoiceCreate(c *cli.Context) error {
+	cl, signer, coin, err := getCoinSigner(c)
+	if err != nil {
+		return err
+	}
+	amount := c.Uint64("amount")
+	if amount == 0 {
+		return xerrors.New("--amount flag is required")
+	}
+
+	p, err := cl.GetProofFromLatest(coin.Slice())
+	if err != nil {
+		return xerrors.Errorf("couldn't get proof for coin: %v", err)
+	}
+	_, cid, darcID, err := p.Proof.Get(coin.Slice())
+	if err != nil {
+		return xerrors.Errorf("couldn't find coin: %v", err)
+	}
+	if cid != contracts.ContractCoinID {
+		return xerrors.Errorf("instance is a %s, not a coin", cid)
+	}
+	ids, _, err := getInvoices(cl, coin)
+	if err != nil {
+		return err
+	}
+
+	args := byzcoin.Arguments{
+		{Name: "coin", Value: coin.Slice()},
+		{Name: "amount", Value: uint64Buf(amount)},
+		{Name: "index", Value: uint64Buf(uint64(len(ids)))},
+	}
+	if memo := c.String("memo"); memo != "" {
+		args = append(args, byzcoin.Argument{Name: "memo", Value: []byte(memo)})
+	}
+	if expiry := c.Duration("expiry"); expiry > 0 {
+		args = append(args, byzcoin.Argument{Name: "expiry",
+			Value: uint64Buf(uint64(time.Now().Add(expiry).UnixNano()))})
+	}
+
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("couldn't get signer counters: %v", err)
+	}
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(darcID),
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractInvoiceID,
+			Args:       args,
+		},
+		SignerCounter: []uint64{counters.Counters[0] + 1},
+	})
+	if err != nil {
+		return err
+	}
+	err = ctx.FillSignersAndSignWith(*signer)
+	if err != nil {
+		return err
+	}
+
+	if lib.FindRecursivefBool("export", c) {
+		return lib.ExportTransaction(ctx)
+	}
+
+	_, err = cl.AddTransactionAndWait(ctx, 10)
+	if err != nil {
+		return err
+	}
+
+	id := contracts.InvoiceID(coin, uint64(len(ids)))
+	log.Infof("Spawned a new invoice. Its instance id is:\n%x", id.Slice())
+
+	if c.Bool("qr") {
+		qr, err := invoiceQR(cl, hex.EncodeToString(id.Slice()))
+		if err != nil {
+			return err
+		}
+		if err = printQR(qr); err != nil {
+			return err
+		}
+	}
+
+	return lib.WaitPropagation(c, cl)
+}
+
+func coinInvoicePay(c *cli.Context) error {
+	cl, signer, coin, err := getCoinSigner(c)
+	if err != nil {
+		return err
+	}
+	id, invoice, err := getInvoiceByString(cl, c.String("invoice"))
+	if err != nil {
+		return err
+	}
+	if invoice.Paid {
+		return xerrors.New("invoice is already paid")
+	}
+
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("couldn't get signer counters: %v", err)
+	}
+	ctx, err := cl.CreateTransaction(
+		byzcoin.Instruction{
+			InstanceID: coin,
+			Invoke: &byzcoin.Invoke{
+				ContractID: contracts.ContractCoinID,
+				Command:    "fetch",
+				Args: byzcoin.Arguments{{
+					Name:  "coins",
+					Value: uint64Buf(invoice.Amount),
+				}},
+			},
+			SignerCounter: []uint64{counters.Counters[0] + 1},
+		},
+		byzcoin.Instruction{
+			InstanceID: id,
+			Invoke: &byzcoin.Invoke{
+				ContractID: contracts.ContractInvoiceID,
+				Command:    "pay",
+			},
+			SignerCounter: []uint64{counters.Counters[0] + 2},
+		})
+	if err != nil {
+		return err
+	}
+	err = ctx.FillSignersAndSignWith(*signer)
+	if err != nil {
+		return err
+	}
+
+	if lib.FindRecursivefBool("export", c) {
+		return lib.ExportTransaction(ctx)
+	}
+
+	_, err = cl.AddTransactionAndWait(ctx, 10)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Paid %d coins for invoice %x", invoice.Amount, id.Slice())
+
+	return lib.WaitPropagation(c, cl)
+}
+
+func coinInvoiceList(c *cli.Context) error {
+	cl, _, coin, err := getCoinSigner(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("paid") && c.Bool("open") {
+		return xerrors.New("only one of --paid and --open can be given")
+	}
+
+	ids, invoices, err := getInvoices(cl, coin)
+	if err != nil {
+		return err
+	}
+	for i, inv := range invoices {
+		if (c.Bool("paid") && !inv.Paid) || (c.Bool("open") && inv.Paid) {
+			continue
+		}
+		state := "open"
+		if inv.Paid {
+			state = "paid"
+		} else if inv.Expiry != 0 && time.Now().UnixNano() > inv.Expiry {
+			state = "expired"
+		}
+		log.Infof("%x: %d coins, %s - %s", ids[i].Slice(), inv.Amount, state,
+			inv.Memo)
+	}
+
+	return nil
+}
+
+// invoiceQR returns the description of an invoice to be shown in a QR code.
+func invoiceQR(cl *byzcoin.Client, idStr string) ([]byte, error) {
+	type invoiceConfig struct {
+		ByzCoinID skipchain.SkipBlockID
+		Invoice   string
+		Coin      string
+		Amount    uint64
+		Memo      string
+	}
+
+	id, invoice, err := getInvoiceByString(cl, idStr)
+	if err != nil {
+		return nil, err
+	}
+	toWrite, err := json.Marshal(invoiceConfig{
+		ByzCoinID: cl.ID,
+		Invoice:   hex.EncodeToString(id.Slice()),
+		Coin:      hex.EncodeToString(invoice.Coin.Slice()),
+		Amount:    invoice.Amount,
+		Memo:      invoice.Memo,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't marshal invoice: %v", err)
+	}
+	return toWrite, nil
+}
+
+// getCoinSigner returns the client, the signer given by --sign, or the admin
+// signer, and the coin given by --coin, or the coin of the signer as created
+// by the mint command.
+func getCoinSigner(c *cli.Context) (*byzcoin.Client, *darc.Signer,
+	byzcoin.InstanceID, error) {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return nil, nil, byzcoin.InstanceID{}, xerrors.New("--bc flag is required")
+	}
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return nil, nil, byzcoin.InstanceID{}, err
+	}
+
+	var signer *darc.Signer
+	if sstr := c.String("sign"); sstr == "" {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	} else {
+		signer, err = lib.LoadKeyFromString(sstr)
+	}
+	if err != nil {
+		return nil, nil, byzcoin.InstanceID{}, err
+	}
+
+	if cstr := c.String("coin"); cstr != "" {
+		coinBuf, err := hex.DecodeString(cstr)
+		if err != nil || len(coinBuf) != 32 {
+			return nil, nil, byzcoin.InstanceID{},
+				xerrors.New("coin should be a hex-string of 32 bytes")
+		}
+		return cl, signer, byzcoin.NewInstanceID(coinBuf), nil
+	}
+	if signer.Ed25519 == nil {
+		return nil, nil, byzcoin.InstanceID{},
+			xerrors.New("--coin flag is required for this signer")
+	}
+	pubBuf, err := signer.Ed25519.Point.MarshalBinary()
+	if err != nil {
+		return nil, nil, byzcoin.InstanceID{}, err
+	}
+	h := sha256.New()
+	h.Write([]byte(contracts.ContractCoinID))
+	h.Write(pubBuf)
+	return cl, signer, byzcoin.NewInstanceID(h.Sum(nil)), nil
+}
+
+// getInvoices returns all invoices of the coin, in the order they were
+// created.
+func getInvoices(cl *byzcoin.Client, coin byzcoin.InstanceID) (
+	ids []byzcoin.InstanceID, invoices []byzcoin.Invoice, err error) {
+	for index := uint64(0); ; index++ {
+		id := contracts.InvoiceID(coin, index)
+		p, err := cl.GetProofFromLatest(id.Slice())
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't get proof for invoice: %v", err)
+		}
+		if !p.Proof.InclusionProof.Match(id.Slice()) {
+			return ids, invoices, nil
+		}
+		invoice, err := decodeInvoice(p.Proof, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		invoices = append(invoices, invoice)
+	}
+}
+
+// getInvoiceByString returns the invoice stored in the instance given as a
+// hex-string.
+func getInvoiceByString(cl *byzcoin.Client, idStr string) (byzcoin.InstanceID,
+	byzcoin.Invoice, error) {
+	if idStr == "" {
+		return byzcoin.InstanceID{}, byzcoin.Invoice{},
+			xerrors.New("--invoice flag is required")
+	}
+	idBuf, err := hex.DecodeString(idStr)
+	if err != nil || len(idBuf) != 32 {
+		return byzcoin.InstanceID{}, byzcoin.Invoice{},
+			xerrors.New("invoice should be a hex-string of 32 bytes")
+	}
+	id := byzcoin.NewInstanceID(idBuf)
+	p, err := cl.GetProofFromLatest(id.Slice())
+	if err != nil {
+		return id, byzcoin.Invoice{}, xerrors.Errorf("couldn't get proof for invoice: %v", err)
+	}
+	invoice, err := decodeInvoice(p.Proof, id)
+	return id, invoice, err
+}
+
+// decodeInvoice returns the invoice stored in the instance of the proof.
+func decodeInvoice(p byzcoin.Proof, id byzcoin.InstanceID) (invoice byzcoin.Invoice,
+	err error) {
+	buf, cid, _, err := p.Get(id.Slice())
+	if err != nil {
+		return invoice, xerrors.Errorf("couldn't find invoice: %v", err)
+	}
+	if cid != contracts.ContractInvoiceID {
+		return invoice, xerrors.Errorf("instance is a %s, not an invoice", cid)
+	}
+	err = protobuf.Decode(buf, &invoice)
+	if err != nil {
+		return invoice, xerrors.Errorf("couldn't decode invoice: %v", err)
+	}
+	return
+}
+
+// uint64Buf returns the value as a 64-bit uint in LittleEndian.
+func uint64Buf(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return buf
+}
@@ -32,6 +32,14 @@ var cmds = cli.Commands{
 				Name:  "blockSize",
 				Usage: "adjust the maximum block size",
 			},
+			cli.IntFlag{
+				Name:  "leaderRotation",
+				Usage: "rotate the leader every given number of blocks - 0 disables the rotation",
+			},
+			cli.StringFlag{
+				Name:  "leaderSchedule",
+				Usage: "how the next leader is chosen: roundrobin or random",
+			},
 		},
 	},
 
@@ -961,6 +969,113 @@ var cmds = cli.Commands{
 		Action:    mint,
 	},
 
+	{
+		Name:  "coin",
+		Usage: "handle coins",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "export, x",
+				Usage: "redirects the transaction to stdout",
+			},
+		},
+		Subcommands: cli.Commands{
+			{
+				Name:  "invoice",
+				Usage: "request and pay coins with invoices",
+				Subcommands: cli.Commands{
+					{
+						Name:   "create",
+						Usage:  "create an invoice to be paid to a coin",
+						Action: coinInvoiceCreate,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "sign",
+								Usage: "public key of the signing entity (default is the admin public key)",
+							},
+							cli.StringFlag{
+								Name:  "coin",
+								Usage: "instance ID of the coin receiving the payment (default is the coin of the signer, as created by mint)",
+							},
+							cli.Uint64Flag{
+								Name:  "amount",
+								Usage: "number of coins to pay (required)",
+							},
+							cli.StringFlag{
+								Name:  "memo",
+								Usage: "description of the payment",
+							},
+							cli.DurationFlag{
+								Name:  "expiry",
+								Usage: "duration after which the invoice cannot be paid anymore",
+							},
+							cli.BoolFlag{
+								Name:  "qr",
+								Usage: "show the invoice as a QR code",
+							},
+						},
+					},
+					{
+						Name:   "pay",
+						Usage:  "pay an invoice in one transaction",
+						Action: coinInvoicePay,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "sign",
+								Usage: "public key of the signing entity (default is the admin public key)",
+							},
+							cli.StringFlag{
+								Name:  "coin",
+								Usage: "instance ID of the coin paying the invoice (default is the coin of the signer, as created by mint)",
+							},
+							cli.StringFlag{
+								Name:  "invoice",
+								Usage: "instance ID of the invoice (required)",
+							},
+						},
+					},
+					{
+						Name:   "list",
+						Usage:  "list the invoices of a coin",
+						Action: coinInvoiceList,
+						Flags: []cli.Flag{
+							cli.StringFlag{
+								Name:   "bc",
+								EnvVar: "BC",
+								Usage:  "the ByzCoin config to use (required)",
+							},
+							cli.StringFlag{
+								Name:  "sign",
+								Usage: "public key of the signing entity (default is the admin public key)",
+							},
+							cli.StringFlag{
+								Name:  "coin",
+								Usage: "instance ID of the coin (default is the coin of the signer, as created by mint)",
+							},
+							cli.BoolFlag{
+								Name:  "paid",
+								Usage: "only list paid invoices",
+							},
+							cli.BoolFlag{
+								Name:  "open",
+								Usage: "only list invoices not paid yet",
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+
 	{
 		Name:    "qr",
 		Usage:   "generates a QRCode containing the description of the BC Config",
@@ -976,6 +1091,10 @@ var cmds = cli.Commands{
 				Name:  "admin",
 				Usage: "If specified, the QR Code will contain the admin keypair",
 			},
+			cli.StringFlag{
+				Name:  "invoice",
+				Usage: "If specified, the QR Code will contain the invoice with this instance ID",
+			},
 		},
 	},
 
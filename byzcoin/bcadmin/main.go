@@ -589,6 +589,15 @@ func config(c *cli.Context) error {
 		}
 		chainConfig.MaxBlockSize = blockSize
 	}
+	if c.IsSet("leaderRotation") {
+		chainConfig.LeaderRotation = c.Int("leaderRotation")
+	}
+	if schedule := c.String("leaderSchedule"); schedule != "" {
+		chainConfig.LeaderSchedule, err = byzcoin.ParseLeaderSchedule(schedule)
+		if err != nil {
+			return xerrors.Errorf("couldn't parse schedule: %v", err)
+		}
+	}
 
 	err = updateConfig(cl, signer, chainConfig)
 	if err != nil {
@@ -667,6 +676,18 @@ func mint(c *cli.Context) error {
 			return err
 		}
 
+		err = rules.AddRule(darc.Action("invoke:coin.fetch"),
+			expression.Expr(pubI.String()))
+		if err != nil {
+			return err
+		}
+
+		err = rules.AddRule(darc.Action("spawn:"+contracts.ContractInvoiceID),
+			expression.Expr(pubI.String()))
+		if err != nil {
+			return err
+		}
+
 		d := darc.NewDarc(rules, []byte("new coin for mba"))
 		dBuf, err := d.ToProto()
 		if err != nil {
@@ -1670,14 +1691,19 @@ func qrcode(c *cli.Context) error {
 		return xerrors.New("--bc flag is required")
 	}
 
-	cfg, _, err := lib.LoadConfig(bcArg)
+	cfg, cl, err := lib.LoadConfig(bcArg)
 	if err != nil {
 		return err
 	}
 
 	var toWrite []byte
 
-	if c.Bool("admin") {
+	if c.String("invoice") != "" {
+		toWrite, err = invoiceQR(cl, c.String("invoice"))
+		if err != nil {
+			return err
+		}
+	} else if c.Bool("admin") {
 		signer, err := lib.LoadKey(cfg.AdminIdentity)
 		if err != nil {
 			return err
@@ -1707,7 +1733,12 @@ func qrcode(c *cli.Context) error {
 		}
 	}
 
-	qr, err := qrgo.NewQR(string(toWrite))
+	return printQR(toWrite)
+}
+
+// printQR outputs the data as a QR code on the terminal.
+func printQR(data []byte) error {
+	qr, err := qrgo.NewQR(string(data))
 	if err != nil {
 		return err
 	}
@@ -35,6 +35,7 @@ main(){
     run testLink
     run testLinkScenario
     run testCoin
+    run testCoinInvoice
     run testRoster
     run testCreateStoreRead
     run testAddDarc
@@ -236,6 +237,33 @@ testCoin(){
   testOK runBA mint $bc $key $keyPub 10000
 }
 
+testCoinInvoice(){
+  rm -f config/*
+  runCoBG 1 2 3
+  testOK runBA create public.toml --interval .5s
+  bc=$( echo config/bc*cfg )
+  key=$( echo config/key*cfg )
+  keyPub=$( echo $key | sed -e "s/.*key-ed25519:\(.*\).cfg/\1/" )
+  runBA key --save payer.id
+  payer=$( cat payer.id )
+  testOK runBA mint $bc $key $keyPub 10
+  testOK runBA mint $bc $key ${payer#ed25519:} 1000
+
+  testFail runBA coin invoice create --bc $bc --sign ed25519:$keyPub
+  testGrep "instance id" runBA coin invoice create --bc $bc \
+    --sign ed25519:$keyPub --amount 100 --memo coffee
+  invoice=$( grep -A 1 "instance id" "$RUNOUT" | sed -n 2p )
+  testOK runBA coin invoice create --bc $bc --sign ed25519:$keyPub \
+    --amount 2000 --memo tea
+  testGrep "coffee" runBA coin invoice list --bc $bc --sign ed25519:$keyPub --open
+  testOK runBA qr --bc $bc --invoice $invoice
+
+  testOK runBA coin invoice pay --bc $bc --sign $payer --invoice $invoice
+  testFail runBA coin invoice pay --bc $bc --sign $payer --invoice $invoice
+  testGrep "coffee" runBA coin invoice list --bc $bc --sign ed25519:$keyPub --paid
+  testNGrep "tea" runBA coin invoice list --bc $bc --sign ed25519:$keyPub --paid
+}
+
 testRoster(){
   rm -f config/*
   runCoBG 1 2 3 4
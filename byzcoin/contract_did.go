@@ -0,0 +1,331 @@
+package byzcoin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractDIDID is the ID of the DID contract, which implements the
+// 'did:byzcoin' method. Every instance holds one DID document, and the DID
+// is 'did:byzcoin:' followed by the hex encoded instance ID.
+//
+// To create a DID, spawn a DID instance with the argument "public", which is
+// the first authentication key of the document, as a marshalled Ed25519
+// point. The instance can then be invoked, with the permission of its darc,
+// with the following commands:
+//   - "rotate" replaces all authentication keys with the key in "public"
+//   - "add_key" adds the key in "public" to the authentication keys
+//   - "add_service" adds a service with the arguments "id", "type" and
+//     "endpoint". An existing service with the same id is replaced.
+//   - "deactivate" removes all keys and services. A deactivated DID cannot
+//     be changed anymore.
+//
+// A darc rule can name a DID, like 'did:byzcoin:0123...ef'. It accepts the
+// signatures of any authentication key of the DID document, as it is when the
+// instruction is executed.
+const ContractDIDID = "did"
+
+// DIDMethod is the prefix of all the DIDs stored in ByzCoin.
+const DIDMethod = "did:byzcoin:"
+
+// didContext is the JSON-LD context of all DID documents.
+const didContext = "https://www.w3.org/ns/did/v1"
+
+// ContractDIDBody is the value of a DID instance.
+type ContractDIDBody struct {
+	Document    darc.DIDDoc
+	Deactivated bool
+}
+
+type contractDID struct {
+	BasicContract
+	ContractDIDBody
+}
+
+// String returns a human readable string representation of ContractDIDBody
+func (c ContractDIDBody) String() string {
+	out := new(strings.Builder)
+	out.WriteString("- ContractDIDBody:\n")
+	fmt.Fprintf(out, "-- ID: %s\n", c.Document.ID)
+	for _, vm := range c.Document.Authentication {
+		fmt.Fprintf(out, "-- Authentication: %s\n", vm.PublicKey.ID)
+	}
+	for _, s := range c.Document.Service {
+		fmt.Fprintf(out, "-- Service: %s %s\n", s.ID, s.ServiceEndpoint)
+	}
+	fmt.Fprintf(out, "-- Deactivated: %t\n", c.Deactivated)
+
+	return out.String()
+}
+
+func contractDIDFromBytes(in []byte) (Contract, error) {
+	c := &contractDID{}
+	err := protobuf.DecodeWithConstructors(in, &c.ContractDIDBody,
+		network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding: %v", err)
+	}
+	return c, nil
+}
+
+// NewDID returns the DID of the given instance.
+func NewDID(id InstanceID) string {
+	return DIDMethod + hex.EncodeToString(id[:])
+}
+
+// DIDInstanceID returns the ID of the instance holding the document of a
+// 'did:byzcoin' DID.
+func DIDInstanceID(did string) (InstanceID, error) {
+	if !strings.HasPrefix(did, DIDMethod) {
+		return InstanceID{}, xerrors.Errorf("not a %s DID: %s", DIDMethod, did)
+	}
+	buf, err := hex.DecodeString(strings.TrimPrefix(did, DIDMethod))
+	if err != nil || len(buf) != len(InstanceID{}) {
+		return InstanceID{}, xerrors.Errorf("malformed DID: %s", did)
+	}
+	return NewInstanceID(buf), nil
+}
+
+func (c *contractDID) Spawn(rst ReadOnlyStateTrie, inst Instruction, coins []Coin) (sc []StateChange, cout []Coin, err error) {
+	cout = coins
+	if rst.GetVersion() < VersionDID {
+		return nil, nil, xerrors.New("DIDs are not supported by this chain")
+	}
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading trie: %v", err)
+	}
+
+	id := inst.DeriveID("")
+	did := NewDID(id)
+	key, err := newDIDKey(did, inst.Spawn.Args.Search("public"))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("invalid key: %v", err)
+	}
+	body := ContractDIDBody{
+		Document: darc.DIDDoc{
+			Context:        []string{didContext},
+			ID:             did,
+			PublicKey:      []darc.PublicKey{key},
+			Authentication: []darc.VerificationMethod{{PublicKey: key}},
+		},
+	}
+	buf, err := protobuf.Encode(&body)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding: %v", err)
+	}
+	sc = []StateChange{
+		NewStateChange(Create, id, ContractDIDID, buf, darcID),
+	}
+	return
+}
+
+func (c *contractDID) Invoke(rst ReadOnlyStateTrie, inst Instruction, coins []Coin) (sc []StateChange, cout []Coin, err error) {
+	cout = coins
+	if c.Deactivated {
+		return nil, nil, xerrors.New("the DID is deactivated")
+	}
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading trie: %v", err)
+	}
+
+	doc := &c.Document
+	args := inst.Invoke.Args
+	switch inst.Invoke.Command {
+	case "rotate":
+		key, err := newDIDKey(doc.ID, args.Search("public"))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid key: %v", err)
+		}
+		doc.PublicKey = []darc.PublicKey{key}
+		doc.Authentication = []darc.VerificationMethod{{PublicKey: key}}
+	case "add_key":
+		key, err := newDIDKey(doc.ID, args.Search("public"))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("invalid key: %v", err)
+		}
+		for _, pk := range doc.PublicKey {
+			if pk.ID == key.ID {
+				return nil, nil, xerrors.New("the key is already in the document")
+			}
+		}
+		doc.PublicKey = append(doc.PublicKey, key)
+		doc.Authentication = append(doc.Authentication,
+			darc.VerificationMethod{PublicKey: key})
+	case "add_service":
+		id := string(args.Search("id"))
+		endpoint := string(args.Search("endpoint"))
+		if len(id) == 0 || len(endpoint) == 0 {
+			return nil, nil, xerrors.New("id and endpoint must be given")
+		}
+		service := darc.DIDService{
+			ID:              doc.ID + "#" + id,
+			Type:            string(args.Search("type")),
+			ServiceEndpoint: endpoint,
+		}
+		services := []darc.DIDService{service}
+		for _, s := range doc.Service {
+			if s.ID != service.ID {
+				services = append(services, s)
+			}
+		}
+		doc.Service = services
+	case "deactivate":
+		doc.PublicKey = nil
+		doc.Authentication = nil
+		doc.Service = nil
+		c.Deactivated = true
+	default:
+		return nil, nil, xerrors.Errorf("invalid invoke command: %s",
+			inst.Invoke.Command)
+	}
+
+	buf, err := protobuf.Encode(&c.ContractDIDBody)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding: %v", err)
+	}
+	sc = []StateChange{
+		NewStateChange(Update, inst.InstanceID, ContractDIDID, buf, darcID),
+	}
+	return
+}
+
+func (c *contractDID) Delete(rst ReadOnlyStateTrie, inst Instruction, coins []Coin) (sc []StateChange, cout []Coin, err error) {
+	return nil, nil, xerrors.New("a DID cannot be deleted, only deactivated")
+}
+
+// newDIDKey creates the public key entry of a DID document. The key ID is
+// derived from the key, so that it stays the same across the versions of
+// the document.
+func newDIDKey(did string, public []byte) (darc.PublicKey, error) {
+	pub := cothority.Suite.Point()
+	if err := pub.UnmarshalBinary(public); err != nil {
+		return darc.PublicKey{}, xerrors.Errorf("unmarshalling key: %v", err)
+	}
+	h := sha256.Sum256(public)
+	return darc.PublicKey{
+		ID:         did + "#key-" + hex.EncodeToString(h[:8]),
+		Type:       darc.DIDKeyTypeEd25519,
+		Controller: did,
+		Value:      public,
+	}, nil
+}
+
+// loadDID reads the DID instance from the global state.
+func loadDID(rst ReadOnlyStateTrie, did string) (*ContractDIDBody, error) {
+	id, err := DIDInstanceID(did)
+	if err != nil {
+		return nil, err
+	}
+	buf, _, cid, _, err := rst.GetValues(id.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("reading trie: %v", err)
+	}
+	if cid != ContractDIDID {
+		return nil, xerrors.Errorf("%s is not a DID instance", id)
+	}
+	return decodeDID(buf)
+}
+
+func decodeDID(buf []byte) (*ContractDIDBody, error) {
+	body := &ContractDIDBody{}
+	err := protobuf.DecodeWithConstructors(buf, body,
+		network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("decoding: %v", err)
+	}
+	return body, nil
+}
+
+// resolveDIDs returns a copy of the identities where the documents of the DID
+// identities are read from the global state. A document sent along with the
+// identity is never trusted, so DIDs that cannot be resolved, or that are
+// deactivated, fail to verify any signature.
+func resolveDIDs(rst ReadOnlyStateTrie, ids []darc.Identity) []darc.Identity {
+	resolved := make([]darc.Identity, len(ids))
+	for i, id := range ids {
+		resolved[i] = id
+		if id.DID == nil {
+			continue
+		}
+		did := *id.DID
+		did.DIDDoc = nil
+		if rst.GetVersion() >= VersionDID {
+			body, err := loadDID(rst, did.DID)
+			if err == nil && !body.Deactivated {
+				did.DIDDoc = &body.Document
+			}
+		}
+		resolved[i].DID = &did
+	}
+	return resolved
+}
+
+// ResolveDID returns the document of a 'did:byzcoin' DID. If BlockIndex is
+// given, the document is returned as it was after this block, using the
+// instance versions stored by the node.
+func (s *Service) ResolveDID(req *ResolveDID) (*ResolveDIDResponse, error) {
+	id, err := DIDInstanceID(req.DID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sc StateChange
+	var index int
+	if req.BlockIndex <= 0 {
+		st, err := s.GetReadOnlyStateTrie(req.SkipChainID)
+		if err != nil {
+			return nil, xerrors.Errorf("getting trie: %v", err)
+		}
+		buf, version, cid, _, err := st.GetValues(id.Slice())
+		if err != nil {
+			return nil, xerrors.Errorf("reading trie: %v", err)
+		}
+		sc = StateChange{ContractID: cid, Value: buf, Version: version}
+		index = st.GetIndex()
+	} else {
+		entries, err := s.stateChangeStorage.getAll(id[:], req.SkipChainID)
+		if err != nil {
+			return nil, xerrors.Errorf("getting state changes: %v", err)
+		}
+		found := false
+		for _, e := range entries {
+			if e.BlockIndex <= req.BlockIndex {
+				sc = e.StateChange
+				index = e.BlockIndex
+				found = true
+			}
+		}
+		if !found {
+			return nil, xerrors.Errorf("%s doesn't exist at block %d",
+				req.DID, req.BlockIndex)
+		}
+	}
+	if sc.ContractID != ContractDIDID {
+		return nil, xerrors.Errorf("%s is not a DID instance", id)
+	}
+
+	body, err := decodeDID(sc.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &ResolveDIDResponse{
+		Document:    body.Document,
+		Deactivated: body.Deactivated,
+		Version:     sc.Version,
+		BlockIndex:  index,
+	}, nil
+}
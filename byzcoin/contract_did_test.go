@@ -0,0 +1,181 @@
+package byzcoin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3/log"
+)
+
+// TestContractDID creates a DID, uses it in a darc rule, and makes sure that
+// only the current authentication keys are accepted.
+func TestContractDID(t *testing.T) {
+	b := newBCT(t, nil)
+	defer b.CloseAll()
+	b.AddGenesisRules("spawn:"+ContractDIDID,
+		"invoke:"+ContractDIDID+".rotate",
+		"invoke:"+ContractDIDID+".add_service",
+		"invoke:"+ContractDIDID+".deactivate")
+	b.CreateByzCoin()
+
+	log.Lvl1("spawn a DID")
+	kp1 := key.NewKeyPair(cothority.Suite)
+	pub1, err := kp1.Public.MarshalBinary()
+	require.NoError(t, err)
+	ctx, _ := b.SendInst(nil, Instruction{
+		InstanceID: NewInstanceID(b.GenesisDarc.GetBaseID()),
+		Spawn: &Spawn{
+			ContractID: ContractDIDID,
+			Args:       Arguments{{Name: "public", Value: pub1}},
+		},
+	})
+	didID := ctx.Instructions[0].DeriveID("")
+	did := NewDID(didID)
+	id, err := DIDInstanceID(did)
+	require.NoError(t, err)
+	require.Equal(t, didID, id)
+
+	res, err := b.Client.ResolveDID(did, 0)
+	require.NoError(t, err)
+	require.Equal(t, did, res.Document.ID)
+	require.Len(t, res.Document.Authentication, 1)
+	require.Equal(t, pub1, res.Document.Authentication[0].PublicKey.Value)
+	createdAt := res.BlockIndex
+
+	log.Lvl1("spawn a darc that can be used by the DID")
+	didDarc := darc.NewDarc(darc.InitRules(
+		[]darc.Identity{b.Signer.Identity()},
+		[]darc.Identity{b.Signer.Identity()}), []byte("did"))
+	require.NoError(t, didDarc.Rules.AddRule("spawn:"+DummyContractName,
+		[]byte(did)))
+	didDarcBuf, err := didDarc.ToProto()
+	require.NoError(t, err)
+	b.SendInst(nil, Instruction{
+		InstanceID: NewInstanceID(b.GenesisDarc.GetBaseID()),
+		Spawn: &Spawn{
+			ContractID: ContractDarcID,
+			Args:       Arguments{{Name: "darc", Value: didDarcBuf}},
+		},
+	})
+
+	counter := uint64(1)
+	spawnWithDID := func(signer darc.Signer) AddTxResponse {
+		inst := Instruction{
+			InstanceID: NewInstanceID(didDarc.GetBaseID()),
+			Spawn: &Spawn{
+				ContractID: DummyContractName,
+				Args:       Arguments{{Name: "data", Value: []byte("did")}},
+			},
+			SignerIdentities: []darc.Identity{signer.Identity()},
+			SignerCounter:    []uint64{counter},
+		}
+		ctx := NewClientTransaction(CurrentVersion, inst)
+		require.NoError(t, ctx.Instructions[0].SignWith(
+			ctx.Instructions.Hash(), signer))
+		resp := b.SendTx(&TxArgs{Wait: 10, WaitPropagation: true}, ctx)
+		if len(resp.Error) == 0 {
+			counter++
+		}
+		return resp
+	}
+
+	signer1, err := darc.NewSignerDID(did, kp1.Public, kp1.Private)
+	require.NoError(t, err)
+	require.Empty(t, spawnWithDID(signer1).Error)
+
+	log.Lvl1("a key that is not in the document is refused")
+	kp2 := key.NewKeyPair(cothority.Suite)
+	signer2, err := darc.NewSignerDID(did, kp2.Public, kp2.Private)
+	require.NoError(t, err)
+	require.NotEmpty(t, spawnWithDID(signer2).Error)
+
+	log.Lvl1("a document sent by the signer is not trusted")
+	forged := signer2.Identity()
+	forged.DID.DIDDoc = &darc.DIDDoc{ID: did, Authentication: []darc.VerificationMethod{{
+		PublicKey: darc.PublicKey{Type: darc.DIDKeyTypeEd25519, Value: pub1}}}}
+	inst := Instruction{
+		InstanceID: NewInstanceID(didDarc.GetBaseID()),
+		Spawn: &Spawn{
+			ContractID: DummyContractName,
+			Args:       Arguments{{Name: "data", Value: []byte("forged")}},
+		},
+		SignerIdentities: []darc.Identity{forged},
+		SignerCounter:    []uint64{counter},
+	}
+	fctx := NewClientTransaction(CurrentVersion, inst)
+	require.NoError(t, fctx.Instructions[0].SignWith(fctx.Instructions.Hash(),
+		signer2))
+	require.NotEmpty(t, b.SendTx(&TxArgs{Wait: 10, WaitPropagation: true},
+		fctx).Error)
+
+	log.Lvl1("rotate the key and add a service")
+	pub2, err := kp2.Public.MarshalBinary()
+	require.NoError(t, err)
+	b.SendInst(nil, Instruction{
+		InstanceID: didID,
+		Invoke: &Invoke{
+			ContractID: ContractDIDID,
+			Command:    "rotate",
+			Args:       Arguments{{Name: "public", Value: pub2}},
+		},
+	}, Instruction{
+		InstanceID: didID,
+		Invoke: &Invoke{
+			ContractID: ContractDIDID,
+			Command:    "add_service",
+			Args: Arguments{
+				{Name: "id", Value: []byte("hub")},
+				{Name: "type", Value: []byte("IdentityHub")},
+				{Name: "endpoint", Value: []byte("https://hub.example.com")},
+			},
+		},
+	})
+	require.NotEmpty(t, spawnWithDID(signer1).Error)
+	require.Empty(t, spawnWithDID(signer2).Error)
+
+	res, err = b.Client.ResolveDID(did, 0)
+	require.NoError(t, err)
+	require.Len(t, res.Document.Authentication, 1)
+	require.Equal(t, pub2, res.Document.Authentication[0].PublicKey.Value)
+	require.Len(t, res.Document.Service, 1)
+	require.Equal(t, did+"#hub", res.Document.Service[0].ID)
+	require.Equal(t, uint64(2), res.Version)
+
+	log.Lvl1("resolve the DID as of an earlier block")
+	res, err = b.Client.ResolveDID(did, createdAt)
+	require.NoError(t, err)
+	require.Equal(t, pub1, res.Document.Authentication[0].PublicKey.Value)
+	require.Equal(t, uint64(0), res.Version)
+
+	log.Lvl1("deactivate the DID")
+	b.SendInst(nil, Instruction{
+		InstanceID: didID,
+		Invoke: &Invoke{
+			ContractID: ContractDIDID,
+			Command:    "deactivate",
+		},
+	})
+	require.NotEmpty(t, spawnWithDID(signer2).Error)
+	res, err = b.Client.ResolveDID(did, 0)
+	require.NoError(t, err)
+	require.True(t, res.Deactivated)
+	require.Empty(t, res.Document.Authentication)
+
+	_, resp := b.SendInst(&TxArgs{Wait: 10, WaitPropagation: true}, Instruction{
+		InstanceID: didID,
+		Invoke: &Invoke{
+			ContractID: ContractDIDID,
+			Command:    "rotate",
+			Args:       Arguments{{Name: "public", Value: pub1}},
+		},
+	})
+	require.Contains(t, resp.Error, "deactivated")
+
+	_, err = b.Client.ResolveDID(NewDID(NewInstanceID(b.GenesisDarc.GetBaseID())), 0)
+	require.Error(t, err)
+	_, err = b.Client.ResolveDID("did:example:123", 0)
+	require.Error(t, err)
+}
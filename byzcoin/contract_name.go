@@ -129,9 +129,10 @@ func (c *contractNaming) VerifyInstruction(rst ReadOnlyStateTrie, inst Instructi
 
 	// Save the identities that provide good signatures.
 	goodIdentities := make([]string, 0)
+	signers := resolveDIDs(rst, inst.SignerIdentities)
 	for i := range inst.Signatures {
-		if err := inst.SignerIdentities[i].Verify(msg, inst.Signatures[i]); err == nil {
-			goodIdentities = append(goodIdentities, inst.SignerIdentities[i].String())
+		if err := signers[i].Verify(msg, inst.Signatures[i]); err == nil {
+			goodIdentities = append(goodIdentities, signers[i].String())
 		}
 	}
 	if len(goodIdentities) == 0 {
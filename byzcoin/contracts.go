@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
-	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -236,48 +235,20 @@ func (b BasicContract) VerifyDeferredInstruction(rst ReadOnlyStateTrie, inst Ins
 	return notImpl("VerifyDeferredInstruction")
 }
 
-// MakeAttrInterpreters provides one default attribute verification which check
-// whether the transaction is sent after a certain block index and before
-// another block index.
+// MakeAttrInterpreters provides the default attribute verifications, which
+// check whether the transaction is sent after a certain block index and
+// before another block index (darc.AttrBlockIndex), or in an interval of
+// block times (darc.AttrBlockTime).
 func (b BasicContract) MakeAttrInterpreters(rst ReadOnlyStateTrie, inst Instruction) darc.AttrInterpreters {
-	cb := func(attr string) error {
-		vals, err := url.ParseQuery(attr)
-		if err != nil {
-			return xerrors.Errorf("parsing query: %v", err)
-		}
-		beforeStr := vals.Get("before")
-		afterStr := vals.Get("after")
-
-		var before, after int
-
-		if len(beforeStr) == 0 {
-			// Set before to something higher than the current
-			// index so that it always passes.
-			before = rst.GetIndex() + 1
-		} else {
-			var err error
-			before, err = strconv.Atoi(beforeStr)
-			if err != nil {
-				return xerrors.Errorf("atoi: %v", err)
-			}
-		}
-
-		if len(afterStr) == 0 {
-			after = -1
-		} else {
-			var err error
-			after, err = strconv.Atoi(afterStr)
-			if err != nil {
-				return xerrors.Errorf("atoi: %v", err)
-			}
-		}
-
-		if after < rst.GetIndex() && rst.GetIndex() < before {
-			return nil
-		}
-		return xerrors.Errorf("the current block index is %d which does not fit in the interval (%d, %d)", rst.GetIndex(), after, before)
+	attrs := darc.AttrInterpreters{
+		darc.AttrBlockIndex: darc.NewBlockIndexAttr(rst.GetIndex()),
 	}
-	return darc.AttrInterpreters{"block": cb}
+	// The block time is only known if we are called with a GlobalState.
+	if tr, ok := rst.(TimeReader); ok {
+		attrs[darc.AttrBlockTime] = darc.NewBlockTimeAttr(
+			time.Unix(0, tr.GetCurrentBlockTimestamp()))
+	}
+	return attrs
 }
 
 // Spawn is not implmented in a BasicContract. Types which embed BasicContract
@@ -471,6 +442,7 @@ func (c *contractConfig) Spawn(rst ReadOnlyStateTrie, inst Instruction, coins []
 // Invoke offers the following functions:
 //   - Invoke:update_config
 //   - Invoke:view_change
+//   - Invoke:rotate_leader
 //
 // Invoke:update_config should have the following input argument:
 //   - config ChainConfig
@@ -478,6 +450,9 @@ func (c *contractConfig) Spawn(rst ReadOnlyStateTrie, inst Instruction, coins []
 // Invoke:view_change sould have the following input arguments:
 //   - newview viewchange.NewViewReq
 //   - multisig []byte
+//
+// Invoke:rotate_leader has no arguments, the new roster is computed from the
+// leader schedule in the ChainConfig.
 func (c *contractConfig) Invoke(rst ReadOnlyStateTrie, inst Instruction, coins []Coin) ([]StateChange, []Coin, error) {
 	// Find the darcID for this instance.
 	var darcID darc.ID
@@ -492,6 +467,8 @@ func (c *contractConfig) Invoke(rst ReadOnlyStateTrie, inst Instruction, coins [
 	// 2. During a view-change. In this case, we need to do additional
 	//    validation to make sure a malicious node doesn't freely change the
 	//    roster.
+	// 3. During a leader rotation. In this case, the new roster is
+	//    computed from the schedule in the config.
 
 	switch inst.Invoke.Command {
 	case "update_config":
@@ -525,6 +502,17 @@ func (c *contractConfig) Invoke(rst ReadOnlyStateTrie, inst Instruction, coins [
 			rules = append(rules, "ed25519:"+p.String())
 		}
 		genesisDarc.Rules.UpdateRule("invoke:"+ContractConfigID+".view_change", expression.InitOrExpr(rules...))
+		if newConfig.LeaderRotation > 0 {
+			rotate := darc.Action("invoke:" + ContractConfigID + ".rotate_leader")
+			if genesisDarc.Rules.Contains(rotate) {
+				err = genesisDarc.Rules.UpdateRule(rotate, expression.InitOrExpr(rules...))
+			} else {
+				err = genesisDarc.Rules.AddRule(rotate, expression.InitOrExpr(rules...))
+			}
+			if err != nil {
+				return nil, nil, xerrors.Errorf("updating rotation rule: %v", err)
+			}
+		}
 		var genesisBuf []byte
 		genesisBuf, err = genesisDarc.ToProto()
 		if err != nil {
@@ -574,6 +562,27 @@ func (c *contractConfig) Invoke(rst ReadOnlyStateTrie, inst Instruction, coins [
 
 		sc, err := updateRosterScs(rst, darcID, req.Roster)
 		return sc, coins, cothority.ErrorOrNil(err, "roster scs")
+	case "rotate_leader":
+		if rst.GetVersion() < VersionLeaderRotation {
+			return nil, nil, xerrors.New("invalid invoke command: " + inst.Invoke.Command)
+		}
+		config, err := rst.LoadConfig()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("reading trie: %v", err)
+		}
+		// The instruction is executed for the block following the
+		// latest block in the trie.
+		index := rst.GetIndex() + 1
+		if !config.rotationDue(index) {
+			return nil, nil, xerrors.Errorf("no leader rotation due for block %d", index)
+		}
+		genesis, err := rst.(ReadOnlySkipChain).GetGenesisBlock()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't get genesis block: %v", err)
+		}
+		sc, err := updateRosterScs(rst, darcID,
+			*config.rotatedRoster(genesis.SkipChainID(), index))
+		return sc, coins, cothority.ErrorOrNil(err, "roster scs")
 	default:
 		return nil, nil, xerrors.New("invalid invoke command: " + inst.Invoke.Command)
 	}
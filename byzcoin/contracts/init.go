@@ -18,4 +18,8 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err)
 	}
+	err = byzcoin.RegisterGlobalContract(ContractInvoiceID, contractInvoiceFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
 }
@@ -0,0 +1,227 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractInvoiceID denotes a contract that requests a payment of coins.
+const ContractInvoiceID = "invoice"
+
+// ContractInvoice is a request for a payment to a coin instance. It must be
+// spawned on the darc of the receiving coin, with the following arguments:
+//  - coin is the instance ID of the receiving coin
+//  - amount is the number of coins to pay. It must be a 64-bit uint in
+//    LittleEndian
+//  - index is the position of the invoice among the invoices of the coin.
+//    It must be a 64-bit uint in LittleEndian, and the invoice with the
+//    previous index must exist, so that the invoices of a coin can be listed
+//    with InvoiceID
+//  - memo is optional and describes the payment
+//  - expiry is optional and holds the time, in nanoseconds since the epoch,
+//    after which the invoice cannot be paid anymore. It must be a 64-bit
+//    int in LittleEndian
+// The instance ID of the invoice is InvoiceID(coin, index).
+// The following method is available:
+//  - pay takes the amount of coins from the coins given to the instruction,
+//    usually by a "fetch" of the payer in the same transaction, and stores
+//    them in the receiving coin. Anybody can pay an invoice, but only once.
+
+func contractInvoiceFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &contractInvoice{}
+	err := protobuf.Decode(in, &c.Invoice)
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal instance data: %v", err)
+	}
+	return c, nil
+}
+
+type contractInvoice struct {
+	byzcoin.BasicContract
+	byzcoin.Invoice
+}
+
+// InvoiceID returns the instance ID of the invoice at the given index among
+// the invoices of the coin.
+func InvoiceID(coin byzcoin.InstanceID, index uint64) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write([]byte(ContractInvoiceID))
+	h.Write(coin.Slice())
+	indexBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(indexBuf, index)
+	h.Write(indexBuf)
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// VerifyInstruction doesn't check the darc for the "pay" command, as the
+// coins are given by the previous instructions of the transaction.
+func (c *contractInvoice) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, ctxHash []byte) error {
+	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "pay" {
+		return nil
+	}
+	return c.BasicContract.VerifyInstruction(rst, inst, ctxHash)
+}
+
+func (c *contractInvoice) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	c.Coin = byzcoin.NewInstanceID(inst.Spawn.Args.Search("coin"))
+	_, _, cid, coinDarcID, err := rst.GetValues(c.Coin.Slice())
+	if err != nil || cid != ContractCoinID {
+		return nil, nil, xerrors.New("argument \"coin\" is not a coin instance")
+	}
+	if !coinDarcID.Equal(darcID) {
+		return nil, nil, xerrors.New("invoice must be spawned on the darc of the coin")
+	}
+	if c.Amount, err = uint64Arg(inst.Spawn.Args, "amount"); err != nil {
+		return
+	}
+	if c.Amount == 0 {
+		return nil, nil, xerrors.New("amount of invoice must be positive")
+	}
+	if c.Index, err = uint64Arg(inst.Spawn.Args, "index"); err != nil {
+		return
+	}
+	c.Memo = string(inst.Spawn.Args.Search("memo"))
+	if inst.Spawn.Args.Search("expiry") != nil {
+		var expiry uint64
+		if expiry, err = uint64Arg(inst.Spawn.Args, "expiry"); err != nil {
+			return
+		}
+		c.Expiry = int64(expiry)
+		if tr, ok := rst.(byzcoin.TimeReader); ok &&
+			c.Expiry <= tr.GetCurrentBlockTimestamp() {
+			return nil, nil, xerrors.New("invoice is already expired")
+		}
+	}
+
+	id := InvoiceID(c.Coin, c.Index)
+	if invoiceExists(rst, id) {
+		return nil, nil, xerrors.New("invoice with this index already exists")
+	}
+	if c.Index > 0 && !invoiceExists(rst, InvoiceID(c.Coin, c.Index-1)) {
+		return nil, nil, xerrors.New("invoice with the previous index doesn't exist")
+	}
+
+	var invBuf []byte
+	invBuf, err = protobuf.Encode(&c.Invoice)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode invoice: %v", err)
+	}
+	log.Lvlf2("Spawning invoice %x of %d coins to %x", id.Slice(), c.Amount,
+		c.Coin.Slice())
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, id, ContractInvoiceID, invBuf, darcID),
+	}
+	return
+}
+
+func (c *contractInvoice) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	if inst.Invoke.Command != "pay" {
+		return nil, nil, xerrors.New("invoice contract can only be paid")
+	}
+	if c.Paid {
+		return nil, nil, xerrors.New("invoice is already paid")
+	}
+	if c.Expiry != 0 {
+		tr, ok := rst.(byzcoin.TimeReader)
+		if !ok {
+			return nil, nil, xerrors.New("time of the block is not available")
+		}
+		if tr.GetCurrentBlockTimestamp() > c.Expiry {
+			return nil, nil, xerrors.New("invoice is expired")
+		}
+	}
+
+	coinBuf, _, cid, coinDarcID, err := rst.GetValues(c.Coin.Slice())
+	if err == nil && cid != ContractCoinID {
+		err = xerrors.New("receiving instance is not a coin contract")
+	}
+	if err != nil {
+		return
+	}
+	var coin byzcoin.Coin
+	err = protobuf.Decode(coinBuf, &coin)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't unmarshal receiving coin: %v", err)
+	}
+
+	// Take the amount out of the coins of the right type, and pass the
+	// remaining coins on to the next instruction.
+	missing := c.Amount
+	cout = []byzcoin.Coin{}
+	for _, co := range coins {
+		if missing > 0 && coin.Name.Equal(co.Name) {
+			pay := co.Value
+			if pay > missing {
+				pay = missing
+			}
+			co.Value -= pay
+			missing -= pay
+		}
+		if co.Value > 0 {
+			cout = append(cout, co)
+		}
+	}
+	if missing > 0 {
+		return nil, nil, xerrors.Errorf("missing %d coins to pay the invoice", missing)
+	}
+	err = coin.SafeAdd(c.Amount)
+	if err != nil {
+		return
+	}
+	c.Paid = true
+
+	coinBuf, err = protobuf.Encode(&coin)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't marshal receiving coin: %v", err)
+	}
+	invBuf, err := protobuf.Encode(&c.Invoice)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode invoice: %v", err)
+	}
+	log.Lvlf2("paying %d to %x", c.Amount, c.Coin.Slice())
+	sc = byzcoin.StateChanges{
+		byzcoin.NewStateChange(byzcoin.Update, c.Coin, ContractCoinID, coinBuf, coinDarcID),
+		byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractInvoiceID, invBuf, darcID),
+	}
+	return
+}
+
+// invoiceExists returns whether an invoice is stored in the instance.
+func invoiceExists(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) bool {
+	_, _, cid, _, err := rst.GetValues(id.Slice())
+	return err == nil && cid == ContractInvoiceID
+}
+
+// uint64Arg returns the argument as a 64-bit uint in LittleEndian.
+func uint64Arg(args byzcoin.Arguments, name string) (uint64, error) {
+	buf := args.Search(name)
+	if buf == nil {
+		return 0, xerrors.Errorf("argument \"%s\" is missing", name)
+	}
+	if len(buf) != 8 {
+		return 0, xerrors.Errorf("argument \"%s\" is wrong length", name)
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
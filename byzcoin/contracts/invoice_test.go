@@ -0,0 +1,129 @@
+package contracts
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/protobuf"
+)
+
+// rostTime adds the time of the block to the simulated trie.
+type rostTime struct {
+	*byzcoin.ROSTSimul
+	now int64
+}
+
+func (rt *rostTime) GetCurrentBlockTimestamp() int64 {
+	return rt.now
+}
+
+func TestInvoice(t *testing.T) {
+	rost := &rostTime{ROSTSimul: byzcoin.NewROSTSimul(), now: 1000}
+	// Darcs are stored with their own ID, as in ByzCoin.
+	newDarc := func(desc string) *darc.Darc {
+		d, err := rost.CreateBasicDarc(nil, desc)
+		require.NoError(t, err)
+		require.NoError(t, rost.CreateSCB(byzcoin.Update, byzcoin.ContractDarcID,
+			byzcoin.NewInstanceID(d.GetBaseID()), d, d.GetBaseID()))
+		return d
+	}
+	d, other := newDarc("payee"), newDarc("other")
+	payee, err := rost.CreateRandomInstance(ContractCoinID,
+		&byzcoin.Coin{Name: CoinName}, d.GetBaseID())
+	require.NoError(t, err)
+
+	u64 := func(v uint64) []byte {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, v)
+		return buf
+	}
+	spawn := func(darcID []byte, amount, index uint64, args ...byzcoin.Argument) error {
+		inst := byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(darcID),
+			Spawn: &byzcoin.Spawn{
+				ContractID: ContractInvoiceID,
+				Args: append(byzcoin.Arguments{
+					{Name: "coin", Value: payee.Slice()},
+					{Name: "amount", Value: u64(amount)},
+					{Name: "index", Value: u64(index)},
+				}, args...),
+			},
+		}
+		c, err := contractInvoiceFromBytes(nil)
+		require.NoError(t, err)
+		sc, _, err := c.Spawn(rost, inst, nil)
+		if err != nil {
+			return err
+		}
+		require.Equal(t, InvoiceID(payee, index).Slice(), sc[0].InstanceID)
+		_, err = rost.StoreAllToReplica(sc)
+		return err
+	}
+	pay := func(index uint64, coins ...byzcoin.Coin) ([]byzcoin.Coin, error) {
+		id := InvoiceID(payee, index)
+		buf, _, _, _, err := rost.GetValues(id.Slice())
+		require.NoError(t, err)
+		c, err := contractInvoiceFromBytes(buf)
+		require.NoError(t, err)
+		inst := byzcoin.Instruction{
+			InstanceID: id,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractInvoiceID,
+				Command:    "pay",
+			},
+		}
+		require.NoError(t, c.VerifyInstruction(rost, inst, nil))
+		sc, cout, err := c.Invoke(rost, inst, coins)
+		if err != nil {
+			return nil, err
+		}
+		_, err = rost.StoreAllToReplica(sc)
+		return cout, err
+	}
+	invoice := func(index uint64) (inv byzcoin.Invoice) {
+		buf, _, _, _, err := rost.GetValues(InvoiceID(payee, index).Slice())
+		require.NoError(t, err)
+		require.NoError(t, protobuf.Decode(buf, &inv))
+		return
+	}
+
+	require.Error(t, spawn(other.GetBaseID(), 10, 0), "not the darc of the coin")
+	require.Error(t, spawn(d.GetBaseID(), 0, 0), "no amount")
+	require.Error(t, spawn(d.GetBaseID(), 10, 1), "no previous invoice")
+	require.Error(t, spawn(d.GetBaseID(), 10, 0,
+		byzcoin.Argument{Name: "expiry", Value: u64(1000)}), "expired")
+	require.NoError(t, spawn(d.GetBaseID(), 10, 0,
+		byzcoin.Argument{Name: "memo", Value: []byte("coffee")}))
+	require.Error(t, spawn(d.GetBaseID(), 10, 0), "index already used")
+	require.NoError(t, spawn(d.GetBaseID(), 20, 1,
+		byzcoin.Argument{Name: "expiry", Value: u64(2000)}))
+	require.Equal(t, "coffee", invoice(0).Memo)
+	require.Equal(t, int64(2000), invoice(1).Expiry)
+
+	other1 := byzcoin.Coin{Name: byzcoin.NewInstanceID([]byte("other")), Value: 100}
+	_, err = pay(0, other1, byzcoin.Coin{Name: CoinName, Value: 9})
+	require.Error(t, err, "not enough coins")
+	cout, err := pay(0, other1, byzcoin.Coin{Name: CoinName, Value: 4},
+		byzcoin.Coin{Name: CoinName, Value: 8})
+	require.NoError(t, err)
+	require.Equal(t, []byzcoin.Coin{other1, {Name: CoinName, Value: 2}}, cout)
+	require.True(t, invoice(0).Paid)
+	coin, err := rost.GetCoin(payee)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), coin.Value)
+	_, err = pay(0, byzcoin.Coin{Name: CoinName, Value: 10})
+	require.Error(t, err, "already paid")
+
+	rost.now = 2001
+	_, err = pay(1, byzcoin.Coin{Name: CoinName, Value: 20})
+	require.Error(t, err, "expired")
+	rost.now = 2000
+	_, err = pay(1, byzcoin.Coin{Name: CoinName, Value: 20})
+	require.NoError(t, err)
+	coin, err = rost.GetCoin(payee)
+	require.NoError(t, err)
+	require.Equal(t, uint64(30), coin.Value)
+}
@@ -0,0 +1,193 @@
+package byzcoin
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+// LeaderSchedule defines how the next leader is chosen when the leader is
+// rotated every ChainConfig.LeaderRotation blocks.
+type LeaderSchedule int
+
+const (
+	// LeaderScheduleRoundRobin passes the leadership to the next node in
+	// the roster.
+	LeaderScheduleRoundRobin LeaderSchedule = iota
+	// LeaderScheduleRandom passes the leadership to a node that is chosen
+	// from the hash of the skipchain-ID and the index of the block doing
+	// the rotation. This schedule is known in advance,
+	// but cannot be biased by the current leader.
+	LeaderScheduleRandom
+)
+
+// String returns the name of the schedule, as it is used in bcadmin.
+func (ls LeaderSchedule) String() string {
+	switch ls {
+	case LeaderScheduleRoundRobin:
+		return "roundrobin"
+	case LeaderScheduleRandom:
+		return "random"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseLeaderSchedule returns the schedule corresponding to the given name.
+func ParseLeaderSchedule(name string) (LeaderSchedule, error) {
+	switch strings.ToLower(name) {
+	case "roundrobin", "":
+		return LeaderScheduleRoundRobin, nil
+	case "random":
+		return LeaderScheduleRandom, nil
+	default:
+		return 0, xerrors.Errorf("unknown leader schedule: %s", name)
+	}
+}
+
+// rotationDue returns true if the block with the given index must pass the
+// leadership to the next scheduled leader.
+func (c ChainConfig) rotationDue(index int) bool {
+	return c.LeaderRotation > 0 && index > 0 && index%c.LeaderRotation == 0
+}
+
+// nextLeader returns the index in the current roster of the node that becomes
+// the leader with the block at the given index.
+// As the current leader is always at index 0,
+// the returned value is between 1 and len(c.Roster.List)-1.
+func (c ChainConfig) nextLeader(scID skipchain.SkipBlockID, index int) int {
+	n := len(c.Roster.List)
+	if n < 2 {
+		return 0
+	}
+	switch c.LeaderSchedule {
+	case LeaderScheduleRandom:
+		h := sha256.New()
+		h.Write(scID)
+		binary.Write(h, binary.LittleEndian, int64(index))
+		seed := binary.LittleEndian.Uint64(h.Sum(nil))
+		return 1 + int(seed%uint64(n-1))
+	default:
+		return 1
+	}
+}
+
+// rotatedRoster returns the roster for the block at the given index,
+// with the next scheduled leader as the first node.
+func (c ChainConfig) rotatedRoster(scID skipchain.SkipBlockID,
+	index int) *onet.Roster {
+	return rotateRoster(&c.Roster, c.nextLeader(scID, index))
+}
+
+// isLeaderRotationTx returns true if the given transactions hold an accepted
+// leader rotation.
+func isLeaderRotationTx(txs TxResults) bool {
+	if len(txs) != 1 || !txs[0].Accepted {
+		// leader rotation block must only have one accepted transaction
+		return false
+	}
+	if len(txs[0].ClientTransaction.Instructions) != 1 {
+		// leader rotation transaction must have one instruction
+		return false
+	}
+
+	invoke := txs[0].ClientTransaction.Instructions[0].Invoke
+	return invoke != nil && invoke.ContractID == ContractConfigID &&
+		invoke.Command == "rotate_leader"
+}
+
+// checkLeaderRotation makes sure that a block at a rotation index passes
+// the leadership to the scheduled node.
+// A view-change is also accepted at this index,
+// as it replaces the leader anyway.
+// This must be called before the state of the new block is stored.
+func (s *Service) checkLeaderRotation(newSB *skipchain.SkipBlock,
+	txs TxResults) error {
+	config, err := s.LoadConfig(newSB.SkipChainID())
+	if err != nil {
+		return xerrors.Errorf("loading config: %v", err)
+	}
+	if !config.rotationDue(newSB.Index) {
+		return nil
+	}
+	if isViewChangeTx(txs) != nil {
+		return nil
+	}
+	if !isLeaderRotationTx(txs) {
+		return xerrors.Errorf("block %d must rotate the leader", newSB.Index)
+	}
+	equal, err := config.rotatedRoster(newSB.SkipChainID(),
+		newSB.Index).Equal(newSB.Roster)
+	if err != nil {
+		return xerrors.Errorf("comparing rosters: %v", err)
+	}
+	if !equal {
+		return xerrors.New("roster doesn't follow the leader schedule")
+	}
+	return nil
+}
+
+// createLeaderRotationBlock creates a new block that passes the leadership to
+// the next scheduled leader.
+// The block is sent to the new leader, who will store it.
+func (s *Service) createLeaderRotationBlock(scID skipchain.SkipBlockID) error {
+	defer log.Lvl2(s.ServerIdentity(), "created leader rotation block")
+	sb, err := s.db().GetLatestByID(scID)
+	if err != nil {
+		return xerrors.Errorf("getting latest: %v", err)
+	}
+	header, err := decodeBlockHeader(sb)
+	if err != nil {
+		return xerrors.Errorf("decoding header: %v", err)
+	}
+	if header.Version < VersionLeaderRotation {
+		return xerrors.New("leader rotation is not supported by this version")
+	}
+	config, err := s.LoadConfig(scID)
+	if err != nil {
+		return xerrors.Errorf("loading config: %v", err)
+	}
+	if !config.rotationDue(sb.Index + 1) {
+		return xerrors.Errorf("no leader rotation due for block %d",
+			sb.Index+1)
+	}
+
+	signer := darc.NewSignerEd25519(s.ServerIdentity().Public, s.getPrivateKey())
+	st, err := s.GetReadOnlyStateTrie(scID)
+	if err != nil {
+		return xerrors.Errorf("getting trie: %v", err)
+	}
+	ctr, err := getSignerCounter(st, signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("getting counter: %v", err)
+	}
+
+	ctx := ClientTransaction{
+		Instructions: []Instruction{{
+			InstanceID: NewInstanceID(nil),
+			Invoke: &Invoke{
+				ContractID: ContractConfigID,
+				Command:    "rotate_leader",
+			},
+			SignerIdentities: []darc.Identity{signer.Identity()},
+			SignerCounter:    []uint64{ctr + 1},
+		}},
+	}
+	ctx.Instructions.SetVersion(header.Version)
+	if err = ctx.Instructions[0].SignWith(ctx.Instructions.Hash(), signer); err != nil {
+		return xerrors.Errorf("signing tx: %v", err)
+	}
+
+	newRoster := config.rotatedRoster(scID, sb.Index+1)
+	log.Lvlf2("%s: passing leadership to %s", s.ServerIdentity(),
+		newRoster.List[0])
+	_, err = s.createNewBlock(scID, newRoster, []TxResult{{ctx, false}})
+	return cothority.ErrorOrNil(err, "creating block")
+}
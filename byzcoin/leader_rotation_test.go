@@ -0,0 +1,126 @@
+package byzcoin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+)
+
+func TestChainConfig_LeaderSchedule(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(5, false)
+	scID := []byte("some skipchain")
+
+	config := ChainConfig{Roster: *roster}
+	for i := 0; i < 10; i++ {
+		require.False(t, config.rotationDue(i))
+	}
+
+	config.LeaderRotation = 3
+	require.False(t, config.rotationDue(0))
+	require.False(t, config.rotationDue(2))
+	require.True(t, config.rotationDue(3))
+	require.False(t, config.rotationDue(4))
+	require.True(t, config.rotationDue(9))
+
+	require.Equal(t, 1, config.nextLeader(scID, 3))
+	require.True(t, config.rotatedRoster(scID, 3).List[0].Equal(roster.List[1]))
+
+	config.LeaderSchedule = LeaderScheduleRandom
+	leaders := make(map[int]bool)
+	for i := 3; i < 300; i += 3 {
+		next := config.nextLeader(scID, i)
+		require.True(t, next > 0 && next < len(roster.List))
+		require.Equal(t, next, config.nextLeader(scID, i))
+		leaders[next] = true
+	}
+	require.Equal(t, len(roster.List)-1, len(leaders))
+
+	for _, name := range []string{"roundrobin", "random"} {
+		ls, err := ParseLeaderSchedule(name)
+		require.NoError(t, err)
+		require.Equal(t, name, ls.String())
+	}
+	_, err := ParseLeaderSchedule("byzantine")
+	require.Error(t, err)
+}
+
+func TestChainConfig_LeaderRotationSanity(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(4, false)
+
+	config := ChainConfig{
+		BlockInterval:  defaultInterval,
+		Roster:         *roster,
+		MaxBlockSize:   defaultMaxBlockSize,
+		LeaderRotation: 10,
+	}
+	require.NoError(t, config.sanityCheck(nil, VersionLeaderRotation))
+	require.Error(t, config.sanityCheck(nil, VersionRosterCheck))
+
+	config.LeaderRotation = 1
+	require.Error(t, config.sanityCheck(nil, VersionLeaderRotation))
+
+	config.LeaderRotation = 10
+	config.LeaderSchedule = LeaderSchedule(42)
+	require.Error(t, config.sanityCheck(nil, VersionLeaderRotation))
+}
+
+// TestService_LeaderRotation makes sure that the leadership is passed along
+// the roster, and that no transactions get lost during the rotations.
+func TestService_LeaderRotation(t *testing.T) {
+	bArgs := defaultBCTArgs
+	bArgs.Nodes = 4
+	b := newBCTRun(t, &bArgs)
+	defer b.CloseAll()
+
+	log.Lvl1("Enabling the leader rotation")
+	config, err := b.Services[0].LoadConfig(b.Genesis.SkipChainID())
+	require.NoError(t, err)
+	config.LeaderRotation = 3
+	configBuf, err := protobuf.Encode(config)
+	require.NoError(t, err)
+	b.SendInst(nil, Instruction{
+		InstanceID: NewInstanceID(nil),
+		Invoke: &Invoke{
+			ContractID: ContractConfigID,
+			Command:    "update_config",
+			Args:       Arguments{{Name: "config", Value: configBuf}},
+		},
+	})
+
+	log.Lvl1("Sending transactions over multiple rotations")
+	txArgs := TxArgsDefault
+	txArgs.WaitPropagation = false
+	for i := 0; i < 6; i++ {
+		txArgs.Node = i % len(b.Services)
+		b.SpawnDummy(&txArgs)
+	}
+	require.NoError(t, b.Client.WaitPropagation(-1))
+
+	latest, err := b.Services[0].db().GetLatestByID(b.Genesis.SkipChainID())
+	require.NoError(t, err)
+	require.True(t, latest.Index >= 9)
+
+	for sb := latest; sb.Index > 0; sb = b.Services[0].db().GetByID(sb.BackLinkIDs[0]) {
+		var body DataBody
+		require.NoError(t, protobuf.Decode(sb.Payload, &body))
+		require.Equal(t, sb.Index%3 == 0 && sb.Index > 1,
+			isLeaderRotationTx(body.TxResults), "block %d", sb.Index)
+		leader := b.Roster.List[(sb.Index/3)%len(b.Roster.List)]
+		require.True(t, sb.Roster.List[0].Equal(leader),
+			"wrong leader for block %d", sb.Index)
+	}
+
+	for _, s := range b.Services {
+		leader, err := s.getLeader(b.Genesis.SkipChainID())
+		require.NoError(t, err)
+		require.True(t, leader.Equal(latest.Roster.List[0]))
+	}
+}
@@ -24,7 +24,7 @@ func init() {
 type Version int
 
 // CurrentVersion is what we're running now
-const CurrentVersion Version = VersionRosterCheck
+const CurrentVersion Version = VersionDID
 
 const (
 	// VersionInstructionHash is the first version and indicates that a new,
@@ -47,4 +47,10 @@ const (
 	// VersionRosterCheck verifies better whether a new proposed roster
 	// configuration is valid
 	VersionRosterCheck = 8
+	// VersionLeaderRotation allows the chain configuration to define a
+	// schedule for rotating the leader every given number of blocks.
+	VersionLeaderRotation = 9
+	// VersionDID adds the DID contract and resolves the DID identities of
+	// the signers from the global state.
+	VersionDID = 10
 )
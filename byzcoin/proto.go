@@ -20,6 +20,7 @@ import (
 // type :InstanceID:bytes
 // type :Version:sint32
 // type :GetUpdatesFlags:uint64
+// type :LeaderSchedule:sint32
 // import "skipchain.proto";
 // import "onet.proto";
 // import "darc.proto";
@@ -177,6 +178,15 @@ type ChainConfig struct {
 	Roster          onet.Roster
 	MaxBlockSize    int
 	DarcContractIDs []string
+	// LeaderRotation is the number of blocks after which the leader is
+	// replaced, even if it didn't fail. Zero means the leader is only
+	// replaced through a view-change.
+	// optional
+	LeaderRotation int `protobuf:"opt"`
+	// LeaderSchedule defines how the next leader is chosen when
+	// LeaderRotation is active.
+	// optional
+	LeaderSchedule LeaderSchedule `protobuf:"opt"`
 }
 
 // Proof represents everything necessary to verify a given
@@ -307,6 +317,25 @@ type Coin struct {
 	Value uint64
 }
 
+// Invoice is a request for a payment of coins, as stored in an invoice
+// instance.
+type Invoice struct {
+	// Coin is the instance of the coin receiving the payment.
+	Coin InstanceID
+	// Amount is the number of coins to pay.
+	Amount uint64
+	// Index is the position of the invoice among the invoices of the coin.
+	Index uint64
+	// Memo describes the payment.
+	Memo string
+	// Expiry is the time, in nanoseconds since the epoch, after which the
+	// invoice cannot be paid anymore. If it is 0, the invoice doesn't
+	// expire.
+	Expiry int64
+	// Paid is true once the invoice has been paid.
+	Paid bool
+}
+
 // StreamingRequest is a request asking the service to start streaming blocks
 // on the chain specified by ID.
 type StreamingRequest struct {
@@ -482,6 +511,23 @@ type ResolvedInstanceID struct {
 	InstanceID InstanceID
 }
 
+// ResolveDID is the request for the document of a 'did:byzcoin' DID. If
+// BlockIndex is given, the document is returned as it was after this block.
+type ResolveDID struct {
+	SkipChainID skipchain.SkipBlockID
+	DID         string
+	BlockIndex  int `protobuf:"opt"`
+}
+
+// ResolveDIDResponse holds the resolved DID document, its version, and the
+// index of the block it is from.
+type ResolveDIDResponse struct {
+	Document    darc.DIDDoc
+	Deactivated bool
+	Version     uint64
+	BlockIndex  int
+}
+
 // DebugRequest returns the list of all byzcoins if byzcoinid is empty, else it returns
 // a dump of all instances if byzcoinid is given and exists.
 type DebugRequest struct {
@@ -78,9 +78,16 @@ func (s *ROSTSimul) LoadConfig() (*ChainConfig, error) {
 	return nil, errors.New("not implemented")
 }
 
-// LoadDarc is not implemented
+// LoadDarc returns the darc stored in the instance of the given ID.
 func (s *ROSTSimul) LoadDarc(id darc.ID) (*darc.Darc, error) {
-	return nil, errors.New("not implemented")
+	buf, _, cid, _, err := s.GetValues(id)
+	if err != nil {
+		return nil, err
+	}
+	if cid != ContractDarcID {
+		return nil, errors.New("instance is not a darc")
+	}
+	return darc.NewFromProtobuf(buf)
 }
 
 // StoreAllToReplica stores all stateChanges, without checking for validity!
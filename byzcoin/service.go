@@ -97,6 +97,10 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	err = RegisterGlobalContract(ContractDIDID, contractDIDFromBytes)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // GenNonce returns a random nonce.
@@ -2083,6 +2087,10 @@ func (s *Service) verifySkipBlock(newID []byte, newSB *skipchain.SkipBlock) bool
 			}
 			return false
 		}
+		if err := s.checkLeaderRotation(newSB, body.TxResults); err != nil {
+			log.Error(s.ServerIdentity(), err)
+			return false
+		}
 	}
 	mtr, txOut, scs, _ := s.createStateChanges(sst, newSB.SkipChainID(), body.TxResults, noTimeout, header.Version, header.Timestamp)
 
@@ -3061,6 +3069,7 @@ func newService(c *onet.Context) (onet.Service, error) {
 		s.GetAllInstanceVersion,
 		s.CheckStateChangeValidity,
 		s.ResolveInstanceID,
+		s.ResolveDID,
 		s.Debug,
 		s.DebugRemove)
 	if err != nil {
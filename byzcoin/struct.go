@@ -673,6 +673,20 @@ func (c ChainConfig) sanityCheck(old *ChainConfig, version Version) error {
 		return xerrors.New("need at least 3 nodes to have a majority")
 	}
 
+	if c.LeaderRotation != 0 {
+		if version < VersionLeaderRotation {
+			return xerrors.New("leader rotation is not supported by this version")
+		}
+		// A rotation at every block would leave no room for transactions.
+		if c.LeaderRotation < 2 {
+			return xerrors.New("leader rotation must be at least 2 blocks")
+		}
+	}
+	if c.LeaderSchedule != LeaderScheduleRoundRobin &&
+		c.LeaderSchedule != LeaderScheduleRandom {
+		return xerrors.New("unknown leader schedule")
+	}
+
 	if version >= VersionRosterCheck {
 		for i, si := range c.Roster.List {
 			if err := c.nodeCheck(i, si); err != nil {
@@ -751,6 +765,12 @@ func (c ChainConfig) checkNewRoster(newRoster onet.Roster) error {
 // --- darc contract ID 1: darc2
 // --- darc contract ID 2: darc3'
 // ```
+//
+// If the leader rotation is active, a last line is added:
+//
+// ```
+// -- LeaderRotation: 100 blocks, roundrobin
+// ```
 func (c ChainConfig) String() string {
 	res := new(strings.Builder)
 	res.WriteString("- ChainConfig:\n")
@@ -761,6 +781,10 @@ func (c ChainConfig) String() string {
 	for i, darcID := range c.DarcContractIDs {
 		fmt.Fprintf(res, "--- darc contract ID %d: %s\n", i, darcID)
 	}
+	if c.LeaderRotation > 0 {
+		fmt.Fprintf(res, "-- LeaderRotation: %d blocks, %s\n",
+			c.LeaderRotation, c.LeaderSchedule)
+	}
 	return res.String()
 }
 
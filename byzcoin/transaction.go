@@ -451,9 +451,10 @@ func (instr Instruction) VerifyWithOption(st ReadOnlyStateTrie, msg []byte, ops
 	// check the signature
 	// Save the identities that provide good signatures
 	identitiesWithCorrectSignatures := make([]string, 0)
+	signers := resolveDIDs(st, instr.SignerIdentities)
 	for i := range instr.Signatures {
-		if err := instr.SignerIdentities[i].Verify(msg, instr.Signatures[i]); err == nil {
-			identitiesWithCorrectSignatures = append(identitiesWithCorrectSignatures, instr.SignerIdentities[i].String())
+		if err := signers[i].Verify(msg, instr.Signatures[i]); err == nil {
+			identitiesWithCorrectSignatures = append(identitiesWithCorrectSignatures, signers[i].String())
 		}
 	}
 
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
 	"go.dedis.ch/protobuf"
 	"golang.org/x/xerrors"
 	"sync"
@@ -19,6 +21,8 @@ var maxTxHashes = 1000
 // With VersionRollup and newer,
 // the nodes send the ClientTransactions directly to the leader,
 // which queues them up, and proposes them to the nodes for signing.
+// When the leader is replaced, the transactions that have not been included
+// in a block yet are handed over to the new leader.
 type txPipeline struct {
 	ctxChan     chan ClientTransaction
 	needUpgrade chan Version
@@ -27,6 +31,10 @@ type txPipeline struct {
 	txQueue     []ClientTransaction
 	wg          sync.WaitGroup
 	processor   txProcessor
+	// handOver holds the transactions that have been removed from the
+	// queue, but have not been proposed because of a leader rotation.
+	handOver      []ClientTransaction
+	handOverMutex sync.Mutex
 }
 
 // newTxPipeline returns an initialized txPipeLine with a byzcoin-service
@@ -70,7 +78,17 @@ leaderLoop:
 	for {
 		select {
 		case <-stopSignal:
-			// Either a view-change or the node goes down.
+			// Either a view-change, a leader rotation,
+			// or the node goes down.
+			// A pending block is not proposed anymore, as this node is not
+			// the leader anymore.
+			select {
+			case nbState, ok := <-newBlock:
+				if ok {
+					p.keepForHandOver(nbState)
+				}
+			default:
+			}
 			close(newBlock)
 			break leaderLoop
 
@@ -140,6 +158,40 @@ leaderLoop:
 		}
 	}
 	p.wg.Wait()
+
+	// Collect all transactions that didn't make it into a block,
+	// so that the new leader can include them.
+	p.keepForHandOver(currentState)
+drain:
+	for {
+		select {
+		case tx := <-p.ctxChan:
+			p.txQueue = append(p.txQueue, tx)
+		default:
+			break drain
+		}
+	}
+	p.handOverMutex.Lock()
+	txs := append(p.handOver, p.txQueue...)
+	p.handOver = nil
+	p.handOverMutex.Unlock()
+	p.txQueue = nil
+	if len(txs) > 0 {
+		p.processor.HandOver(txs)
+	}
+}
+
+// keepForHandOver stores the transactions of the given proposition,
+// so they are handed over to the next leader once the pipeline stops.
+func (p *txPipeline) keepForHandOver(state *proposedTransactions) {
+	if state == nil || state.isVersionUpdate() {
+		return
+	}
+	p.handOverMutex.Lock()
+	defer p.handOverMutex.Unlock()
+	for _, txRes := range state.txs {
+		p.handOver = append(p.handOver, txRes.ClientTransaction)
+	}
 }
 
 // createBlocks is the background routine that listens for new blocks and
@@ -155,7 +207,16 @@ func (p *txPipeline) createBlocks(newBlock chan *proposedTransactions,
 			break
 		}
 
-		if inState.isVersionUpdate() {
+		if p.processor.LeaderRotationDue() {
+			// The next block passes the leadership to another node,
+			// so the transactions of this proposition will be handed over
+			// to the new leader once this pipeline stops.
+			p.keepForHandOver(inState)
+			err := p.processor.ProposeLeaderRotationBlock()
+			if err != nil {
+				log.Error("failed to rotate leader:", err)
+			}
+		} else if inState.isVersionUpdate() {
 			// Create an upgrade block for the next version
 			err := p.processor.ProposeUpgradeBlock(inState.newVersion)
 			if err != nil {
@@ -199,6 +260,15 @@ type txProcessor interface {
 	GetBlockSize() int
 	// Returns the current version of ByzCoin as per the stateTrie
 	GetVersion() (Version, error)
+	// LeaderRotationDue returns true if the next block must pass the
+	// leadership to the next scheduled leader.
+	LeaderRotationDue() bool
+	// ProposeLeaderRotationBlock should create the block that passes the
+	// leadership to the next scheduled leader.
+	ProposeLeaderRotationBlock() error
+	// HandOver should send the given transactions to the current leader.
+	// It is called once the pipeline stops.
+	HandOver([]ClientTransaction)
 }
 
 // defaultTxProcessor is an implementation of txProcessor that uses a
@@ -260,6 +330,55 @@ func (s *defaultTxProcessor) GetVersion() (Version, error) {
 	return st.GetVersion(), nil
 }
 
+func (s *defaultTxProcessor) LeaderRotationDue() bool {
+	st, err := s.Service.getStateTrie(s.scID)
+	if err != nil {
+		log.Error(s.ServerIdentity(), "couldn't get trie:", err)
+		return false
+	}
+	config, err := st.LoadConfig()
+	if err != nil {
+		log.Error(s.ServerIdentity(), "couldn't get configuration:", err)
+		return false
+	}
+	return config.rotationDue(st.GetIndex() + 1)
+}
+
+func (s *defaultTxProcessor) ProposeLeaderRotationBlock() error {
+	return cothority.ErrorOrNil(s.createLeaderRotationBlock(s.scID),
+		"creating block")
+}
+
+func (s *defaultTxProcessor) HandOver(txs []ClientTransaction) {
+	leader, err := s.getLeader(s.scID)
+	if err != nil {
+		log.Error(s.ServerIdentity(), "couldn't get leader:", err)
+		return
+	}
+	if leader.Equal(s.ServerIdentity()) {
+		// The node is shutting down while still being the leader.
+		log.Lvlf2("%s: dropping %d transactions on shutdown",
+			s.ServerIdentity(), len(txs))
+		return
+	}
+	if !s.tasks.add(1) {
+		return
+	}
+	go func() {
+		defer s.tasks.done()
+		log.Lvlf2("%s: handing over %d transactions to %s",
+			s.ServerIdentity(), len(txs), leader)
+		cl := NewClient(s.scID,
+			*onet.NewRoster([]*network.ServerIdentity{leader}))
+		for _, tx := range txs {
+			if _, err := cl.AddTransaction(tx); err != nil {
+				log.Warn(s.ServerIdentity(), "couldn't hand over transaction:",
+					err)
+			}
+		}
+	}()
+}
+
 // proposedTransactions hold the proposal of the block to be sent out to the
 // nodes.
 // It can be updated with new transactions until is it sent to the nodes.
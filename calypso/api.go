@@ -12,6 +12,7 @@ import (
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/network"
 	"go.dedis.ch/protobuf"
@@ -36,6 +37,12 @@ type ReadReply struct {
 	byzcoin.InstanceID
 }
 
+// GroupKeyReply is returned upon successfully spawning a GroupKey instance.
+type GroupKeyReply struct {
+	*byzcoin.AddTxResponse
+	byzcoin.InstanceID
+}
+
 // NewClient instantiates a new Client.
 // It takes as input an "initialized" byzcoin client
 // with an already created ledger
@@ -48,8 +55,17 @@ func NewClient(byzcoin *byzcoin.Client) *Client {
 // created. It first sends a transaction to ByzCoin to spawn a LTS instance,
 // then it asks the Calypso cothority to start the DKG.
 func (c *Client) CreateLTS(ltsRoster *onet.Roster, darcID darc.ID, signers []darc.Signer, counters []uint64) (reply *CreateLTSReply, err error) {
+	return c.CreateProactiveLTS(ltsRoster, 0, darcID, signers, counters)
+}
+
+// CreateProactiveLTS is like CreateLTS, but the nodes of the LTS reshare
+// their shares every reshareInterval blocks, keeping the same public key. If
+// reshareInterval is 0, the shares are only reshared on request.
+func (c *Client) CreateProactiveLTS(ltsRoster *onet.Roster, reshareInterval int, darcID darc.ID,
+	signers []darc.Signer, counters []uint64) (reply *CreateLTSReply, err error) {
 	// Make the transaction and get its proof
-	buf, err := protobuf.Encode(&LtsInstanceInfo{*ltsRoster})
+	buf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *ltsRoster,
+		ReshareInterval: reshareInterval})
 	if err != nil {
 		return nil, xerrors.Errorf("encoding roster: %v", err)
 	}
@@ -141,6 +157,34 @@ func (c *Client) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error)
 	return reply, cothority.ErrorOrNil(err, "sending DecryptKey message")
 }
 
+// DecryptReleased takes as input the proof of a write with a release
+// condition. Once the condition is reached, it returns the secret of the
+// write. If the release asks for it, the LTS also publishes the secret in the
+// write instance.
+func (c *Client) DecryptReleased(write byzcoin.Proof) (reply *DecryptReleasedReply, err error) {
+	reply = &DecryptReleasedReply{}
+	err = c.c.SendProtobuf(c.bcClient.Roster.List[0], &DecryptReleased{Write: write}, reply)
+	return reply, cothority.ErrorOrNil(err, "sending DecryptReleased message")
+}
+
+// Audit returns all the read instances of the write instance, in the order
+// they have been spawned. The reply is verified against the genesis block of
+// the ledger.
+func (c *Client) Audit(write byzcoin.InstanceID) (reply *AuditReply, err error) {
+	reply = &AuditReply{}
+	err = c.c.SendProtobuf(c.bcClient.Roster.List[0],
+		&Audit{ByzCoinID: c.bcClient.ID, Write: write}, reply)
+	if err != nil {
+		return nil, xerrors.Errorf("sending Audit message: %v", err)
+	}
+	genesis, err := skipchain.NewClient().GetSingleBlock(&c.bcClient.Roster, c.bcClient.ID)
+	if err != nil {
+		return nil, xerrors.Errorf("getting genesis block: %v", err)
+	}
+	return reply, cothority.ErrorOrNil(reply.Verify(genesis, write),
+		"verifying audit")
+}
+
 // WaitProof calls the byzcoin client's wait proof
 func (c *Client) WaitProof(id byzcoin.InstanceID, interval time.Duration,
 	value []byte) (*byzcoin.Proof, error) {
@@ -203,11 +247,44 @@ func (c *Client) AddWrite(write *Write, signer darc.Signer, signerCtr uint64,
 //   - err - Error if any, nil otherwise.
 func (c *Client) AddRead(proof *byzcoin.Proof, signer darc.Signer, signerCtr uint64, wait int) (
 	reply *ReadReply, err error) {
-	var readBuf []byte
 	read := &Read{
 		Write: byzcoin.NewInstanceID(proof.InclusionProof.Key()),
 		Xc:    signer.Ed25519.Point,
 	}
+	return c.addRead(read, signer, signerCtr, wait)
+}
+
+// AddGroupRead creates a Read Instance which re-encrypts the secret to the
+// key of a group, so that all the members of the group can decrypt it.
+//
+// Input:
+//   - proof - A ByzCoin proof of the Write Operation.
+//   - group - A ByzCoin proof of the GroupKey instance.
+//   - signer - The member of the group who will sign the transaction
+//   - signerCtr - A monotonically increasing counter for the signer
+//   - wait - The number of blocks to wait -- 0 means no wait
+//
+// Output:
+//   - reply - ReadReply containing the transaction response and instance id
+//   - err - Error if any, nil otherwise.
+func (c *Client) AddGroupRead(proof *byzcoin.Proof, group *byzcoin.Proof,
+	signer darc.Signer, signerCtr uint64, wait int) (reply *ReadReply, err error) {
+	var gk GroupKey
+	if err := group.VerifyAndDecode(cothority.Suite, ContractGroupKeyID, &gk); err != nil {
+		return nil, xerrors.Errorf("getting group key: %v", err)
+	}
+	groupID := byzcoin.NewInstanceID(group.InclusionProof.Key())
+	read := &Read{
+		Write: byzcoin.NewInstanceID(proof.InclusionProof.Key()),
+		Xc:    gk.X,
+		Group: &groupID,
+	}
+	return c.addRead(read, signer, signerCtr, wait)
+}
+
+func (c *Client) addRead(read *Read, signer darc.Signer, signerCtr uint64, wait int) (
+	reply *ReadReply, err error) {
+	var readBuf []byte
 	reply = &ReadReply{}
 	readBuf, err = protobuf.Encode(read)
 	if err != nil {
@@ -216,7 +293,7 @@ func (c *Client) AddRead(proof *byzcoin.Proof, signer darc.Signer, signerCtr uin
 
 	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
 		byzcoin.Instruction{
-			InstanceID: byzcoin.NewInstanceID(proof.InclusionProof.Key()),
+			InstanceID: read.Write,
 			Spawn: &byzcoin.Spawn{
 				ContractID: ContractReadID,
 				Args:       byzcoin.Arguments{{Name: "read", Value: readBuf}},
@@ -237,6 +314,50 @@ func (c *Client) AddRead(proof *byzcoin.Proof, signer darc.Signer, signerCtr uin
 	return reply, nil
 }
 
+// AddGroupKey creates a GroupKey Instance by adding a transaction on the
+// byzcoin client.
+//
+// Input:
+//   - groupKey - The public key of the group
+//   - signer - The signer authorizing the spawn of the group key
+//   - signerCtr - A monotonically increasing counter for the signer
+//   - darc - The DARC with a spawn:calypsoGroupKey rule on it, which will
+//   also govern the updates of the group key
+//   - wait - The number of blocks to wait -- 0 means no wait
+//
+// Output:
+//   - reply - GroupKeyReply containing the transaction response and instance id
+//   - err - Error if any, nil otherwise.
+func (c *Client) AddGroupKey(groupKey *GroupKey, signer darc.Signer, signerCtr uint64,
+	darc darc.Darc, wait int) (reply *GroupKeyReply, err error) {
+	reply = &GroupKeyReply{}
+	buf, err := protobuf.Encode(groupKey)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding GroupKey message: %v", err)
+	}
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+		byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(darc.GetBaseID()),
+			Spawn: &byzcoin.Spawn{
+				ContractID: ContractGroupKeyID,
+				Args: byzcoin.Arguments{{
+					Name: "groupKey", Value: buf}},
+			},
+			SignerCounter: []uint64{signerCtr},
+		},
+	)
+	err = ctx.FillSignersAndSignWith(signer)
+	if err != nil {
+		return nil, xerrors.Errorf("signing txn: %v", err)
+	}
+	reply.InstanceID = ctx.Instructions[0].DeriveID("")
+	reply.AddTxResponse, err = c.bcClient.AddTransactionAndWait(ctx, wait)
+	if err != nil {
+		return nil, xerrors.Errorf("adding txn: %v", err)
+	}
+	return reply, nil
+}
+
 // SpawnDarc spawns a Darc Instance by adding a transaction on the byzcoin client.
 // Input:
 //   - signer - The signer authorizing the spawn of this darc (calypso "admin")
@@ -291,9 +412,45 @@ func (c *Client) SpawnDarc(signer darc.Signer, signerCtr uint64,
 //   - key - the re-assembled key
 //   - err - a possible error when trying to recover the data from the point
 func (r *DecryptKeyReply) RecoverKey(xc kyber.Scalar) (key []byte, err error) {
-	xcInv := xc.Clone().Neg(xc)
-	XhatDec := r.X.Clone().Mul(xcInv, r.X)
-	Xhat := XhatDec.Clone().Add(r.XhatEnc, XhatDec)
+	return r.recoverKey(r.X.Clone().Mul(xc, r.X))
+}
+
+// GroupDecryptShare returns the share of a member of a group, which is needed
+// to recover the key re-encrypted to the key of the group.
+//
+// Input:
+//   - xi - the share of the member of the private key of the group
+//
+// Output:
+//   - the decryption share of the member
+func (r *DecryptKeyReply) GroupDecryptShare(xi *share.PriShare) *share.PubShare {
+	return &share.PubShare{I: xi.I, V: r.X.Clone().Mul(xi.V, r.X)}
+}
+
+// RecoverGroupKey is used to recover the secret key once it has been
+// re-encrypted to the key of a group. It needs the decryption shares of t
+// members out of the n members of the group.
+//
+// Input:
+//   - shares - the decryption shares returned by GroupDecryptShare
+//   - t - the threshold of the group key
+//   - n - the number of members of the group
+//
+// Output:
+//   - key - the re-assembled key
+//   - err - a possible error when trying to recover the data from the point
+func (r *DecryptKeyReply) RecoverGroupKey(shares []*share.PubShare, t, n int) (key []byte, err error) {
+	xX, err := share.RecoverCommit(cothority.Suite, shares, t, n)
+	if err != nil {
+		return nil, xerrors.Errorf("recovering group decryption: %v", err)
+	}
+	return r.recoverKey(xX)
+}
+
+// recoverKey decrypts the key, given the private key of the reader times
+// the public key of the LTS.
+func (r *DecryptKeyReply) recoverKey(XhatDec kyber.Point) (key []byte, err error) {
+	Xhat := XhatDec.Clone().Sub(r.XhatEnc, XhatDec)
 	XhatInv := Xhat.Clone().Neg(Xhat)
 
 	// Decrypt r.C to keyPointHat
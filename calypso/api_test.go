@@ -12,6 +12,8 @@ import (
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/share"
 	"go.dedis.ch/onet/v3"
 )
 
@@ -217,3 +219,344 @@ func TestClient_Calypso(t *testing.T) {
 
 	// use keyCopy to unlock the stuff in writeInstance.Data
 }
+
+// Tests the client api's AddGroupKey and AddGroupRead, re-encrypting to the
+// key of a group of readers.
+func TestClient_GroupRead(t *testing.T) {
+	l := onet.NewTCPTest(cothority.Suite)
+	_, roster, _ := l.GenTree(3, true)
+	defer l.CloseAll()
+
+	admin := darc.NewSignerEd25519(nil, nil)
+	adminCt := uint64(1)
+	provider := darc.NewSignerEd25519(nil, nil)
+	members := []darc.Signer{darc.NewSignerEd25519(nil, nil),
+		darc.NewSignerEd25519(nil, nil), darc.NewSignerEd25519(nil, nil)}
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{"spawn:" + ContractLongTermSecretID},
+		admin.Identity())
+	require.NoError(t, err)
+	msg.BlockInterval = 500 * time.Millisecond
+	gDarc := msg.GenesisDarc
+	c, _, err := byzcoin.NewLedger(msg, false)
+	require.NoError(t, err)
+	calypsoClient := NewClient(c)
+	for _, who := range roster.List {
+		require.NoError(t, calypsoClient.Authorize(who, c.ID))
+	}
+	ltsReply, err := calypsoClient.CreateLTS(roster, gDarc.GetBaseID(), []darc.Signer{admin}, []uint64{adminCt})
+	adminCt++
+	require.NoError(t, err)
+
+	// Any member of the group can sign for the group, which holds its key.
+	var ids []string
+	for _, m := range members {
+		ids = append(ids, m.Identity().String())
+	}
+	groupDarc := darc.NewDarc(darc.InitRules([]darc.Identity{admin.Identity()},
+		[]darc.Identity{admin.Identity()}), []byte("Group"))
+	require.NoError(t, groupDarc.Rules.UpdateSign(expression.InitOrExpr(ids...)))
+	require.NoError(t, groupDarc.Rules.AddRule(darc.Action("spawn:"+ContractGroupKeyID),
+		expression.InitOrExpr(ids...)))
+	_, err = calypsoClient.SpawnDarc(admin, adminCt, gDarc, *groupDarc, 10)
+	adminCt++
+	require.NoError(t, err)
+
+	// The group may read the data of the provider.
+	darc1 := darc.NewDarc(darc.InitRules([]darc.Identity{provider.Identity()},
+		[]darc.Identity{provider.Identity()}), []byte("Provider"))
+	require.NoError(t, darc1.Rules.AddRule(darc.Action("spawn:"+ContractWriteID),
+		expression.InitOrExpr(provider.Identity().String())))
+	require.NoError(t, darc1.Rules.AddRule(darc.Action("spawn:"+ContractReadID),
+		expression.InitOrExpr(darc.NewIdentityDarc(groupDarc.GetBaseID()).String())))
+	_, err = calypsoClient.SpawnDarc(admin, adminCt, gDarc, *darc1, 10)
+	require.NoError(t, err)
+
+	// The key of the group is shared among its members, 2 out of 3.
+	groupPri := share.NewPriPoly(cothority.Suite, 2, nil, cothority.Suite.RandomStream())
+	groupShares := groupPri.Shares(len(members))
+	gk, err := calypsoClient.AddGroupKey(&GroupKey{X: groupPri.Commit(nil).Commit()},
+		members[0], 1, *groupDarc, 10)
+	require.NoError(t, err)
+	prGk, err := calypsoClient.WaitProof(gk.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+
+	key := []byte("group secret")
+	write := NewWrite(cothority.Suite, ltsReply.InstanceID, darc1.GetBaseID(), ltsReply.X, key)
+	wr, err := calypsoClient.AddWrite(write, provider, 1, *darc1, 10)
+	require.NoError(t, err)
+	prWr, err := calypsoClient.WaitProof(wr.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+
+	// The key of a group read must be the one of the group.
+	groupID := gk.InstanceID
+	_, err = calypsoClient.addRead(&Read{Write: wr.InstanceID,
+		Xc: members[1].Ed25519.Point, Group: &groupID}, members[1], 1, 10)
+	require.Error(t, err)
+
+	re, err := calypsoClient.AddGroupRead(prWr, prGk, members[1], 1, 10)
+	require.NoError(t, err)
+	prRe, err := calypsoClient.WaitProof(re.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+
+	// One re-encryption is enough for all the members.
+	calypsoClient.ltsReply = ltsReply
+	dk, err := calypsoClient.DecryptKey(&DecryptKey{Read: *prRe, Write: *prWr})
+	require.NoError(t, err)
+	_, err = dk.RecoverGroupKey([]*share.PubShare{
+		dk.GroupDecryptShare(groupShares[0])}, 2, 3)
+	require.Error(t, err)
+	for _, pair := range [][2]int{{0, 1}, {1, 2}, {0, 2}} {
+		keyCopy, err := dk.RecoverGroupKey([]*share.PubShare{
+			dk.GroupDecryptShare(groupShares[pair[0]]),
+			dk.GroupDecryptShare(groupShares[pair[1]]),
+		}, 2, 3)
+		require.NoError(t, err)
+		require.Equal(t, key, keyCopy)
+	}
+}
+
+// Tests a write whose secret is released at a given block index and
+// published by the LTS.
+func TestClient_Release(t *testing.T) {
+	l := onet.NewTCPTest(cothority.Suite)
+	_, roster, _ := l.GenTree(3, true)
+	defer l.CloseAll()
+
+	admin := darc.NewSignerEd25519(nil, nil)
+	adminCt := uint64(1)
+	provider := darc.NewSignerEd25519(nil, nil)
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{"spawn:" + ContractLongTermSecretID},
+		admin.Identity())
+	require.NoError(t, err)
+	msg.BlockInterval = 500 * time.Millisecond
+	gDarc := msg.GenesisDarc
+	c, _, err := byzcoin.NewLedger(msg, false)
+	require.NoError(t, err)
+	calypsoClient := NewClient(c)
+	for _, who := range roster.List {
+		require.NoError(t, calypsoClient.Authorize(who, c.ID))
+	}
+	ltsReply, err := calypsoClient.CreateLTS(roster, gDarc.GetBaseID(), []darc.Signer{admin}, []uint64{adminCt})
+	adminCt++
+	require.NoError(t, err)
+
+	darc1 := darc.NewDarc(darc.InitRules([]darc.Identity{provider.Identity()},
+		[]darc.Identity{provider.Identity()}), []byte("Provider"))
+	for _, action := range []string{"spawn:" + ContractWriteID, "spawn:" + ContractReadID} {
+		require.NoError(t, darc1.Rules.AddRule(darc.Action(action),
+			expression.InitOrExpr(provider.Identity().String())))
+	}
+	_, err = calypsoClient.SpawnDarc(admin, adminCt, gDarc, *darc1, 10)
+	adminCt++
+	require.NoError(t, err)
+
+	pr, err := c.GetProof(darc1.GetBaseID())
+	require.NoError(t, err)
+	release := &Release{BlockIndex: pr.Proof.Latest.Index + 3, Publish: true}
+	key := []byte("released secret")
+	write := NewReleaseWrite(cothority.Suite, ltsReply.InstanceID,
+		darc1.GetBaseID(), ltsReply.X, key, release)
+	wr, err := calypsoClient.AddWrite(write, provider, 1, *darc1, 10)
+	require.NoError(t, err)
+	prWr, err := calypsoClient.WaitProof(wr.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+
+	// Nobody can read a released secret.
+	_, err = calypsoClient.AddRead(prWr, provider, 2, 10)
+	require.Error(t, err)
+	_, err = calypsoClient.DecryptReleased(*prWr)
+	require.Error(t, err)
+
+	// Create some blocks.
+	for prWr.Latest.Index < release.BlockIndex {
+		d := darc.NewDarc(darc.InitRules([]darc.Identity{admin.Identity()},
+			[]darc.Identity{admin.Identity()}), []byte{byte(adminCt)})
+		_, err = calypsoClient.SpawnDarc(admin, adminCt, gDarc, *d, 10)
+		adminCt++
+		require.NoError(t, err)
+		reply, err := c.GetProof(wr.InstanceID.Slice())
+		require.NoError(t, err)
+		prWr = &reply.Proof
+	}
+
+	dr, err := calypsoClient.DecryptReleased(*prWr)
+	require.NoError(t, err)
+	require.Equal(t, key, dr.Key)
+
+	// The key is now stored in the write instance.
+	reply, err := c.GetProof(wr.InstanceID.Slice())
+	require.NoError(t, err)
+	var published Write
+	require.NoError(t, reply.Proof.VerifyAndDecode(cothority.Suite, ContractWriteID, &published))
+	require.Equal(t, key, published.Key)
+	dr, err = calypsoClient.DecryptReleased(reply.Proof)
+	require.NoError(t, err)
+	require.Equal(t, key, dr.Key)
+}
+
+// Tests that a wrong key published by a node of the LTS is not returned by
+// DecryptReleased.
+func TestClient_ReleaseWrongKey(t *testing.T) {
+	l := onet.NewTCPTest(cothority.Suite)
+	servers, roster, _ := l.GenTree(3, true)
+	defer l.CloseAll()
+
+	admin := darc.NewSignerEd25519(nil, nil)
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{"spawn:" + ContractLongTermSecretID, "spawn:" + ContractWriteID},
+		admin.Identity())
+	require.NoError(t, err)
+	msg.BlockInterval = 500 * time.Millisecond
+	gDarc := msg.GenesisDarc
+	c, _, err := byzcoin.NewLedger(msg, false)
+	require.NoError(t, err)
+	calypsoClient := NewClient(c)
+	for _, who := range roster.List {
+		require.NoError(t, calypsoClient.Authorize(who, c.ID))
+	}
+	ltsReply, err := calypsoClient.CreateLTS(roster, gDarc.GetBaseID(),
+		[]darc.Signer{admin}, []uint64{1})
+	require.NoError(t, err)
+
+	key := []byte("released secret")
+	write := NewReleaseWrite(cothority.Suite, ltsReply.InstanceID,
+		gDarc.GetBaseID(), ltsReply.X, key,
+		&Release{Timestamp: 1, Publish: true})
+	wr, err := calypsoClient.AddWrite(write, admin, 2, gDarc, 10)
+	require.NoError(t, err)
+	prWr, err := calypsoClient.WaitProof(wr.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+
+	// A faulty node publishes a wrong key.
+	faulty := l.GetServices(servers, calypsoID)[1].(*Service)
+	require.NoError(t, faulty.publish(prWr, []byte("wrong secret")))
+	reply, err := c.GetProof(wr.InstanceID.Slice())
+	require.NoError(t, err)
+	var published Write
+	require.NoError(t, reply.Proof.VerifyAndDecode(cothority.Suite, ContractWriteID, &published))
+	require.Equal(t, []byte("wrong secret"), published.Key)
+
+	dr, err := calypsoClient.DecryptReleased(reply.Proof)
+	require.NoError(t, err)
+	require.Equal(t, key, dr.Key)
+}
+
+func TestClient_Audit(t *testing.T) {
+	l := onet.NewTCPTest(cothority.Suite)
+	_, roster, _ := l.GenTree(3, true)
+	defer l.CloseAll()
+
+	admin := darc.NewSignerEd25519(nil, nil)
+	adminCt := uint64(1)
+	provider := darc.NewSignerEd25519(nil, nil)
+	reader1 := darc.NewSignerEd25519(nil, nil)
+	reader2 := darc.NewSignerEd25519(nil, nil)
+	msg, err := byzcoin.DefaultGenesisMsg(byzcoin.CurrentVersion, roster,
+		[]string{"spawn:" + ContractLongTermSecretID},
+		admin.Identity())
+	require.NoError(t, err)
+	msg.BlockInterval = 500 * time.Millisecond
+	gDarc := msg.GenesisDarc
+	c, _, err := byzcoin.NewLedger(msg, false)
+	require.NoError(t, err)
+	calypsoClient := NewClient(c)
+	for _, who := range roster.List {
+		require.NoError(t, calypsoClient.Authorize(who, c.ID))
+	}
+	ltsReply, err := calypsoClient.CreateLTS(roster, gDarc.GetBaseID(), []darc.Signer{admin}, []uint64{adminCt})
+	adminCt++
+	require.NoError(t, err)
+
+	darc1 := darc.NewDarc(darc.InitRulesWith([]darc.Identity{provider.Identity()},
+		[]darc.Identity{provider.Identity()}, "invoke:"+byzcoin.ContractDarcID+".evolve"),
+		[]byte("Provider"))
+	require.NoError(t, darc1.Rules.AddRule(darc.Action("spawn:"+ContractWriteID),
+		expression.InitOrExpr(provider.Identity().String())))
+	require.NoError(t, darc1.Rules.AddRule(darc.Action("spawn:"+ContractReadID),
+		expression.InitOrExpr(reader1.Identity().String())))
+	_, err = calypsoClient.SpawnDarc(admin, adminCt, gDarc, *darc1, 10)
+	require.NoError(t, err)
+
+	write := NewWrite(cothority.Suite, ltsReply.InstanceID,
+		darc1.GetBaseID(), ltsReply.X, []byte("secret key"))
+	wr, err := calypsoClient.AddWrite(write, provider, 1, *darc1, 10)
+	require.NoError(t, err)
+	prWr, err := calypsoClient.WaitProof(wr.InstanceID, time.Second, nil)
+	require.NoError(t, err)
+
+	ar, err := calypsoClient.Audit(wr.InstanceID)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ar.Reads))
+
+	re1, err := calypsoClient.AddRead(prWr, reader1, 1, 10)
+	require.NoError(t, err)
+
+	// Give the second reader access with a new version of the darc.
+	darc2 := darc1.Copy()
+	require.NoError(t, darc2.EvolveFrom(darc1))
+	require.NoError(t, darc2.Rules.UpdateRule(darc.Action("spawn:"+ContractReadID),
+		expression.InitOrExpr(reader1.Identity().String(), reader2.Identity().String())))
+	darc2Buf, err := darc2.ToProto()
+	require.NoError(t, err)
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion, byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(darc1.GetBaseID()),
+		Invoke: &byzcoin.Invoke{
+			ContractID: byzcoin.ContractDarcID,
+			Command:    "evolve",
+			Args:       byzcoin.Arguments{{Name: "darc", Value: darc2Buf}},
+		},
+		SignerCounter: []uint64{2},
+	})
+	require.NoError(t, ctx.FillSignersAndSignWith(provider))
+	_, err = c.AddTransactionAndWait(ctx, 10)
+	require.NoError(t, err)
+	re2, err := calypsoClient.AddRead(prWr, reader2, 1, 10)
+	require.NoError(t, err)
+
+	ar, err = calypsoClient.Audit(wr.InstanceID)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(ar.Reads))
+	for i, exp := range []struct {
+		id      byzcoin.InstanceID
+		reader  darc.Signer
+		version uint64
+	}{{re1.InstanceID, reader1, 0}, {re2.InstanceID, reader2, 1}} {
+		read := ar.Reads[i]
+		require.True(t, read.ReadID.Equal(exp.id))
+		require.Equal(t, []string{exp.reader.Identity().String()}, read.Readers)
+		require.True(t, read.Xc.Equal(exp.reader.Ed25519.Point))
+		require.Equal(t, darc1.GetBaseID(), read.DarcID)
+		require.Equal(t, exp.version, read.DarcVersion)
+		require.NotZero(t, read.Timestamp)
+	}
+	require.True(t, ar.Reads[0].BlockIndex < ar.Reads[1].BlockIndex)
+
+	// A tampered audit doesn't verify.
+	genesis, err := skipchain.NewClient().GetSingleBlock(roster, c.ID)
+	require.NoError(t, err)
+	require.NoError(t, ar.Verify(genesis, wr.InstanceID))
+	for _, tamper := range []func(r *AuditRead){
+		func(r *AuditRead) { r.ReadID = re2.InstanceID },
+		func(r *AuditRead) { r.Readers = []string{reader2.Identity().String()} },
+		func(r *AuditRead) { r.BlockIndex++ },
+		func(r *AuditRead) { r.Timestamp++ },
+		func(r *AuditRead) { r.DarcVersion = 1 },
+		func(r *AuditRead) { r.Block = ar.Reads[1].Block },
+	} {
+		read := ar.Reads[0]
+		tamper(&ar.Reads[0])
+		require.Error(t, ar.Verify(genesis, wr.InstanceID))
+		ar.Reads[0] = read
+	}
+	evolutions := ar.DarcEvolutions
+	ar.DarcEvolutions = nil
+	require.Error(t, ar.Verify(genesis, wr.InstanceID))
+	ar.DarcEvolutions = evolutions
+	require.NoError(t, ar.Verify(genesis, wr.InstanceID))
+
+	// Only write instances can be audited.
+	_, err = calypsoClient.Audit(re1.InstanceID)
+	require.Error(t, err)
+}
@@ -0,0 +1,420 @@
+package calypso
+
+import (
+	"bytes"
+	"sort"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// Audit returns all the read instances of a write instance, with the
+// identities of the readers, the blocks holding them, the version of the darc
+// that authorized them and a proof for each of them. The node must follow
+// the ByzCoin ledger, and the ledger must be authorised.
+// Only the blocks from the one holding the write are scanned.
+func (s *Service) Audit(req *Audit) (*AuditReply, error) {
+	s.storage.Lock()
+	_, ok := s.storage.AuthorisedByzCoinIDs[string(req.ByzCoinID)]
+	s.storage.Unlock()
+	if !ok {
+		return nil, xerrors.New("this ByzCoin ID is not authorised")
+	}
+
+	bc := s.Service(byzcoin.ServiceName).(*byzcoin.Service)
+	db := s.Service(skipchain.ServiceName).(*skipchain.Service).GetDB()
+	if db.GetByID(req.ByzCoinID) == nil {
+		return nil, xerrors.New("this node doesn't follow the ByzCoin ledger")
+	}
+	wp, err := bc.GetProof(&byzcoin.GetProof{
+		Version: byzcoin.CurrentVersion,
+		Key:     req.Write.Slice(),
+		ID:      req.ByzCoinID,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting write proof: %v", err)
+	}
+	_, _, cid, darcID, err := wp.Proof.KeyValue()
+	if err != nil {
+		return nil, xerrors.Errorf("getting write instance: %v", err)
+	}
+	if cid != ContractWriteID {
+		return nil, xerrors.New("not a write instance")
+	}
+	dp, err := bc.GetProof(&byzcoin.GetProof{
+		Version: byzcoin.CurrentVersion,
+		Key:     darcID,
+		ID:      req.ByzCoinID,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting darc proof: %v", err)
+	}
+	reply := &AuditReply{Write: wp.Proof, Darc: dp.Proof}
+
+	writeVersions, err := bc.GetAllInstanceVersion(&byzcoin.GetAllInstanceVersion{
+		SkipChainID: req.ByzCoinID,
+		InstanceID:  req.Write,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting write versions: %v", err)
+	}
+	if len(writeVersions.StateChanges) == 0 {
+		return nil, xerrors.New("didn't find the block of the write")
+	}
+	start := writeVersions.StateChanges[0].BlockIndex
+
+	versions, err := bc.GetAllInstanceVersion(&byzcoin.GetAllInstanceVersion{
+		SkipChainID: req.ByzCoinID,
+		InstanceID:  byzcoin.NewInstanceID(darcID),
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("getting darc versions: %v", err)
+	}
+	sort.Slice(versions.StateChanges, func(i, j int) bool {
+		return versions.StateChanges[i].StateChange.Version <
+			versions.StateChanges[j].StateChange.Version
+	})
+	var evolutions []auditPosition
+	for _, v := range versions.StateChanges {
+		version := v.StateChange.Version
+		if version == 0 {
+			continue
+		}
+		bp, err := getBlockProof(db, req.ByzCoinID, v.BlockIndex)
+		if err != nil {
+			return nil, xerrors.Errorf("darc version %d: %v", version, err)
+		}
+		_, body, err := decodeBlock(bp.last())
+		if err != nil {
+			return nil, xerrors.Errorf("darc version %d: %v", version, err)
+		}
+		pos, err := findEvolution(bp.last(), body, darcID, version)
+		if err != nil {
+			return nil, err
+		}
+		evolutions = append(evolutions, pos)
+		reply.DarcEvolutions = append(reply.DarcEvolutions, *bp)
+	}
+
+	pr, err := db.GetProofFromIndex(req.ByzCoinID, start)
+	if err != nil {
+		return nil, xerrors.Errorf("getting block of the write: %v", err)
+	}
+	for sb := pr[len(pr)-1]; sb != nil; {
+		header, body, err := decodeBlock(sb)
+		if err != nil {
+			return nil, xerrors.Errorf("block %d: %v", sb.Index, err)
+		}
+		reads, err := auditBlock(sb, header, body, req.Write, darcID, evolutions)
+		if err != nil {
+			return nil, xerrors.Errorf("block %d: %v", sb.Index, err)
+		}
+		if len(reads) > 0 {
+			bp, err := getBlockProof(db, req.ByzCoinID, sb.Index)
+			if err != nil {
+				return nil, xerrors.Errorf("block %d: %v", sb.Index, err)
+			}
+			for i := range reads {
+				reads[i].Block = *bp
+			}
+		}
+		reply.Reads = append(reply.Reads, reads...)
+		if len(sb.ForwardLink) == 0 {
+			break
+		}
+		next := db.GetByID(sb.ForwardLink[0].To)
+		if next == nil {
+			return nil, xerrors.Errorf("missing block after %d", sb.Index)
+		}
+		sb = next
+	}
+
+	for i := range reply.Reads {
+		rp, err := bc.GetProof(&byzcoin.GetProof{
+			Version: byzcoin.CurrentVersion,
+			Key:     reply.Reads[i].ReadID.Slice(),
+			ID:      req.ByzCoinID,
+		})
+		if err != nil {
+			return nil, xerrors.Errorf("getting read proof: %v", err)
+		}
+		reply.Reads[i].Proof = rp.Proof
+	}
+	return reply, nil
+}
+
+// auditPosition is the position of an instruction in the ledger.
+type auditPosition struct {
+	block, tx, inst int
+}
+
+// before returns true if the position comes before o.
+func (p auditPosition) before(o auditPosition) bool {
+	if p.block != o.block {
+		return p.block < o.block
+	}
+	if p.tx != o.tx {
+		return p.tx < o.tx
+	}
+	return p.inst < o.inst
+}
+
+// auditBlock returns the reads of the write that have been accepted in the
+// block, without their proofs. The darc version of a read is the number of
+// evolutions of the darc that come before it.
+func auditBlock(sb *skipchain.SkipBlock, header *byzcoin.DataHeader,
+	body *byzcoin.DataBody, write byzcoin.InstanceID, darcID darc.ID,
+	evolutions []auditPosition) ([]AuditRead, error) {
+	var reads []AuditRead
+	for t, tx := range body.TxResults {
+		if !tx.Accepted {
+			continue
+		}
+		for i, inst := range tx.ClientTransaction.Instructions {
+			if !inst.InstanceID.Equal(write) ||
+				inst.GetType() != byzcoin.SpawnType ||
+				inst.Spawn.ContractID != ContractReadID {
+				continue
+			}
+			var read Read
+			err := protobuf.DecodeWithConstructors(inst.Spawn.Args.Search("read"),
+				&read, network.DefaultConstructors(cothority.Suite))
+			if err != nil {
+				return nil, xerrors.Errorf("decoding read: %v", err)
+			}
+			id, err := inst.DeriveIDArg("", "preID")
+			if err != nil {
+				return nil, xerrors.Errorf("getting read ID: %v", err)
+			}
+			var readers []string
+			for _, signer := range inst.SignerIdentities {
+				readers = append(readers, signer.String())
+			}
+			pos := auditPosition{sb.Index, t, i}
+			var version uint64
+			for _, ev := range evolutions {
+				if ev.before(pos) {
+					version++
+				}
+			}
+			reads = append(reads, AuditRead{
+				ReadID:      id,
+				Readers:     readers,
+				Xc:          read.Xc,
+				BlockIndex:  sb.Index,
+				Timestamp:   header.Timestamp,
+				DarcID:      darcID,
+				DarcVersion: version,
+			})
+		}
+	}
+	return reads, nil
+}
+
+// findEvolution returns the position of the accepted instruction of the
+// block that evolved the darc to the given version.
+func findEvolution(sb *skipchain.SkipBlock, body *byzcoin.DataBody,
+	darcID darc.ID, version uint64) (auditPosition, error) {
+	darcInstID := byzcoin.NewInstanceID(darcID)
+	for t, tx := range body.TxResults {
+		if !tx.Accepted {
+			continue
+		}
+		for i, inst := range tx.ClientTransaction.Instructions {
+			if !inst.InstanceID.Equal(darcInstID) ||
+				inst.GetType() != byzcoin.InvokeType ||
+				inst.Invoke.ContractID != byzcoin.ContractDarcID {
+				continue
+			}
+			d, err := darc.NewFromProtobuf(inst.Invoke.Args.Search("darc"))
+			if err != nil {
+				continue
+			}
+			if d.Version == version && d.GetBaseID().Equal(darcID) {
+				return auditPosition{sb.Index, t, i}, nil
+			}
+		}
+	}
+	return auditPosition{}, xerrors.Errorf(
+		"didn't find the evolution of the darc to version %d in block %d",
+		version, sb.Index)
+}
+
+// getBlockProof returns the blocks from the genesis block to the block at
+// the given index.
+func getBlockProof(db *skipchain.SkipBlockDB, id skipchain.SkipBlockID,
+	index int) (*BlockProof, error) {
+	pr, err := db.GetProofFromIndex(id, index)
+	if err != nil {
+		return nil, xerrors.Errorf("getting proof of block %d: %v", index, err)
+	}
+	return &BlockProof{Blocks: pr}, nil
+}
+
+// decodeBlock returns the header and the body of a ByzCoin block.
+func decodeBlock(sb *skipchain.SkipBlock) (*byzcoin.DataHeader,
+	*byzcoin.DataBody, error) {
+	var header byzcoin.DataHeader
+	if err := protobuf.Decode(sb.Data, &header); err != nil {
+		return nil, nil, xerrors.Errorf("decoding header: %v", err)
+	}
+	var body byzcoin.DataBody
+	if err := protobuf.Decode(sb.Payload, &body); err != nil {
+		return nil, nil, xerrors.Errorf("decoding body: %v", err)
+	}
+	body.TxResults.SetVersion(header.Version)
+	return &header, &body, nil
+}
+
+func (bp *BlockProof) last() *skipchain.SkipBlock {
+	return bp.Blocks[len(bp.Blocks)-1]
+}
+
+// verify checks the links of the blocks from the genesis block, and that the
+// body of the last block is the one of its header. It returns the last block
+// with its header and body.
+func (bp *BlockProof) verify(genesis *skipchain.SkipBlock) (*skipchain.SkipBlock,
+	*byzcoin.DataHeader, *byzcoin.DataBody, error) {
+	if len(bp.Blocks) == 0 || !bp.Blocks[0].Hash.Equal(genesis.Hash) {
+		return nil, nil, nil, xerrors.New("blocks don't start at the genesis block")
+	}
+	if err := skipchain.Proof(bp.Blocks).Verify(); err != nil {
+		return nil, nil, nil, xerrors.Errorf("verifying blocks: %v", err)
+	}
+	sb := bp.last()
+	header, body, err := decodeBlock(sb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !bytes.Equal(body.TxResults.Hash(), header.ClientTransactionHash) {
+		return nil, nil, nil, xerrors.New("body doesn't match the header")
+	}
+	return sb, header, body, nil
+}
+
+// Verify checks the proofs of the reply against the genesis block of the
+// ledger, and makes sure that the reads are reads of the given write. The
+// readers, the block index, the timestamp and the darc version of every read
+// are computed again from the verified blocks.
+func (r *AuditReply) Verify(genesis *skipchain.SkipBlock, write byzcoin.InstanceID) error {
+	if err := r.Write.VerifyFromBlock(genesis); err != nil {
+		return xerrors.Errorf("write: %v", err)
+	}
+	if !r.Write.InclusionProof.Match(write.Slice()) {
+		return xerrors.New("write: proof is for another instance")
+	}
+	_, _, cid, darcID, err := r.Write.KeyValue()
+	if err != nil {
+		return xerrors.Errorf("write: %v", err)
+	}
+	if cid != ContractWriteID {
+		return xerrors.New("write: not a write instance")
+	}
+
+	if err := r.Darc.VerifyFromBlock(genesis); err != nil {
+		return xerrors.Errorf("darc: %v", err)
+	}
+	if !r.Darc.InclusionProof.Match(darcID) {
+		return xerrors.New("darc: proof is for another instance")
+	}
+	_, darcBuf, cid, _, err := r.Darc.KeyValue()
+	if err != nil {
+		return xerrors.Errorf("darc: %v", err)
+	}
+	if cid != byzcoin.ContractDarcID {
+		return xerrors.New("darc: not a darc instance")
+	}
+	d, err := darc.NewFromProtobuf(darcBuf)
+	if err != nil {
+		return xerrors.Errorf("darc: %v", err)
+	}
+	if uint64(len(r.DarcEvolutions)) != d.Version {
+		return xerrors.Errorf("darc: got %d evolutions for version %d",
+			len(r.DarcEvolutions), d.Version)
+	}
+	var evolutions []auditPosition
+	for i, bp := range r.DarcEvolutions {
+		sb, _, body, err := bp.verify(genesis)
+		if err != nil {
+			return xerrors.Errorf("darc version %d: %v", i+1, err)
+		}
+		pos, err := findEvolution(sb, body, darcID, uint64(i+1))
+		if err != nil {
+			return err
+		}
+		evolutions = append(evolutions, pos)
+	}
+
+	for i, ar := range r.Reads {
+		if err := ar.Proof.VerifyFromBlock(genesis); err != nil {
+			return xerrors.Errorf("read %d: %v", i, err)
+		}
+		key, _, _, _, err := ar.Proof.KeyValue()
+		if err != nil {
+			return xerrors.Errorf("read %d: %v", i, err)
+		}
+		if !ar.ReadID.Equal(byzcoin.NewInstanceID(key)) {
+			return xerrors.Errorf("read %d: proof is for another instance", i)
+		}
+		var read Read
+		if err := ar.Proof.VerifyAndDecode(cothority.Suite, ContractReadID, &read); err != nil {
+			return xerrors.Errorf("read %d: %v", i, err)
+		}
+		if !read.Write.Equal(write) {
+			return xerrors.Errorf("read %d: not a read of this write", i)
+		}
+		if !read.Xc.Equal(ar.Xc) {
+			return xerrors.Errorf("read %d: wrong reader key", i)
+		}
+
+		sb, header, body, err := ar.Block.verify(genesis)
+		if err != nil {
+			return xerrors.Errorf("read %d: %v", i, err)
+		}
+		reads, err := auditBlock(sb, header, body, write, darcID, evolutions)
+		if err != nil {
+			return xerrors.Errorf("read %d: %v", i, err)
+		}
+		found := false
+		for _, exp := range reads {
+			if exp.ReadID.Equal(ar.ReadID) {
+				if err := exp.compare(&ar); err != nil {
+					return xerrors.Errorf("read %d: %v", i, err)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return xerrors.Errorf("read %d: not spawned in its block", i)
+		}
+	}
+	return nil
+}
+
+// compare returns an error if ar doesn't describe the same read as exp.
+func (exp *AuditRead) compare(ar *AuditRead) error {
+	if len(exp.Readers) != len(ar.Readers) {
+		return xerrors.New("wrong readers")
+	}
+	for i := range exp.Readers {
+		if exp.Readers[i] != ar.Readers[i] {
+			return xerrors.New("wrong readers")
+		}
+	}
+	if !exp.Xc.Equal(ar.Xc) {
+		return xerrors.New("wrong reader key")
+	}
+	if exp.BlockIndex != ar.BlockIndex || exp.Timestamp != ar.Timestamp {
+		return xerrors.New("wrong block")
+	}
+	if !exp.DarcID.Equal(ar.DarcID) || exp.DarcVersion != ar.DarcVersion {
+		return xerrors.New("wrong darc version")
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Package blob stores files of any size for calypso. The files are encrypted
+// in authenticated chunks, and the chunks are kept in a content-addressed
+// store outside of ByzCoin. A manifest lists the chunks, and its ID is stored
+// in the Data field of the write instance, so that the whole file is bound to
+// the write.
+//
+// The symmetric key of the file is short enough to be the secret of the
+// write instance. Once it has been recovered with DecryptKey, Download
+// fetches the file and verifies every chunk against the manifest.
+package blob
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// KeyLength is the length of the keys returned by NewKey. It fits in the
+// secret of a write instance with the ed25519 suite.
+const KeyLength = 24
+
+// DefaultChunkSize is the size of the chunks if none is given.
+const DefaultChunkSize = 1 << 20
+
+// Manifest describes an encrypted file. It is stored in the store, and its ID
+// is the one recorded in the write instance.
+type Manifest struct {
+	// ChunkSize is the size of every chunk of the file, except the last one.
+	ChunkSize int
+	// Size is the size of the file.
+	Size int64
+	// Chunks are the IDs of the encrypted chunks, in order.
+	Chunks [][]byte
+}
+
+// NewKey returns a random key for a file.
+func NewKey() []byte {
+	key := make([]byte, KeyLength)
+	if _, err := rand.Read(key); err != nil {
+		panic("couldn't read random key: " + err.Error())
+	}
+	return key
+}
+
+// Upload encrypts the content of r with the key, stores the encrypted chunks
+// and the manifest in the store, and returns the ID of the manifest. If
+// chunkSize is 0, DefaultChunkSize is used.
+func Upload(s Store, key []byte, r io.Reader, chunkSize int) ([]byte, error) {
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 {
+		return nil, xerrors.New("negative chunk size")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every chunk is read before the next one is encrypted, so that the
+	// last chunk can be marked as such. An empty file has one empty chunk.
+	m := &Manifest{ChunkSize: chunkSize}
+	chunk, err := readChunk(r, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); ; i++ {
+		next, err := readChunk(r, chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		last := len(chunk) < chunkSize || len(next) == 0
+		id, err := s.Put(aead.Seal(nil, nonce(aead, i, last), chunk, nil))
+		if err != nil {
+			return nil, xerrors.Errorf("storing chunk %d: %v", i, err)
+		}
+		m.Chunks = append(m.Chunks, id)
+		m.Size += int64(len(chunk))
+		if last {
+			break
+		}
+		chunk = next
+	}
+
+	buf, err := protobuf.Encode(m)
+	if err != nil {
+		return nil, xerrors.Errorf("encoding manifest: %v", err)
+	}
+	id, err := s.Put(buf)
+	if err != nil {
+		return nil, xerrors.Errorf("storing manifest: %v", err)
+	}
+	return id, nil
+}
+
+// Download fetches the file with the given manifest ID from the store,
+// decrypts it with the key, and writes it to w. Every chunk is verified
+// before it is written, but if an error is returned, w may already hold the
+// first chunks of the file.
+func Download(s Store, key []byte, id []byte, w io.Writer) error {
+	m, err := GetManifest(s, id)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for i, cid := range m.Chunks {
+		ct, err := get(s, cid)
+		if err != nil {
+			return xerrors.Errorf("getting chunk %d: %v", i, err)
+		}
+		last := i == len(m.Chunks)-1
+		chunk, err := aead.Open(nil, nonce(aead, uint64(i), last), ct, nil)
+		if err != nil {
+			return xerrors.Errorf("decrypting chunk %d: %v", i, err)
+		}
+		if !last && len(chunk) != m.ChunkSize || len(chunk) > m.ChunkSize {
+			return xerrors.Errorf("chunk %d has a wrong size", i)
+		}
+		size += int64(len(chunk))
+		if _, err := w.Write(chunk); err != nil {
+			return xerrors.Errorf("writing chunk %d: %v", i, err)
+		}
+	}
+	if size != m.Size {
+		return xerrors.Errorf("got %d bytes instead of %d", size, m.Size)
+	}
+	return nil
+}
+
+// GetManifest returns the manifest with the given ID, after checking it
+// against its ID.
+func GetManifest(s Store, id []byte) (*Manifest, error) {
+	buf, err := get(s, id)
+	if err != nil {
+		return nil, xerrors.Errorf("getting manifest: %v", err)
+	}
+	m := &Manifest{}
+	if err := protobuf.Decode(buf, m); err != nil {
+		return nil, xerrors.Errorf("decoding manifest: %v", err)
+	}
+	if len(m.Chunks) == 0 {
+		return nil, xerrors.New("manifest has no chunks")
+	}
+	return m, nil
+}
+
+// get returns the data with the given ID, and makes sure the store returned
+// the correct data.
+func get(s Store, id []byte) ([]byte, error) {
+	buf, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(ID(buf), id) {
+		return nil, xerrors.New("data doesn't match its ID")
+	}
+	return buf, nil
+}
+
+// readChunk reads up to size bytes from r.
+func readChunk(r io.Reader, size int) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, xerrors.Errorf("reading file: %v", err)
+	}
+	return buf[:n], nil
+}
+
+// newAEAD derives the AES-256-GCM key from the key of the file. As every
+// file has its own key, the nonces can be derived from the chunk indexes.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) < 16 {
+		return nil, xerrors.New("key is too short")
+	}
+	h := sha256.New()
+	h.Write([]byte("calypso-blob"))
+	h.Write(key)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, xerrors.Errorf("creating cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce returns the nonce of the chunk at the given index. The last chunk
+// has its own nonces, so that a truncated file is detected.
+func nonce(aead cipher.AEAD, index uint64, last bool) []byte {
+	n := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(n, index)
+	if last {
+		n[8] = 1
+	}
+	return n
+}
@@ -0,0 +1,111 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/protobuf"
+)
+
+func TestUploadDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s, err := NewLocalStore(dir)
+	require.NoError(t, err)
+
+	key := NewKey()
+	for _, size := range []int{0, 1, 64, 65, 64*3 + 5} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		id, err := Upload(s, key, bytes.NewReader(data), 64)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		require.NoError(t, Download(s, key, id, &out))
+		require.True(t, bytes.Equal(data, out.Bytes()))
+		require.Error(t, Download(s, NewKey(), id, &bytes.Buffer{}))
+	}
+}
+
+func TestDownload_Tampered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	s, err := NewLocalStore(dir)
+	require.NoError(t, err)
+
+	key := NewKey()
+	data := make([]byte, 200)
+	id, err := Upload(s, key, bytes.NewReader(data), 64)
+	require.NoError(t, err)
+	m, err := GetManifest(s, id)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(m.Chunks))
+
+	// Dropping the last chunk or swapping chunks is detected.
+	for _, chunks := range [][][]byte{m.Chunks[:3],
+		{m.Chunks[1], m.Chunks[0], m.Chunks[2], m.Chunks[3]}} {
+		bad := *m
+		bad.Chunks = chunks
+		buf, err := protobuf.Encode(&bad)
+		require.NoError(t, err)
+		badID, err := s.Put(buf)
+		require.NoError(t, err)
+		require.Error(t, Download(s, key, badID, &bytes.Buffer{}))
+	}
+
+	// A chunk that doesn't match its ID is detected.
+	require.NoError(t, ioutil.WriteFile(s.path(m.Chunks[1]), []byte("bad"), 0600))
+	require.Error(t, Download(s, key, id, &bytes.Buffer{}))
+}
+
+func TestS3Store(t *testing.T) {
+	var mutex sync.Mutex
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=access/") ||
+			!strings.HasPrefix(r.URL.Path, "/bucket/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			buf, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = buf
+		case http.MethodGet:
+			buf, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(buf)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewS3Store(srv.URL, "bucket", "", "access", "secret")
+	key := NewKey()
+	data := []byte(strings.Repeat("calypso", 100))
+	id, err := Upload(s, key, bytes.NewReader(data), 128)
+	require.NoError(t, err)
+	require.Equal(t, 7, len(objects))
+	var out bytes.Buffer
+	require.NoError(t, Download(s, key, id, &out))
+	require.Equal(t, data, out.Bytes())
+
+	_, err = s.Get(ID([]byte("unknown")))
+	require.Error(t, err)
+}
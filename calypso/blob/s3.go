@@ -0,0 +1,134 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// S3Store keeps the data in a bucket of an S3-compatible store, using
+// path-style URLs and requests signed with AWS signature version 4.
+type S3Store struct {
+	// Endpoint is the URL of the store, https://s3.amazonaws.com by default.
+	Endpoint string
+	// Bucket is the name of the bucket holding the data.
+	Bucket string
+	// Region is the region of the bucket, us-east-1 by default.
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Client is used for the requests, http.DefaultClient by default.
+	Client *http.Client
+}
+
+// NewS3Store returns a store in the given bucket.
+func NewS3Store(endpoint, bucket, region, accessKey, secretKey string) *S3Store {
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Store{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    http.DefaultClient,
+	}
+}
+
+// Put implements Store.
+func (s *S3Store) Put(data []byte) ([]byte, error) {
+	id := ID(data)
+	resp, err := s.do(http.MethodPut, id, data)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return id, nil
+}
+
+// Get implements Store.
+func (s *S3Store) Get(id []byte) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("reading object: %v", err)
+	}
+	return buf, nil
+}
+
+// do sends a signed request for the object with the given ID, and returns
+// the response if it was successful.
+func (s *S3Store) do(method string, id []byte, body []byte) (*http.Response, error) {
+	url := fmt.Sprintf("%s/%s/%x", s.Endpoint, s.Bucket, id)
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, xerrors.Errorf("creating request: %v", err)
+	}
+	s.sign(req, body, time.Now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("sending request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, xerrors.Errorf("%s %x: %s", method, id, resp.Status)
+	}
+	return resp, nil
+}
+
+// sign adds the headers of AWS signature version 4 to the request.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{date, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%x",
+		s.AccessKey, scope, signed, hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,98 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Store is a content-addressed store for the encrypted chunks and the
+// manifests.
+type Store interface {
+	// Put stores the data and returns its ID.
+	Put(data []byte) ([]byte, error)
+	// Get returns the data with the given ID.
+	Get(id []byte) ([]byte, error)
+}
+
+// ID returns the ID of the data in a store, which is its SHA-256 hash.
+func ID(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// Open returns the store described by the given string. A string starting
+// with "s3://" is the bucket of an S3-compatible store, configured with the
+// S3_ENDPOINT, AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// environment variables. Any other string is the directory of a local store.
+func Open(spec string) (Store, error) {
+	if strings.HasPrefix(spec, "s3://") {
+		bucket := strings.TrimPrefix(spec, "s3://")
+		if bucket == "" {
+			return nil, xerrors.New("missing bucket name")
+		}
+		return NewS3Store(os.Getenv("S3_ENDPOINT"), bucket,
+			os.Getenv("AWS_REGION"), os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY")), nil
+	}
+	return NewLocalStore(spec)
+}
+
+// LocalStore keeps the data in files of a directory, named after their IDs.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore returns a store in the given directory, which is created if
+// it doesn't exist.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, xerrors.Errorf("creating store: %v", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put implements Store.
+func (s *LocalStore) Put(data []byte) ([]byte, error) {
+	id := ID(data)
+	fn := s.path(id)
+	if _, err := os.Stat(fn); err == nil {
+		return id, nil
+	}
+	// Write to a temporary file first, so that a file named after an ID
+	// always holds the whole data.
+	tmp, err := ioutil.TempFile(s.dir, "tmp-")
+	if err != nil {
+		return nil, xerrors.Errorf("creating file: %v", err)
+	}
+	_, err = tmp.Write(data)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fn)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, xerrors.Errorf("writing file: %v", err)
+	}
+	return id, nil
+}
+
+// Get implements Store.
+func (s *LocalStore) Get(id []byte) ([]byte, error) {
+	buf, err := ioutil.ReadFile(s.path(id))
+	if err != nil {
+		return nil, xerrors.Errorf("reading file: %v", err)
+	}
+	return buf, nil
+}
+
+func (s *LocalStore) path(id []byte) string {
+	return filepath.Join(s.dir, hex.EncodeToString(id))
+}
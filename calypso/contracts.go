@@ -1,6 +1,7 @@
 package calypso
 
 import (
+	"encoding/binary"
 	"fmt"
 	"strings"
 
@@ -79,6 +80,15 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 			err = xerrors.Errorf("proof of write failed: %v", err)
 			return
 		}
+		if c.Write.Release != nil {
+			if err = c.Write.Release.check(); err != nil {
+				return
+			}
+		}
+		if len(c.Write.Key) > 0 {
+			err = xerrors.New("the key can only be published by the LTS")
+			return
+		}
 		instID, err := inst.DeriveIDArg("", "preID")
 		if err != nil {
 			return nil, nil, xerrors.Errorf(
@@ -99,6 +109,14 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		if !rd.Write.Equal(inst.InstanceID) {
 			return nil, nil, xerrors.New("the read request doesn't reference this write-instance")
 		}
+		if c.Release != nil {
+			return nil, nil, xerrors.New("the secret of this write is only released by its condition")
+		}
+		if rd.Group != nil {
+			if err := checkGroupRead(rst, &rd); err != nil {
+				return nil, nil, xerrors.Errorf("invalid group read: %v", err)
+			}
+		}
 		if c.Cost.Value > 0 {
 			for i, coin := range cout {
 				if coin.Name.Equal(c.Cost.Name) {
@@ -124,9 +142,12 @@ func (c ContractWrite) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 	return
 }
 
-// Invoke supports the following command:
+// Invoke supports the following commands:
 //  - update - it takes a 'data' and/or 'extraData' argument that is used to
 //    update the data and/or extradata part of the write structure.
+//  - publish - it takes the released secret in a 'key' argument, and can only
+//    be sent by a node of the LTS once the release condition is reached. The
+//    key cannot be verified here, so it is only a hint for the clients.
 func (c *ContractWrite) Invoke(rst byzcoin.ReadOnlyStateTrie,
 	inst byzcoin.Instruction, cin []byzcoin.Coin) ([]byzcoin.StateChange,
 	[]byzcoin.Coin, error) {
@@ -150,8 +171,23 @@ func (c *ContractWrite) Invoke(rst byzcoin.ReadOnlyStateTrie,
 			c.ExtraData = extraData
 			update = true
 		}
+	case "publish":
+		if c.Release == nil || !c.Release.Publish {
+			return nil, nil, xerrors.New("this write doesn't publish its secret")
+		}
+		if len(c.Key) > 0 {
+			return nil, nil, xerrors.New("the secret is already published")
+		}
+		if err := c.Release.Reached(rst.GetIndex(), blockTimestamp(rst)); err != nil {
+			return nil, nil, xerrors.Errorf("the secret is not released yet: %v", err)
+		}
+		c.Key = inst.Invoke.Args.Search("key")
+		if len(c.Key) == 0 {
+			return nil, nil, xerrors.New("need a key argument")
+		}
+		update = true
 	default:
-		return nil, nil, xerrors.New("only know 'update' command")
+		return nil, nil, xerrors.New("only know 'update' and 'publish' commands")
 	}
 
 	if !update {
@@ -180,6 +216,95 @@ func contractReadFromBytes(in []byte) (byzcoin.Contract, error) {
 	return nil, xerrors.New("calypso read instances are never instantiated")
 }
 
+// checkGroupRead makes sure the read re-encrypts to the current key of the
+// group it points to.
+func checkGroupRead(rst byzcoin.ReadOnlyStateTrie, rd *Read) error {
+	buf, _, cid, _, err := rst.GetValues(rd.Group.Slice())
+	if err != nil {
+		return xerrors.Errorf("getting group key: %v", err)
+	}
+	if cid != ContractGroupKeyID {
+		return xerrors.New("group doesn't point to a group key instance")
+	}
+	var gk GroupKey
+	err = protobuf.DecodeWithConstructors(buf, &gk, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return xerrors.Errorf("decoding group key: %v", err)
+	}
+	if !rd.Xc.Equal(gk.X) {
+		return xerrors.New("Xc is not the key of the group")
+	}
+	return nil
+}
+
+// ContractGroupKeyID references a group key contract system-wide.
+const ContractGroupKeyID = "calypsoGroupKey"
+
+// contractGroupKey holds the public key of a group of readers. The darc of
+// the instance defines who can update the key.
+type contractGroupKey struct {
+	byzcoin.BasicContract
+	GroupKey
+}
+
+func contractGroupKeyFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &contractGroupKey{}
+
+	err := protobuf.DecodeWithConstructors(in, &c.GroupKey, network.DefaultConstructors(cothority.Suite))
+	return c, cothority.ErrorOrNil(err, "couldn't unmarshal group key")
+}
+
+// decodeGroupKey returns the group key in the 'groupKey' argument.
+func decodeGroupKey(args byzcoin.Arguments) ([]byte, error) {
+	buf := args.Search("groupKey")
+	if len(buf) == 0 {
+		return nil, xerrors.New("need a groupKey argument")
+	}
+	var gk GroupKey
+	err := protobuf.DecodeWithConstructors(buf, &gk, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("passed groupKey argument is invalid: %v", err)
+	}
+	if gk.X == nil || gk.X.Equal(cothority.Suite.Point().Null()) {
+		return nil, xerrors.New("the group key is empty")
+	}
+	return buf, nil
+}
+
+// Spawn creates a new group key instance, governed by the darc it is spawned
+// from.
+func (c *contractGroupKey) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) ([]byzcoin.StateChange, []byzcoin.Coin, error) {
+	_, _, _, darcID, err := rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("getting values: %v", err)
+	}
+	buf, err := decodeGroupKey(inst.Spawn.Args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create,
+		inst.DeriveID(""), ContractGroupKeyID, buf, darcID)}, coins, nil
+}
+
+// Invoke supports the following command:
+//  - update - replaces the key of the group with the one in the 'groupKey'
+//    argument. Reads done before keep the old key.
+func (c *contractGroupKey) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) ([]byzcoin.StateChange, []byzcoin.Coin, error) {
+	_, _, _, darcID, err := rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("getting values: %v", err)
+	}
+	if inst.Invoke.Command != "update" {
+		return nil, nil, xerrors.New("only know 'update' command")
+	}
+	buf, err := decodeGroupKey(inst.Invoke.Args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update,
+		inst.InstanceID, ContractGroupKeyID, buf, darcID)}, coins, nil
+}
+
 // ContractLongTermSecretID is the contract ID for updating the LTS roster.
 var ContractLongTermSecretID = "longTermSecret"
 
@@ -214,6 +339,13 @@ func (c *contractLTS) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruct
 	if err != nil {
 		return nil, nil, xerrors.Errorf("passed lts_instance_info argument is invalid: %v", err)
 	}
+	if info.Epoch != nil {
+		return nil, nil, xerrors.New("the epoch is set by the contract")
+	}
+	infoBuf, err = info.startEpochs(rst)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding lts_instance_info: %v", err)
+	}
 	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""), ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
 }
 
@@ -224,6 +356,9 @@ func (c *contractLTS) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		return nil, nil, xerrors.Errorf("getting values: %v", err)
 	}
 
+	if inst.Invoke.Command == "epoch" {
+		return c.invokeEpoch(rst, inst, coins, curBuf, darcID)
+	}
 	if inst.Invoke.Command != "reshare" {
 		return nil, nil, xerrors.New("can only reshare long-term secrets")
 	}
@@ -251,9 +386,81 @@ func (c *contractLTS) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruc
 		return nil, nil, xerrors.New("new roster does not overlap enough with current roster")
 	}
 
+	// The epochs can only be changed by the LTS nodes.
+	newInfo.Epoch = curInfo.Epoch
+	infoBuf, err = newInfo.startEpochs(rst)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding lts_instance_info: %v", err)
+	}
+
 	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
 }
 
+// invokeEpoch records a new proactive resharing. The "block" argument is the
+// index of the block that triggered the resharing.
+func (c *contractLTS) invokeEpoch(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction,
+	coins []byzcoin.Coin, curBuf []byte, darcID darc.ID) ([]byzcoin.StateChange, []byzcoin.Coin, error) {
+	var info LtsInstanceInfo
+	err := protobuf.DecodeWithConstructors(curBuf, &info, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("current info is invalid: %v", err)
+	}
+	if info.Epoch == nil || info.ReshareInterval == 0 {
+		return nil, nil, xerrors.New("no proactive resharing for this LTS")
+	}
+	blockBuf := inst.Invoke.Args.Search("block")
+	if len(blockBuf) != 8 {
+		return nil, nil, xerrors.New("need a block argument")
+	}
+	block := int(binary.LittleEndian.Uint64(blockBuf))
+	if block <= info.Epoch.BlockIndex || block > rst.GetIndex() {
+		return nil, nil, xerrors.Errorf("invalid block %d for the epoch", block)
+	}
+	info.Epoch = &ReshareEpoch{
+		Index:      info.Epoch.Index + 1,
+		BlockIndex: block,
+		Timestamp:  blockTimestamp(rst),
+	}
+	infoBuf, err := protobuf.Encode(&info)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("encoding lts_instance_info: %v", err)
+	}
+	return byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractLongTermSecretID, infoBuf, darcID)}, coins, nil
+}
+
+// VerifyInstruction makes sure the epochs are recorded by a node of the LTS,
+// instead of checking the darc.
+func (c *contractLTS) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, ctxHash []byte) error {
+	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "epoch" {
+		return verifyNodeSigner(rst, &c.LtsInstanceInfo.Roster, inst, ctxHash)
+	}
+	return inst.VerifyWithOption(rst, ctxHash, nil)
+}
+
+// startEpochs sets the first epoch if the proactive resharing is enabled,
+// and returns the encoded info.
+func (info *LtsInstanceInfo) startEpochs(rst byzcoin.ReadOnlyStateTrie) ([]byte, error) {
+	if info.ReshareInterval < 0 {
+		return nil, xerrors.New("negative reshare interval")
+	}
+	if info.ReshareInterval > 0 && info.Epoch == nil {
+		info.Epoch = &ReshareEpoch{
+			BlockIndex: rst.GetIndex(),
+			Timestamp:  blockTimestamp(rst),
+		}
+	}
+	return protobuf.Encode(info)
+}
+
+// blockTimestamp returns the timestamp of the block being created, in
+// nanoseconds.
+func blockTimestamp(rst byzcoin.ReadOnlyStateTrie) int64 {
+	if tr, ok := rst.(byzcoin.TimeReader); ok {
+		return tr.GetCurrentBlockTimestamp()
+	}
+	return 0
+}
+
 func intersectRosters(r1, r2 *onet.Roster) int {
 	res := 0
 	for _, x := range r2.List {
@@ -276,5 +483,59 @@ func (c ContractWrite) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst byz
 		}
 		return inst.VerifyWithOption(rst, ctxHash, &byzcoin.VerificationOptions{EvalAttr: evalAttr})
 	}
+	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "publish" {
+		return c.verifyPublish(rst, inst, ctxHash)
+	}
 	return inst.VerifyWithOption(rst, ctxHash, nil)
 }
+
+// verifyPublish makes sure the secret is published by a node of the LTS of
+// the write, instead of checking the darc.
+func (c ContractWrite) verifyPublish(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, ctxHash []byte) error {
+	buf, _, cid, _, err := rst.GetValues(c.LTSID.Slice())
+	if err != nil {
+		return xerrors.Errorf("getting LTS instance: %v", err)
+	}
+	if cid != ContractLongTermSecretID {
+		return xerrors.New("LTSID doesn't point to an LTS instance")
+	}
+	var info LtsInstanceInfo
+	err = protobuf.DecodeWithConstructors(buf, &info, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return xerrors.Errorf("decoding LTS instance: %v", err)
+	}
+	return verifyNodeSigner(rst, &info.Roster, inst, ctxHash)
+}
+
+// verifyNodeSigner makes sure the instruction is signed by one node of the
+// roster.
+func verifyNodeSigner(rst byzcoin.ReadOnlyStateTrie, roster *onet.Roster,
+	inst byzcoin.Instruction, ctxHash []byte) error {
+	if len(inst.SignerIdentities) != 1 || len(inst.Signatures) != 1 ||
+		len(inst.SignerCounter) != 1 {
+		return xerrors.New("the instruction must be signed by one node")
+	}
+	id := inst.SignerIdentities[0]
+	if id.Ed25519 == nil {
+		return xerrors.New("the instruction must be signed by a node")
+	}
+	found := false
+	for _, si := range roster.List {
+		if si.Public.Equal(id.Ed25519.Point) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return xerrors.New("the instruction must be signed by a node of the LTS")
+	}
+	ctr, err := rst.GetSignerCounter(id)
+	if err != nil {
+		return xerrors.Errorf("getting counter: %v", err)
+	}
+	if inst.SignerCounter[0] != ctr+1 {
+		return xerrors.Errorf("got counter %d, expected %d", inst.SignerCounter[0], ctr+1)
+	}
+	return cothority.ErrorOrNil(id.Verify(ctxHash, inst.Signatures[0]),
+		"verifying signature")
+}
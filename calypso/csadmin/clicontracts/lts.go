@@ -20,7 +20,8 @@ import (
 
 // LTSSpawn spawns a instance of an LTS contract. It prints the instance id,
 // which can then be used to stat the DKG. This instance id will also be needed
-// to send write requests.
+// to send write requests. With --reshareInterval, the LTS nodes reshare their
+// shares every given number of blocks.
 // With the --export option, the instance id is sent to STDOUT.
 func LTSSpawn(c *cli.Context) error {
 	bcArg := c.String("bc")
@@ -62,7 +63,8 @@ func LTSSpawn(c *cli.Context) error {
 	export := c.Bool("export")
 
 	// Make the transaction and get its proof
-	ltsInstanceInfo := calypso.LtsInstanceInfo{Roster: cfg.Roster}
+	ltsInstanceInfo := calypso.LtsInstanceInfo{Roster: cfg.Roster,
+		ReshareInterval: c.Int("reshareInterval")}
 	if rFile := c.String("roster"); rFile != "" {
 		r, err := lib.ReadRoster(rFile)
 		if err != nil {
@@ -15,6 +15,7 @@ import (
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
 	"go.dedis.ch/cothority/v3/calypso"
+	"go.dedis.ch/cothority/v3/calypso/blob"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/protobuf"
 )
@@ -30,8 +31,11 @@ import (
 // is useful to store cleartext data, where --data should be used to store
 // encrypted data. The 'extra data' field can be filled with either --extraData
 // or --readExtra. Both --readExtra and --readData can NOT be used at the same
-// time. If everything goes well, it prints the instance id of the newly spawned
-// Write instance. With the --export option, the instance id is sent to STDOUT.
+// time. With --upload, the given file is encrypted under a random secret and
+// stored in the blob store given by --store, and the data of the write is the
+// ID of its manifest. If everything goes well, it prints the instance id of
+// the newly spawned Write instance. With the --export option, the instance id
+// is sent to STDOUT.
 func WriteSpawn(c *cli.Context) error {
 	bcArg := c.String("bc")
 	if bcArg == "" {
@@ -78,13 +82,26 @@ func WriteSpawn(c *cli.Context) error {
 		extraDataBuf = []byte(c.String("extraData"))
 	}
 
-	secret := c.String("secret")
-	if secret == "" {
-		return xerrors.New("please provide secret with --secret")
-	}
-	secretBuf, err := hex.DecodeString(secret)
-	if err != nil {
-		return xerrors.Errorf("failed to decode secret as hexadecimal: %v", err)
+	var secretBuf []byte
+	if c.String("upload") != "" {
+		if c.String("secret") != "" || len(dataBuf) > 0 {
+			return xerrors.New("--secret and --data can not be used " +
+				"together with --upload")
+		}
+		secretBuf = blob.NewKey()
+		dataBuf, err = uploadFile(c, secretBuf)
+		if err != nil {
+			return xerrors.Errorf("uploading file: %v", err)
+		}
+	} else {
+		secret := c.String("secret")
+		if secret == "" {
+			return xerrors.New("please provide secret with --secret")
+		}
+		secretBuf, err = hex.DecodeString(secret)
+		if err != nil {
+			return xerrors.Errorf("failed to decode secret as hexadecimal: %v", err)
+		}
 	}
 
 	instidstr := c.String("instid")
@@ -187,6 +204,25 @@ func WriteSpawn(c *cli.Context) error {
 	return nil
 }
 
+// uploadFile encrypts the file given by --upload with the key, stores it in
+// the blob store given by --store, and returns the ID of its manifest.
+func uploadFile(c *cli.Context, key []byte) ([]byte, error) {
+	storeStr := c.String("store")
+	if storeStr == "" {
+		return nil, xerrors.New("please provide the blob store with --store")
+	}
+	store, err := blob.Open(storeStr)
+	if err != nil {
+		return nil, xerrors.Errorf("opening store: %v", err)
+	}
+	f, err := os.Open(c.String("upload"))
+	if err != nil {
+		return nil, xerrors.Errorf("opening file: %v", err)
+	}
+	defer f.Close()
+	return blob.Upload(store, key, f, c.Int("chunkSize"))
+}
+
 // WriteGet checks the proof and prints the content of the Write contract.
 func WriteGet(c *cli.Context) error {
 
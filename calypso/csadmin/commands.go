@@ -99,6 +99,55 @@ var cmds = cli.Commands{
 			},
 		},
 	},
+	{
+		Name:   "download",
+		Usage:  "download a file stored with --upload given a DecryptKeyReply struct read from STDIN",
+		Action: download,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config to use (required)",
+			},
+			cli.StringFlag{
+				Name:  "writeid, w",
+				Usage: "instance id of the write instance",
+			},
+			cli.StringFlag{
+				Name:  "store",
+				Usage: "blob store of the file: a directory, or s3://bucket for an S3-compatible store",
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "path to the private.toml file (default is admin key)",
+			},
+			cli.StringFlag{
+				Name:  "out, o",
+				Usage: "file to write the decrypted file to (default is STDOUT)",
+			},
+		},
+	},
+	{
+		Name:   "audit",
+		Usage:  "list all the read instances of a write instance",
+		Action: audit,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "bc",
+				EnvVar: "BC",
+				Usage:  "the ByzCoin config to use (required)",
+			},
+			cli.StringFlag{
+				Name:  "writeid, w",
+				Usage: "instance id of the write instance",
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "output format: csv or json",
+				Value: "csv",
+			},
+		},
+	},
 	{
 		Name:  "contract",
 		Usage: "Provides cli interface for contracts",
@@ -122,6 +171,10 @@ var cmds = cli.Commands{
 								Usage: "the path of a roster file to be used as argument for the spawn. " +
 									"If not provided the config roster is used (optional)",
 							},
+							cli.IntFlag{
+								Name:  "reshareInterval",
+								Usage: "number of blocks between two proactive resharings of the LTS (default is no proactive resharing)",
+							},
 							cli.StringFlag{
 								Name:  "darc",
 								Usage: "DARC with the right to create an LTS (default is the admin DARC)",
@@ -184,6 +237,18 @@ var cmds = cli.Commands{
 								Name:  "readExtra, re",
 								Usage: "if provided, the --extraData flag is not used and the extra data is read from STDIN. Can NOT be used conjointly with --readData.",
 							},
+							cli.StringFlag{
+								Name:  "upload",
+								Usage: "file to encrypt with a random secret and to store in the blob store. Can NOT be used with --secret or --data.",
+							},
+							cli.StringFlag{
+								Name:  "store",
+								Usage: "blob store for --upload: a directory, or s3://bucket for an S3-compatible store",
+							},
+							cli.IntFlag{
+								Name:  "chunkSize",
+								Usage: "size of the encrypted chunks of --upload (default is 1MB)",
+							},
 							cli.StringFlag{
 								Name:  "key",
 								Usage: "hexadecimal LTS public key",
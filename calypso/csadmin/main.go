@@ -2,21 +2,26 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/calypso"
+	"go.dedis.ch/cothority/v3/calypso/blob"
 	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/keystore"
 	"go.dedis.ch/onet/v3"
-	"go.dedis.ch/onet/v3/app"
 	"golang.org/x/xerrors"
 
 	"github.com/urfave/cli"
@@ -80,7 +85,7 @@ func authorize(c *cli.Context) error {
 		return xerrors.New("please give: private.toml byzcoin-id")
 	}
 
-	cfg, err := app.LoadCothority(c.Args().First())
+	cfg, err := keystore.LoadCothority(c.Args().First())
 	if err != nil {
 		return xerrors.Errorf("loading cothority: %v", err)
 	}
@@ -329,27 +334,184 @@ func reencrypt(c *cli.Context) error {
 // expects the DecryptKeyReply to be protobuf encoded and passed in STDIN. With
 // the --export option, the recovered secret is sent to STDOUT.
 func decrypt(c *cli.Context) error {
-	decryptKeyReplyBuf, err := ioutil.ReadAll(os.Stdin)
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	cfg, _, err := lib.LoadConfig(bcArg)
 	if err != nil {
-		return xerrors.Errorf("failed to read from stdin: %v", err)
+		return xerrors.Errorf("loading byzcoin config: %v", err)
 	}
 
-	dkr := calypso.DecryptKeyReply{}
-	err = protobuf.Decode(decryptKeyReplyBuf, &dkr)
+	key, err := recoverKey(c, cfg)
 	if err != nil {
-		return xerrors.Errorf("failed to decode decryptKeyReply: %v", err)
+		return err
 	}
 
+	if c.Bool("export") {
+		reader := bytes.NewReader(key)
+		_, err = io.Copy(os.Stdout, reader)
+		if err != nil {
+			return xerrors.Errorf("failed to copy to stdout: %v", err)
+		}
+		return nil
+	}
+
+	log.Infof("Key decrypted:\n%x", key)
+
+	return nil
+}
+
+// download fetches and decrypts a file that has been stored with the
+// --upload option of the write spawn. It expects the DecryptKeyReply of the
+// write instance to be protobuf encoded and passed in STDIN. The file is
+// verified against the manifest stored in the write instance.
+func download(c *cli.Context) error {
 	bcArg := c.String("bc")
 	if bcArg == "" {
 		return xerrors.New("--bc flag is required")
 	}
 
-	cfg, _, err := lib.LoadConfig(bcArg)
+	cfg, cl, err := lib.LoadConfig(bcArg)
+	if err != nil {
+		return xerrors.Errorf("loading byzcoin config: %v", err)
+	}
+
+	storeStr := c.String("store")
+	if storeStr == "" {
+		return xerrors.New("please provide the blob store with --store")
+	}
+	store, err := blob.Open(storeStr)
+	if err != nil {
+		return xerrors.Errorf("opening store: %v", err)
+	}
+
+	writeID, err := hex.DecodeString(c.String("writeid"))
+	if err != nil || len(writeID) == 0 {
+		return xerrors.New("please provide the write instance id with --writeid")
+	}
+	resp, err := cl.GetProof(writeID)
+	if err != nil {
+		return xerrors.Errorf("failed to get proof: %v", err)
+	}
+	var write calypso.Write
+	err = resp.Proof.VerifyAndDecode(cothority.Suite, calypso.ContractWriteID, &write)
+	if err != nil {
+		return xerrors.Errorf("failed to get write instance: %v", err)
+	}
+
+	key, err := recoverKey(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if fn := c.String("out"); fn != "" {
+		out, err = os.Create(fn)
+		if err != nil {
+			return xerrors.Errorf("failed to create file: %v", err)
+		}
+		defer out.Close()
+	}
+	err = blob.Download(store, key, write.Data, out)
+	if err != nil {
+		return xerrors.Errorf("failed to download file: %v", err)
+	}
+	return nil
+}
+
+// auditEntry is one read instance, as printed by audit.
+type auditEntry struct {
+	ReadID      string   `json:"read_id"`
+	Readers     []string `json:"readers"`
+	Xc          string   `json:"xc"`
+	BlockIndex  int      `json:"block_index"`
+	Time        string   `json:"time"`
+	DarcID      string   `json:"darc_id"`
+	DarcVersion uint64   `json:"darc_version"`
+	Proof       string   `json:"proof"`
+}
+
+// audit prints all the read instances of a write instance, as CSV or JSON.
+// The proofs of the reads are verified, and printed as hex encoded protobuf
+// messages, so that they can be checked again later.
+func audit(c *cli.Context) error {
+	bcArg := c.String("bc")
+	if bcArg == "" {
+		return xerrors.New("--bc flag is required")
+	}
+
+	_, cl, err := lib.LoadConfig(bcArg)
 	if err != nil {
 		return xerrors.Errorf("loading byzcoin config: %v", err)
 	}
 
+	writeID, err := hex.DecodeString(c.String("writeid"))
+	if err != nil || len(writeID) == 0 {
+		return xerrors.New("please provide the write instance id with --writeid")
+	}
+
+	format := c.String("format")
+	if format != "csv" && format != "json" {
+		return xerrors.Errorf("unknown format: %s", format)
+	}
+
+	reply, err := calypso.NewClient(cl).Audit(byzcoin.NewInstanceID(writeID))
+	if err != nil {
+		return xerrors.Errorf("failed to audit write: %v", err)
+	}
+
+	entries := make([]auditEntry, len(reply.Reads))
+	for i, read := range reply.Reads {
+		proofBuf, err := protobuf.Encode(&read.Proof)
+		if err != nil {
+			return xerrors.Errorf("failed to encode proof: %v", err)
+		}
+		entries[i] = auditEntry{
+			ReadID:      hex.EncodeToString(read.ReadID.Slice()),
+			Readers:     read.Readers,
+			Xc:          read.Xc.String(),
+			BlockIndex:  read.BlockIndex,
+			Time:        time.Unix(0, read.Timestamp).UTC().Format(time.RFC3339),
+			DarcID:      hex.EncodeToString(read.DarcID),
+			DarcVersion: read.DarcVersion,
+			Proof:       hex.EncodeToString(proofBuf),
+		}
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return cothority.ErrorOrNil(enc.Encode(entries), "failed to write JSON")
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"read_id", "readers", "xc", "block_index", "time",
+		"darc_id", "darc_version", "proof"})
+	for _, e := range entries {
+		w.Write([]string{e.ReadID, strings.Join(e.Readers, ";"), e.Xc,
+			strconv.Itoa(e.BlockIndex), e.Time, e.DarcID,
+			strconv.FormatUint(e.DarcVersion, 10), e.Proof})
+	}
+	w.Flush()
+	return cothority.ErrorOrNil(w.Error(), "failed to write CSV")
+}
+
+// recoverKey reads a protobuf encoded DecryptKeyReply from STDIN, and
+// recovers the secret with the private key given by --key.
+func recoverKey(c *cli.Context, cfg lib.Config) ([]byte, error) {
+	decryptKeyReplyBuf, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to read from stdin: %v", err)
+	}
+
+	dkr := calypso.DecryptKeyReply{}
+	err = protobuf.Decode(decryptKeyReplyBuf, &dkr)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to decode decryptKeyReply: %v", err)
+	}
+
 	keyPath := c.String("key")
 	var signer *darc.Signer
 	if keyPath == "" {
@@ -358,29 +520,17 @@ func decrypt(c *cli.Context) error {
 		signer, err = lib.LoadSigner(keyPath)
 	}
 	if err != nil {
-		return xerrors.Errorf("failed to load key file: %v", err)
+		return nil, xerrors.Errorf("failed to load key file: %v", err)
 	}
 
 	xc, err := signer.GetPrivate()
 	if err != nil {
-		return xerrors.Errorf("failed to get private key: %v", err)
+		return nil, xerrors.Errorf("failed to get private key: %v", err)
 	}
 
 	key, err := dkr.RecoverKey(xc)
 	if err != nil {
-		return xerrors.Errorf("failed to recover the key: %v", err)
-	}
-
-	if c.Bool("export") {
-		reader := bytes.NewReader(key)
-		_, err = io.Copy(os.Stdout, reader)
-		if err != nil {
-			return xerrors.Errorf("failed to copy to stdout: %v", err)
-		}
-		return nil
+		return nil, xerrors.Errorf("failed to recover the key: %v", err)
 	}
-
-	log.Infof("Key decrypted:\n%x", key)
-
-	return nil
+	return key, nil
 }
@@ -34,6 +34,8 @@ main(){
     run testContractRead
     run testReencrypt
     run testDecrypt
+    run testDownload
+    run testAudit
     stopTest
 }
 
@@ -292,6 +294,136 @@ aabbccddeeff0011"
 aabbccddeeff0011"
 }
 
+# rely on:
+# - csadmin contract lts spawn
+# - csadmin authorize
+# - csadmin contract write spawn
+# - csadmin contract read spawn
+# - csadmin reencrypt
+testDownload(){
+    rm -f config/*
+    runCoBG 1 2 3
+    runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+    eval $SED
+    [ -z "$BC" ] && exit 1
+
+    # Create a DARC
+    testOK runBA darc add -out_id ./darc_id.txt -out_key ./darc_key.txt -unrestricted
+    ID=`cat ./darc_id.txt`
+    KEY=`cat ./darc_key.txt`
+    testOK runBA darc rule -rule "spawn:longTermSecret" --darc $ID --sign $KEY --identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoWrite" -darc $ID -sign $KEY -identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoRead" -darc $ID -sign $KEY -identity $KEY
+
+    # Spawn LTS
+    OUTRES=`runCA0 contract lts spawn --darc "$ID" --sign "$KEY"`
+    LTS_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $LTS_ID ^[0-9a-f]{64}$
+
+    # Authorize nodes
+    bcID=$( ls config/bc-* | sed -e "s/.*bc-\(.*\).cfg/\1/" )
+    testOK runCA authorize co1/private.toml $bcID
+    testOK runCA authorize co2/private.toml $bcID
+    testOK runCA authorize co3/private.toml $bcID
+
+    # Creat LTS and save the public key
+    runCA0 dkg start --instid "$LTS_ID" -x > key.pub
+    PUB_KEY=`cat key.pub`
+    matchOK $PUB_KEY ^[0-9a-f]{64}$
+
+    # Upload a file of 1MB in chunks of 64kB
+    rm -rf store
+    head -c 1000000 /dev/urandom > file.bin
+    testFail runCA contract write spawn --darc "$ID" --sign "$KEY" \
+                    --instid "$LTS_ID" --key "$PUB_KEY" --upload file.bin
+    testFail runCA contract write spawn --darc "$ID" --sign "$KEY" \
+                    --instid "$LTS_ID" --key "$PUB_KEY" --upload file.bin \
+                    --store store --secret "aabbccdd"
+    OUTRES=`runCA0 contract write spawn --darc "$ID" --sign "$KEY" \
+                    --instid "$LTS_ID" --key "$PUB_KEY" --upload file.bin \
+                    --store store --chunkSize 65536`
+    WRITE_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $WRITE_ID ^[0-9a-f]{64}$
+    # 16 chunks and the manifest
+    matchOK "`ls store | wc -l | tr -d ' '`" "^17$"
+
+    # Spawn read and re-encrypt
+    OUTRES=`runCA0 contract read spawn --sign $KEY --instid $WRITE_ID`
+    READ_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $READ_ID ^[0-9a-f]{64}$
+    runCA0 reencrypt --writeid $WRITE_ID --readid $READ_ID -x > reply.bin
+
+    # The admin key cannot decrypt the file
+    testFail runCA download --writeid $WRITE_ID --store store -o out.bin < reply.bin
+    testOK runCA download --writeid $WRITE_ID --store store \
+                --key config/key-$KEY.cfg -o out.bin < reply.bin
+    testOK cmp file.bin out.bin
+
+    # A modified chunk is detected
+    CHUNK=`ls store | head -n 1`
+    echo "bad" > store/$CHUNK
+    testFail runCA download --writeid $WRITE_ID --store store \
+                --key config/key-$KEY.cfg -o out.bin < reply.bin
+}
+
+# rely on:
+# - csadmin contract lts spawn
+# - csadmin authorize
+# - csadmin contract write spawn
+# - csadmin contract read spawn
+testAudit(){
+    rm -f config/*
+    runCoBG 1 2 3
+    runGrepSed "export BC=" "" runBA create --roster public.toml --interval .5s
+    eval $SED
+    [ -z "$BC" ] && exit 1
+
+    # Create a DARC
+    testOK runBA darc add -out_id ./darc_id.txt -out_key ./darc_key.txt -unrestricted
+    ID=`cat ./darc_id.txt`
+    KEY=`cat ./darc_key.txt`
+    testOK runBA darc rule -rule "spawn:longTermSecret" --darc $ID --sign $KEY --identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoWrite" -darc $ID -sign $KEY -identity $KEY
+    testOK runBA darc rule -rule "spawn:calypsoRead" -darc $ID -sign $KEY -identity $KEY
+
+    # Spawn LTS
+    OUTRES=`runCA0 contract lts spawn --darc "$ID" --sign "$KEY"`
+    LTS_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $LTS_ID ^[0-9a-f]{64}$
+
+    # Authorize nodes
+    bcID=$( ls config/bc-* | sed -e "s/.*bc-\(.*\).cfg/\1/" )
+    testOK runCA authorize co1/private.toml $bcID
+    testOK runCA authorize co2/private.toml $bcID
+    testOK runCA authorize co3/private.toml $bcID
+
+    runCA0 dkg start --instid "$LTS_ID" -x > key.pub
+    PUB_KEY=`cat key.pub`
+    matchOK $PUB_KEY ^[0-9a-f]{64}$
+
+    OUTRES=`runCA0 contract write spawn --darc "$ID" --sign "$KEY" \
+                    --instid "$LTS_ID" --secret "aabbccddeeff0011" --key "$PUB_KEY"`
+    WRITE_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $WRITE_ID ^[0-9a-f]{64}$
+
+    testFail runCA audit
+    testFail runCA audit --writeid $WRITE_ID --format xml
+
+    # No read yet: only the CSV header
+    matchOK "`runCA0 audit --writeid $WRITE_ID | wc -l | tr -d ' '`" "^1$"
+
+    OUTRES=`runCA0 contract read spawn --sign $KEY --instid $WRITE_ID`
+    READ_ID=`echo "$OUTRES" | sed -n '2p'` # must be at the second line
+    matchOK $READ_ID ^[0-9a-f]{64}$
+
+    OUTRES=`runCA0 audit --writeid $WRITE_ID`
+    matchOK "`echo "$OUTRES" | sed -n '1p'`" \
+        "^read_id,readers,xc,block_index,time,darc_id,darc_version,proof$"
+    matchOK "`echo "$OUTRES" | sed -n '2p'`" "^$READ_ID,$KEY,"
+    OUTRES=`runCA0 audit --writeid $WRITE_ID --format json`
+    testGrep "\"read_id\": \"$READ_ID\"" echo "$OUTRES"
+}
+
 runCA(){
     ./csadmin -c config/ --debug $DBG_APP "$@"
 }
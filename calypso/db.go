@@ -6,6 +6,7 @@ import (
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
 	dkgprotocol "go.dedis.ch/cothority/v3/dkg/pedersen"
+	"go.dedis.ch/cothority/v3/keystore"
 	dkg "go.dedis.ch/kyber/v3/share/dkg/pedersen"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
@@ -27,6 +28,9 @@ type storage struct {
 	Rosters map[byzcoin.InstanceID]*onet.Roster
 	Replies map[byzcoin.InstanceID]*CreateLTSReply
 	DKS     map[byzcoin.InstanceID]*dkg.DistKeyShare
+	// Proactive holds the latest proof of the LTS instances with a
+	// proactive resharing.
+	Proactive map[byzcoin.InstanceID]*byzcoin.Proof
 
 	sync.Mutex
 }
@@ -35,7 +39,7 @@ type storage struct {
 func (s *Service) save() error {
 	s.storage.Lock()
 	defer s.storage.Unlock()
-	err := s.Save(storageKey, s.storage)
+	err := keystore.Save(s, storageKey, s.storage)
 	if err != nil {
 		log.Error("Couldn't save data:", err)
 		return xerrors.Errorf("saving data: %v", err)
@@ -69,6 +73,9 @@ func (s *Service) tryLoad() error {
 		if len(s.storage.DKS) == 0 {
 			s.storage.DKS = make(map[byzcoin.InstanceID]*dkg.DistKeyShare)
 		}
+		if len(s.storage.Proactive) == 0 {
+			s.storage.Proactive = make(map[byzcoin.InstanceID]*byzcoin.Proof)
+		}
 		if len(s.storage.AuthorisedByzCoinIDs) == 0 {
 			s.storage.AuthorisedByzCoinIDs = make(map[string]bool)
 		}
@@ -82,7 +89,7 @@ func (s *Service) tryLoad() error {
 		}
 		return cothority.ErrorOrNil(s.SaveVersion(dbVersion), "saving version")
 	}
-	msg, err := s.Load(storageKey)
+	msg, err := keystore.Load(s, storageKey)
 	if err != nil {
 		return xerrors.Errorf("loading storage: %v", err)
 	}
@@ -0,0 +1,168 @@
+package calypso
+
+import (
+	"encoding/binary"
+	"time"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/xerrors"
+)
+
+// reshareCheckInterval is how often the leader of an LTS with a proactive
+// resharing checks whether the next resharing is due.
+var reshareCheckInterval = 10 * time.Second
+
+// storeProactive remembers the latest proof of an LTS instance with a
+// proactive resharing, and starts or stops the resharing led by this node.
+// The caller must save the storage.
+func (s *Service) storeProactive(id byzcoin.InstanceID, proof *byzcoin.Proof) {
+	info, _, err := getLtsInfo(proof)
+	if err != nil {
+		log.Error(s.ServerIdentity(), "couldn't get LTS info:", err)
+		return
+	}
+	s.storage.Lock()
+	if info.ReshareInterval > 0 {
+		s.storage.Proactive[id] = proof
+	} else {
+		delete(s.storage.Proactive, id)
+	}
+	s.storage.Unlock()
+	s.updateProactive(id)
+}
+
+// updateProactive makes sure the proactive resharing of the LTS runs on this
+// node if, and only if, this node is the first of its roster.
+func (s *Service) updateProactive(id byzcoin.InstanceID) {
+	lead := false
+	s.storage.Lock()
+	if proof := s.storage.Proactive[id]; proof != nil {
+		info, _, err := getLtsInfo(proof)
+		lead = err == nil && len(info.Roster.List) > 0 &&
+			info.Roster.List[0].Equal(s.ServerIdentity())
+	}
+	s.storage.Unlock()
+
+	s.proactiveMut.Lock()
+	defer s.proactiveMut.Unlock()
+	if s.proactiveClosed {
+		return
+	}
+	stop, running := s.proactive[id]
+	switch {
+	case lead && !running:
+		stop = make(chan bool)
+		s.proactive[id] = stop
+		s.proactiveWG.Add(1)
+		go s.proactiveLoop(id, stop)
+	case !lead && running:
+		close(stop)
+		delete(s.proactive, id)
+	}
+}
+
+// proactiveLoop regularly checks whether the LTS must be reshared, until the
+// stop channel is closed.
+func (s *Service) proactiveLoop(id byzcoin.InstanceID, stop chan bool) {
+	defer s.proactiveWG.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(reshareCheckInterval):
+			if err := s.proactiveReshare(id); err != nil {
+				log.Error(s.ServerIdentity(), "proactive resharing failed:", err)
+			}
+		}
+	}
+}
+
+// proactiveReshare gets the latest LTS instance, and if ReshareInterval
+// blocks have been created since the latest epoch, it refreshes the shares
+// of the LTS and records the new epoch. The old shares are overwritten by
+// ReshareLTS.
+// If the shares have already been refreshed, but the epoch couldn't be
+// recorded, only the epoch is recorded, so that a failing instruction doesn't
+// lead to a new resharing at every check.
+func (s *Service) proactiveReshare(id byzcoin.InstanceID) error {
+	s.storage.Lock()
+	proof := s.storage.Proactive[id]
+	s.storage.Unlock()
+	if proof == nil {
+		return nil
+	}
+	cl := byzcoin.NewClient(proof.Latest.SkipChainID(), *proof.Latest.Roster)
+	reply, err := cl.GetProof(id.Slice())
+	if err != nil {
+		return xerrors.Errorf("getting proof: %v", err)
+	}
+	if err := s.verifyProof(&reply.Proof); err != nil {
+		return xerrors.Errorf("verifying proof: %v", err)
+	}
+	info, _, err := getLtsInfo(&reply.Proof)
+	if err != nil {
+		return err
+	}
+	s.storeProactive(id, &reply.Proof)
+	if err := s.save(); err != nil {
+		return err
+	}
+	if info.ReshareInterval == 0 || info.Epoch == nil {
+		return nil
+	}
+
+	s.proactiveMut.Lock()
+	block, pending := s.reshared[id]
+	if pending && block <= info.Epoch.BlockIndex {
+		delete(s.reshared, id)
+		pending = false
+	}
+	s.proactiveMut.Unlock()
+	if reply.Proof.Latest.Index < info.Epoch.BlockIndex+info.ReshareInterval {
+		return nil
+	}
+
+	if pending {
+		log.Lvlf2("%v recording the epoch of the resharing of %v at block %d",
+			s.ServerIdentity(), id, block)
+	} else {
+		block = reply.Proof.Latest.Index
+		log.Lvlf2("%v starting proactive resharing of %v at block %d",
+			s.ServerIdentity(), id, block)
+		if _, err := s.ReshareLTS(&ReshareLTS{Proof: reply.Proof}); err != nil {
+			return xerrors.Errorf("resharing: %v", err)
+		}
+		s.proactiveMut.Lock()
+		s.reshared[id] = block
+		s.proactiveMut.Unlock()
+	}
+	blockBuf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(blockBuf, uint64(block))
+	err = s.sendNodeInstruction(&reply.Proof, byzcoin.Invoke{
+		ContractID: ContractLongTermSecretID,
+		Command:    "epoch",
+		Args:       byzcoin.Arguments{{Name: "block", Value: blockBuf}},
+	})
+	if err != nil {
+		return xerrors.Errorf("recording epoch: %v", err)
+	}
+	s.proactiveMut.Lock()
+	delete(s.reshared, id)
+	s.proactiveMut.Unlock()
+	return nil
+}
+
+// TestClose stops the proactive resharings. It is exported because we need
+// it in tests, it should not be used in non-test code outside of this
+// package.
+func (s *Service) TestClose() {
+	s.proactiveMut.Lock()
+	s.proactiveClosed = true
+	for id, stop := range s.proactive {
+		close(stop)
+		delete(s.proactive, id)
+	}
+	s.proactiveMut.Unlock()
+	s.proactiveWG.Wait()
+}
@@ -2,6 +2,7 @@ package calypso
 
 import (
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/onet/v3"
@@ -9,6 +10,7 @@ import (
 
 // PROTOSTART
 // type :skipchain.SkipBlockID:bytes
+// type :darc.ID:bytes
 // package calypso;
 // import "byzcoin.proto";
 // import "onet.proto";
@@ -47,6 +49,28 @@ type Write struct {
 	LTSID byzcoin.InstanceID
 	// Cost reflects how many coins you'll have to pay for a read-request
 	Cost byzcoin.Coin `protobuf:"opt"`
+	// Release is the condition for the secret to be released to everybody.
+	// It is bound to the proof, and no read can be spawned for such a write.
+	Release *Release `protobuf:"opt"`
+	// Key is the secret, once it has been published by a node of the LTS.
+	// It is not verified by the contract: DecryptReleased gives a
+	// verified key.
+	Key []byte `protobuf:"opt"`
+}
+
+// Release is the condition for the secret of a write to be released: once
+// the ledger reached the block index and the time, anybody can get the
+// secret, and before nobody can.
+type Release struct {
+	// BlockIndex is the index of the block from which the secret is
+	// released, or 0.
+	BlockIndex int
+	// Timestamp is the time, in nanoseconds since the epoch, from which the
+	// secret is released, or 0.
+	Timestamp int64
+	// Publish asks the LTS to store the secret in the write instance when
+	// it is released.
+	Publish bool
 }
 
 // Read is the data stored in a read instance. It has a pointer to the write
@@ -54,6 +78,17 @@ type Write struct {
 type Read struct {
 	Write byzcoin.InstanceID
 	Xc    kyber.Point
+	// Group points to a group key instance if the secret is re-encrypted to
+	// a group instead of a single reader. Xc must then be the key of the
+	// group.
+	Group *byzcoin.InstanceID `protobuf:"opt"`
+}
+
+// GroupKey is the data stored in a group key instance. X is the public key
+// of a group of readers, whose private key is shared among the members of
+// the group, for example with a DKG.
+type GroupKey struct {
+	X kyber.Point
 }
 
 // ***
@@ -141,6 +176,71 @@ type DecryptKeyReply struct {
 	X kyber.Point
 }
 
+// DecryptReleased asks the LTS for the secret of a write once its release
+// condition is reached. Anybody can send it. If the release asks for it, the
+// secret is also published in the write instance.
+type DecryptReleased struct {
+	// Write is the proof of the write instance, with a latest block that
+	// reached the release condition.
+	Write byzcoin.Proof
+}
+
+// DecryptReleasedReply holds the secret of the write.
+type DecryptReleasedReply struct {
+	Key []byte
+}
+
+// Audit asks for all the read instances of a write instance. The node must
+// follow the ByzCoin ledger.
+type Audit struct {
+	// ByzCoinID is the ID of the ledger holding the write instance.
+	ByzCoinID skipchain.SkipBlockID
+	// Write is the instance ID of the write.
+	Write byzcoin.InstanceID
+}
+
+// AuditReply lists the read instances of a write, in the order they have
+// been spawned.
+type AuditReply struct {
+	Reads []AuditRead
+	// Write is the proof of the write instance.
+	Write byzcoin.Proof
+	// Darc is the proof of the darc of the write, in its latest version.
+	Darc byzcoin.Proof
+	// DarcEvolutions holds, for every version of the darc after the first
+	// one, the blocks up to the block holding the evolution to this
+	// version.
+	DarcEvolutions []BlockProof
+}
+
+// BlockProof holds the blocks from the genesis block of a ledger to a given
+// block, which is the last one, following the highest forward-links.
+type BlockProof struct {
+	Blocks []*skipchain.SkipBlock
+}
+
+// AuditRead describes one read instance of a write.
+type AuditRead struct {
+	// ReadID is the instance ID of the read.
+	ReadID byzcoin.InstanceID
+	// Readers are the identities that signed the spawn of the read.
+	Readers []string
+	// Xc is the public key the secret is re-encrypted to.
+	Xc kyber.Point
+	// BlockIndex is the index of the block holding the read.
+	BlockIndex int
+	// Timestamp is the time of the block holding the read, in nanoseconds.
+	Timestamp int64
+	// DarcID is the darc of the write, which authorized the read.
+	DarcID darc.ID
+	// DarcVersion is the version of the darc when the read was spawned.
+	DarcVersion uint64
+	// Proof is the proof of the read instance.
+	Proof byzcoin.Proof
+	// Block holds the blocks up to the block holding the read.
+	Block BlockProof
+}
+
 // GetLTSReply asks for the shared public key of the corresponding LTSID
 type GetLTSReply struct {
 	// LTSID is the id of the LTS instance created.
@@ -150,4 +250,23 @@ type GetLTSReply struct {
 // LtsInstanceInfo is the information stored in an LTS instance.
 type LtsInstanceInfo struct {
 	Roster onet.Roster
+	// ReshareInterval is the number of blocks between two proactive
+	// resharings of the LTS. If it is 0, the shares are only reshared
+	// on request.
+	ReshareInterval int `protobuf:"opt"`
+	// Epoch is the latest proactive resharing. It is set by the contract
+	// and the LTS nodes.
+	Epoch *ReshareEpoch `protobuf:"opt"`
+}
+
+// ReshareEpoch is the on-chain record of a proactive resharing.
+type ReshareEpoch struct {
+	// Index counts the resharings. Epoch 0 are the shares from the creation
+	// of the LTS, or from when the proactive resharing was enabled.
+	Index int
+	// BlockIndex is the block that triggered the resharing.
+	BlockIndex int
+	// Timestamp is the time of the block recording the epoch, in
+	// nanoseconds.
+	Timestamp int64
 }
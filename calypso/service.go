@@ -28,6 +28,7 @@
 package calypso
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -112,6 +113,10 @@ func init() {
 	if err != nil {
 		log.ErrFatal(err)
 	}
+	err = byzcoin.RegisterGlobalContract(ContractGroupKeyID, contractGroupKeyFromBytes)
+	if err != nil {
+		log.ErrFatal(err)
+	}
 }
 
 // Service is our calypso-service. It stores all created LTSs.
@@ -123,6 +128,16 @@ type Service struct {
 	// blocks are only used to insure that proofs start with the expected roster.
 	genesisBlocks     map[string]*skipchain.SkipBlock
 	genesisBlocksLock sync.Mutex
+	// proactive holds a channel for every LTS with a proactive resharing
+	// led by this node, which is closed to stop the resharing.
+	proactive       map[byzcoin.InstanceID]chan bool
+	proactiveClosed bool
+	proactiveMut    sync.Mutex
+	proactiveWG     sync.WaitGroup
+	// reshared holds the block index of the latest proactive resharing
+	// of an LTS whose epoch might not be recorded yet. It is protected by
+	// proactiveMut.
+	reshared map[byzcoin.InstanceID]int
 	// for use by testing only
 	afterReshare func()
 }
@@ -306,6 +321,7 @@ func (s *Service) CreateLTS(req *CreateLTS) (reply *CreateLTSReply, err error) {
 		s.storage.Replies[instID] = reply
 		s.storage.DKS[instID] = dks
 		s.storage.Unlock()
+		s.storeProactive(instID, &req.Proof)
 		err = s.save()
 		if err != nil {
 			return nil, xerrors.Errorf("save dkg state: %v", err)
@@ -434,6 +450,7 @@ func (s *Service) ReshareLTS(req *ReshareLTS) (*ReshareLTSReply, error) {
 		s.storage.Rosters[id] = roster
 		s.storage.DKS[id] = dks
 		s.storage.Unlock()
+		s.storeProactive(id, &req.Proof)
 		err = s.save()
 		if err != nil {
 			return nil, xerrors.Errorf("saving dkg state: %v", err)
@@ -507,6 +524,14 @@ func (s *Service) fetchGenesisBlock(scID skipchain.SkipBlockID, roster *onet.Ros
 }
 
 func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.InstanceID, error) {
+	info, id, err := getLtsInfo(proof)
+	if err != nil {
+		return nil, byzcoin.InstanceID{}, err
+	}
+	return &info.Roster, id, nil
+}
+
+func getLtsInfo(proof *byzcoin.Proof) (*LtsInstanceInfo, byzcoin.InstanceID, error) {
 	instanceID, buf, _, _, err := proof.KeyValue()
 	if err != nil {
 		return nil, byzcoin.InstanceID{},
@@ -519,7 +544,7 @@ func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.Inst
 		return nil, byzcoin.InstanceID{},
 			xerrors.Errorf("decoding roster: %v", err)
 	}
-	return &info.Roster, byzcoin.NewInstanceID(instanceID), nil
+	return &info, byzcoin.NewInstanceID(instanceID), nil
 }
 
 // DecryptKey takes as an input a Read- and a Write-proof. Proofs contain
@@ -529,7 +554,6 @@ func (s *Service) getLtsRoster(proof *byzcoin.Proof) (*onet.Roster, byzcoin.Inst
 // requests match and then re-encrypts the secret to the public key given
 // in the Read-instance.
 func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error) {
-	reply = &DecryptKeyReply{}
 	log.Lvl2(s.ServerIdentity(), "Re-encrypt the key to the public key of the reader")
 
 	var read Read
@@ -567,6 +591,15 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 
 	// Start ocs-protocol to re-encrypt the file's symmetric key under the
 	// reader's public key.
+	return s.reencrypt(&write, roster, read.Xc, &vData{Proof: dkr.Read})
+}
+
+// reencrypt runs the OCS protocol to re-encrypt the secret of the write to
+// xc.
+func (s *Service) reencrypt(write *Write, roster *onet.Roster, xc kyber.Point,
+	verificationData *vData) (reply *DecryptKeyReply, err error) {
+	reply = &DecryptKeyReply{}
+	id := write.LTSID
 	nodes := len(roster.List)
 	threshold := nodes - (nodes-1)/3
 	tree := roster.GenerateNaryTreeWithRoot(nodes, s.ServerIdentity())
@@ -576,10 +609,7 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 	}
 	ocsProto := pi.(*protocol.OCS)
 	ocsProto.U = write.U
-	verificationData := &vData{
-		Proof: dkr.Read,
-	}
-	ocsProto.Xc = read.Xc
+	ocsProto.Xc = xc
 	log.Lvlf2("%v Public key is: %s", s.ServerIdentity(), ocsProto.Xc)
 	ocsProto.VerificationData, err = protobuf.Encode(verificationData)
 	if err != nil {
@@ -624,6 +654,107 @@ func (s *Service) DecryptKey(dkr *DecryptKey) (reply *DecryptKeyReply, err error
 	return
 }
 
+// DecryptReleased returns the secret of a write whose release condition is
+// reached, and publishes it in the write instance if the release asks for it
+// and it is not published yet. The secret is always recovered by the LTS,
+// even if it is already published.
+func (s *Service) DecryptReleased(req *DecryptReleased) (*DecryptReleasedReply, error) {
+	var write Write
+	if err := req.Write.VerifyAndDecode(cothority.Suite, ContractWriteID, &write); err != nil {
+		return nil, xerrors.New("didn't get a write instance: " + err.Error())
+	}
+	if err := s.verifyProof(&req.Write); err != nil {
+		return nil, xerrors.Errorf(
+			"write proof cannot be verified to come from scID: %v", err)
+	}
+	if err := checkReleased(&write, &req.Write); err != nil {
+		return nil, err
+	}
+
+	// A published key is never returned as is: it has been stored by one
+	// node only, and the contract cannot verify it. So the key is always
+	// recovered from a threshold of verified shares.
+	s.storage.Lock()
+	roster := s.storage.Rosters[write.LTSID]
+	s.storage.Unlock()
+	if roster == nil {
+		return nil,
+			xerrors.Errorf("don't know the LTSID '%v' stored in write", write.LTSID)
+	}
+
+	// Re-encrypting to the null point gives the secret to everybody.
+	dkr, err := s.reencrypt(&write, roster, cothority.Suite.Point().Null(),
+		&vData{Proof: req.Write})
+	if err != nil {
+		return nil, err
+	}
+	key, err := dkr.RecoverKey(cothority.Suite.Scalar().Zero())
+	if err != nil {
+		return nil, xerrors.Errorf("recovering key: %v", err)
+	}
+
+	if len(write.Key) > 0 {
+		if !bytes.Equal(write.Key, key) {
+			log.Errorf("write %x holds a wrong published key",
+				req.Write.InclusionProof.Key())
+		}
+	} else if write.Release.Publish {
+		if err := s.publish(&req.Write, key); err != nil {
+			return nil, xerrors.Errorf("publishing key: %v", err)
+		}
+	}
+	return &DecryptReleasedReply{Key: key}, nil
+}
+
+// checkReleased returns nil if the write has a release condition that is
+// reached by the latest block of the proof.
+func checkReleased(write *Write, proof *byzcoin.Proof) error {
+	if write.Release == nil {
+		return xerrors.New("this write has no release condition")
+	}
+	var header byzcoin.DataHeader
+	if err := protobuf.Decode(proof.Latest.Data, &header); err != nil {
+		return xerrors.Errorf("decoding header: %v", err)
+	}
+	return cothority.ErrorOrNil(
+		write.Release.Reached(proof.Latest.Index, header.Timestamp),
+		"the secret is not released yet")
+}
+
+// publish stores the released key in the write instance, signing the
+// instruction with the key of this node.
+func (s *Service) publish(proof *byzcoin.Proof, key []byte) error {
+	return s.sendNodeInstruction(proof, byzcoin.Invoke{
+		ContractID: ContractWriteID,
+		Command:    "publish",
+		Args:       byzcoin.Arguments{{Name: "key", Value: key}},
+	})
+}
+
+// sendNodeInstruction invokes the instance of the proof with an instruction
+// signed by the key of this node.
+func (s *Service) sendNodeInstruction(proof *byzcoin.Proof, invoke byzcoin.Invoke) error {
+	signer := darc.NewSignerEd25519(s.ServerIdentity().Public,
+		s.ServerIdentity().GetPrivate())
+	cl := byzcoin.NewClient(proof.Latest.SkipChainID(), *proof.Latest.Roster)
+	ctrs, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return xerrors.Errorf("getting counters: %v", err)
+	}
+	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
+		byzcoin.Instruction{
+			InstanceID:    byzcoin.NewInstanceID(proof.InclusionProof.Key()),
+			Invoke:        &invoke,
+			SignerCounter: []uint64{ctrs.Counters[0] + 1},
+		},
+	)
+	if err := ctx.FillSignersAndSignWith(signer); err != nil {
+		return xerrors.Errorf("signing txn: %v", err)
+	}
+	_, err = cl.AddTransactionAndWait(ctx, 10)
+	return cothority.ErrorOrNil(err, "adding txn")
+}
+
 // GetLTSReply returns the CreateLTSReply message of a previous LTS.
 func (s *Service) GetLTSReply(req *GetLTSReply) (*CreateLTSReply, error) {
 	log.Lvlf2("Getting LTS Reply for ID: %v", req.LTSID)
@@ -691,6 +822,7 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			s.storage.Replies[id] = reply
 			s.storage.Rosters[id] = tn.Roster()
 			s.storage.Unlock()
+			s.storeProactive(id, &cfg.Proof)
 			err = s.save()
 			if err != nil {
 				log.Error(err)
@@ -786,6 +918,7 @@ func (s *Service) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.GenericConfi
 			s.storage.DKS[id] = dks
 			s.storage.Rosters[id] = roster
 			s.storage.Unlock()
+			s.storeProactive(id, &cfg.Proof)
 			err = s.save()
 			if err != nil {
 				log.Fatal(err)
@@ -837,6 +970,9 @@ func (s *Service) verifyReencryption(rc *protocol.Reencrypt) bool {
 		if err != nil {
 			return xerrors.Errorf("proof cannot return values: %v", err)
 		}
+		if contractID == ContractWriteID {
+			return s.verifyRelease(rc, &verificationData.Proof, v0)
+		}
 		if contractID != ContractReadID {
 			return xerrors.New("proof doesn't point to read instance")
 		}
@@ -860,6 +996,26 @@ func (s *Service) verifyReencryption(rc *protocol.Reencrypt) bool {
 	return true
 }
 
+// verifyRelease makes sure a re-encryption to the null point is only done
+// for a write whose release condition is reached.
+func (s *Service) verifyRelease(rc *protocol.Reencrypt, proof *byzcoin.Proof, buf []byte) error {
+	var w Write
+	err := protobuf.DecodeWithConstructors(buf, &w, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return xerrors.Errorf("couldn't decode write data: %v", err)
+	}
+	if !rc.U.Equal(w.U) {
+		return xerrors.New("not the secret of this write")
+	}
+	if !rc.Xc.Equal(cothority.Suite.Point().Null()) {
+		return xerrors.New("released secrets are not re-encrypted")
+	}
+	if err := s.verifyProof(proof); err != nil {
+		return xerrors.Errorf("verifying proof: %v", err)
+	}
+	return checkReleased(&w, proof)
+}
+
 // newService receives the context that holds information about the node it's
 // running on. Saving and loading can be done using the context. The data will
 // be stored in memory for tests and simulations, and on disk for real deployments.
@@ -867,9 +1023,11 @@ func newService(c *onet.Context) (onet.Service, error) {
 	s := &Service{
 		ServiceProcessor: onet.NewServiceProcessor(c),
 		genesisBlocks:    make(map[string]*skipchain.SkipBlock),
+		proactive:        make(map[byzcoin.InstanceID]chan bool),
+		reshared:         make(map[byzcoin.InstanceID]int),
 	}
 	if err := s.RegisterHandlers(s.CreateLTS, s.ReshareLTS, s.DecryptKey,
-		s.GetLTSReply, s.Authorise, s.Authorize, s.updateValidPeers); err != nil {
+		s.DecryptReleased, s.Audit, s.GetLTSReply, s.Authorise, s.Authorize, s.updateValidPeers); err != nil {
 		return nil, xerrors.New("couldn't register messages")
 	}
 	if err := s.tryLoad(); err != nil {
@@ -881,6 +1039,9 @@ func newService(c *onet.Context) (onet.Service, error) {
 	for ltsID, roster := range s.storage.Rosters {
 		s.SetValidPeers(s.NewPeerSetID(ltsID[:]), roster.List)
 	}
+	for ltsID := range s.storage.Proactive {
+		s.updateProactive(ltsID)
+	}
 
 	return s, nil
 }
@@ -1,6 +1,7 @@
 package calypso
 
 import (
+	"encoding/binary"
 	"sync"
 	"testing"
 	"time"
@@ -54,7 +55,7 @@ func TestService_ReshareLTS_Different(t *testing.T) {
 	// The current DKG is on List[0:nodes], and this new roster will
 	// be on List[nodes:], thus entirely disjoint.
 	otherRoster := onet.NewRoster(s.allRoster.List[nodes:])
-	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*otherRoster})
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *otherRoster})
 	require.NoError(t, err)
 
 	ctx := byzcoin.NewClientTransaction(byzcoin.CurrentVersion,
@@ -92,7 +93,7 @@ func TestService_ReshareLTS_Same(t *testing.T) {
 			require.NotNil(t, s.ltsReply.X)
 			sec1 := s.reconstructKey(t)
 
-			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 			require.NoError(t, err)
 
 			ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
@@ -160,7 +161,7 @@ func TestService_ReshareLTS_OneMore(t *testing.T) {
 			// Create a new roster that has one more node than
 			// before
 			s.ltsRoster = onet.NewRoster(s.allRoster.List[:nodes+1])
-			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+			ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster})
 			require.NoError(t, err)
 
 			ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
@@ -211,6 +212,86 @@ func TestService_ReshareLTS_OneMore(t *testing.T) {
 }
 
 // TestContract_Write creates a write request and check that it gets stored.
+// TestService_ProactiveReshare creates an LTS that is reshared every two
+// blocks, and checks that the shares are refreshed with the same key, and
+// that the epochs are recorded.
+func TestService_ProactiveReshare(t *testing.T) {
+	defer func(d time.Duration) { reshareCheckInterval = d }(reshareCheckInterval)
+	reshareCheckInterval = 100 * time.Millisecond
+
+	s := newTSWithInterval(t, 4, 0, 2)
+	defer s.closeAll(t)
+	id := s.ltsReply.InstanceID
+	sec := s.reconstructKey(t)
+	s.services[1].storage.Lock()
+	oldShare := s.services[1].storage.Shared[id].V.Clone()
+	s.services[1].storage.Unlock()
+
+	info := func() *LtsInstanceInfo {
+		reply, err := s.cl.GetProof(id.Slice())
+		require.NoError(t, err)
+		info, _, err := getLtsInfo(&reply.Proof)
+		require.NoError(t, err)
+		return info
+	}
+	first := info().Epoch
+	require.NotNil(t, first)
+	require.Equal(t, 0, first.Index)
+
+	// Only the nodes of the LTS can record an epoch.
+	block := make([]byte, 8)
+	binary.LittleEndian.PutUint64(block, uint64(first.BlockIndex+1))
+	ctx, err := s.cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: id,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractLongTermSecretID,
+			Command:    "epoch",
+			Args:       byzcoin.Arguments{{Name: "block", Value: block}},
+		},
+		SignerCounter: []uint64{2},
+	})
+	require.NoError(t, err)
+	require.NoError(t, ctx.FillSignersAndSignWith(s.signer))
+	_, err = s.cl.AddTransactionAndWait(ctx, 4)
+	require.Error(t, err)
+
+	// Every write creates a new block.
+	var epoch *ReshareEpoch
+	for i := 0; i < 30; i++ {
+		s.addWriteAndWait(t, []byte("secret key"))
+		epoch = info().Epoch
+		if epoch.Index >= 2 {
+			break
+		}
+	}
+	require.True(t, epoch.Index >= 2)
+	require.True(t, epoch.BlockIndex >= first.BlockIndex+2*2)
+	require.True(t, epoch.Timestamp > first.Timestamp)
+
+	// Stop the resharing before checking the shares.
+	s.services[0].TestClose()
+	require.True(t, s.reconstructKey(t).Equal(sec))
+	s.services[1].storage.Lock()
+	require.False(t, s.services[1].storage.Shared[id].V.Equal(oldShare))
+	oldShare = s.services[1].storage.Shared[id].V.Clone()
+	s.services[1].storage.Unlock()
+
+	// If the epoch of a resharing couldn't be recorded, the next check only
+	// records it, without resharing again.
+	epoch = info().Epoch
+	s.addWriteAndWait(t, []byte("secret key"))
+	pr := s.addWriteAndWait(t, []byte("secret key"))
+	s.services[0].proactiveMut.Lock()
+	s.services[0].reshared[id] = pr.Latest.Index
+	s.services[0].proactiveMut.Unlock()
+	require.NoError(t, s.services[0].proactiveReshare(id))
+	require.Equal(t, epoch.Index+1, info().Epoch.Index)
+	require.Equal(t, pr.Latest.Index, info().Epoch.BlockIndex)
+	s.services[1].storage.Lock()
+	require.True(t, s.services[1].storage.Shared[id].V.Equal(oldShare))
+	s.services[1].storage.Unlock()
+}
+
 func TestContract_Write(t *testing.T) {
 	s := newTS(t, 5)
 	defer s.closeAll(t)
@@ -377,6 +458,12 @@ func newTS(t *testing.T, nodes int) ts {
 // newTSWithExtras initially the byzRoster and ltsRoster are the same, the extras are
 // there so that we can change the ltsRoster later to be something different.
 func newTSWithExtras(t *testing.T, nodes int, extras int) ts {
+	return newTSWithInterval(t, nodes, extras, 0)
+}
+
+// newTSWithInterval creates an LTS that is proactively reshared every
+// interval blocks.
+func newTSWithInterval(t *testing.T, nodes int, extras int, interval int) ts {
 	allowInsecureAdmin = true
 	s := ts{}
 	s.local = onet.NewLocalTestT(cothority.Suite, t)
@@ -395,7 +482,8 @@ func newTSWithExtras(t *testing.T, nodes int, extras int) ts {
 	s.createGenesis(t)
 
 	// Create LTS instance
-	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{*s.ltsRoster})
+	ltsInstInfoBuf, err := protobuf.Encode(&LtsInstanceInfo{Roster: *s.ltsRoster,
+		ReshareInterval: interval})
 	require.NoError(t, err)
 	inst := byzcoin.Instruction{
 		InstanceID: byzcoin.NewInstanceID(s.gDarc.GetBaseID()),
@@ -2,7 +2,10 @@ package calypso
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"time"
 
 	"go.dedis.ch/cothority/v3/byzcoin"
 	"go.dedis.ch/cothority/v3/darc"
@@ -10,12 +13,15 @@ import (
 	"go.dedis.ch/kyber/v3/suites"
 	"go.dedis.ch/kyber/v3/xof/keccak"
 	"go.dedis.ch/onet/v3/network"
+	"golang.org/x/xerrors"
 )
 
 func init() {
 	network.RegisterMessages(CreateLTS{}, CreateLTSReply{},
 		Authorize{}, AuthorizeReply{},
-		DecryptKey{}, DecryptKeyReply{})
+		DecryptKey{}, DecryptKeyReply{},
+		DecryptReleased{}, DecryptReleasedReply{},
+		Audit{}, AuditReply{})
 }
 
 type suite interface {
@@ -39,7 +45,15 @@ type suite interface {
 //   it containing the reader-darc. If it is nil then we failed to embed the
 //   key because it is too long to represent the key using a point.
 func NewWrite(suite suites.Suite, ltsid byzcoin.InstanceID, writeDarc darc.ID, X kyber.Point, key []byte) *Write {
-	wr := &Write{LTSID: ltsid}
+	return NewReleaseWrite(suite, ltsid, writeDarc, X, key, nil)
+}
+
+// NewReleaseWrite is like NewWrite, but the secret is released to everybody
+// once the release condition is reached. The condition is bound to the
+// proof, so it cannot be changed afterwards.
+func NewReleaseWrite(suite suites.Suite, ltsid byzcoin.InstanceID, writeDarc darc.ID, X kyber.Point, key []byte,
+	release *Release) *Write {
+	wr := &Write{LTSID: ltsid, Release: release}
 	r := suite.Scalar().Pick(suite.RandomStream())
 	C := suite.Point().Mul(r, X)
 	wr.U = suite.Point().Mul(r, nil)
@@ -63,6 +77,7 @@ func NewWrite(suite suites.Suite, ltsid byzcoin.InstanceID, writeDarc darc.ID, X
 	w.MarshalTo(hash)
 	wBar.MarshalTo(hash)
 	hash.Write(writeDarc)
+	wr.Release.hash(hash)
 	wr.E = suite.Scalar().SetBytes(hash.Sum(nil))
 	wr.F = suite.Scalar().Add(s, suite.Scalar().Mul(wr.E, r))
 	return wr
@@ -87,6 +102,7 @@ func (wr *Write) CheckProof(suite suite, writeID darc.ID) error {
 	w.MarshalTo(hash)
 	wBar.MarshalTo(hash)
 	hash.Write(writeID)
+	wr.Release.hash(hash)
 
 	e := suite.Scalar().SetBytes(hash.Sum(nil))
 	if e.Equal(wr.E) {
@@ -97,6 +113,47 @@ func (wr *Write) CheckProof(suite suite, writeID darc.ID) error {
 		"%s\n%s", e.String(), wr.E.String())
 }
 
+// hash adds the release condition to the hash of the proof of a write.
+// Nothing is added if there is no condition, so that the proofs of writes
+// without condition stay the same.
+func (r *Release) hash(h io.Writer) {
+	if r == nil {
+		return
+	}
+	buf := make([]byte, 17)
+	binary.LittleEndian.PutUint64(buf, uint64(r.BlockIndex))
+	binary.LittleEndian.PutUint64(buf[8:], uint64(r.Timestamp))
+	if r.Publish {
+		buf[16] = 1
+	}
+	h.Write(buf)
+}
+
+// Reached returns nil if the release condition is reached for the block
+// with the given index and timestamp.
+func (r *Release) Reached(index int, timestamp int64) error {
+	if index < r.BlockIndex {
+		return xerrors.Errorf("block index %d is before the release at %d",
+			index, r.BlockIndex)
+	}
+	if timestamp < r.Timestamp {
+		return xerrors.Errorf("block time %v is before the release at %v",
+			time.Unix(0, timestamp), time.Unix(0, r.Timestamp))
+	}
+	return nil
+}
+
+// check makes sure the release condition can be reached.
+func (r *Release) check() error {
+	if r.BlockIndex < 0 || r.Timestamp < 0 {
+		return xerrors.New("negative release condition")
+	}
+	if r.BlockIndex == 0 && r.Timestamp == 0 {
+		return xerrors.New("empty release condition")
+	}
+	return nil
+}
+
 type newLtsConfig struct {
 	byzcoin.Proof
 }
@@ -10,6 +10,17 @@
 //
 //  ./conode
 //
+// The private keys can be sealed with a passphrase, which is then asked for
+// when the daemon starts:
+//
+//  ./conode keystore seal
+//
+// The data the services stored before is sealed in place when the daemon
+// starts. Once it is stopped, the plaintext left in the database is removed
+// with:
+//
+//  ./conode keystore compact
+//
 // Services need to be imported to be available when the conode is
 // running.
 package main
@@ -25,6 +36,8 @@ import (
 
 	cli "github.com/urfave/cli"
 	"go.dedis.ch/cothority/v3"
+	_ "go.dedis.ch/cothority/v3/beacon"
+	_ "go.dedis.ch/cothority/v3/evoting/contracts"
 	_ "go.dedis.ch/cothority/v3/evoting/service"
 	_ "go.dedis.ch/cothority/v3/personhood/contracts"
 	_ "go.dedis.ch/cothority/v3/skipchain"
@@ -88,6 +101,25 @@ func main() {
 			Name:   "server",
 			Usage:  "Start cothority server",
 			Action: runServer,
+			Flags:  []cli.Flag{passphraseFlag},
+		},
+		{
+			Name:  "keystore",
+			Usage: "Manage the keystore sealing the private keys",
+			Subcommands: cli.Commands{
+				{
+					Name:   "seal",
+					Usage:  "Seal the configuration of the server with a passphrase",
+					Action: sealConfig,
+					Flags:  []cli.Flag{passphraseFlag},
+				},
+				{
+					Name:   "compact",
+					Usage:  "Copy the database of the stopped server to a fresh file, removing the data sealed in place",
+					Action: compactDB,
+					Flags:  []cli.Flag{passphraseFlag},
+				},
+			},
 		},
 		{
 			Name:      "check",
@@ -151,7 +183,11 @@ func runServer(ctx *cli.Context) error {
 	if raiseFdLimit != nil {
 		raiseFdLimit()
 	}
-	app.RunServer(config)
+	server, err := loadServer(ctx, config)
+	if err != nil {
+		return fmt.Errorf("couldn't load config: %v", err)
+	}
+	server.Start()
 	return nil
 }
 
@@ -1,10 +1,22 @@
 package main
 
 import (
-	"testing"
-
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
+	"testing"
+	"time"
 
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/keystore"
+	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/log"
 )
 
@@ -16,3 +28,93 @@ func TestRun(t *testing.T) {
 	os.Args = []string{os.Args[0], "--help"}
 	main()
 }
+
+func TestKeystoreSeal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conode")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "private.toml")
+	pass := filepath.Join(dir, "pass.txt")
+	require.NoError(t, ioutil.WriteFile(pass, []byte("passphrase\n"), 0600))
+
+	os.Args = []string{os.Args[0], "-c", config, "setup", "--non-interactive"}
+	main()
+	plain, err := keystore.DecodeCothority(readFile(t, config), nil)
+	require.NoError(t, err)
+
+	os.Args = []string{os.Args[0], "-c", config, "keystore", "seal",
+		"--passphrase-file", pass}
+	main()
+	buf := readFile(t, config)
+	require.True(t, keystore.IsSealed(buf))
+	require.NotContains(t, string(buf), plain.Private)
+
+	_, err = keystore.DecodeCothority(buf, nil)
+	require.Error(t, err)
+	_, err = keystore.DecodeCothority(buf, []byte("wrong"))
+	require.Error(t, err)
+	opened, err := keystore.DecodeCothority(buf, []byte("passphrase"))
+	require.NoError(t, err)
+	require.Equal(t, plain, opened)
+}
+
+// TestNewServerCertificate makes sure that the certificates of a sealed
+// configuration are reloaded if they are files.
+func TestNewServerCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conode")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "private.toml")
+	os.Args = []string{os.Args[0], "-c", config, "setup", "--non-interactive"}
+	main()
+	hc, err := keystore.DecodeCothority(readFile(t, config), nil)
+	require.NoError(t, err)
+
+	cert, key := writeCertificate(t, dir)
+	hc.WebSocketTLSCertificate = app.CertificateURL("file://" + cert)
+	hc.WebSocketTLSCertificateKey = app.CertificateURL(key)
+	server, err := newServer(hc)
+	require.NoError(t, err)
+	require.NotNil(t, server.WebSocket.TLSConfig.GetCertificate)
+	require.Empty(t, server.WebSocket.TLSConfig.Certificates)
+	require.NoError(t, server.Close())
+
+	hc.WebSocketTLSCertificate = app.CertificateURL("string://" +
+		string(readFile(t, cert)))
+	server, err = newServer(hc)
+	require.NoError(t, err)
+	require.Nil(t, server.WebSocket.TLSConfig.GetCertificate)
+	require.Len(t, server.WebSocket.TLSConfig.Certificates, 1)
+	require.NoError(t, server.Close())
+}
+
+// writeCertificate writes a self-signed certificate and its key to the
+// directory, and returns their paths.
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+
+	cert := filepath.Join(dir, "cert.pem")
+	key := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(cert,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(key,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
+
+func readFile(t *testing.T, fn string) []byte {
+	buf, err := ioutil.ReadFile(fn)
+	require.NoError(t, err)
+	return buf
+}
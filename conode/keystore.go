@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cli "github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3/keystore"
+	"go.dedis.ch/kyber/v3/suites"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/app"
+	"go.dedis.ch/onet/v3/cfgpath"
+	"go.dedis.ch/onet/v3/log"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// stdin is shared by the prompts, so that no line is lost in a buffer.
+var stdin = bufio.NewReader(os.Stdin)
+
+var passphraseFlag = cli.StringFlag{
+	Name:  "passphrase-file",
+	Usage: "file holding the passphrase of the keystore",
+}
+
+// readPassphrase returns the passphrase of the keystore, read from the
+// passphrase file, the environment or, if ask is true, from STDIN. It returns
+// nil if no passphrase is given.
+func readPassphrase(c *cli.Context, ask bool, prompt string) ([]byte, error) {
+	if fn := c.String("passphrase-file"); fn != "" {
+		buf, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase file: %v", err)
+		}
+		return checkPassphrase(bytes.TrimRight(buf, "\r\n"))
+	}
+	if pass, ok := os.LookupEnv(keystore.PassphraseEnv); ok {
+		return checkPassphrase([]byte(pass))
+	}
+	if !ask {
+		return nil, nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		pass, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %v", err)
+		}
+		return checkPassphrase(pass)
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return nil, fmt.Errorf("reading passphrase: %v", err)
+	}
+	return checkPassphrase([]byte(strings.TrimRight(line, "\r\n")))
+}
+
+func checkPassphrase(pass []byte) ([]byte, error) {
+	if len(pass) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return pass, nil
+}
+
+// loadServer reads the configuration of the conode and returns the server.
+// If the configuration is sealed, or if a passphrase is given, the keystore
+// is unlocked before the services are created.
+func loadServer(c *cli.Context, config string) (*onet.Server, error) {
+	buf, err := ioutil.ReadFile(config)
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration: %v", err)
+	}
+	sealed := keystore.IsSealed(buf)
+	pass, err := readPassphrase(c, sealed, "Passphrase of the keystore: ")
+	if err != nil {
+		return nil, err
+	}
+	if pass == nil {
+		_, server, err := app.ParseCothority(config)
+		return server, err
+	}
+	if !sealed {
+		log.Warn("The private key of the conode is not sealed, " +
+			"use 'conode keystore seal' to seal it.")
+		keystore.Unlock(pass)
+		_, server, err := app.ParseCothority(config)
+		return server, err
+	}
+
+	hc, err := keystore.DecodeCothority(buf, pass)
+	if err != nil {
+		return nil, err
+	}
+	keystore.Unlock(pass)
+	return newServer(hc)
+}
+
+// newServer returns the server of the configuration, like
+// app.ParseCothority, but without writing the opened configuration to a
+// file. As in app.ParseCothority, TLS certificates given as files are
+// reloaded when they change.
+func newServer(hc *app.CothorityConfig) (*onet.Server, error) {
+	suite, err := suites.Find(hc.Suite)
+	if err != nil {
+		return nil, fmt.Errorf("kyber suite: %v", err)
+	}
+	si, err := hc.GetServerIdentity()
+	if err != nil {
+		return nil, fmt.Errorf("parse server identity: %v", err)
+	}
+	server := onet.NewServerTCPWithListenAddr(si, suite, hc.ListenAddress)
+
+	if hc.WebSocketTLSCertificate == "" || hc.WebSocketTLSCertificateKey == "" {
+		return server, nil
+	}
+	var tlsConfig *tls.Config
+	if hc.WebSocketTLSCertificate.CertificateURLType() == app.File &&
+		hc.WebSocketTLSCertificateKey.CertificateURLType() == app.File {
+		cr, err := onet.NewCertificateReloader(
+			certificatePath(hc.WebSocketTLSCertificate),
+			certificatePath(hc.WebSocketTLSCertificateKey),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("certificate: %v", err)
+		}
+		tlsConfig = &tls.Config{GetCertificate: cr.GetCertificateFunc()}
+	} else {
+		cert, err := hc.WebSocketTLSCertificate.Content()
+		if err != nil {
+			return nil, fmt.Errorf("getting WebSocketTLSCertificate content: %v", err)
+		}
+		certKey, err := hc.WebSocketTLSCertificateKey.Content()
+		if err != nil {
+			return nil, fmt.Errorf("getting WebSocketTLSCertificateKey content: %v", err)
+		}
+		pair, err := tls.X509KeyPair(cert, certKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading X509KeyPair: %v", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{pair}}
+	}
+	server.WebSocket.Lock()
+	server.WebSocket.TLSConfig = tlsConfig
+	server.WebSocket.Unlock()
+	return server, nil
+}
+
+// certificatePath returns the path of a certificate given as a file, with or
+// without the "file://" prefix.
+func certificatePath(cu app.CertificateURL) string {
+	s := cu.String()
+	if i := strings.Index(s, "://"); i >= 0 {
+		return s[i+len("://"):]
+	}
+	return s
+}
+
+// sealConfig seals the private key and the service keys of the conode. The
+// storage of the services is sealed the next time the conode is started with
+// the passphrase.
+func sealConfig(c *cli.Context) error {
+	config := c.GlobalString("config")
+	buf, err := ioutil.ReadFile(config)
+	if err != nil {
+		return fmt.Errorf("reading configuration: %v", err)
+	}
+	if keystore.IsSealed(buf) {
+		return errors.New("the configuration is already sealed")
+	}
+	hc, err := keystore.DecodeCothority(buf, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := hc.GetServerIdentity(); err != nil {
+		return fmt.Errorf("parse server identity: %v", err)
+	}
+
+	pass, err := readPassphrase(c, true, "New passphrase of the keystore: ")
+	if err != nil {
+		return err
+	}
+	if c.String("passphrase-file") == "" && os.Getenv(keystore.PassphraseEnv) == "" {
+		again, err := readPassphrase(c, true, "Repeat the passphrase: ")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pass, again) {
+			return errors.New("the passphrases don't match")
+		}
+	}
+
+	sealed, err := keystore.New(pass).Seal(buf)
+	if err != nil {
+		return fmt.Errorf("sealing configuration: %v", err)
+	}
+	// Write the sealed configuration next to the old one first, so that the
+	// configuration is never lost.
+	tmp := config + ".sealed"
+	if err := ioutil.WriteFile(tmp, sealed, 0600); err != nil {
+		return fmt.Errorf("writing configuration: %v", err)
+	}
+	if err := os.Rename(tmp, config); err != nil {
+		return fmt.Errorf("replacing configuration: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Sealed %v\n", config)
+	return nil
+}
+
+// compactDB copies the database of the server to a fresh file, so that the
+// plaintext data left behind when it has been sealed in place is not in the
+// database file anymore. The server must be stopped.
+func compactDB(c *cli.Context) error {
+	buf, err := ioutil.ReadFile(c.GlobalString("config"))
+	if err != nil {
+		return fmt.Errorf("reading configuration: %v", err)
+	}
+	pass, err := readPassphrase(c, keystore.IsSealed(buf), "Passphrase of the keystore: ")
+	if err != nil {
+		return err
+	}
+	hc, err := keystore.DecodeCothority(buf, pass)
+	if err != nil {
+		return err
+	}
+	si, err := hc.GetServerIdentity()
+	if err != nil {
+		return fmt.Errorf("parse server identity: %v", err)
+	}
+	pub, err := si.Public.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	// Same path as the one used by onet for the database of the server.
+	dir := os.Getenv("CONODE_SERVICE_PATH")
+	if dir == "" {
+		dir = cfgpath.GetDataPath("conode")
+	}
+	db := filepath.Join(dir, fmt.Sprintf("%x.db", sha256.Sum256(pub)))
+	if err := keystore.Compact(db); err != nil {
+		return fmt.Errorf("compacting %v: %v", db, err)
+	}
+	fmt.Fprintf(os.Stderr, "Compacted %v\n", db)
+	return nil
+}
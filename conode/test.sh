@@ -11,6 +11,7 @@ main(){
     run testBuild
     run testConode
     run testDatabase
+    run testKeystore
     stopTest
 }
 
@@ -43,4 +44,24 @@ testBuild(){
     testOK dbgRun runCo 1 --help
 }
 
+testKeystore(){
+    echo "secret passphrase" > pass.txt
+    testOK runCo 1 keystore seal --passphrase-file pass.txt
+    testGrep "BEGIN COTHORITY SEALED DATA" cat co1/private.toml
+    testFail runCo 1 keystore seal --passphrase-file pass.txt
+
+    # The sealed conode starts with the passphrase from the environment.
+    cp co1/public.toml .
+    CONODE_PASSPHRASE="secret passphrase" runCoBG 1
+    testOK runCo 2 check -g public.toml
+    pkill conode
+    rm -f "$COLOG"1.log.dead
+
+    # The stopped conode's database is compacted.
+    testOK runCo 1 keystore compact --passphrase-file pass.txt
+
+    # The wrong passphrase doesn't open it.
+    CONODE_PASSPHRASE="wrong" testFail runCo 1 server
+}
+
 main
@@ -0,0 +1,94 @@
+package darc
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// AttrBlockIndex is the name of the attribute restricting a rule to an
+	// interval of block indexes, e.g. 'attr:block:after=10&before=20'.
+	AttrBlockIndex = "block"
+	// AttrBlockTime is the name of the attribute restricting a rule to an
+	// interval of block times, e.g.
+	// 'attr:block_time:after=2020-01-01T00:00:00Z&before=1609459200'. The
+	// times are given in RFC3339 or as seconds since the Unix epoch.
+	AttrBlockTime = "block_time"
+)
+
+// NewBlockIndexAttr returns the interpreter of the AttrBlockIndex attribute
+// for a block with the given index. Both bounds of the interval are
+// optional and excluded.
+func NewBlockIndexAttr(index int) func(string) error {
+	return func(attr string) error {
+		vals, err := url.ParseQuery(attr)
+		if err != nil {
+			return xerrors.Errorf("parsing query: %v", err)
+		}
+
+		after, before := -1, index+1
+		if s := vals.Get("after"); len(s) > 0 {
+			after, err = strconv.Atoi(s)
+			if err != nil {
+				return xerrors.Errorf("atoi: %v", err)
+			}
+		}
+		if s := vals.Get("before"); len(s) > 0 {
+			before, err = strconv.Atoi(s)
+			if err != nil {
+				return xerrors.Errorf("atoi: %v", err)
+			}
+		}
+
+		if after < index && index < before {
+			return nil
+		}
+		return xerrors.Errorf("the current block index is %d which does "+
+			"not fit in the interval (%d, %d)", index, after, before)
+	}
+}
+
+// NewBlockTimeAttr returns the interpreter of the AttrBlockTime attribute
+// for a block with the given time. Both bounds of the interval are optional
+// and excluded.
+func NewBlockTimeAttr(now time.Time) func(string) error {
+	return func(attr string) error {
+		vals, err := url.ParseQuery(attr)
+		if err != nil {
+			return xerrors.Errorf("parsing query: %v", err)
+		}
+
+		if s := vals.Get("after"); len(s) > 0 {
+			after, err := parseTime(s)
+			if err != nil {
+				return xerrors.Errorf("parsing after: %v", err)
+			}
+			if !now.After(after) {
+				return xerrors.Errorf("the current block time %s is not "+
+					"after %s", now.UTC().Format(time.RFC3339), s)
+			}
+		}
+		if s := vals.Get("before"); len(s) > 0 {
+			before, err := parseTime(s)
+			if err != nil {
+				return xerrors.Errorf("parsing before: %v", err)
+			}
+			if !now.Before(before) {
+				return xerrors.Errorf("the current block time %s is not "+
+					"before %s", now.UTC().Format(time.RFC3339), s)
+			}
+		}
+		return nil
+	}
+}
+
+// parseTime accepts a time in RFC3339 or in seconds since the Unix epoch.
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
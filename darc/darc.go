@@ -773,6 +773,8 @@ func (s Signer) Type() int {
 		return 3
 	case s.EvmContract != nil:
 		return 4
+	case s.DID != nil:
+		return 5
 	default:
 		return -1
 	}
@@ -790,6 +792,8 @@ func (s Signer) Identity() Identity {
 		return NewIdentityProxy(s.Proxy)
 	case 4:
 		return NewIdentityEvmContract(s.EvmContract)
+	case 5:
+		return NewIdentityDID(s.DID.DID)
 	default:
 		return Identity{}
 	}
@@ -811,6 +815,8 @@ func (s Signer) Sign(msg []byte) ([]byte, error) {
 		return s.Proxy.Sign(msg)
 	case 4:
 		return s.EvmContract.Sign(msg)
+	case 5:
+		return s.DID.Sign(msg)
 	default:
 		return nil, errors.New("unknown signer type")
 	}
@@ -821,6 +827,8 @@ func (s Signer) GetPrivate() (kyber.Scalar, error) {
 	switch s.Type() {
 	case 1:
 		return s.Ed25519.Secret, nil
+	case 5:
+		return s.DID.private()
 	case 0, 2, 3:
 		return nil, errors.New("signer lacks a private key")
 	default:
@@ -845,6 +853,8 @@ func (id Identity) Equal(id2 *Identity) bool {
 		return id.Proxy.Equal(id2.Proxy)
 	case 4:
 		return id.EvmContract.Equal(id2.EvmContract)
+	case 5:
+		return id.DID.Equal(id2.DID)
 	}
 	return false
 }
@@ -863,6 +873,8 @@ func (id Identity) Type() int {
 		return 3
 	case id.EvmContract != nil:
 		return 4
+	case id.DID != nil:
+		return 5
 	}
 	return -1
 }
@@ -881,6 +893,8 @@ func (id Identity) PrimaryIdentity() bool {
 		return true
 	case id.EvmContract != nil:
 		return true
+	case id.DID != nil:
+		return true
 	}
 	return false
 }
@@ -898,6 +912,8 @@ func (id Identity) TypeString() string {
 		return "proxy"
 	case 4:
 		return "evm_contract"
+	case 5:
+		return "did"
 	default:
 		return "No identity"
 	}
@@ -918,6 +934,8 @@ func (id Identity) String() string {
 		bevmString := hex.EncodeToString(id.EvmContract.BEvmID)
 		addrString := id.EvmContract.Address.Hex()
 		return fmt.Sprintf("%s:%s:%s", id.TypeString(), bevmString, addrString)
+	case 5:
+		return id.DID.DID
 	default:
 		return "No identity"
 	}
@@ -937,6 +955,8 @@ func (id Identity) Verify(msg, sig []byte) error {
 		return id.Proxy.Verify(msg, sig)
 	case 4:
 		return id.EvmContract.Verify(msg, sig)
+	case 5:
+		return id.DID.Verify(msg, sig)
 	default:
 		return errors.New("unknown identity")
 	}
@@ -964,6 +984,8 @@ func (id Identity) GetPublicBytes() []byte {
 		return buf
 	case 4:
 		return id.EvmContract.Address[:]
+	case 5:
+		return []byte(id.DID.DID)
 	default:
 		return nil
 	}
@@ -1120,6 +1142,8 @@ func ParseIdentity(in string) (Identity, error) {
 		return parseIDProxy(fields[1])
 	case "evm_contract":
 		return parseIDEvmContract(fields[1])
+	case "did":
+		return parseIDDID(in)
 	default:
 		return Identity{}, fmt.Errorf("unknown identity type %v", fields[0])
 	}
@@ -1229,11 +1253,17 @@ func evalThreshold(visited map[string]bool, getDarc GetDarc,
 		return xerrors.Errorf("failed to convert denominator: %v", err)
 	}
 
-	uniqIds := make(map[string]struct{}, len(entries)-1)
+	// uniqIds holds the weight of every identity, validIds the identities
+	// that signed.
+	uniqIds := make(map[string]int, len(entries)-1)
 	validIds := make(map[string]struct{}, len(entries)-1)
 
 	for _, entry := range entries[1:] {
-		uniqIds[entry] = struct{}{}
+		entry, weight, err := parseWeight(entry)
+		if err != nil {
+			return xerrors.Errorf("wrong threshold entry: %v", err)
+		}
+		uniqIds[entry] = weight
 
 		for _, id := range ids {
 			if id == entry {
@@ -1290,20 +1320,45 @@ func evalThreshold(visited map[string]bool, getDarc GetDarc,
 		}
 	}
 
-	// we clamp the number of identities to at least 1, so we avoid 0/0.
-	if len(uniqIds) == 0 {
-		uniqIds["fake"] = struct{}{}
+	// we clamp the total weight to at least 1, so we avoid 0/0.
+	total, valid := 0, 0
+	for id, weight := range uniqIds {
+		total += weight
+		if _, ok := validIds[id]; ok {
+			valid += weight
+		}
+	}
+	if total == 0 {
+		total = 1
 	}
 
 	// a1/a2 < b1/b2 <=> a1*b2 < a2*b1
-	if denominator*len(validIds) < numerator*len(uniqIds) {
+	if denominator*valid < numerator*total {
 		return xerrors.Errorf("computed fraction is lower than threshold: "+
-			"%d/%d < %d/%d", len(validIds), len(uniqIds), numerator, denominator)
+			"%d/%d < %d/%d", valid, total, numerator, denominator)
 	}
 
 	return nil
 }
 
+// parseWeight splits a threshold entry of the form 'id*weight' in the
+// identity and its weight. An entry without weight has a weight of 1.
+func parseWeight(entry string) (string, int, error) {
+	i := strings.LastIndex(entry, "*")
+	if i < 0 || strings.HasPrefix(entry, "proxy:") {
+		return entry, 1, nil
+	}
+	weight, err := strconv.Atoi(entry[i+1:])
+	if err != nil {
+		return "", 0, xerrors.Errorf("failed to convert weight: %v", err)
+	}
+	if weight < 1 {
+		return "", 0, xerrors.Errorf("weight of %s must be at least 1",
+			entry[:i])
+	}
+	return entry[:i], weight, nil
+}
+
 // NewSignerEd25519 initializes a new SignerEd25519 signer given public and
 // private keys. If either of the given keys is nil, then a new key pair is
 // generated.
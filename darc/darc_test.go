@@ -6,9 +6,12 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/kyber/v3/util/key"
 )
 
 func TestRules(t *testing.T) {
@@ -574,6 +577,58 @@ func TestDarc_Threshold_Simple(t *testing.T) {
 	}
 }
 
+func TestDarc_Threshold_Weighted(t *testing.T) {
+	getDarc := func(id string, latest bool) *Darc {
+		return nil
+	}
+
+	id1 := createIdentity().String()
+	id2 := createIdentity().String()
+	id3 := createIdentity().String()
+
+	// id1 holds 2 of the 4 votes.
+	expr := []byte(fmt.Sprintf("threshold<2/3,%s*2,%s,%s>", id1, id2, id3))
+	require.Error(t, EvalExprAttr(expr, getDarc, nil, id1))
+	require.Error(t, EvalExprAttr(expr, getDarc, nil, id2, id3))
+	require.NoError(t, EvalExprAttr(expr, getDarc, nil, id1, id2))
+	require.NoError(t, EvalExprAttr(expr, getDarc, nil, id1, id3))
+
+	expr = []byte(fmt.Sprintf("threshold<1/2,%s *3,%s>", id1, id2))
+	require.NoError(t, EvalExprAttr(expr, getDarc, nil, id1))
+	require.Error(t, EvalExprAttr(expr, getDarc, nil, id2))
+
+	expr = []byte(fmt.Sprintf("threshold<1/2,%s*0,%s>", id1, id2))
+	require.Error(t, EvalExprAttr(expr, getDarc, nil, id1, id2))
+}
+
+func TestDarc_BlockAttr(t *testing.T) {
+	index := NewBlockIndexAttr(10)
+	require.NoError(t, index(""))
+	require.NoError(t, index("after=9&before=11"))
+	require.Error(t, index("after=10"))
+	require.Error(t, index("before=10"))
+	require.Error(t, index("after=abc"))
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	bt := NewBlockTimeAttr(now)
+	require.NoError(t, bt(""))
+	require.NoError(t, bt("after=2020-01-01T00:00:00Z&before=2021-01-01T00:00:00Z"))
+	require.NoError(t, bt(fmt.Sprintf("after=%d", now.Unix()-1)))
+	require.Error(t, bt(fmt.Sprintf("after=%d", now.Unix())))
+	require.Error(t, bt("before=2020-01-01T00:00:00Z"))
+	require.Error(t, bt("before=tomorrow"))
+
+	getDarc := func(id string, latest bool) *Darc {
+		return nil
+	}
+	attrs := AttrInterpreters{AttrBlockIndex: index, AttrBlockTime: bt}
+	id := createIdentity().String()
+	expr := []byte(id + " & attr:block_time:before=2021-01-01T00:00:00Z")
+	require.NoError(t, EvalExprAttr(expr, getDarc, attrs, id))
+	expr = []byte(id + " & attr:block:after=10")
+	require.Error(t, EvalExprAttr(expr, getDarc, attrs, id))
+}
+
 func TestDarc_Threshold_Darc(t *testing.T) {
 
 	darc1 := createDarc(1, "darc 1")
@@ -747,4 +802,52 @@ func TestParseIdentity(t *testing.T) {
 	require.NotNil(t, i.EvmContract)
 	// ToLower() because common.Address uses address checksum (EIP-55)
 	require.Equal(t, in, strings.ToLower(i.String()))
+
+	in = "did:byzcoin"
+	i, err = ParseIdentity(in)
+	require.Error(t, err)
+
+	in = "did:byzcoin:0102"
+	i, err = ParseIdentity(in)
+	require.NoError(t, err)
+	require.NotNil(t, i.DID)
+	require.Equal(t, "byzcoin", i.DID.Method)
+	require.Equal(t, in, i.String())
+}
+
+func TestDarc_DID(t *testing.T) {
+	did := "did:byzcoin:0102"
+	kp := key.NewKeyPair(cothority.Suite)
+	signer, err := NewSignerDID(did, kp.Public, kp.Private)
+	require.NoError(t, err)
+	id := signer.Identity()
+	require.Equal(t, did, id.String())
+	require.True(t, id.PrimaryIdentity())
+
+	msg := []byte("document")
+	sig, err := signer.Sign(msg)
+	require.NoError(t, err)
+	// The document must be resolved first.
+	require.Error(t, id.Verify(msg, sig))
+
+	pub, err := kp.Public.MarshalBinary()
+	require.NoError(t, err)
+	other, err := key.NewKeyPair(cothority.Suite).Public.MarshalBinary()
+	require.NoError(t, err)
+	id.DID.DIDDoc = &DIDDoc{
+		ID: did,
+		Authentication: []VerificationMethod{
+			{PublicKey: PublicKey{Type: DIDKeyTypeEd25519, Value: other}},
+			{PublicKey: PublicKey{Type: DIDKeyTypeEd25519, Value: pub}},
+		},
+	}
+	require.NoError(t, id.Verify(msg, sig))
+	require.Error(t, id.Verify([]byte("other"), sig))
+
+	id.DID.DIDDoc.ID = "did:byzcoin:0304"
+	require.Error(t, id.Verify(msg, sig))
+
+	// A DID can be used in expressions.
+	expr := []byte(fmt.Sprintf("%s | threshold<1/2,%s*2,ed25519:aa>", did, did))
+	require.NoError(t, EvalExpr(expr, nil, did))
 }
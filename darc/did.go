@@ -1,12 +1,121 @@
 package darc
 
-// Support for verification using DIDs on DARCs requires resolving the
-// DID to a DID document containing public keys. There is preliminary work
-// done in the `darc-resolve-did` branch which relies on an external
-// service like Indy VDR to resolve Sovrin/Indy DIDs. One of its limitations is
-// that it only supports resolving the latest DID Document and not the DID
-// Document as it existed at a certain point in time. This might pose problems
-// while replaying the chain. A more concrete solution would involve following
-// the Indy `NYM` and `POOL` ledgers which is largely future work.
-// The `did-contract` branch implements a patriciatree that may be used to
-// verify the state root hash on Indy ledgers.
+// A DID identity names a decentralized identifier, for example
+// 'did:byzcoin:0123...ef'. It is verified against the authentication keys of
+// the DID document, which must be resolved before the verification. The
+// resolution is not done here, as it depends on the ledger holding the
+// documents: ByzCoin resolves 'did:byzcoin' DIDs from its global state, as it
+// was when the instruction was executed, so that replaying the chain gives
+// the same result.
+
+import (
+	"strings"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"golang.org/x/xerrors"
+)
+
+// DIDKeyTypeEd25519 is the type of the Ed25519 public keys in a DID
+// document.
+const DIDKeyTypeEd25519 = "Ed25519VerificationKey2018"
+
+// NewIdentityDID creates a new DID identity. The DID document is not
+// resolved.
+func NewIdentityDID(did string) Identity {
+	return Identity{
+		DID: &IdentityDID{
+			DID:    did,
+			Method: didMethod(did),
+		},
+	}
+}
+
+// Equal returns true if both IdentityDID are the same DID. The documents are
+// not compared, as they only hold a resolved version of the DID.
+func (idd IdentityDID) Equal(idd2 *IdentityDID) bool {
+	return idd.DID == idd2.DID
+}
+
+// Verify returns nil if the signature is correct for one of the
+// authentication keys of the resolved DID document, or an error otherwise.
+func (idd IdentityDID) Verify(msg, sig []byte) error {
+	if idd.DIDDoc == nil {
+		return xerrors.Errorf("document of %s is not resolved", idd.DID)
+	}
+	if idd.DIDDoc.ID != idd.DID {
+		return xerrors.Errorf("document %s doesn't belong to %s",
+			idd.DIDDoc.ID, idd.DID)
+	}
+	for _, vm := range idd.DIDDoc.Authentication {
+		if vm.PublicKey.Type != DIDKeyTypeEd25519 {
+			continue
+		}
+		pub := cothority.Suite.Point()
+		if err := pub.UnmarshalBinary(vm.PublicKey.Value); err != nil {
+			continue
+		}
+		if schnorr.Verify(cothority.Suite, pub, msg, sig) == nil {
+			return nil
+		}
+	}
+	return xerrors.Errorf("no authentication key of %s matches the signature",
+		idd.DID)
+}
+
+// NewSignerDID creates a new signer for the DID, using one of the
+// authentication keys of its document.
+func NewSignerDID(did string, public kyber.Point, private kyber.Scalar) (Signer, error) {
+	pub, err := public.MarshalBinary()
+	if err != nil {
+		return Signer{}, xerrors.Errorf("marshalling public key: %v", err)
+	}
+	secret, err := private.MarshalBinary()
+	if err != nil {
+		return Signer{}, xerrors.Errorf("marshalling private key: %v", err)
+	}
+	return Signer{DID: &SignerDID{
+		Public: pub,
+		Secret: secret,
+		DID:    did,
+	}}, nil
+}
+
+// Sign creates a schnorr signature on the message.
+func (sd SignerDID) Sign(msg []byte) ([]byte, error) {
+	private, err := sd.private()
+	if err != nil {
+		return nil, err
+	}
+	return schnorr.Sign(cothority.Suite, private, msg)
+}
+
+func (sd SignerDID) private() (kyber.Scalar, error) {
+	private := cothority.Suite.Scalar()
+	if err := private.UnmarshalBinary(sd.Secret); err != nil {
+		return nil, xerrors.Errorf("unmarshalling private key: %v", err)
+	}
+	return private, nil
+}
+
+// parseIDDID parses a DID of the form 'did:method:identifier'.
+func parseIDDID(in string) (Identity, error) {
+	fields := strings.SplitN(in, ":", 3)
+	if len(fields) != 3 || fields[0] != "did" || len(fields[1]) == 0 ||
+		len(fields[2]) == 0 {
+		return Identity{}, xerrors.New("expected DID format of " +
+			"did:method:identifier")
+	}
+	return NewIdentityDID(in), nil
+}
+
+// didMethod returns the method of the DID, or an empty string if the DID is
+// malformed.
+func didMethod(did string) string {
+	fields := strings.SplitN(did, ":", 3)
+	if len(fields) != 3 || fields[0] != "did" {
+		return ""
+	}
+	return fields[1]
+}
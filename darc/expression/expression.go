@@ -9,15 +9,18 @@ the syntax we use is from: https://en.wikipedia.org/wiki/Extended_Backus%E2%80%9
 	identity = (darc|ed25519|x509ec):[0-9a-fA-F]+
 	proxy = proxy:[0-9a-fA-F]+:[^ \n\t]*
 	evm_identity = evm_contract:[0-9a-fA-F]+:0x[0-9a-fA-F]+
+	did = did:[0-9a-z]+:[0-9a-zA-Z\.\-\_]+
 	attr = attr:[0-9a-zA-Z\-\_]+:[^ \n\t]*
-	threshold = threshold<\d+/\d+ [',' id]* >
+	threshold = threshold<\d+/\d+ [',' id ['*' \d+]]* >
 
 Examples:
 
 	ed25519:deadbeef // every id evaluates to a boolean
 	(ed25519:a & x509ec:b) | (darc:c & ed25519:d)
 	proxy:deadbeef:me@example.com // where deadbeef is a ed25519 public key
+	did:byzcoin:deadbeef // any authentication key of the DID document
 	attr:time_interval:before=5pm&after=9am & ed25519:deadbeef
+	threshold<2/3, ed25519:a*2, ed25519:b, darc:c>
 
 In the simplest case, the evaluation of an expression is performed against a
 set of valid ids.  Suppose we have the expression (a:a & b:b) | (c:c & d:d),
@@ -26,6 +29,11 @@ true.  If the set of valid ids is [a:a, c:c], then the expression will evaluate
 to false. However, the user is able to provide a ValueCheckFn to customise how
 the expressions are evaluated.
 
+In a threshold, every id counts once, unless it is followed by a weight. The
+threshold<2/3, ed25519:a*2, ed25519:b, darc:c> above is met if a signs
+together with b or c, as a holds 2 of the 4 votes. The weight is kept in the
+id that is given to the ValueCheckFn, which has to interpret it.
+
 EXTENSION - NOT YET IMPLEMENTED:
 To support threshold signatures, we extend the syntax to include the following.
 	thexpr = '[', id, [ ',', id ]*, ']', '/', digit
@@ -65,7 +73,10 @@ func InitParser(fn ValueCheckFn) parsec.Parser {
 	var orop = parsec.Token(`\|`, "OR")
 
 	// Threshold expression.
-	tElems := parsec.OrdChoice(one2one, identity(), proxy(), evmIdentity())
+	tWeight := parsec.Token(`\*\d+`, "TWEIGHT")
+	tElems := parsec.And(thresholdElemNode,
+		parsec.OrdChoice(one2one, identity(), proxy(), evmIdentity(), did()),
+		parsec.Maybe(one2one, tWeight))
 	startT := parsec.Token("threshold<", "STARTT")
 	endT := parsec.Token(">", "ENDT")
 	tVal := parsec.Token(`\d+/\d+`, "TVAL")
@@ -91,7 +102,7 @@ func InitParser(fn ValueCheckFn) parsec.Parser {
 	sum = parsec.And(sumNode(fn), &value, prodK)
 	// value -> id | "(" expr ")"
 	value = parsec.OrdChoice(exprValueNode(fn), identity(), proxy(),
-		evmIdentity(), attr(), threshold, groupExpr)
+		evmIdentity(), did(), attr(), threshold, groupExpr)
 	// expr  -> sum
 	Y = parsec.OrdChoice(one2one, sum)
 	return Y
@@ -165,6 +176,15 @@ func evmIdentity() parsec.Parser {
 	}
 }
 
+// Accepts tokens of the form "did:method:identifier"
+func did() parsec.Parser {
+	return func(s parsec.Scanner) (parsec.ParsecNode, parsec.Scanner) {
+		_, s = s.SkipAny(`^[ \n\t]+`)
+		p := parsec.Token(`did:[0-9a-z]+:[0-9a-zA-Z\.\-\_]+`, "DID")
+		return p(s)
+	}
+}
+
 // Accepts tokens of the form that begins with "attr:"
 func attr() parsec.Parser {
 	return func(s parsec.Scanner) (parsec.ParsecNode, parsec.Scanner) {
@@ -226,6 +246,19 @@ func exprThresholeNode(fn ValueCheckFn) func(ns []parsec.ParsecNode) parsec.Pars
 	}
 }
 
+// thresholdElemNode appends the optional weight to the id of a threshold
+// element, so that 'darc:aa *2' becomes 'darc:aa*2'.
+func thresholdElemNode(ns []parsec.ParsecNode) parsec.ParsecNode {
+	if len(ns) != 2 {
+		return nil
+	}
+	id := *ns[0].(*parsec.Terminal)
+	if weight, ok := ns[1].(*parsec.Terminal); ok {
+		id.Value += weight.Value
+	}
+	return &id
+}
+
 func exprNode(ns []parsec.ParsecNode) parsec.ParsecNode {
 	if len(ns) == 0 {
 		return nil
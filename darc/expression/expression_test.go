@@ -262,6 +262,27 @@ func TestParsing_Threshold_Simple(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestParsing_Threshold_Weighted(t *testing.T) {
+	getFn := func(expected string) func(s string) bool {
+		return func(expr string) bool {
+			require.Equal(t, expected, expr)
+			return true
+		}
+	}
+
+	expr := []byte(`threshold<2/3,darc:aa*2,ed25519:bb>`)
+	_, err := Evaluate(InitParser(getFn("threshold<2/3,darc:aa*2,ed25519:bb>")), expr)
+	require.NoError(t, err)
+
+	expr = []byte(`threshold< 2/3 , darc:aa *2, ed25519:bb*10 >`)
+	_, err = Evaluate(InitParser(getFn("threshold<2/3,darc:aa*2,ed25519:bb*10>")), expr)
+	require.NoError(t, err)
+
+	expr = []byte(`threshold<2/3,darc:aa*,ed25519:bb>`)
+	_, err = Evaluate(InitParser(trueFn), expr)
+	require.Error(t, err)
+}
+
 func TestParsing_Threshold_Integrated(t *testing.T) {
 	expr := []byte(`(darc:ed | darc:aa) & (threshold<1/2,darc:ee, ed25519:ff>|(darc:ae))`)
 	_, err := Evaluate(InitParser(trueFn), expr)
@@ -125,7 +125,11 @@ func (o *Setup) Dispatch() error {
 			return err
 		}
 	}
-	for !o.DKG.Certified() {
+	// In a resharing, the old nodes which are not in the new group only
+	// issue deals. They cannot tell when all deals are certified, because
+	// the nodes in both groups do not send a response to their own deal.
+	dealOnly := o.DKG.ExpectedDeals() == 0
+	for !dealOnly && !o.DKG.Certified() {
 		err := o.allResponse(<-o.structResponse)
 		if err != nil && err.Error() != "vss: already existing response from same origin" {
 			return err
@@ -137,12 +141,12 @@ func (o *Setup) Dispatch() error {
 			o.SendToChildren(&WaitSetup{})
 			<-o.structWaitReply
 		} else {
-			<-o.structWaitSetup
+			o.waitSetup()
 			o.SendToParent(&WaitReply{})
 		}
 	}
 
-	if !o.DKG.Certified() {
+	if !dealOnly && !o.DKG.Certified() {
 		return errors.New("not certified")
 	}
 
@@ -150,6 +154,18 @@ func (o *Setup) Dispatch() error {
 	return nil
 }
 
+// waitSetup waits for the root to be set up, dropping the responses which
+// are not needed anymore, so that they do not fill up the channel.
+func (o *Setup) waitSetup() {
+	for {
+		select {
+		case <-o.structResponse:
+		case <-o.structWaitSetup:
+			return
+		}
+	}
+}
+
 // Children reactions
 func (o *Setup) childInit(i structInit) error {
 	o.Wait = i.Wait
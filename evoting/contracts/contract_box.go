@@ -0,0 +1,367 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractBoxID denotes a contract holding the ballots and the state of an
+// election.
+var ContractBoxID = "evotingBox"
+
+// ContractBallotID denotes a contract holding the last ballot of a voter. It
+// is spawned and updated by the ballot box.
+var ContractBallotID = "evotingBallot"
+
+// ContractMixID denotes a contract holding a shuffle of the ballots of an
+// election. It is spawned by the ballot box.
+var ContractMixID = "evotingMix"
+
+// ContractPartialID denotes a contract holding a partial decryption of the
+// ballots of an election. It is spawned by the ballot box.
+var ContractPartialID = "evotingPartial"
+
+// BoxID returns the instance of the ballot box of an election.
+func BoxID(election byzcoin.InstanceID) byzcoin.InstanceID {
+	return deriveID(election, ContractBoxID, 0)
+}
+
+// BallotID returns the instance of the ballot of the user in an election.
+func BallotID(election byzcoin.InstanceID, user string) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(BoxID(election).Slice())
+	h.Write([]byte(ContractBallotID))
+	h.Write([]byte(user))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// MixID returns the instance of the i-th mix of an election.
+func MixID(election byzcoin.InstanceID, i int) byzcoin.InstanceID {
+	return deriveID(election, ContractMixID, i)
+}
+
+// PartialID returns the instance of the i-th partial of an election.
+func PartialID(election byzcoin.InstanceID, i int) byzcoin.InstanceID {
+	return deriveID(election, ContractPartialID, i)
+}
+
+func deriveID(election byzcoin.InstanceID, contractID string, i int) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write(election.Slice())
+	h.Write([]byte(contractID))
+	binary.Write(h, binary.LittleEndian, uint32(i))
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// ContractBox is the ballot box of an election. Only the last ballot of each
+// voter is kept, so that a voter can replace their ballot while the election
+// is running. Every voter has their own ballot instance, so that casting a
+// ballot doesn't rewrite the other ones.
+type ContractBox struct {
+	byzcoin.BasicContract
+	BoxStruct
+}
+
+// ContractBoxFromBytes returns a ballot box contract given a slice of bytes,
+// or an error if something went wrong.
+func ContractBoxFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractBox{}
+	err := protobuf.DecodeWithConstructors(in, &c.BoxStruct, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal box: %v", err)
+	}
+	return c, nil
+}
+
+// Spawn creates a mix from the "mix" argument, or a partial from the
+// "partial" argument, after verifying it against the previous ones.
+func (c *ContractBox) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get darc: %v", err)
+	}
+	election, err := c.election(rst)
+	if err != nil {
+		return nil, nil, err
+	}
+	mixes, err := c.mixes(rst)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The ballots are only needed for the first mix, or for the partials of
+	// a homomorphic election.
+	var ballots []*lib.Ballot
+	if len(mixes) == 0 {
+		ballots, err = c.ballots(rst)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var id byzcoin.InstanceID
+	var buf []byte
+	switch inst.Spawn.ContractID {
+	case ContractMixID:
+		buf = inst.Spawn.Args.Search("mix")
+		mix := &lib.Mix{}
+		err = protobuf.DecodeWithConstructors(buf, mix, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't unmarshal mix: %v", err)
+		}
+		if err := election.verifyMix(ballots, mixes, mix); err != nil {
+			return nil, nil, xerrors.Errorf("invalid mix: %v", err)
+		}
+		id = MixID(c.Election, len(c.Mixes))
+		c.Mixes = append(c.Mixes, id)
+	case ContractPartialID:
+		buf = inst.Spawn.Args.Search("partial")
+		partial := &lib.Partial{}
+		err = protobuf.DecodeWithConstructors(buf, partial, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't unmarshal partial: %v", err)
+		}
+		// Without the commits, the decryption couldn't be verified.
+		if len(election.Commits) == 0 {
+			return nil, nil, errors.New("election has no commits to verify the partial")
+		}
+		partials, err := c.partials(rst)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := election.verifyPartial(ballots, mixes, partials, partial); err != nil {
+			return nil, nil, xerrors.Errorf("invalid partial: %v", err)
+		}
+		id = PartialID(c.Election, len(c.Partials))
+		c.Partials = append(c.Partials, id)
+	default:
+		return nil, nil, errors.New("can only spawn mixes and partials")
+	}
+	c.Stage = election.stage(&c.BoxStruct)
+
+	boxBuf, err := protobuf.Encode(&c.BoxStruct)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode box: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, id, inst.Spawn.ContractID, buf, darcID),
+		byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID, ContractBoxID, boxBuf, darcID),
+	}
+	return
+}
+
+// Invoke has the following command:
+//   - cast casts the ballot given in "ballot" for the voter signing the
+//     instruction, whose identity becomes the user ID of the ballot. A
+//     previous ballot of the voter is replaced in its instance, else a new
+//     ballot instance is spawned and counted in the box.
+func (c *ContractBox) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get darc: %v", err)
+	}
+
+	switch inst.Invoke.Command {
+	case "cast":
+		election, err := c.election(rst)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(c.Mixes) > 0 || len(c.Partials) > 0 {
+			return nil, nil, errors.New("election not in running stage")
+		}
+		if now := blockTimestamp(rst) / 1e9; now != 0 {
+			if now < election.Start {
+				return nil, nil, errors.New("election is not yet open")
+			}
+			if now > election.End {
+				return nil, nil, errors.New("election is already closed")
+			}
+		}
+		if len(inst.SignerIdentities) != 1 {
+			return nil, nil, errors.New("a ballot must be signed by its voter only")
+		}
+		user := inst.SignerIdentities[0].String()
+
+		ballot := &lib.Ballot{}
+		err = protobuf.DecodeWithConstructors(inst.Invoke.Args.Search("ballot"), ballot,
+			network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't unmarshal ballot: %v", err)
+		}
+		ballot.User, ballot.UserID = 0, user
+		if err := election.verifyBallot(user, ballot); err != nil {
+			return nil, nil, xerrors.Errorf("invalid ballot: %v", err)
+		}
+
+		id := BallotID(c.Election, user)
+		var bs BallotStruct
+		action := byzcoin.Create
+		if err := getInstance(rst, id, ContractBallotID, &bs); err == nil {
+			action = byzcoin.Update
+		} else {
+			bs.Previous = c.Last
+			c.Last = id
+			c.Voters++
+		}
+		bs.Ballot = ballot
+		buf, err := protobuf.Encode(&bs)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode ballot: %v", err)
+		}
+		sc = append(sc, byzcoin.NewStateChange(action, id, ContractBallotID, buf, darcID))
+		if action == byzcoin.Update {
+			return sc, cout, nil
+		}
+	default:
+		return nil, nil, errors.New("unknown command: " + inst.Invoke.Command)
+	}
+
+	buf, err := protobuf.Encode(&c.BoxStruct)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode box: %v", err)
+	}
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
+		ContractBoxID, buf, darcID))
+	return
+}
+
+// election returns the election of the box.
+func (c *ContractBox) election(rst byzcoin.ReadOnlyStateTrie) (*ContractElection, error) {
+	buf, _, contractID, _, err := rst.GetValues(c.Election.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get election: %v", err)
+	}
+	if contractID != ContractElectionID {
+		return nil, errors.New("box does not point to an election")
+	}
+	ce, err := ContractElectionFromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	election := ce.(*ContractElection)
+	if len(election.ID) == 0 {
+		election.ID = c.Election.Slice()
+	}
+	return election, nil
+}
+
+// ballots returns the ballots of the box, in the order their voters cast
+// their first ballot.
+func (c *ContractBox) ballots(rst byzcoin.ReadOnlyStateTrie) ([]*lib.Ballot, error) {
+	ballots := make([]*lib.Ballot, c.Voters)
+	id := c.Last
+	for i := c.Voters - 1; i >= 0; i-- {
+		var bs BallotStruct
+		if err := getInstance(rst, id, ContractBallotID, &bs); err != nil {
+			return nil, err
+		}
+		ballots[i] = bs.Ballot
+		id = bs.Previous
+	}
+	return ballots, nil
+}
+
+// mixes returns the mixes of the box, in order.
+func (c *ContractBox) mixes(rst byzcoin.ReadOnlyStateTrie) ([]*lib.Mix, error) {
+	mixes := make([]*lib.Mix, len(c.Mixes))
+	for i, id := range c.Mixes {
+		mixes[i] = &lib.Mix{}
+		if err := getInstance(rst, id, ContractMixID, mixes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return mixes, nil
+}
+
+// partials returns the partials of the box.
+func (c *ContractBox) partials(rst byzcoin.ReadOnlyStateTrie) ([]*lib.Partial, error) {
+	partials := make([]*lib.Partial, len(c.Partials))
+	for i, id := range c.Partials {
+		partials[i] = &lib.Partial{}
+		if err := getInstance(rst, id, ContractPartialID, partials[i]); err != nil {
+			return nil, err
+		}
+	}
+	return partials, nil
+}
+
+// getInstance decodes the value of an instance of the given contract.
+func getInstance(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID, contractID string, value interface{}) error {
+	buf, _, cid, _, err := rst.GetValues(id.Slice())
+	if err != nil {
+		return xerrors.Errorf("couldn't get instance %x: %v", id.Slice(), err)
+	}
+	if cid != contractID {
+		return xerrors.Errorf("instance %x is not a %s", id.Slice(), contractID)
+	}
+	return protobuf.DecodeWithConstructors(buf, value, network.DefaultConstructors(cothority.Suite))
+}
+
+// ContractBallot is the last ballot of a voter. It is only changed by the
+// ballot box.
+type ContractBallot struct {
+	byzcoin.BasicContract
+	BallotStruct
+}
+
+// ContractBallotFromBytes returns a ballot contract given a slice of bytes, or
+// an error if something went wrong.
+func ContractBallotFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractBallot{}
+	err := protobuf.DecodeWithConstructors(in, &c.BallotStruct, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal ballot: %v", err)
+	}
+	return c, nil
+}
+
+// ContractMix is a shuffle of the ballots of an election. It cannot be
+// changed once spawned by the ballot box.
+type ContractMix struct {
+	byzcoin.BasicContract
+	lib.Mix
+}
+
+// ContractMixFromBytes returns a mix contract given a slice of bytes, or an
+// error if something went wrong.
+func ContractMixFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractMix{}
+	err := protobuf.DecodeWithConstructors(in, &c.Mix, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal mix: %v", err)
+	}
+	return c, nil
+}
+
+// ContractPartial is a partial decryption of the ballots of an election. It
+// cannot be changed once spawned by the ballot box.
+type ContractPartial struct {
+	byzcoin.BasicContract
+	lib.Partial
+}
+
+// ContractPartialFromBytes returns a partial contract given a slice of bytes,
+// or an error if something went wrong.
+func ContractPartialFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractPartial{}
+	err := protobuf.DecodeWithConstructors(in, &c.Partial, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal partial: %v", err)
+	}
+	return c, nil
+}
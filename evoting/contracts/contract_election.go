@@ -0,0 +1,265 @@
+package contracts
+
+import (
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractElectionID denotes a contract holding the configuration of an
+// election.
+var ContractElectionID = "evotingElection"
+
+// ContractElection is an election. Its instance holds the configuration of
+// the election as it was given when spawning it, and is never updated: the
+// ballots and the stage of the election are stored in its ballot box.
+//
+// The darc of the election guards all the instances of the election. It
+// should have the following rules:
+//   - spawn:evotingElection for the admins opening elections
+//   - invoke:evotingBox.cast for the voters
+//   - spawn:evotingMix and spawn:evotingPartial for the nodes, or the admins,
+//     storing the shuffles and the decryptions
+//
+// The evoting service doesn't run the shuffles and the decryptions of the
+// elections stored on ByzCoin: their mixes and partials must be spawned by
+// the clients holding them.
+type ContractElection struct {
+	byzcoin.BasicContract
+	lib.Election
+}
+
+// ContractElectionFromBytes returns an election contract given a slice of
+// bytes, or an error if something went wrong.
+func ContractElectionFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractElection{}
+	err := protobuf.DecodeWithConstructors(in, &c.Election, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal election: %v", err)
+	}
+	return c, nil
+}
+
+// Spawn creates a new election with the configuration given in the
+// "election" argument, and its empty ballot box. The election is stored as
+// given, because its maps cannot be encoded deterministically.
+//
+// A new election needs the commits of the DKG, so that the partials are
+// verified with the public key shares of the nodes. An election stored on a
+// skipchain is migrated by adding the "migration" argument, holding its box,
+// mixes and partials. They are verified against the election and copied to
+// the ballot box and to new mix and partial instances. The partials of an
+// election opened before the commits were stored only have their signature
+// checked, and no more partials can be spawned for it.
+func (c *ContractElection) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get darc: %v", err)
+	}
+
+	buf := inst.Spawn.Args.Search("election")
+	if len(buf) == 0 {
+		return nil, nil, errors.New("need an election in 'election' argument")
+	}
+	err = protobuf.DecodeWithConstructors(buf, &c.Election, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't unmarshal election: %v", err)
+	}
+	if c.Roster == nil || len(c.Roster.List) == 0 {
+		return nil, nil, errors.New("election needs a roster")
+	}
+	if c.Key == nil {
+		return nil, nil, errors.New("election needs a key")
+	}
+	if err := c.Check(); err != nil {
+		return nil, nil, err
+	}
+	if len(c.Commits) > 0 {
+		if _, err := c.PublicShare(0); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	id, err := inst.DeriveIDArg("", "preID")
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get ID for instance: %v", err)
+	}
+	if len(c.ID) == 0 {
+		c.ID = id.Slice()
+	}
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, id,
+		ContractElectionID, buf, darcID))
+
+	box := &BoxStruct{Election: id, Stage: lib.Running}
+	if m := inst.Spawn.Args.Search("migration"); m != nil {
+		var migration Migration
+		err = protobuf.DecodeWithConstructors(m, &migration, network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't unmarshal migration: %v", err)
+		}
+		scs, err := c.migrate(box, &migration, darcID)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't migrate election: %v", err)
+		}
+		sc = append(sc, scs...)
+	} else {
+		if len(c.Commits) == 0 {
+			return nil, nil, errors.New("election needs the commits of the DKG")
+		}
+		if now := blockTimestamp(rst); now != 0 && c.End <= now/1e9 {
+			return nil, nil, errors.New("invalid end date")
+		}
+	}
+
+	boxBuf, err := protobuf.Encode(box)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode box: %v", err)
+	}
+	sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, BoxID(id),
+		ContractBoxID, boxBuf, darcID))
+	return
+}
+
+// migrate verifies the state of an election stored on a skipchain, and
+// returns the state changes creating its ballots, mixes and partials.
+func (c *ContractElection) migrate(box *BoxStruct, m *Migration, darcID darc.ID) ([]byzcoin.StateChange, error) {
+	var sc []byzcoin.StateChange
+	var ballots []*lib.Ballot
+	if m.Box != nil {
+		ballots = m.Box.Ballots
+		voted := make(map[string]bool)
+		for _, ballot := range ballots {
+			if voted[ballot.UserID] {
+				return nil, fmt.Errorf("ballot of %s: more than one ballot", ballot.UserID)
+			}
+			voted[ballot.UserID] = true
+			if err := c.verifyBallot(ballot.UserID, ballot); err != nil {
+				return nil, fmt.Errorf("ballot of %s: %v", ballot.UserID, err)
+			}
+			id := BallotID(box.Election, ballot.UserID)
+			buf, err := protobuf.Encode(&BallotStruct{Ballot: ballot, Previous: box.Last})
+			if err != nil {
+				return nil, err
+			}
+			sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, id, ContractBallotID, buf, darcID))
+			box.Last = id
+			box.Voters++
+		}
+	}
+
+	for i, mix := range m.Mixes {
+		if err := c.verifyMix(ballots, m.Mixes[:i], mix); err != nil {
+			return nil, fmt.Errorf("mix %d: %v", i, err)
+		}
+		buf, err := protobuf.Encode(mix)
+		if err != nil {
+			return nil, err
+		}
+		id := MixID(box.Election, i)
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, id, ContractMixID, buf, darcID))
+		box.Mixes = append(box.Mixes, id)
+	}
+
+	for i, partial := range m.Partials {
+		if err := c.verifyPartial(ballots, m.Mixes, m.Partials[:i], partial); err != nil {
+			return nil, fmt.Errorf("partial %d: %v", i, err)
+		}
+		buf, err := protobuf.Encode(partial)
+		if err != nil {
+			return nil, err
+		}
+		id := PartialID(box.Election, i)
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Create, id, ContractPartialID, buf, darcID))
+		box.Partials = append(box.Partials, id)
+	}
+	box.Stage = c.stage(box)
+	return sc, nil
+}
+
+// verifyBallot checks that the user can cast the ballot.
+func (c *ContractElection) verifyBallot(user string, ballot *lib.Ballot) error {
+	if len(c.Voters) > 0 && !c.IsUser(user) {
+		return errors.New("not a registered voter")
+	}
+	if err := ballot.Check(); err != nil {
+		return err
+	}
+	return c.VerifyBallot(ballot)
+}
+
+// verifyMix checks that the mix is a valid shuffle of the last mix, or of the
+// ballots if it is the first mix.
+func (c *ContractElection) verifyMix(ballots []*lib.Ballot, mixes []*lib.Mix, mix *lib.Mix) error {
+	if c.Mode == lib.Homomorphic {
+		return errors.New("homomorphic elections are not shuffled")
+	}
+	if len(mixes) > 2*len(c.Roster.List)/3 {
+		return errors.New("election already shuffled")
+	}
+	for _, m := range mixes {
+		if m.NodeID.Equal(mix.NodeID) {
+			return errors.New("node has already proposed a shuffle")
+		}
+	}
+	previous := c.Flatten(ballots)
+	if len(mixes) > 0 {
+		previous = mixes[len(mixes)-1].Ballots
+	}
+	return c.VerifyMix(mix, previous)
+}
+
+// verifyPartial checks that the partial is a valid decryption of the last
+// mix, or of the sums of the ballots in a homomorphic election.
+func (c *ContractElection) verifyPartial(ballots []*lib.Ballot, mixes []*lib.Mix, partials []*lib.Partial, partial *lib.Partial) error {
+	var ciphertexts []*lib.Ballot
+	if c.Mode == lib.Homomorphic {
+		ciphertexts = c.Sum(ballots)
+	} else {
+		if len(mixes) <= 2*len(c.Roster.List)/3 {
+			return errors.New("election not shuffled yet")
+		}
+		ciphertexts = mixes[len(mixes)-1].Ballots
+	}
+	if len(partials) >= len(c.Roster.List) {
+		return errors.New("election already decrypted")
+	}
+	for _, p := range partials {
+		if p.NodeID.Equal(partial.NodeID) {
+			return errors.New("node has already proposed a partial")
+		}
+	}
+	return c.VerifyPartial(partial, ciphertexts)
+}
+
+// stage returns the stage of the election given the mixes and partials of
+// its box: a shuffle or a decryption is done once a threshold of
+// floor(2*n/3) + 1 nodes contributed to it.
+func (c *ContractElection) stage(box *BoxStruct) lib.ElectionState {
+	threshold := 2*len(c.Roster.List)/3 + 1
+	switch {
+	case len(box.Partials) >= threshold:
+		return lib.Decrypted
+	case len(box.Mixes) >= threshold:
+		return lib.Shuffled
+	}
+	return lib.Running
+}
+
+// blockTimestamp returns the timestamp of the block being created, in
+// nanoseconds, or 0 if it is not known.
+func blockTimestamp(rst byzcoin.ReadOnlyStateTrie) int64 {
+	if tr, ok := rst.(byzcoin.TimeReader); ok {
+		return tr.GetCurrentBlockTimestamp()
+	}
+	return 0
+}
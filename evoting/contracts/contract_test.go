@@ -0,0 +1,365 @@
+package contracts
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+// testElection is an election with n nodes holding the shares of its key.
+type testElection struct {
+	*lib.Election
+	secrets []*lib.SharedSecret
+	pairs   []*key.Pair
+}
+
+func newTestElection(t *testing.T, n int, voters []darc.Signer) *testElection {
+	dkgs, err := lib.DKGSimulate(n, 2*n/3+1)
+	require.NoError(t, err)
+	te := &testElection{secrets: make([]*lib.SharedSecret, n), pairs: make([]*key.Pair, n)}
+	list := make([]*network.ServerIdentity, n)
+	for i := range dkgs {
+		te.secrets[i], err = lib.NewSharedSecret(dkgs[i])
+		require.NoError(t, err)
+		te.pairs[i] = key.NewKeyPair(cothority.Suite)
+		addr := network.NewAddress(network.Local, fmt.Sprintf("127.0.0.1:%d", 2000+i))
+		list[i] = network.NewServerIdentity(te.pairs[i].Public, addr)
+	}
+	te.Election = &lib.Election{
+		Name:    map[string]string{"en": "election", "fr": "élection"},
+		Roster:  onet.NewRoster(list),
+		Key:     te.secrets[0].X,
+		Commits: te.secrets[0].Commits,
+		Start:   time.Now().Unix(),
+		End:     time.Now().Add(time.Hour).Unix(),
+	}
+	for _, v := range voters {
+		te.Voters = append(te.Voters, v.Identity().String())
+	}
+	return te
+}
+
+// mix shuffles the ballots as the i-th node.
+func (te *testElection) mix(t *testing.T, i int, ballots []*lib.Ballot) *lib.Mix {
+	mix, err := te.Shuffle(ballots)
+	require.NoError(t, err)
+	mix.NodeID = te.Roster.List[i].ID
+	mix.Signature = te.sign(i)
+	return mix
+}
+
+// partial decrypts the ballots as the i-th node.
+func (te *testElection) partial(t *testing.T, i int, ballots []*lib.Ballot) *lib.Partial {
+	partial := &lib.Partial{NodeID: te.Roster.List[i].ID, Signature: te.sign(i)}
+	for _, b := range ballots {
+		point, proof, err := lib.DecryptWithProof(te.secrets[i].V, b.Alpha, b.Beta)
+		require.NoError(t, err)
+		partial.Points = append(partial.Points, point)
+		partial.Proofs = append(partial.Proofs, proof)
+	}
+	return partial
+}
+
+func (te *testElection) sign(i int) []byte {
+	data, _ := te.pairs[i].Public.MarshalBinary()
+	sig, _ := schnorr.Sign(cothority.Suite, te.pairs[i].Private, data)
+	return sig
+}
+
+// testBox runs the contracts of an election on a simulated state trie.
+type testBox struct {
+	t        *testing.T
+	rost     *byzcoin.ROSTSimul
+	election byzcoin.InstanceID
+	box      byzcoin.InstanceID
+}
+
+func newTestBox(t *testing.T, te *testElection, migration *Migration) (*testBox, error) {
+	rost := byzcoin.NewROSTSimul()
+	d, err := rost.CreateBasicDarc(nil, "election")
+	require.NoError(t, err)
+
+	buf, err := protobuf.Encode(te.Election)
+	require.NoError(t, err)
+	args := byzcoin.Arguments{{Name: "election", Value: buf}}
+	if migration != nil {
+		buf, err := protobuf.Encode(migration)
+		require.NoError(t, err)
+		args = append(args, byzcoin.Argument{Name: "migration", Value: buf})
+	}
+	c, err := ContractElectionFromBytes(nil)
+	require.NoError(t, err)
+	scs, _, err := c.Spawn(rost, byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(d.GetBaseID()),
+		Spawn:      &byzcoin.Spawn{ContractID: ContractElectionID, Args: args},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rost.StoreAllToReplica(scs)
+	require.NoError(t, err)
+	id := byzcoin.NewInstanceID(scs[0].InstanceID)
+	return &testBox{t: t, rost: rost, election: id, box: BoxID(id)}, nil
+}
+
+func (tb *testBox) contract() byzcoin.Contract {
+	buf, _, cid, _, err := tb.rost.GetValues(tb.box.Slice())
+	require.NoError(tb.t, err)
+	require.Equal(tb.t, ContractBoxID, cid)
+	c, err := ContractBoxFromBytes(buf)
+	require.NoError(tb.t, err)
+	return c
+}
+
+func (tb *testBox) state() *BoxStruct {
+	return &tb.contract().(*ContractBox).BoxStruct
+}
+
+func (tb *testBox) ballots() []*lib.Ballot {
+	ballots, err := tb.contract().(*ContractBox).ballots(tb.rost)
+	require.NoError(tb.t, err)
+	return ballots
+}
+
+func (tb *testBox) store(scs []byzcoin.StateChange, err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = tb.rost.StoreAllToReplica(scs)
+	require.NoError(tb.t, err)
+	return nil
+}
+
+func (tb *testBox) cast(ballot *lib.Ballot, signers ...darc.Signer) error {
+	buf, err := protobuf.Encode(ballot)
+	require.NoError(tb.t, err)
+	inst := byzcoin.Instruction{
+		InstanceID: tb.box,
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractBoxID,
+			Command:    "cast",
+			Args:       byzcoin.Arguments{{Name: "ballot", Value: buf}},
+		},
+	}
+	for _, s := range signers {
+		inst.SignerIdentities = append(inst.SignerIdentities, s.Identity())
+	}
+	scs, _, err := tb.contract().Invoke(tb.rost, inst, nil)
+	return tb.store(scs, err)
+}
+
+func (tb *testBox) spawn(contractID, name string, value interface{}) error {
+	buf, err := protobuf.Encode(value)
+	require.NoError(tb.t, err)
+	scs, _, err := tb.contract().Spawn(tb.rost, byzcoin.Instruction{
+		InstanceID: tb.box,
+		Spawn: &byzcoin.Spawn{
+			ContractID: contractID,
+			Args:       byzcoin.Arguments{{Name: name, Value: buf}},
+		},
+	}, nil)
+	return tb.store(scs, err)
+}
+
+func TestContractBox(t *testing.T) {
+	alice, bob, eve := darc.NewSignerEd25519(nil, nil), darc.NewSignerEd25519(nil, nil),
+		darc.NewSignerEd25519(nil, nil)
+	te := newTestElection(t, 3, []darc.Signer{alice, bob})
+	tb, err := newTestBox(t, te, nil)
+	require.NoError(t, err)
+	require.Equal(t, lib.Running, tb.state().Stage)
+
+	ballot := func() *lib.Ballot {
+		ballot, _ := lib.NewAuditableBallot(te.Key, []byte{1})
+		return ballot
+	}
+	require.NoError(t, tb.cast(ballot(), alice))
+	require.NoError(t, tb.cast(ballot(), bob))
+	box := tb.state()
+	require.Equal(t, 2, box.Voters)
+	require.Equal(t, BallotID(tb.election, bob.Identity().String()), box.Last)
+
+	// Replacing a ballot only updates the ballot instance of the voter.
+	last := ballot()
+	require.NoError(t, tb.cast(last, alice))
+	require.Equal(t, box, tb.state())
+	require.Error(t, tb.cast(ballot(), eve))
+	require.Error(t, tb.cast(ballot(), alice, bob))
+	require.Error(t, tb.cast(&lib.Ballot{}, alice))
+
+	// Only the last ballot of each voter is kept, bound to the signer.
+	ballots := tb.ballots()
+	require.Len(t, ballots, 2)
+	require.Equal(t, alice.Identity().String(), ballots[0].UserID)
+	require.Equal(t, bob.Identity().String(), ballots[1].UserID)
+	require.True(t, last.Alpha.Equal(ballots[0].Alpha))
+	var bs BallotStruct
+	require.NoError(t, getInstance(tb.rost, BallotID(tb.election, alice.Identity().String()),
+		ContractBallotID, &bs))
+	require.True(t, last.Alpha.Equal(bs.Ballot.Alpha))
+
+	flat := te.Flatten(ballots)
+	mix := te.mix(t, 0, flat)
+	require.Error(t, tb.spawn(ContractPartialID, "partial", te.partial(t, 0, mix.Ballots)))
+	require.NoError(t, tb.spawn(ContractMixID, "mix", mix))
+	require.Error(t, tb.cast(ballot(), alice))
+	require.Error(t, tb.spawn(ContractMixID, "mix", te.mix(t, 0, mix.Ballots)))
+	require.Error(t, tb.spawn(ContractMixID, "mix", te.mix(t, 1, flat)))
+	for i := 1; i < 3; i++ {
+		mix = te.mix(t, i, mix.Ballots)
+		require.NoError(t, tb.spawn(ContractMixID, "mix", mix))
+	}
+	require.Equal(t, lib.Shuffled, tb.state().Stage)
+	require.Len(t, tb.state().Mixes, 3)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tb.spawn(ContractPartialID, "partial", te.partial(t, i, mix.Ballots)))
+	}
+	require.Error(t, tb.spawn(ContractPartialID, "partial", te.partial(t, 0, mix.Ballots)))
+	state := tb.state()
+	require.Equal(t, lib.Decrypted, state.Stage)
+	require.Equal(t, []byzcoin.InstanceID{PartialID(tb.election, 0), PartialID(tb.election, 1),
+		PartialID(tb.election, 2)}, state.Partials)
+
+	partial := &lib.Partial{}
+	require.NoError(t, getInstance(tb.rost, state.Partials[2], ContractPartialID, partial))
+	require.Equal(t, te.Roster.List[2].ID, partial.NodeID)
+}
+
+func TestContractElection_Migrate(t *testing.T) {
+	te := newTestElection(t, 3, nil)
+	te.ID = []byte("skipchain")
+	te.Voters = []string{"alice", "bob"}
+	box := &lib.Box{}
+	for _, user := range te.Voters {
+		ballot, _ := lib.NewAuditableBallot(te.Key, []byte(user))
+		ballot.UserID = user
+		box.Ballots = append(box.Ballots, ballot)
+	}
+	migration := &Migration{Box: box}
+	ballots := box.Ballots
+	for i := 0; i < 3; i++ {
+		migration.Mixes = append(migration.Mixes, te.mix(t, i, ballots))
+		ballots = migration.Mixes[i].Ballots
+	}
+	migration.Partials = []*lib.Partial{te.partial(t, 1, ballots), te.partial(t, 2, ballots)}
+
+	tb, err := newTestBox(t, te, migration)
+	require.NoError(t, err)
+	state := tb.state()
+	require.Equal(t, lib.Shuffled, state.Stage)
+	require.Equal(t, 2, state.Voters)
+	require.Len(t, tb.ballots(), 2)
+	require.Equal(t, "bob", tb.ballots()[1].UserID)
+	require.Equal(t, []byzcoin.InstanceID{MixID(tb.election, 0), MixID(tb.election, 1),
+		MixID(tb.election, 2)}, state.Mixes)
+
+	// The migration continues on ByzCoin.
+	require.NoError(t, tb.spawn(ContractPartialID, "partial", te.partial(t, 0, ballots)))
+	require.Equal(t, lib.Decrypted, tb.state().Stage)
+
+	// The election ID of the skipchain is kept.
+	buf, _, _, _, err := tb.rost.GetValues(tb.election.Slice())
+	require.NoError(t, err)
+	c, err := ContractElectionFromBytes(buf)
+	require.NoError(t, err)
+	require.Equal(t, "skipchain", string(c.(*ContractElection).ID))
+
+	migration.Mixes[1], migration.Mixes[2] = migration.Mixes[2], migration.Mixes[1]
+	_, err = newTestBox(t, te, migration)
+	require.Error(t, err)
+
+	migration.Mixes[1], migration.Mixes[2] = migration.Mixes[2], migration.Mixes[1]
+	box.Ballots[1].UserID = "alice"
+	_, err = newTestBox(t, te, migration)
+	require.Error(t, err)
+	box.Ballots[1].UserID = "bob"
+
+	// An election opened before the commits were stored can be migrated,
+	// but its decryption cannot continue on ByzCoin.
+	te.Commits = nil
+	tb, err = newTestBox(t, te, migration)
+	require.NoError(t, err)
+	require.Error(t, tb.spawn(ContractPartialID, "partial", te.partial(t, 0, ballots)))
+}
+
+func TestContractElection_Commits(t *testing.T) {
+	te := newTestElection(t, 3, nil)
+	commits := te.Commits
+	te.Commits = nil
+	_, err := newTestBox(t, te, nil)
+	require.Error(t, err)
+	te.Commits = newTestElection(t, 3, nil).Commits
+	_, err = newTestBox(t, te, nil)
+	require.Error(t, err)
+	te.Commits = commits
+	_, err = newTestBox(t, te, nil)
+	require.NoError(t, err)
+}
+
+func TestContractElection_ByzCoin(t *testing.T) {
+	b := byzcoin.NewBCTestDefault(t)
+	defer b.CloseAll()
+	b.AddGenesisRules("spawn:"+ContractElectionID, "invoke:"+ContractBoxID+".cast")
+	b.CreateByzCoin()
+
+	te := newTestElection(t, 3, []darc.Signer{b.Signer})
+	buf, err := protobuf.Encode(te.Election)
+	require.NoError(t, err)
+	ctx, _ := b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(b.GenesisDarc.GetBaseID()),
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractElectionID,
+			Args:       byzcoin.Arguments{{Name: "election", Value: buf}},
+		},
+	})
+	election := ctx.Instructions[0].DeriveID("")
+
+	ballot, _ := lib.NewAuditableBallot(te.Key, []byte{1})
+	buf, err = protobuf.Encode(ballot)
+	require.NoError(t, err)
+	b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: BoxID(election),
+		Invoke: &byzcoin.Invoke{
+			ContractID: ContractBoxID,
+			Command:    "cast",
+			Args:       byzcoin.Arguments{{Name: "ballot", Value: buf}},
+		},
+	})
+
+	proof, err := b.Client.GetProof(BoxID(election).Slice())
+	require.NoError(t, err)
+	buf, cid, _, err := proof.Proof.Get(BoxID(election).Slice())
+	require.NoError(t, err)
+	require.Equal(t, ContractBoxID, cid)
+	c, err := ContractBoxFromBytes(buf)
+	require.NoError(t, err)
+	require.Equal(t, 1, c.(*ContractBox).Voters)
+
+	id := BallotID(election, b.Signer.Identity().String())
+	require.Equal(t, id, c.(*ContractBox).Last)
+	proof, err = b.Client.GetProof(id.Slice())
+	require.NoError(t, err)
+	buf, cid, _, err = proof.Proof.Get(id.Slice())
+	require.NoError(t, err)
+	require.Equal(t, ContractBallotID, cid)
+	c, err = ContractBallotFromBytes(buf)
+	require.NoError(t, err)
+	require.Equal(t, b.Signer.Identity().String(), c.(*ContractBallot).Ballot.UserID)
+}
@@ -0,0 +1,19 @@
+package contracts
+
+import (
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/onet/v3/log"
+)
+
+func init() {
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractElectionID,
+		ContractElectionFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractBoxID,
+		ContractBoxFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractBallotID,
+		ContractBallotFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractMixID,
+		ContractMixFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractPartialID,
+		ContractPartialFromBytes))
+}
@@ -0,0 +1,33 @@
+package contracts
+
+import (
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+)
+
+// BoxStruct is the value of a ballot box instance. It holds the state of the
+// election, as the election instance itself is never updated. The ballots are
+// stored in their own instances, linked from the last one.
+type BoxStruct struct {
+	Election byzcoin.InstanceID   // Election is the instance of the election.
+	Stage    lib.ElectionState    // Stage is the current stage of the election.
+	Voters   int                  // Voters is the number of voters having cast a ballot.
+	Last     byzcoin.InstanceID   // Last is the ballot instance of the last new voter.
+	Mixes    []byzcoin.InstanceID // Mixes are the instances of the shuffles, in order.
+	Partials []byzcoin.InstanceID // Partials are the instances of the partial decryptions.
+}
+
+// BallotStruct is the value of a ballot instance, holding the last ballot of
+// a voter.
+type BallotStruct struct {
+	Ballot   *lib.Ballot        // Ballot is the last ballot cast by the voter.
+	Previous byzcoin.InstanceID // Previous is the ballot instance of the voter who voted first before.
+}
+
+// Migration holds the state of an election stored on a skipchain, to be
+// copied to ByzCoin when spawning the election.
+type Migration struct {
+	Box      *lib.Box       // Box of the election.
+	Mixes    []*lib.Mix     // Mixes of the election, in order.
+	Partials []*lib.Partial // Partials of the election.
+}
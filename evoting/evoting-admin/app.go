@@ -3,17 +3,20 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/evoting"
+	"go.dedis.ch/cothority/v3/evoting/lib"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/util/key"
@@ -24,12 +27,15 @@ import (
 
 var (
 	argRoster       = flag.String("roster", "", "path to roster toml file")
-	argAdmins       = flag.String("admins", "", "list of admin users")
+	argAdmins       = flag.String("admins", "", "comma-separated list of the IDs of the admin users")
 	argPin          = flag.String("pin", "", "service pin")
 	argKey          = flag.String("key", "", "public key of authentication server")
 	argID           = flag.String("id", "", "ID of the master chain to modify (optional)")
 	argUser         = flag.Int("user", 0, "The SCIPER of an existing admin of this chain")
+	argUserID       = flag.String("userid", "", "The ID of an existing admin of this chain, instead of -user")
 	argSig          = flag.String("sig", "", "A signature proving that you can login to Tequila with the given SCIPER.")
+	argIdentity     = flag.String("identity", "", "Load the identity provider of the voters from the specified json file when creating or updating the master chain.")
+	argRoll         = flag.String("roll", "", "Use the voter roll in the specified csv file (id,name,email) as identity provider of the voters.")
 	argShow         = flag.Bool("show", false, "Show the current Master config")
 	argDumpVoters   = flag.Bool("dumpvoters", false, "Dump a list of voters for election skipchain specified with -id (ballot de-duplication has already been taken into account, order is preserved)")
 	argDumpElection = flag.Bool("dumpelection", false, "Dump the current election config for the election specified with -id.")
@@ -60,9 +66,10 @@ func main() {
 			log.Fatal("get elections request: ", err)
 		}
 		m := reply.Master
-		fmt.Printf(" Admins: %v\n", m.Admins)
-		fmt.Printf(" Roster: %v\n", m.Roster.List)
-		fmt.Printf("    Key: %v\n", m.Key)
+		fmt.Printf("   Admins: %v\n", m.AdminIDs)
+		fmt.Printf("   Roster: %v\n", m.Roster.List)
+		fmt.Printf("      Key: %v\n", m.Key)
+		fmt.Printf(" Identity: %v\n", m.IdentityProvider().Type)
 		return
 	}
 
@@ -78,7 +85,7 @@ func main() {
 		}
 
 		for _, b := range reply.Box.Ballots {
-			fmt.Println(b.User)
+			fmt.Println(b.UserID)
 		}
 		return
 	}
@@ -98,8 +105,8 @@ func main() {
 			e := reply.Election
 			j := &jsonElection{
 				Name:         e.Name,
-				Creator:      e.Creator,
-				Users:        e.Users,
+				Creator:      e.CreatorID,
+				Voters:       e.Voters,
 				Candidates:   e.Candidates,
 				MaxChoices:   e.MaxChoices,
 				Subtitle:     e.Subtitle,
@@ -183,8 +190,8 @@ func main() {
 		if err != nil {
 			log.Fatal("sig decode", err)
 		}
-		var u = uint32(*argUser)
-		request.User = u
+		request.User = uint32(*argUser)
+		request.UserID = *argUserID
 		request.Signature = sig
 
 		reply2 := &evoting.OpenReply{}
@@ -219,7 +226,13 @@ func main() {
 		pub = kp.Public
 	}
 
-	request := &evoting.Link{Pin: *argPin, Roster: roster, Key: pub, Admins: admins}
+	identity, err := parseIdentity(*argIdentity, *argRoll)
+	if err != nil {
+		log.Fatal("cannot parse identity provider: ", err)
+	}
+
+	request := &evoting.Link{Pin: *argPin, Roster: roster, Key: pub, AdminIDs: admins,
+		Identity: identity}
 	if *argID != "" {
 		id, err := hex.DecodeString(*argID)
 		if err != nil {
@@ -235,8 +248,12 @@ func main() {
 		}
 		var sbid skipchain.SkipBlockID = id
 		request.ID = &sbid
-		var u = uint32(*argUser)
-		request.User = &u
+		if *argUserID != "" {
+			request.UserID = argUserID
+		} else {
+			var u = uint32(*argUser)
+			request.User = &u
+		}
 		request.Signature = &sig
 	}
 	reply := &evoting.LinkReply{}
@@ -264,22 +281,67 @@ func parseRoster(path string) (*onet.Roster, error) {
 	return group.Roster, nil
 }
 
-// parseAdmins converts a string of comma-separated sciper numbers in
-// the format sciper1,sciper2,sciper3 to a list of integers.
-func parseAdmins(scipers string) ([]uint32, error) {
-	if scipers == "" {
+// parseAdmins converts a string of comma-separated user IDs in the format
+// id1,id2,id3 to a list of IDs. SCIPER numbers are used as they are.
+func parseAdmins(ids string) ([]string, error) {
+	if ids == "" {
 		return nil, nil
 	}
 
-	admins := make([]uint32, 0)
-	for _, admin := range strings.Split(scipers, ",") {
-		sciper, err := strconv.Atoi(admin)
+	admins := make([]string, 0)
+	for _, admin := range strings.Split(ids, ",") {
+		admin = strings.TrimSpace(admin)
+		if admin == "" {
+			return nil, errors.New("empty admin ID")
+		}
+		admins = append(admins, admin)
+	}
+	return admins, nil
+}
+
+// parseIdentity reads the identity provider from a json file, or makes a
+// voter roll provider from a csv file with the columns id, name and email. It
+// returns nil if no file is given.
+func parseIdentity(identityFile, rollFile string) (*lib.Identity, error) {
+	switch {
+	case identityFile != "" && rollFile != "":
+		return nil, errors.New("only one of -identity and -roll can be given")
+	case identityFile != "":
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		identity := &lib.Identity{}
+		if err := json.NewDecoder(f).Decode(identity); err != nil {
+			return nil, err
+		}
+		return identity, identity.Check()
+	case rollFile != "":
+		f, err := os.Open(rollFile)
 		if err != nil {
 			return nil, err
 		}
-		admins = append(admins, uint32(sciper))
+		defer f.Close()
+		return parseRoll(f)
 	}
-	return admins, nil
+	return nil, nil
+}
+
+// parseRoll reads a voter roll with the columns id, name and email.
+func parseRoll(r io.Reader) (*lib.Identity, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	identity := &lib.Identity{Type: lib.IdentityRoll}
+	for _, r := range records {
+		identity.Roll = append(identity.Roll, &lib.Voter{ID: r[0], FullName: r[1], Email: r[2]})
+	}
+	return identity, identity.Check()
 }
 
 // parseKey unmarshals a Ed25519 point given in hexadecimal form.
@@ -299,8 +361,8 @@ func parseKey(key string) (kyber.Point, error) {
 // A restricted version of Election, for printing/parsing
 type jsonElection struct {
 	Name    map[string]string // Name of the election. lang-code, value pair
-	Creator uint32            // Creator is the election responsible.
-	Users   []uint32          // Users is the list of registered voters.
+	Creator string            // Creator is the election responsible.
+	Voters  []string          // Voters is the list of registered voters.
 
 	Candidates   []uint32          // Candidates is the list of candidate scipers.
 	MaxChoices   int               // MaxChoices is the max votes in allowed in a ballot.
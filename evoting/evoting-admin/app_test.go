@@ -1,10 +1,12 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
 	"go.dedis.ch/kyber/v3/util/random"
 	"go.dedis.ch/onet/v3/log"
 )
@@ -29,9 +31,27 @@ func TestParseAdmins(t *testing.T) {
 	admins, err := parseAdmins("")
 	assert.Nil(t, admins, err)
 
-	_, err = parseAdmins("1,2,a,3")
+	_, err = parseAdmins("1,2,,3")
 	assert.NotNil(t, err)
 
 	admins, _ = parseAdmins("1,2,3")
-	assert.Equal(t, []uint32{1, 2, 3}, admins)
+	assert.Equal(t, []string{"1", "2", "3"}, admins)
+
+	admins, _ = parseAdmins("123456, alice@example.com")
+	assert.Equal(t, []string{"123456", "alice@example.com"}, admins)
+}
+
+func TestParseRoll(t *testing.T) {
+	identity, err := parseRoll(strings.NewReader("alice,Alice,alice@example.com\nbob, Bob, bob@example.com\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, lib.IdentityRoll, identity.Type)
+	assert.Equal(t, []*lib.Voter{
+		{ID: "alice", FullName: "Alice", Email: "alice@example.com"},
+		{ID: "bob", FullName: "Bob", Email: "bob@example.com"},
+	}, identity.Roll)
+
+	_, err = parseRoll(strings.NewReader("alice,Alice\n"))
+	assert.Error(t, err)
+	_, err = parseRoll(strings.NewReader("alice,Alice,a@example.com\nalice,Bob,b@example.com\n"))
+	assert.Error(t, err)
 }
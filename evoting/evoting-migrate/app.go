@@ -0,0 +1,181 @@
+// This is a command line tool to migrate elections stored on skipchains to
+// the evoting contracts of a ByzCoin ledger.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/bcadmin/lib"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/evoting"
+	"go.dedis.ch/cothority/v3/evoting/contracts"
+	evlib "go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+)
+
+var (
+	argRoster = flag.String("roster", "", "path to roster toml file of the evoting conodes")
+	argID     = flag.String("id", "", "ID of the election skipchain to migrate")
+	argMaster = flag.String("master", "", "ID of the master skipchain whose elections are migrated")
+	argBC     = flag.String("bc", "", "path to the bcadmin config of the ByzCoin ledger")
+	argDarc   = flag.String("darc", "", "darc guarding the elections (default: admin darc of the config)")
+	argSign   = flag.String("sign", "", "public key of the signer (default: admin identity of the config)")
+)
+
+func main() {
+	flag.Parse()
+
+	if *argRoster == "" {
+		log.Fatal("Roster argument (-roster) is required.")
+	}
+	roster, err := lib.ReadRoster(*argRoster)
+	if err != nil {
+		log.Fatal("cannot parse roster: ", err)
+	}
+	if *argBC == "" {
+		log.Fatal("ByzCoin config argument (-bc) is required.")
+	}
+	cfg, cl, err := lib.LoadConfig(*argBC)
+	if err != nil {
+		log.Fatal("cannot load ByzCoin config: ", err)
+	}
+
+	darcID := cfg.AdminDarc.GetBaseID()
+	if *argDarc != "" {
+		darcID, err = lib.StringToDarcID(*argDarc)
+		if err != nil {
+			log.Fatal("cannot parse darc ID: ", err)
+		}
+	}
+	var signer *darc.Signer
+	if *argSign != "" {
+		signer, err = lib.LoadKeyFromString(*argSign)
+	} else {
+		signer, err = lib.LoadKey(cfg.AdminIdentity)
+	}
+	if err != nil {
+		log.Fatal("cannot load signer: ", err)
+	}
+
+	var ids []skipchain.SkipBlockID
+	switch {
+	case *argID != "" && *argMaster != "":
+		log.Fatal("only one of -id and -master can be given")
+	case *argID != "":
+		id, err := hex.DecodeString(*argID)
+		if err != nil {
+			log.Fatal("election ID (-id) must be given in hex")
+		}
+		ids = append(ids, id)
+	case *argMaster != "":
+		master, err := hex.DecodeString(*argMaster)
+		if err != nil {
+			log.Fatal("master ID (-master) must be given in hex")
+		}
+		ids, err = elections(roster, master)
+		if err != nil {
+			log.Fatal("cannot get the elections of the master: ", err)
+		}
+	default:
+		log.Fatal("Election (-id) or master (-master) argument is required.")
+	}
+
+	for _, id := range ids {
+		instance, err := migrate(roster, cl, darcID, *signer, id)
+		if err != nil {
+			log.Fatalf("cannot migrate election %x: %v", id, err)
+		}
+		log.Infof("Election %x migrated to instance %x, ballot box %x",
+			id, instance.Slice(), contracts.BoxID(instance).Slice())
+	}
+}
+
+// elections returns the IDs of the elections linked from a master skipchain.
+func elections(roster *onet.Roster, master skipchain.SkipBlockID) ([]skipchain.SkipBlockID, error) {
+	client := skipchain.NewClient()
+	block, err := client.GetSingleBlock(roster, master)
+	if err != nil {
+		return nil, err
+	}
+	var ids []skipchain.SkipBlockID
+	for {
+		transaction := evlib.UnmarshalTransaction(block.Data)
+		if transaction != nil && transaction.Link != nil {
+			ids = append(ids, transaction.Link.ID)
+		}
+		if len(block.ForwardLink) == 0 {
+			break
+		}
+		block, err = client.GetSingleBlock(roster, block.ForwardLink[0].To)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// migrate downloads an election from its skipchain and spawns it, with its
+// ballots, mixes and partials, on the darc. It returns the instance of the
+// election.
+func migrate(roster *onet.Roster, cl *byzcoin.Client, darcID darc.ID, signer darc.Signer,
+	id skipchain.SkipBlockID) (byzcoin.InstanceID, error) {
+	client := onet.NewClient(cothority.Suite, evoting.ServiceName)
+	box := &evoting.GetBoxReply{}
+	if err := client.SendProtobuf(roster.List[0], &evoting.GetBox{ID: id}, box); err != nil {
+		return byzcoin.InstanceID{}, fmt.Errorf("get box request: %v", err)
+	}
+	mixes := &evoting.GetMixesReply{}
+	if err := client.SendProtobuf(roster.List[0], &evoting.GetMixes{ID: id}, mixes); err != nil {
+		return byzcoin.InstanceID{}, fmt.Errorf("get mixes request: %v", err)
+	}
+	partials := &evoting.GetPartialsReply{}
+	if err := client.SendProtobuf(roster.List[0], &evoting.GetPartials{ID: id}, partials); err != nil {
+		return byzcoin.InstanceID{}, fmt.Errorf("get partials request: %v", err)
+	}
+
+	election, err := protobuf.Encode(box.Election)
+	if err != nil {
+		return byzcoin.InstanceID{}, err
+	}
+	migration, err := protobuf.Encode(&contracts.Migration{
+		Box:      box.Box,
+		Mixes:    mixes.Mixes,
+		Partials: partials.Partials,
+	})
+	if err != nil {
+		return byzcoin.InstanceID{}, err
+	}
+
+	counters, err := cl.GetSignerCounters(signer.Identity().String())
+	if err != nil {
+		return byzcoin.InstanceID{}, err
+	}
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(darcID),
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractElectionID,
+			Args: byzcoin.Arguments{
+				{Name: "election", Value: election},
+				{Name: "migration", Value: migration},
+			},
+		},
+		SignerCounter: []uint64{counters.Counters[0] + 1},
+	})
+	if err != nil {
+		return byzcoin.InstanceID{}, err
+	}
+	if err = ctx.FillSignersAndSignWith(signer); err != nil {
+		return byzcoin.InstanceID{}, err
+	}
+	if _, err = cl.AddTransactionAndWait(ctx, 10); err != nil {
+		return byzcoin.InstanceID{}, err
+	}
+	return ctx.Instructions[0].DeriveID(""), nil
+}
@@ -0,0 +1,163 @@
+// This is a command line tool to verify an election independently of the
+// conodes having run it.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/encoding"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/app"
+	"go.dedis.ch/onet/v3/log"
+)
+
+var (
+	argRoster  = flag.String("roster", "", "path to roster toml file")
+	argID      = flag.String("id", "", "ID of the election skipchain to verify")
+	argPrivate = flag.String("private", "", "hex private key signing the report (a new key is generated if empty)")
+	argNewKey  = flag.String("newkey", "verifier.key", "file to write the generated private key to")
+	argOutput  = flag.String("o", "", "write the report to this file instead of stdout")
+	argCheck   = flag.String("check", "", "check the signature of the report in the specified json file")
+)
+
+func main() {
+	flag.Parse()
+
+	if *argCheck != "" {
+		r, err := readReport(*argCheck)
+		if err != nil {
+			log.Fatal("cannot read report: ", err)
+		}
+		if err := r.checkSignature(); err != nil {
+			log.Fatal("invalid signature: ", err)
+		}
+		log.Infof("Report signed by %s, election valid: %t", r.Verifier, r.Valid)
+		return
+	}
+
+	if *argRoster == "" {
+		log.Fatal("Roster argument (-roster) is required.")
+	}
+	roster, err := parseRoster(*argRoster)
+	if err != nil {
+		log.Fatal("cannot parse roster: ", err)
+	}
+	id, err := hex.DecodeString(*argID)
+	if err != nil || len(id) == 0 {
+		log.Fatal("election ID (-id) must be given in hex")
+	}
+
+	var private kyber.Scalar
+	if *argPrivate != "" {
+		private, err = encoding.StringHexToScalar(cothority.Suite, *argPrivate)
+		if err != nil {
+			log.Fatal("cannot parse private key: ", err)
+		}
+	} else {
+		kp := key.NewKeyPair(cothority.Suite)
+		if err = writeKey(kp.Private, *argNewKey); err != nil {
+			log.Fatal("cannot write private key: ", err)
+		}
+		log.Infof("Verifier private key written to %s", *argNewKey)
+		private = kp.Private
+	}
+
+	// Download the blocks of the election, and read the election from the
+	// transactions they hold instead of trusting the conodes to do it.
+	blocks, err := fetchChain(roster, id)
+	if err != nil {
+		log.Fatal("cannot get the election skipchain: ", err)
+	}
+	c, err := readChain(blocks)
+	if err != nil {
+		log.Fatal("cannot read the election skipchain: ", err)
+	}
+	if !bytes.Equal(c.election.ID, id) {
+		log.Fatal("the skipchain holds another election")
+	}
+
+	r := verify(c.election, c.box, c.mixes, c.partials)
+	r.Time = time.Now().UTC().Format(time.RFC3339)
+	if err = r.sign(private); err != nil {
+		log.Fatal("cannot sign report: ", err)
+	}
+	if err = writeReport(r, *argOutput); err != nil {
+		log.Fatal("cannot write report: ", err)
+	}
+	if !r.Valid {
+		log.Errorf("Election %x is not valid: %d errors", id, len(r.Errors))
+		os.Exit(1)
+	}
+}
+
+// parseRoster reads a Dedis group toml file a converts it to a cothority roster.
+func parseRoster(path string) (*onet.Roster, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := app.ReadGroupDescToml(file)
+	if err != nil {
+		return nil, err
+	}
+	return group.Roster, nil
+}
+
+// writeKey writes the private key in hex to a new file, readable only by its
+// owner.
+func writeKey(private kyber.Scalar, path string) error {
+	hex, err := encoding.ScalarToStringHex(cothority.Suite, private)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, hex); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readReport reads a report from a json file.
+func readReport(path string) (*report, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &report{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// writeReport writes the report as indented json to the file, or to stdout if
+// path is empty.
+func writeReport(r *report, path string) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	json.Indent(&out, b, "", "\t")
+	fmt.Fprintln(&out)
+	if path == "" {
+		_, err = out.WriteTo(os.Stdout)
+		return err
+	}
+	return ioutil.WriteFile(path, out.Bytes(), 0644)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+)
+
+// chain is the content of an election skipchain, as the service reads it.
+type chain struct {
+	election *lib.Election
+	box      *lib.Box
+	mixes    []*lib.Mix
+	partials []*lib.Partial
+}
+
+// fetchChain downloads every block of the election skipchain and checks that
+// they are linked, starting from the genesis block, by forward links signed
+// by the roster.
+func fetchChain(roster *onet.Roster, id skipchain.SkipBlockID) ([]*skipchain.SkipBlock, error) {
+	// Only follow the links of height 1, to get all the blocks.
+	blocks, err := skipchain.NewClient().GetUpdateChainLevel(roster, id, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChain(roster, id, blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// verifyChain checks that the blocks are all the blocks of the skipchain, from
+// its genesis block, and that their forward links are signed by the roster.
+func verifyChain(roster *onet.Roster, id skipchain.SkipBlockID, blocks []*skipchain.SkipBlock) error {
+	if len(blocks) == 0 {
+		return errors.New("no block in the skipchain")
+	}
+	if !blocks[0].Hash.Equal(id) {
+		return errors.New("the first block is not the genesis block of the election")
+	}
+	for i, sb := range blocks {
+		if sb.Index != i {
+			return fmt.Errorf("block %d is missing", i)
+		}
+		if sb.Roster == nil || !sb.Roster.ID.Equal(roster.ID) {
+			return fmt.Errorf("block %d is not signed by the roster", i)
+		}
+	}
+	return skipchain.Proof(blocks).Verify()
+}
+
+// readChain rebuilds the election, the ballot box, the mixes and the partials
+// from the transactions of the blocks. Like for the service, the election is
+// the last one stored and only the last ballot of every voter is in the box,
+// where the ballots are in the order they have been cast.
+func readChain(blocks []*skipchain.SkipBlock) (*chain, error) {
+	c := &chain{box: &lib.Box{}}
+	var ballots []*lib.Ballot
+	for _, sb := range blocks {
+		if len(sb.Data) == 0 {
+			continue
+		}
+		tx := lib.UnmarshalTransaction(sb.Data)
+		if tx == nil {
+			return nil, fmt.Errorf("block %d: cannot decode the transaction", sb.Index)
+		}
+		switch {
+		case tx.Election != nil:
+			c.election = tx.Election
+		case tx.Ballot != nil:
+			tx.Ballot.Migrate()
+			ballots = append(ballots, tx.Ballot)
+		case tx.Mix != nil:
+			c.mixes = append(c.mixes, tx.Mix)
+		case tx.Partial != nil:
+			c.partials = append(c.partials, tx.Partial)
+		}
+	}
+	if c.election == nil {
+		return nil, errors.New("no election in the skipchain")
+	}
+	c.election.Migrate()
+
+	last := make(map[string]int)
+	for i, b := range ballots {
+		last[b.UserID] = i
+	}
+	for i, b := range ballots {
+		if last[b.UserID] == i {
+			c.box.Ballots = append(c.box.Ballots, b)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/encoding"
+)
+
+// report is the machine-readable result of the verification of an election.
+type report struct {
+	Election  string        `json:"election"`            // Election is the ID of the election skipchain.
+	Master    string        `json:"master"`              // Master is the ID of the master skipchain.
+	Key       string        `json:"key"`                 // Key is the public key of the election.
+	Time      string        `json:"time"`                // Time of the verification, in RFC 3339 format.
+	Voters    int           `json:"voters"`              // Voters is the number of registered voters.
+	Ballots   []string      `json:"ballots"`             // Ballots lists the voters having cast a ballot.
+	Mixes     []check       `json:"mixes"`               // Mixes are the checks of the shuffles.
+	Partials  []check       `json:"partials"`            // Partials are the checks of the decryptions.
+	Results   []string      `json:"results"`             // Results are the decrypted ballots, in hex.
+	Tally     []int         `json:"tally,omitempty"`     // Tally is the number of votes of each candidate in a homomorphic election.
+	Questions []*lib.Result `json:"questions,omitempty"` // Questions are the results of a multi-question election.
+	Errors    []string      `json:"errors"`              // Errors lists all the problems found.
+	Valid     bool          `json:"valid"`               // Valid is true if no problem was found.
+
+	Verifier  string `json:"verifier"`  // Verifier is the public key of the signer of the report.
+	Signature string `json:"signature"` // Signature of the report with an empty signature.
+}
+
+// check is the verification of a mix or a partial made by a node.
+type check struct {
+	Node  string `json:"node"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// verify checks the ballot box, the mixes and the partials of an election,
+// and recovers the results from the valid partials.
+func verify(election *lib.Election, box *lib.Box, mixes []*lib.Mix, partials []*lib.Partial) *report {
+	r := &report{
+		Election: hex.EncodeToString(election.ID),
+		Master:   hex.EncodeToString(election.Master),
+		Key:      election.Key.String(),
+		Voters:   len(election.Voters),
+		Ballots:  []string{},
+		Mixes:    []check{},
+		Partials: []check{},
+		Results:  []string{},
+		Errors:   []string{},
+	}
+	fail := func(format string, a ...interface{}) {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, a...))
+	}
+
+	n := len(election.Roster.List)
+	threshold := 2*n/3 + 1
+
+	// Every ballot must be cast by a registered voter, once.
+	cast := make(map[string]bool)
+	for _, b := range box.Ballots {
+		if !election.IsUser(b.UserID) {
+			fail("ballot of %s: not a registered voter", b.UserID)
+		}
+		if cast[b.UserID] {
+			fail("ballot of %s: more than one ballot", b.UserID)
+		}
+		if err := election.VerifyBallot(b); err != nil {
+			fail("ballot of %s: %v", b.UserID, err)
+		}
+		cast[b.UserID] = true
+		r.Ballots = append(r.Ballots, b.UserID)
+	}
+
+	if election.Mode == lib.Homomorphic {
+		if len(mixes) > 0 {
+			fail("a homomorphic election has %d mixes", len(mixes))
+		}
+		return verifyPartials(r, election, election.Sum(box.Ballots), partials, len(box.Ballots))
+	}
+
+	// Every mix must be a shuffle of the previous one, starting with the box.
+	ballots := election.Flatten(box.Ballots)
+	shuffled := make(map[string]bool)
+	for i, mix := range mixes {
+		c := check{Node: mix.NodeID.String()}
+		if shuffled[c.Node] {
+			c.Error = "node has already proposed a shuffle"
+		} else if len(mix.Ballots) != len(ballots) {
+			c.Error = fmt.Sprintf("%d ballots instead of %d", len(mix.Ballots), len(ballots))
+		} else if err := election.VerifyMix(mix, ballots); err != nil {
+			c.Error = err.Error()
+		} else {
+			c.Valid = true
+		}
+		if !c.Valid {
+			fail("mix %d: %s", i, c.Error)
+		}
+		shuffled[c.Node] = true
+		r.Mixes = append(r.Mixes, c)
+		ballots = mix.Ballots
+	}
+	if len(mixes) < threshold {
+		fail("%d mixes, %d are required", len(mixes), threshold)
+	}
+	if len(mixes) == 0 {
+		r.Valid = len(r.Errors) == 0
+		return r
+	}
+	return verifyPartials(r, election, ballots, partials, 0)
+}
+
+// verifyPartials checks that every partial is a decryption of the ballots
+// with the key share of its node, and recovers the results. In a homomorphic
+// election, the ballots are the sums of the votes and the tally is recovered,
+// with at most max votes per candidate.
+func verifyPartials(r *report, election *lib.Election, ballots []*lib.Ballot, partials []*lib.Partial, max int) *report {
+	fail := func(format string, a ...interface{}) {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, a...))
+	}
+	n := len(election.Roster.List)
+	threshold := 2*n/3 + 1
+
+	if len(election.Commits) == 0 {
+		fail("the election has no DKG commits, the decryptions cannot be verified")
+	} else if !election.Commits[0].Equal(election.Key) {
+		fail("the DKG commits do not match the election key")
+	}
+	shares := make([][]*share.PubShare, len(ballots))
+	for i := range shares {
+		shares[i] = make([]*share.PubShare, n)
+	}
+	var valid int
+	decrypted := make(map[string]bool)
+	for i, partial := range partials {
+		c := check{Node: partial.NodeID.String()}
+		index, _ := election.Roster.Search(partial.NodeID)
+		if decrypted[c.Node] {
+			c.Error = "node has already proposed a partial"
+		} else if len(partial.Points) != len(ballots) {
+			c.Error = fmt.Sprintf("%d points instead of %d", len(partial.Points), len(ballots))
+		} else if err := election.VerifyPartial(partial, ballots); err != nil {
+			c.Error = err.Error()
+		} else {
+			c.Valid = true
+		}
+		if c.Valid {
+			valid++
+			for j, point := range partial.Points {
+				shares[j][index] = &share.PubShare{I: index, V: point}
+			}
+		} else {
+			fail("partial %d: %s", i, c.Error)
+		}
+		decrypted[c.Node] = true
+		r.Partials = append(r.Partials, c)
+	}
+	if valid < threshold {
+		fail("%d valid partials, %d are required", valid, threshold)
+		return r
+	}
+
+	var messages []kyber.Point
+	for i := range ballots {
+		message, err := share.RecoverCommit(cothority.Suite, shares[i], threshold, n)
+		if err != nil {
+			fail("ballot %d: %v", i, err)
+			continue
+		}
+		messages = append(messages, message)
+		if len(election.Questions) > 0 {
+			// Undecodable answers are counted as invalid by the tally.
+			data, _ := message.Data()
+			r.Results = append(r.Results, hex.EncodeToString(data))
+			continue
+		}
+		if election.Mode == lib.Homomorphic {
+			votes, err := lib.DiscreteLog(message, max)
+			if err != nil {
+				fail("candidate %d: %v", i, err)
+				continue
+			}
+			r.Tally = append(r.Tally, votes)
+			continue
+		}
+		data, err := message.Data()
+		if err != nil {
+			fail("ballot %d: %v", i, err)
+			continue
+		}
+		r.Results = append(r.Results, hex.EncodeToString(data))
+	}
+	if len(election.Questions) > 0 && len(messages) == len(ballots) {
+		results, err := election.Tally(messages)
+		if err != nil {
+			fail("%v", err)
+		}
+		r.Questions = results
+	}
+	r.Valid = len(r.Errors) == 0
+	return r
+}
+
+// sign signs the report with the private key of the verifier.
+func (r *report) sign(private kyber.Scalar) error {
+	public := cothority.Suite.Point().Mul(private, nil)
+	verifier, err := encoding.PointToStringHex(cothority.Suite, public)
+	if err != nil {
+		return err
+	}
+	r.Verifier = verifier
+	msg, err := r.message()
+	if err != nil {
+		return err
+	}
+	sig, err := schnorr.Sign(cothority.Suite, private, msg)
+	if err != nil {
+		return err
+	}
+	r.Signature = hex.EncodeToString(sig)
+	return nil
+}
+
+// checkSignature verifies the signature of the report by its verifier.
+func (r *report) checkSignature() error {
+	if r.Signature == "" {
+		return errors.New("report is not signed")
+	}
+	public, err := encoding.StringHexToPoint(cothority.Suite, r.Verifier)
+	if err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return err
+	}
+	msg, err := r.message()
+	if err != nil {
+		return err
+	}
+	return schnorr.Verify(cothority.Suite, public, msg, sig)
+}
+
+// message returns the signed message, which is the JSON encoding of the report
+// with an empty signature.
+func (r *report) message() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signature = ""
+	return json.Marshal(&unsigned)
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+	"go.dedis.ch/kyber/v3/proof/dleq"
+	"go.dedis.ch/kyber/v3/shuffle"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+// fixture is a shuffled and decrypted election.
+type fixture struct {
+	election *lib.Election
+	box      *lib.Box
+	mixes    []*lib.Mix
+	partials []*lib.Partial
+	messages []string
+
+	secrets []*lib.SharedSecret
+	sign    func(i int) []byte
+}
+
+// genFixture runs an election with n nodes and the given voters, each casting
+// a ballot.
+func genFixture(t *testing.T, n int, voters []string) *fixture {
+	dkgs, err := lib.DKGSimulate(n, 2*n/3+1)
+	require.NoError(t, err)
+	secrets := make([]*lib.SharedSecret, n)
+	for i := range dkgs {
+		secrets[i], err = lib.NewSharedSecret(dkgs[i])
+		require.NoError(t, err)
+	}
+
+	list := make([]*network.ServerIdentity, n)
+	pairs := make([]*key.Pair, n)
+	for i := range list {
+		pairs[i] = key.NewKeyPair(cothority.Suite)
+		addr := network.NewAddress(network.Local, fmt.Sprintf("127.0.0.1:%d", 2000+i))
+		list[i] = network.NewServerIdentity(pairs[i].Public, addr)
+	}
+	sign := func(i int) []byte {
+		data, _ := pairs[i].Public.MarshalBinary()
+		sig, _ := schnorr.Sign(cothority.Suite, pairs[i].Private, data)
+		return sig
+	}
+
+	f := &fixture{
+		election: &lib.Election{
+			ID:      []byte{1},
+			Master:  []byte{2},
+			Roster:  onet.NewRoster(list),
+			Key:     secrets[0].X,
+			Commits: secrets[0].Commits,
+			Voters:  voters,
+		},
+		box:     &lib.Box{},
+		secrets: secrets,
+		sign:    sign,
+	}
+	key := f.election.Key
+	for _, v := range voters {
+		message := "vote of " + v
+		a, b := lib.Encrypt(key, []byte(message))
+		f.box.Ballots = append(f.box.Ballots, &lib.Ballot{UserID: v, Alpha: a, Beta: b})
+		f.messages = append(f.messages, hex.EncodeToString([]byte(message)))
+	}
+
+	x, y := lib.Split(f.box.Ballots)
+	for i := 0; i < n; i++ {
+		v, w, prover := shuffle.Shuffle(cothority.Suite, nil, key, x, y, random.New())
+		tag, err := proof.HashProve(cothority.Suite, "", prover)
+		require.NoError(t, err)
+		f.mixes = append(f.mixes, &lib.Mix{
+			Ballots:   lib.Combine(v, w),
+			Proof:     tag,
+			NodeID:    list[i].ID,
+			Signature: sign(i),
+		})
+		x, y = v, w
+	}
+
+	f.decrypt(t, f.mixes[n-1].Ballots)
+	return f
+}
+
+// decrypt sets the partials of all the nodes for the ballots.
+func (f *fixture) decrypt(t *testing.T, ballots []*lib.Ballot) {
+	f.partials = nil
+	for i, secret := range f.secrets {
+		partial := &lib.Partial{NodeID: f.election.Roster.List[i].ID, Signature: f.sign(i)}
+		for _, b := range ballots {
+			point, proof, err := lib.DecryptWithProof(secret.V, b.Alpha, b.Beta)
+			require.NoError(t, err)
+			partial.Points = append(partial.Points, point)
+			partial.Proofs = append(partial.Proofs, proof)
+		}
+		f.partials = append(f.partials, partial)
+	}
+}
+
+func (f *fixture) verify() *report {
+	return verify(f.election, f.box, f.mixes, f.partials)
+}
+
+func TestVerify(t *testing.T) {
+	f := genFixture(t, 3, []string{"alice", "bob", "carol"})
+	r := f.verify()
+	assert.Empty(t, r.Errors)
+	assert.True(t, r.Valid)
+	assert.Equal(t, 3, r.Voters)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, r.Ballots)
+	assert.Len(t, r.Mixes, 3)
+	assert.Len(t, r.Partials, 3)
+
+	sort.Strings(r.Results)
+	sort.Strings(f.messages)
+	assert.Equal(t, f.messages, r.Results)
+}
+
+func TestVerify_Eligibility(t *testing.T) {
+	f := genFixture(t, 3, []string{"alice", "bob"})
+	f.election.Voters = []string{"alice"}
+	r := f.verify()
+	assert.False(t, r.Valid)
+	assert.Equal(t, []string{"ballot of bob: not a registered voter"}, r.Errors)
+
+	f = genFixture(t, 3, []string{"alice", "bob"})
+	f.box.Ballots[1].UserID = "alice"
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Equal(t, []string{"ballot of alice: more than one ballot"}, r.Errors)
+}
+
+func TestVerify_Mixes(t *testing.T) {
+	f := genFixture(t, 3, []string{"alice", "bob"})
+	f.mixes[1].Ballots[0], f.mixes[1].Ballots[1] = f.mixes[1].Ballots[1], f.mixes[1].Ballots[0]
+	r := f.verify()
+	assert.False(t, r.Valid)
+	assert.True(t, r.Mixes[0].Valid)
+	assert.False(t, r.Mixes[1].Valid)
+	assert.False(t, r.Mixes[2].Valid)
+
+	f = genFixture(t, 3, []string{"alice", "bob"})
+	f.mixes[1].NodeID = f.mixes[0].NodeID
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Equal(t, "node has already proposed a shuffle", r.Mixes[1].Error)
+
+	f = genFixture(t, 3, []string{"alice", "bob"})
+	f.mixes = f.mixes[:2]
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Contains(t, r.Errors, "2 mixes, 3 are required")
+}
+
+func TestVerify_Partials(t *testing.T) {
+	f := genFixture(t, 4, []string{"alice", "bob"})
+	f.partials[2].Points[0] = cothority.Suite.Point().Pick(random.New())
+	r := f.verify()
+	assert.False(t, r.Valid)
+	assert.True(t, r.Partials[0].Valid)
+	assert.False(t, r.Partials[2].Valid)
+	// The results are still recovered from the three valid partials.
+	assert.Len(t, r.Results, 2)
+
+	f = genFixture(t, 3, []string{"alice", "bob"})
+	f.partials[0].Proofs = []*dleq.Proof{nil, nil}
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Contains(t, r.Errors, "2 valid partials, 3 are required")
+	assert.Empty(t, r.Results)
+
+	f = genFixture(t, 3, []string{"alice", "bob"})
+	f.election.Commits = nil
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Len(t, r.Results, 2)
+
+	f = genFixture(t, 3, []string{"alice", "bob"})
+	f.election.Commits = []kyber.Point{cothority.Suite.Point().Pick(random.New())}
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Empty(t, r.Results)
+}
+
+func TestVerify_Homomorphic(t *testing.T) {
+	f := genFixture(t, 3, []string{"alice", "bob", "carol"})
+	e := f.election
+	e.Mode = lib.Homomorphic
+	e.Candidates = []uint32{1, 2}
+	e.MaxChoices = 1
+	f.mixes = nil
+	for i, choices := range [][]bool{{true, false}, {false, true}, {true, false}} {
+		user := e.Voters[i]
+		ballot, err := lib.NewVote(e.Key, choices, 1, lib.VoteContext(e.ID, user))
+		require.NoError(t, err)
+		ballot.UserID = user
+		f.box.Ballots[i] = ballot
+	}
+	f.decrypt(t, e.Sum(f.box.Ballots))
+
+	r := f.verify()
+	assert.Empty(t, r.Errors)
+	assert.True(t, r.Valid)
+	assert.Equal(t, []int{2, 1}, r.Tally)
+	assert.Empty(t, r.Results)
+
+	// A ballot copied from another voter is detected.
+	f.box.Ballots[2].UserID = "dave"
+	e.Voters = append(e.Voters, "dave")
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.Len(t, r.Errors, 1)
+}
+
+func TestVerify_Questions(t *testing.T) {
+	f := genFixture(t, 3, []string{"alice", "bob", "carol"})
+	e := f.election
+	e.Questions = []*lib.Question{
+		{Type: lib.SingleChoice, Candidates: []string{"yes", "no"}},
+		{Type: lib.Ranked, Candidates: []string{"a", "b", "c"}, MaxChoices: 2, Method: lib.Borda},
+	}
+	for i, answers := range [][][]int{{{0}, {1, 0}}, {{1}, {1}}, {{0}, {2, 1}}} {
+		ballot, err := e.EncryptAnswers(answers)
+		require.NoError(t, err)
+		ballot.UserID = e.Voters[i]
+		f.box.Ballots[i] = ballot
+	}
+	ballots := e.Flatten(f.box.Ballots)
+	for i := range f.mixes {
+		mix, err := e.Shuffle(ballots)
+		require.NoError(t, err)
+		mix.NodeID, mix.Signature = f.mixes[i].NodeID, f.mixes[i].Signature
+		f.mixes[i] = mix
+		ballots = mix.Ballots
+	}
+	f.decrypt(t, ballots)
+
+	r := f.verify()
+	assert.Empty(t, r.Errors)
+	assert.True(t, r.Valid)
+	assert.Len(t, r.Results, 6)
+	require.Len(t, r.Questions, 2)
+	assert.Equal(t, []int{2, 1}, r.Questions[0].Counts)
+	assert.Equal(t, []int{1, 5, 2}, r.Questions[1].Counts)
+	assert.Equal(t, 1, r.Questions[1].Winner)
+
+	// The answers to different questions cannot be mixed together.
+	last := f.mixes[2]
+	last.Ballots[0], last.Ballots[5] = last.Ballots[5], last.Ballots[0]
+	r = f.verify()
+	assert.False(t, r.Valid)
+	assert.False(t, r.Mixes[2].Valid)
+}
+
+func TestReport_Signature(t *testing.T) {
+	f := genFixture(t, 3, []string{"alice", "bob"})
+	r := f.verify()
+	assert.Error(t, r.checkSignature())
+
+	kp := key.NewKeyPair(cothority.Suite)
+	require.NoError(t, r.sign(kp.Private))
+	assert.NoError(t, r.checkSignature())
+
+	// The signature survives the JSON encoding.
+	b, err := json.Marshal(r)
+	require.NoError(t, err)
+	r2 := &report{}
+	require.NoError(t, json.Unmarshal(b, r2))
+	assert.NoError(t, r2.checkSignature())
+
+	r2.Valid = false
+	assert.Error(t, r2.checkSignature())
+}
+
+func TestChain(t *testing.T) {
+	l := onet.NewLocalTest(cothority.Suite)
+	defer l.CloseAll()
+	_, roster, _ := l.GenTree(3, true)
+	client := skipchain.NewClient()
+	genesis, err := client.CreateGenesis(roster, 2, 2, skipchain.VerificationStandard, nil)
+	require.NoError(t, err)
+
+	f := genFixture(t, 3, []string{"alice", "bob"})
+	f.election.ID = genesis.Hash
+	a, b := lib.Encrypt(f.election.Key, []byte("first vote of alice"))
+	txs := []*lib.Transaction{
+		lib.NewTransaction(f.election, "admin"),
+		// Only the last ballot of alice is in the box.
+		lib.NewTransaction(&lib.Ballot{UserID: "alice", Alpha: a, Beta: b}, "alice"),
+	}
+	for _, ballot := range f.box.Ballots {
+		txs = append(txs, lib.NewTransaction(ballot, ballot.UserID))
+	}
+	for _, m := range f.mixes {
+		txs = append(txs, lib.NewTransaction(m, ""))
+	}
+	for _, p := range f.partials {
+		txs = append(txs, lib.NewTransaction(p, ""))
+	}
+	for _, tx := range txs {
+		data, err := protobuf.Encode(tx)
+		require.NoError(t, err)
+		_, err = client.StoreSkipBlock(genesis, nil, data)
+		require.NoError(t, err)
+	}
+
+	blocks, err := fetchChain(roster, genesis.Hash)
+	require.NoError(t, err)
+	require.Len(t, blocks, len(txs)+1)
+	c, err := readChain(blocks)
+	require.NoError(t, err)
+	require.Len(t, c.box.Ballots, 2)
+	for i, b := range c.box.Ballots {
+		require.Equal(t, f.box.Ballots[i].UserID, b.UserID)
+		require.True(t, f.box.Ballots[i].Alpha.Equal(b.Alpha))
+	}
+	r := verify(c.election, c.box, c.mixes, c.partials)
+	assert.Empty(t, r.Errors)
+	assert.True(t, r.Valid)
+
+	// The content of the blocks cannot be changed.
+	tampered := append([]*skipchain.SkipBlock{}, blocks...)
+	tampered[2] = blocks[2].Copy()
+	tampered[2].Data = blocks[3].Data
+	require.Error(t, verifyChain(roster, genesis.Hash, tampered))
+
+	// No block can be left out.
+	missing := append(append([]*skipchain.SkipBlock{}, blocks[:2]...), blocks[3:]...)
+	require.Error(t, verifyChain(roster, genesis.Hash, missing))
+
+	// The blocks must be signed by the given roster.
+	_, other, _ := l.GenTree(3, true)
+	require.Error(t, verifyChain(other, genesis.Hash, blocks))
+	require.Error(t, verifyChain(roster, blocks[1].Hash, blocks))
+}
@@ -1,8 +1,11 @@
 package lib
 
 import (
+	"errors"
+
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/proof"
+	"go.dedis.ch/kyber/v3/proof/dleq"
 	"go.dedis.ch/kyber/v3/share/dkg/rabin"
 	"go.dedis.ch/kyber/v3/shuffle"
 	"go.dedis.ch/kyber/v3/util/random"
@@ -13,14 +16,54 @@ import (
 
 // Ballot represents an encrypted vote.
 type Ballot struct {
-	User uint32 // User identifier.
+	User uint32 // Deprecated: use UserID.
 
 	// ElGamal ciphertext pair.
 	Alpha kyber.Point
 	Beta  kyber.Point
+
+	UserID string // UserID identifies the voter.
+
+	Vote *Vote // Vote holds the encrypted choices in a homomorphic election.
+
+	// ElGamal ciphertext pairs of the answers to each question of a
+	// multi-question election.
+	Alphas []kyber.Point
+	Betas  []kyber.Point
+}
+
+// Migrate converts the SCIPER number of a ballot cast before the voters were
+// identified by strings to a voter ID.
+func (b *Ballot) Migrate() {
+	if b.UserID == "" {
+		b.UserID = LegacyID(b.User)
+	}
+	b.User = 0
+}
+
+// Check returns an error if the ciphertext of the ballot is missing. The
+// answers of a multi-question ballot are checked with the election.
+func (b *Ballot) Check() error {
+	if len(b.Alphas) > 0 {
+		return nil
+	}
+	null := cothority.Suite.Point().Null()
+	if b.Alpha == nil || b.Beta == nil {
+		return errors.New("alpha and beta must be non-nil")
+	}
+	if b.Alpha.Equal(null) || b.Beta.Equal(null) {
+		return errors.New("alpha and beta must not be null points")
+	}
+	return nil
 }
 
 // Box is a wrapper around a list of encrypted ballots.
+//
+// A voter can cast a ballot as many times as they want while the election is
+// running: only the last ballot of each voter is kept in the box, and all the
+// previous ones are discarded before the shuffle. A voter under coercion can
+// thus cast the ballot asked for, and replace it later with a ballot of their
+// own. The ballots of the box are in the order of their last cast.
 type Box struct {
 	Ballots []*Ballot
 }
@@ -49,6 +92,8 @@ type Mix struct {
 
 	NodeID    network.ServerIdentityID // Node signifies the creator of the mix.
 	Signature []byte                   // Signature of the public key
+
+	Proofs [][]byte // Proofs of the shuffle of each question of a multi-question election.
 }
 
 // Partial contains the partially decrypted ballots.
@@ -57,6 +102,8 @@ type Partial struct {
 
 	NodeID    network.ServerIdentityID // NodeID is the node having signed the partial
 	Signature []byte                   // Signature of the public key
+
+	Proofs []*dleq.Proof // Proofs of the decryption of each point with the share of the node.
 }
 
 // genPartials generates partial decryptions for a given list of shared secrets.
@@ -66,11 +113,13 @@ func (m *Mix) genPartials(dkgs []*dkg.DistKeyGenerator) []*Partial {
 	for i, gen := range dkgs {
 		secret, _ := NewSharedSecret(gen)
 		points := make([]kyber.Point, len(m.Ballots))
+		proofs := make([]*dleq.Proof, len(m.Ballots))
 		for j, ballot := range m.Ballots {
-			points[j] = Decrypt(secret.V, ballot.Alpha, ballot.Beta)
+			points[j], proofs[j], _ = DecryptWithProof(secret.V, ballot.Alpha, ballot.Beta)
 		}
 		partials[i] = &Partial{
 			Points: points,
+			Proofs: proofs,
 		}
 	}
 	return partials
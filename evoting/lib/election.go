@@ -9,9 +9,15 @@ import (
 	"time"
 
 	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+	"go.dedis.ch/kyber/v3/share"
+	"go.dedis.ch/kyber/v3/shuffle"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/random"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/network"
 
+	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/skipchain"
 )
 
@@ -27,6 +33,18 @@ const (
 	Decrypted
 )
 
+// ElectionMode is the way the ballots of an election are tallied.
+type ElectionMode uint32
+
+const (
+	// Mixnet depicts that the ballots are shuffled before each of them is
+	// decrypted
+	Mixnet ElectionMode = iota
+	// Homomorphic depicts that the ballots are added up and only the number
+	// of votes of each candidate is decrypted
+	Homomorphic
+)
+
 func init() {
 	network.RegisterMessages(Election{}, Ballot{}, Box{}, Mix{}, Partial{})
 }
@@ -36,8 +54,8 @@ func init() {
 // to the election skipchain is appended to the master skipchain upon opening.
 type Election struct {
 	Name    map[string]string // Name of the election. lang-code, value pair
-	Creator uint32            // Creator is the election responsible.
-	Users   []uint32          // Users is the list of registered voters.
+	Creator uint32            // Deprecated: use CreatorID.
+	Users   []uint32          // Deprecated: use Voters.
 
 	ID        skipchain.SkipBlockID // ID is the hash of the genesis block.
 	Master    skipchain.SkipBlockID // Master is the hash of the master skipchain.
@@ -58,6 +76,15 @@ type Election struct {
 
 	Voted        skipchain.SkipBlockID // Voted denotes if a user has already cast a ballot for this election.
 	MoreInfoLang map[string]string     // MoreInfoLang, is MoreInfo, but as a lang-code/value map. MoreInfoLang should be used in preference to MoreInfo.
+
+	CreatorID string   // CreatorID identifies the election responsible.
+	Voters    []string // Voters is the list of the IDs of the registered voters.
+
+	Commits []kyber.Point // Commits of the DKG, to verify the decryption of the ballots.
+
+	Mode ElectionMode // Mode is how the ballots are tallied.
+
+	Questions []*Question // Questions of a multi-question election, instead of the candidates.
 }
 
 // Footer denotes the fields for the election footer
@@ -70,7 +97,7 @@ type Footer struct {
 
 // GetElection fetches the election structure from its skipchain and sets the stage.
 func GetElection(s *skipchain.Service, id skipchain.SkipBlockID,
-	checkVoted bool, user uint32) (*Election, error) {
+	checkVoted bool, user string) (*Election, error) {
 
 	var election *Election
 	index := 1
@@ -106,6 +133,7 @@ func GetElection(s *skipchain.Service, id skipchain.SkipBlockID,
 	if election == nil {
 		return nil, errors.New("no election found")
 	}
+	election.Migrate()
 	// check for voted only if required. We cache things in localStorage
 	// on the frontend
 	if checkVoted {
@@ -119,7 +147,7 @@ func GetElection(s *skipchain.Service, id skipchain.SkipBlockID,
 
 // setVoted sets the Voted field of the election to the skipblock id
 // of the last ballot cast by the user
-func (e *Election) setVoted(s *skipchain.Service, user uint32) error {
+func (e *Election) setVoted(s *skipchain.Service, user string) error {
 	db := s.GetDB()
 	block := db.GetByID(e.ID)
 	if block == nil {
@@ -135,8 +163,11 @@ func (e *Election) setVoted(s *skipchain.Service, user uint32) error {
 			block = db.GetByID(block.ForwardLink[0].To)
 			continue
 		}
-		if transaction.Ballot != nil && transaction.Ballot.User == user {
-			e.Voted = block.Hash
+		if transaction.Ballot != nil {
+			transaction.Ballot.Migrate()
+			if transaction.Ballot.UserID == user {
+				e.Voted = block.Hash
+			}
 		}
 		if transaction.Mix != nil || transaction.Partial != nil {
 			break
@@ -175,24 +206,73 @@ func (e *Election) setStage(s *skipchain.Service) error {
 	return nil
 }
 
-// Box accumulates all the ballots while only keeping the last ballot for each user.
+// Box accumulates all the ballots while only keeping the last ballot for each
+// user, see the Box type.
 func (e *Election) Box(s *skipchain.Service) (*Box, error) {
+	ballots, _, err := e.casts(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reverse ballot list
+	for i, j := 0, len(ballots)-1; i < j; i, j = i+1, j-1 {
+		ballots[i], ballots[j] = ballots[j], ballots[i]
+	}
+
+	// Only keep last casted ballot per user
+	mapping := make(map[string]bool)
+	unique := make([]*Ballot, 0)
+	for _, ballot := range ballots {
+		if _, found := mapping[ballot.UserID]; !found {
+			unique = append(unique, ballot)
+			mapping[ballot.UserID] = true
+		}
+	}
+
+	// Reverse back list of unique ballots
+	for i, j := 0, len(unique)-1; i < j; i, j = i+1, j-1 {
+		unique[i], unique[j] = unique[j], unique[i]
+	}
+	return &Box{Ballots: unique}, nil
+}
+
+// TrackingCodes returns the tracking codes of all the ballots cast in the
+// election, in the order they have been cast. Replaced ballots are listed as
+// well, and the codes do not reveal the voters, so that the bulletin board
+// does not show who cast a ballot more than once.
+func (e *Election) TrackingCodes(s *skipchain.Service) ([][]byte, error) {
+	ballots, blocks, err := e.casts(s)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([][]byte, len(ballots))
+	for i := range ballots {
+		codes[i] = TrackingCode(ballots[i], blocks[i])
+	}
+	return codes, nil
+}
+
+// casts returns all the ballots cast in the election and the IDs of the
+// blocks storing them.
+func (e *Election) casts(s *skipchain.Service) ([]*Ballot, []skipchain.SkipBlockID, error) {
 	search, err := s.GetSingleBlockByIndex(
 		&skipchain.GetSingleBlockByIndex{
 			Genesis: e.ID,
 			Index:   0,
 		})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	block := search.SkipBlock
 
-	// Use map to only included a user's last ballot.
 	ballots := make([]*Ballot, 0)
+	blocks := make([]skipchain.SkipBlockID, 0)
 	for {
 		transaction := UnmarshalTransaction(block.Data)
 		if transaction != nil && transaction.Ballot != nil {
+			transaction.Ballot.Migrate()
 			ballots = append(ballots, transaction.Ballot)
+			blocks = append(blocks, block.Hash)
 		}
 
 		if len(block.ForwardLink) <= 0 {
@@ -203,27 +283,7 @@ func (e *Election) Box(s *skipchain.Service) (*Box, error) {
 				ID: block.ForwardLink[0].To,
 			})
 	}
-
-	// Reverse ballot list
-	for i, j := 0, len(ballots)-1; i < j; i, j = i+1, j-1 {
-		ballots[i], ballots[j] = ballots[j], ballots[i]
-	}
-
-	// Only keep last casted ballot per user
-	mapping := make(map[uint32]bool)
-	unique := make([]*Ballot, 0)
-	for _, ballot := range ballots {
-		if _, found := mapping[ballot.User]; !found {
-			unique = append(unique, ballot)
-			mapping[ballot.User] = true
-		}
-	}
-
-	// Reverse back list of unique ballots
-	for i, j := 0, len(unique)-1; i < j; i, j = i+1, j-1 {
-		unique[i], unique[j] = unique[j], unique[i]
-	}
-	return &Box{Ballots: unique}, nil
+	return ballots, blocks, nil
 }
 
 // Mixes returns all mixes created by the roster conodes.
@@ -318,8 +378,8 @@ func (e *Election) Partials(s *skipchain.Service) ([]*Partial, error) {
 }
 
 // IsUser checks if a given user is a registered voter for the election.
-func (e *Election) IsUser(user uint32) bool {
-	for _, u := range e.Users {
+func (e *Election) IsUser(user string) bool {
+	for _, u := range e.Voters {
 		if u == user {
 			return true
 		}
@@ -328,21 +388,252 @@ func (e *Election) IsUser(user uint32) bool {
 }
 
 // IsCreator checks if a given user is the creator of the election.
-func (e *Election) IsCreator(user uint32) bool {
-	return user == e.Creator
+func (e *Election) IsCreator(user string) bool {
+	return user == e.CreatorID
+}
+
+// Migrate converts the SCIPER numbers of an election created before the
+// voters were identified by strings to voter IDs. Elections are migrated when
+// they are read from their skipchain, and stored migrated when updated.
+func (e *Election) Migrate() {
+	if e.CreatorID == "" {
+		e.CreatorID = LegacyID(e.Creator)
+	}
+	for _, u := range e.Users {
+		e.Voters = append(e.Voters, LegacyID(u))
+	}
+	e.Creator = 0
+	e.Users = nil
+}
+
+// Ciphertexts returns the ciphertexts the partials decrypt: the ballots of
+// the last mix, or the sums of the ballots in a homomorphic election.
+func (e *Election) Ciphertexts(s *skipchain.Service) ([]*Ballot, error) {
+	if e.Mode == Homomorphic {
+		box, err := e.Box(s)
+		if err != nil {
+			return nil, err
+		}
+		return e.Sum(box.Ballots), nil
+	}
+	mixes, err := e.Mixes(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(mixes) <= 2*len(e.Roster.List)/3 {
+		return nil, errors.New("election not shuffled yet")
+	}
+	return mixes[len(mixes)-1].Ballots, nil
+}
+
+// Sum adds up the votes of the ballots of a homomorphic election, and returns
+// one ciphertext per candidate encrypting its number of votes.
+func (e *Election) Sum(ballots []*Ballot) []*Ballot {
+	sums := make([]*Ballot, len(e.Candidates))
+	for i := range sums {
+		sums[i] = &Ballot{Alpha: cothority.Suite.Point().Null(), Beta: cothority.Suite.Point().Null()}
+	}
+	for _, ballot := range ballots {
+		// Ballots are verified when they are cast.
+		if ballot.Vote == nil || len(ballot.Vote.Alpha) != len(sums) || len(ballot.Vote.Beta) != len(sums) {
+			continue
+		}
+		for i, sum := range sums {
+			sum.Alpha.Add(sum.Alpha, ballot.Vote.Alpha[i])
+			sum.Beta.Add(sum.Beta, ballot.Vote.Beta[i])
+		}
+	}
+	return sums
+}
+
+// Check returns an error if the questions, the mode or the maximum number of
+// choices of the election are not valid.
+func (e *Election) Check() error {
+	for i, q := range e.Questions {
+		if err := q.Check(); err != nil {
+			return fmt.Errorf("question %d: %v", i, err)
+		}
+	}
+	switch e.Mode {
+	case Mixnet:
+	case Homomorphic:
+		if len(e.Questions) != 0 {
+			return errors.New("homomorphic elections have no questions")
+		}
+		if e.MaxChoices < 1 || e.MaxChoices > len(e.Candidates) {
+			return errors.New("invalid max choices for a homomorphic election")
+		}
+	default:
+		return fmt.Errorf("unknown election mode %d", e.Mode)
+	}
+	return nil
+}
+
+// VerifyBallot checks that a ballot can be cast in the election. In a
+// homomorphic election, the proofs of the vote must hold.
+func (e *Election) VerifyBallot(ballot *Ballot) error {
+	switch e.Mode {
+	case Mixnet:
+		if ballot.Vote != nil {
+			return errors.New("only homomorphic elections accept votes")
+		}
+		if len(e.Questions) == 0 {
+			if len(ballot.Alphas) != 0 || len(ballot.Betas) != 0 {
+				return errors.New("only multi-question elections accept answers")
+			}
+			return nil
+		}
+		if len(ballot.Alphas) != len(e.Questions) || len(ballot.Betas) != len(e.Questions) {
+			return fmt.Errorf("the ballot must have %d answers", len(e.Questions))
+		}
+		null := cothority.Suite.Point().Null()
+		for i := range ballot.Alphas {
+			if ballot.Alphas[i] == nil || ballot.Betas[i] == nil {
+				return errors.New("alpha and beta must be non-nil")
+			}
+			if ballot.Alphas[i].Equal(null) || ballot.Betas[i].Equal(null) {
+				return errors.New("alpha and beta must not be null points")
+			}
+		}
+		return nil
+	case Homomorphic:
+		return VerifyVote(e.Key, ballot, len(e.Candidates), e.MaxChoices, VoteContext(e.ID, ballot.UserID))
+	}
+	return fmt.Errorf("unknown election mode %d", e.Mode)
+}
+
+// VerifyNode checks that the signature of a mix or a partial has been made by
+// a node of the roster, and returns the index of the node.
+func (e *Election) VerifyNode(id network.ServerIdentityID, signature []byte) (int, error) {
+	index, node := e.Roster.Search(id)
+	if node == nil {
+		return -1, fmt.Errorf("node %s is not in the roster", id)
+	}
+	data, err := node.Public.MarshalBinary()
+	if err != nil {
+		return -1, err
+	}
+	if err := schnorr.Verify(cothority.Suite, node.Public, data, signature); err != nil {
+		return -1, err
+	}
+	return index, nil
+}
+
+// Shuffle shuffles and re-encrypts the ballots with a proof. In a
+// multi-question election, the flattened ballots are shuffled separately for
+// each question.
+func (e *Election) Shuffle(ballots []*Ballot) (*Mix, error) {
+	mix := &Mix{}
+	for _, column := range e.Columns(ballots) {
+		a, b := Split(column)
+		// Protect from missing input.
+		for i := range a {
+			if a[i] == nil {
+				a[i] = cothority.Suite.Point().Null()
+			}
+		}
+		for i := range b {
+			if b[i] == nil {
+				b[i] = cothority.Suite.Point().Null()
+			}
+		}
+		g, d, prov := shuffle.Shuffle(cothority.Suite, nil, e.Key, a, b, random.New())
+		proof, err := proof.HashProve(cothority.Suite, "", prov)
+		if err != nil {
+			return nil, err
+		}
+		mix.Ballots = append(mix.Ballots, Combine(g, d)...)
+		mix.Proofs = append(mix.Proofs, proof)
+	}
+	if len(e.Questions) == 0 {
+		mix.Proof, mix.Proofs = mix.Proofs[0], nil
+	}
+	return mix, nil
+}
+
+// VerifyMix checks that a mix has been signed by a node of the roster and
+// that it is a valid shuffle of the ballots. In a multi-question election,
+// the shuffle of each question is verified.
+func (e *Election) VerifyMix(mix *Mix, ballots []*Ballot) error {
+	if _, err := e.VerifyNode(mix.NodeID, mix.Signature); err != nil {
+		return err
+	}
+	if len(e.Questions) == 0 {
+		x, y := Split(ballots)
+		v, w := Split(mix.Ballots)
+		return Verify(mix.Proof, e.Key, x, y, v, w)
+	}
+	if len(mix.Ballots) != len(ballots) || len(ballots)%len(e.Questions) != 0 {
+		return errors.New("mix does not match the ballots")
+	}
+	if len(mix.Proofs) != len(e.Questions) {
+		return errors.New("mix needs one proof per question")
+	}
+	in, out := e.Columns(ballots), e.Columns(mix.Ballots)
+	for i := range in {
+		x, y := Split(in[i])
+		v, w := Split(out[i])
+		if err := Verify(mix.Proofs[i], e.Key, x, y, v, w); err != nil {
+			return fmt.Errorf("question %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// VerifyPartial checks that a partial has been signed by a node of the roster
+// and, if the election holds the commits of the DKG, that each point is the
+// decryption of the corresponding ballot with the key share of the node.
+func (e *Election) VerifyPartial(partial *Partial, ballots []*Ballot) error {
+	index, err := e.VerifyNode(partial.NodeID, partial.Signature)
+	if err != nil {
+		return err
+	}
+	if len(e.Commits) == 0 {
+		return nil
+	}
+	if len(partial.Points) != len(ballots) || len(partial.Proofs) != len(ballots) {
+		return errors.New("partial does not match the ballots")
+	}
+	public, err := e.PublicShare(index)
+	if err != nil {
+		return err
+	}
+	for i, ballot := range ballots {
+		err := VerifyDecryption(partial.Proofs[i], public, ballot.Alpha, ballot.Beta, partial.Points[i])
+		if err != nil {
+			return fmt.Errorf("ballot %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// PublicShare returns the public key share of the node at the given index of
+// the roster, computed from the commits of the DKG.
+func (e *Election) PublicShare(index int) (kyber.Point, error) {
+	if len(e.Commits) == 0 {
+		return nil, errors.New("election has no commits")
+	}
+	poly := share.NewPubPoly(cothority.Suite, nil, e.Commits)
+	if !poly.Commit().Equal(e.Key) {
+		return nil, errors.New("commits do not match the election key")
+	}
+	return poly.Eval(index).V, nil
 }
 
 func (e *Election) String() string {
 	str := new(strings.Builder)
 
 	fmt.Fprintf(str, "Election %x on master %x\n", e.ID, e.Master)
-	fmt.Fprintf(str, "Creator: %v\n", e.Creator)
+	fmt.Fprintf(str, "Creator: %v\n", e.CreatorID)
 	fmt.Fprintf(str, "Name:\n")
 	printLang(str, e.Name)
 	fmt.Fprintf(str, "Subtitle:\n")
 	printLang(str, e.Subtitle)
 	fmt.Fprintf(str, "Candidates: %v\n", e.Candidates)
 	fmt.Fprintf(str, "MaxChoices: %v\n", e.MaxChoices)
+	for i, q := range e.Questions {
+		fmt.Fprintf(str, "Question %d: %v %v %v\n", i, q.Title, q.Candidates, q.MaxChoices)
+	}
 	fmt.Fprintf(str, "MoreInfo: %v\n", e.MoreInfo)
 	fmt.Fprintf(str, "MoreInfoLang:\n")
 	printLang(str, e.MoreInfoLang)
@@ -356,7 +647,7 @@ func (e *Election) String() string {
 	fmt.Fprintf(str, "Election pubkey: %v\n", e.Key)
 	fmt.Fprintf(str, "Authentication server pubkey: %v\n", e.MasterKey)
 	fmt.Fprintf(str, "Stage: %v\n", e.Stage)
-	fmt.Fprintf(str, "Voters: %v\n", e.Users)
+	fmt.Fprintf(str, "Voters: %v\n", e.Voters)
 
 	return str.String()
 }
@@ -1,19 +1,90 @@
 package lib
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+
+	"go.dedis.ch/cothority/v3"
 )
 
 func TestIsUser(t *testing.T) {
-	e := &Election{Creator: 0, Users: []uint32{0}}
-	assert.True(t, e.IsUser(0))
-	assert.False(t, e.IsUser(1))
+	e := &Election{CreatorID: "0", Voters: []string{"0"}}
+	assert.True(t, e.IsUser("0"))
+	assert.False(t, e.IsUser("1"))
 }
 
 func TestIsCreator(t *testing.T) {
-	e := &Election{Creator: 0, Users: []uint32{0, 1}}
-	assert.True(t, e.IsCreator(0))
-	assert.False(t, e.IsCreator(1))
+	e := &Election{CreatorID: "0", Voters: []string{"0", "1"}}
+	assert.True(t, e.IsCreator("0"))
+	assert.False(t, e.IsCreator("1"))
+}
+
+func TestElection_Migrate(t *testing.T) {
+	e := &Election{Creator: 123456, Users: []uint32{123456, 234567}}
+	e.Migrate()
+	assert.Equal(t, "123456", e.CreatorID)
+	assert.Equal(t, []string{"123456", "234567"}, e.Voters)
+	assert.Nil(t, e.Users)
+	assert.True(t, e.IsUser("234567"))
+
+	// Migrating again changes nothing.
+	e.Migrate()
+	assert.Equal(t, "123456", e.CreatorID)
+	assert.Equal(t, []string{"123456", "234567"}, e.Voters)
+
+	b := &Ballot{User: 234567}
+	b.Migrate()
+	assert.Equal(t, "234567", b.UserID)
+	b = &Ballot{UserID: "alice"}
+	b.Migrate()
+	assert.Equal(t, "alice", b.UserID)
+}
+
+func TestElection_Verify(t *testing.T) {
+	n := 3
+	dkgs, err := DKGSimulate(n, 2*n/3+1)
+	require.Nil(t, err)
+	secret, _ := NewSharedSecret(dkgs[0])
+
+	list := make([]*network.ServerIdentity, n)
+	pairs := make([]*key.Pair, n)
+	for i := range list {
+		pairs[i] = key.NewKeyPair(cothority.Suite)
+		addr := network.NewAddress(network.Local, fmt.Sprintf("127.0.0.1:%d", 2000+i))
+		list[i] = network.NewServerIdentity(pairs[i].Public, addr)
+	}
+	e := &Election{Roster: onet.NewRoster(list), Key: secret.X, Commits: secret.Commits}
+	sign := func(i int) []byte {
+		data, _ := pairs[i].Public.MarshalBinary()
+		sig, _ := schnorr.Sign(cothority.Suite, pairs[i].Private, data)
+		return sig
+	}
+
+	box := genBox(e.Key, 3)
+	mix := box.genMix(e.Key, 1)[0]
+	mix.NodeID, mix.Signature = list[0].ID, sign(0)
+	assert.Nil(t, e.VerifyMix(mix, box.Ballots))
+	mix.NodeID = list[1].ID
+	assert.NotNil(t, e.VerifyMix(mix, box.Ballots))
+
+	partials := mix.genPartials(dkgs)
+	for i, p := range partials {
+		p.NodeID, p.Signature = list[i].ID, sign(i)
+		assert.Nil(t, e.VerifyPartial(p, mix.Ballots))
+	}
+	// The partial of node 1 doesn't match the share of node 0.
+	partials[1].NodeID, partials[1].Signature = list[0].ID, sign(0)
+	assert.NotNil(t, e.VerifyPartial(partials[1], mix.Ballots))
+	assert.NotNil(t, e.VerifyPartial(partials[0], box.Ballots))
+
+	// Without commits, only the signature is verified.
+	e.Commits = nil
+	assert.Nil(t, e.VerifyPartial(partials[1], mix.Ballots))
 }
@@ -5,6 +5,7 @@ import (
 
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/proof"
+	"go.dedis.ch/kyber/v3/proof/dleq"
 	"go.dedis.ch/kyber/v3/shuffle"
 	"go.dedis.ch/kyber/v3/util/random"
 
@@ -13,13 +14,20 @@ import (
 
 // Encrypt performs the ElGamal encryption algorithm.
 func Encrypt(public kyber.Point, message []byte) (K, C kyber.Point) {
-	M := cothority.Suite.Point().Embed(message, random.New())
+	K, C, _, _ = encrypt(public, message)
+	return
+}
+
+// encrypt performs the ElGamal encryption algorithm and also returns the
+// ephemeral private key and the embedded message, which open the ciphertext.
+func encrypt(public kyber.Point, message []byte) (K, C kyber.Point, k kyber.Scalar, M kyber.Point) {
+	M = cothority.Suite.Point().Embed(message, random.New())
 
 	// ElGamal-encrypt the point to produce ciphertext (K,C).
-	k := cothority.Suite.Scalar().Pick(random.New()) // ephemeral private key
-	K = cothority.Suite.Point().Mul(k, nil)          // ephemeral DH public key
-	S := cothority.Suite.Point().Mul(k, public)      // ephemeral DH shared secret
-	C = S.Add(S, M)                                  // message blinded with secret
+	k = cothority.Suite.Scalar().Pick(random.New()) // ephemeral private key
+	K = cothority.Suite.Point().Mul(k, nil)         // ephemeral DH public key
+	S := cothority.Suite.Point().Mul(k, public)     // ephemeral DH shared secret
+	C = S.Add(S, M)                                 // message blinded with secret
 	return
 }
 
@@ -30,6 +38,27 @@ func Decrypt(private kyber.Scalar, K, C kyber.Point) kyber.Point {
 	return cothority.Suite.Point().Sub(C, S)     // use to un-blind the message
 }
 
+// DecryptWithProof performs the ElGamal decryption algorithm with a share of
+// the private key, and proves that the share matching the public share has
+// been used.
+func DecryptWithProof(private kyber.Scalar, K, C kyber.Point) (kyber.Point, *dleq.Proof, error) {
+	proof, _, S, err := dleq.NewDLEQProof(cothority.Suite, cothority.Suite.Point().Base(), K, private)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cothority.Suite.Point().Sub(C, S), proof, nil
+}
+
+// VerifyDecryption verifies the proof that the point P is the decryption of
+// the ciphertext (K,C) with the share of the private key of the public share X.
+func VerifyDecryption(proof *dleq.Proof, X, K, C, P kyber.Point) error {
+	if proof == nil {
+		return errors.New("missing decryption proof")
+	}
+	S := cothority.Suite.Point().Sub(C, P)
+	return proof.Verify(cothority.Suite, cothority.Suite.Point().Base(), K, X, S)
+}
+
 // Verify performs verifies the proof of a Neff shuffle.
 func Verify(tag []byte, public kyber.Point, x, y, v, w []kyber.Point) error {
 	if len(x) < 2 || len(y) < 2 || len(v) < 2 || len(w) < 2 {
@@ -19,3 +19,20 @@ func TestElGamal(t *testing.T) {
 	dec, _ := Decrypt(secret, K, C).Data()
 	assert.Equal(t, message, dec)
 }
+
+func TestDecryptWithProof(t *testing.T) {
+	secret := cothority.Suite.Scalar().Pick(random.New())
+	public := cothority.Suite.Point().Mul(secret, nil)
+
+	K, C := Encrypt(public, []byte("nevv"))
+	P, proof, err := DecryptWithProof(secret, K, C)
+	assert.Nil(t, err)
+	assert.True(t, P.Equal(Decrypt(secret, K, C)))
+	assert.Nil(t, VerifyDecryption(proof, public, K, C, P))
+
+	// The proof doesn't hold for another public key or another point.
+	_, other := RandomKeyPair()
+	assert.NotNil(t, VerifyDecryption(proof, other, K, C, P))
+	assert.NotNil(t, VerifyDecryption(proof, public, K, C, other))
+	assert.NotNil(t, VerifyDecryption(nil, public, K, C, P))
+}
@@ -0,0 +1,234 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/random"
+
+	"go.dedis.ch/cothority/v3"
+)
+
+/*
+In a homomorphic election, a ballot holds one exponential ElGamal ciphertext
+per candidate, (K,C) = (rG, rX + mG), where m is 1 if the candidate is chosen
+and 0 otherwise. The ciphertexts of all the ballots are added up and only the
+sums, mG with m the number of votes of a candidate, are decrypted.
+
+The voter proves with disjunctive Chaum-Pedersen proofs that every ciphertext
+encrypts 0 or 1, and that the sum of the ciphertexts of the ballot encrypts at
+most MaxChoices. The proofs are bound to the election and to the voter, so that
+a ballot cannot be copied by another voter.
+*/
+
+// Vote is the encrypted vote of a ballot in a homomorphic election. The Alpha
+// and Beta of the ballot are the sum of the ciphertexts of the vote.
+type Vote struct {
+	Alpha  []kyber.Point // Alpha are the ephemeral keys, one per candidate.
+	Beta   []kyber.Point // Beta are the blinded votes, one per candidate.
+	Proofs []*RangeProof // Proofs that every ciphertext encrypts 0 or 1.
+	Sum    *RangeProof   // Sum proves that the ballot encrypts at most MaxChoices.
+}
+
+// RangeProof proves that an exponential ElGamal ciphertext encrypts an integer
+// between 0 and a maximum, with one challenge and response per integer.
+type RangeProof struct {
+	Challenges []kyber.Scalar
+	Responses  []kyber.Scalar
+}
+
+// NewVote encrypts the choices of a voter for the candidates of a homomorphic
+// election and returns the ballot holding them. The context binds the proofs
+// to the election and to the voter, see VoteContext.
+func NewVote(public kyber.Point, choices []bool, max int, context []byte) (*Ballot, error) {
+	n := len(choices)
+	vote := &Vote{
+		Alpha:  make([]kyber.Point, n),
+		Beta:   make([]kyber.Point, n),
+		Proofs: make([]*RangeProof, n),
+	}
+	ballot := &Ballot{
+		Alpha: cothority.Suite.Point().Null(),
+		Beta:  cothority.Suite.Point().Null(),
+		Vote:  vote,
+	}
+	r := cothority.Suite.Scalar().Zero()
+	var m int
+	for i, chosen := range choices {
+		var v int
+		if chosen {
+			v = 1
+		}
+		ri := cothority.Suite.Scalar().Pick(random.New())
+		vote.Alpha[i], vote.Beta[i] = encryptInt(public, ri, v)
+		proof, err := proveRange(public, vote.Alpha[i], vote.Beta[i], ri, v, 1, context)
+		if err != nil {
+			return nil, err
+		}
+		vote.Proofs[i] = proof
+		ballot.Alpha.Add(ballot.Alpha, vote.Alpha[i])
+		ballot.Beta.Add(ballot.Beta, vote.Beta[i])
+		r.Add(r, ri)
+		m += v
+	}
+	if m > max {
+		return nil, fmt.Errorf("%d choices, at most %d are allowed", m, max)
+	}
+	proof, err := proveRange(public, ballot.Alpha, ballot.Beta, r, m, max, context)
+	if err != nil {
+		return nil, err
+	}
+	vote.Sum = proof
+	return ballot, nil
+}
+
+// VerifyVote checks that the ballot holds a ciphertext encrypting 0 or 1 for
+// each of the n candidates, and that their sum, the Alpha and Beta of the
+// ballot, encrypts at most max.
+func VerifyVote(public kyber.Point, ballot *Ballot, n, max int, context []byte) error {
+	vote := ballot.Vote
+	if vote == nil {
+		return errors.New("missing vote")
+	}
+	if len(vote.Alpha) != n || len(vote.Beta) != n || len(vote.Proofs) != n {
+		return fmt.Errorf("the vote must have %d ciphertexts", n)
+	}
+	alpha, beta := cothority.Suite.Point().Null(), cothority.Suite.Point().Null()
+	for i := range vote.Alpha {
+		if vote.Alpha[i] == nil || vote.Beta[i] == nil {
+			return errors.New("alpha and beta must be non-nil")
+		}
+		if err := vote.Proofs[i].verify(public, vote.Alpha[i], vote.Beta[i], 1, context); err != nil {
+			return fmt.Errorf("candidate %d: %v", i, err)
+		}
+		alpha.Add(alpha, vote.Alpha[i])
+		beta.Add(beta, vote.Beta[i])
+	}
+	if ballot.Alpha == nil || ballot.Beta == nil || !alpha.Equal(ballot.Alpha) || !beta.Equal(ballot.Beta) {
+		return errors.New("the ballot is not the sum of the vote")
+	}
+	if err := vote.Sum.verify(public, alpha, beta, max, context); err != nil {
+		return fmt.Errorf("sum: %v", err)
+	}
+	return nil
+}
+
+// VoteContext returns the context binding the proofs of a vote to an election
+// and to a voter.
+func VoteContext(election []byte, user string) []byte {
+	return append(append([]byte{}, election...), user...)
+}
+
+// DiscreteLog returns the integer m between 0 and max such that P = mG.
+func DiscreteLog(P kyber.Point, max int) (int, error) {
+	G := cothority.Suite.Point().Base()
+	acc := cothority.Suite.Point().Null()
+	for m := 0; m <= max; m++ {
+		if acc.Equal(P) {
+			return m, nil
+		}
+		acc.Add(acc, G)
+	}
+	return 0, fmt.Errorf("point is not a multiple of the base up to %d", max)
+}
+
+// encryptInt performs the exponential ElGamal encryption of m with the
+// randomness r.
+func encryptInt(public kyber.Point, r kyber.Scalar, m int) (K, C kyber.Point) {
+	K = cothority.Suite.Point().Mul(r, nil)
+	C = cothority.Suite.Point().Mul(r, public)
+	C.Add(C, intPoint(m))
+	return
+}
+
+// intPoint returns mG.
+func intPoint(m int) kyber.Point {
+	return cothority.Suite.Point().Mul(cothority.Suite.Scalar().SetInt64(int64(m)), nil)
+}
+
+// proveRange proves that (K,C) = (rG, rX + mG) with m between 0 and max. For
+// every j other than m, the proof of log_G(K) = log_X(C - jG) is simulated,
+// and the challenges add up to the hash of all the commitments.
+func proveRange(public, K, C kyber.Point, r kyber.Scalar, m, max int, context []byte) (*RangeProof, error) {
+	if m < 0 || m > max {
+		return nil, errors.New("message out of range")
+	}
+	suite := cothority.Suite
+	p := &RangeProof{
+		Challenges: make([]kyber.Scalar, max+1),
+		Responses:  make([]kyber.Scalar, max+1),
+	}
+	a := make([]kyber.Point, max+1)
+	b := make([]kyber.Point, max+1)
+	w := suite.Scalar().Pick(random.New())
+	sum := suite.Scalar().Zero()
+	for j := range a {
+		if j == m {
+			a[j] = suite.Point().Mul(w, nil)
+			b[j] = suite.Point().Mul(w, public)
+			continue
+		}
+		p.Challenges[j] = suite.Scalar().Pick(random.New())
+		p.Responses[j] = suite.Scalar().Pick(random.New())
+		a[j], b[j] = rangeCommits(public, K, C, j, p.Challenges[j], p.Responses[j])
+		sum.Add(sum, p.Challenges[j])
+	}
+	c, err := rangeChallenge(public, K, C, a, b, context)
+	if err != nil {
+		return nil, err
+	}
+	p.Challenges[m] = c.Sub(c, sum)
+	p.Responses[m] = suite.Scalar().Add(w, suite.Scalar().Mul(p.Challenges[m], r))
+	return p, nil
+}
+
+// verify checks the proof that (K,C) encrypts an integer between 0 and max.
+func (p *RangeProof) verify(public, K, C kyber.Point, max int, context []byte) error {
+	if p == nil {
+		return errors.New("missing range proof")
+	}
+	if len(p.Challenges) != max+1 || len(p.Responses) != max+1 {
+		return errors.New("wrong size of range proof")
+	}
+	suite := cothority.Suite
+	a := make([]kyber.Point, max+1)
+	b := make([]kyber.Point, max+1)
+	sum := suite.Scalar().Zero()
+	for j := range a {
+		if p.Challenges[j] == nil || p.Responses[j] == nil {
+			return errors.New("incomplete range proof")
+		}
+		a[j], b[j] = rangeCommits(public, K, C, j, p.Challenges[j], p.Responses[j])
+		sum.Add(sum, p.Challenges[j])
+	}
+	c, err := rangeChallenge(public, K, C, a, b, context)
+	if err != nil {
+		return err
+	}
+	if !c.Equal(sum) {
+		return errors.New("invalid range proof")
+	}
+	return nil
+}
+
+// rangeCommits returns the commitments zG - cK and zX - c(C - jG).
+func rangeCommits(public, K, C kyber.Point, j int, c, z kyber.Scalar) (a, b kyber.Point) {
+	suite := cothority.Suite
+	a = suite.Point().Sub(suite.Point().Mul(z, nil), suite.Point().Mul(c, K))
+	S := suite.Point().Sub(C, intPoint(j))
+	b = suite.Point().Sub(suite.Point().Mul(z, public), suite.Point().Mul(c, S))
+	return
+}
+
+// rangeChallenge hashes the statement and the commitments of a range proof.
+func rangeChallenge(public, K, C kyber.Point, a, b []kyber.Point, context []byte) (kyber.Scalar, error) {
+	h := cothority.Suite.Hash()
+	h.Write(context)
+	for _, P := range append([]kyber.Point{public, K, C}, append(a, b...)...) {
+		if _, err := P.MarshalTo(h); err != nil {
+			return nil, err
+		}
+	}
+	return cothority.Suite.Scalar().SetBytes(h.Sum(nil)), nil
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+
+	"go.dedis.ch/cothority/v3"
+)
+
+func TestNewVote(t *testing.T) {
+	secret, public := RandomKeyPair()
+	context := VoteContext([]byte{1, 2, 3}, "alice")
+
+	ballot, err := NewVote(public, []bool{true, false, true}, 2, context)
+	require.Nil(t, err)
+	assert.Nil(t, VerifyVote(public, ballot, 3, 2, context))
+
+	// The number of chosen candidates is encrypted in the ballot.
+	votes, err := DiscreteLog(Decrypt(secret, ballot.Alpha, ballot.Beta), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, votes)
+
+	// The proofs are bound to the voter, the key and the maximum.
+	assert.NotNil(t, VerifyVote(public, ballot, 3, 2, VoteContext([]byte{1, 2, 3}, "bob")))
+	_, other := RandomKeyPair()
+	assert.NotNil(t, VerifyVote(other, ballot, 3, 2, context))
+	assert.NotNil(t, VerifyVote(public, ballot, 3, 1, context))
+	assert.NotNil(t, VerifyVote(public, ballot, 2, 2, context))
+
+	_, err = NewVote(public, []bool{true, true}, 1, context)
+	assert.NotNil(t, err)
+}
+
+func TestVerifyVote_Invalid(t *testing.T) {
+	_, public := RandomKeyPair()
+	context := VoteContext([]byte{1}, "alice")
+
+	// A ciphertext encrypting 2 cannot be proven to encrypt 0 or 1.
+	r := cothority.Suite.Scalar().Pick(cothority.Suite.RandomStream())
+	K, C := encryptInt(public, r, 2)
+	_, err := proveRange(public, K, C, r, 2, 1, context)
+	assert.NotNil(t, err)
+	proof, err := proveRange(public, K, C, r, 2, 2, context)
+	require.Nil(t, err)
+	assert.Nil(t, proof.verify(public, K, C, 2, context))
+	ballot := &Ballot{Alpha: K, Beta: C, Vote: &Vote{
+		Alpha:  []kyber.Point{K},
+		Beta:   []kyber.Point{C},
+		Proofs: []*RangeProof{proof},
+		Sum:    proof,
+	}}
+	assert.NotNil(t, VerifyVote(public, ballot, 1, 2, context))
+
+	// The ballot must be the sum of the vote.
+	ballot, err = NewVote(public, []bool{true, false}, 1, context)
+	require.Nil(t, err)
+	ballot.Alpha, ballot.Beta = ballot.Vote.Alpha[0], ballot.Vote.Beta[0]
+	assert.NotNil(t, VerifyVote(public, ballot, 2, 1, context))
+	ballot.Vote = nil
+	assert.NotNil(t, VerifyVote(public, ballot, 2, 1, context))
+}
+
+func TestElection_Sum(t *testing.T) {
+	secret, public := RandomKeyPair()
+	e := &Election{ID: []byte{1}, Key: public, Candidates: []uint32{1, 2}, MaxChoices: 1, Mode: Homomorphic}
+
+	var ballots []*Ballot
+	for i, choices := range [][]bool{{true, false}, {false, true}, {true, false}, {false, false}} {
+		user := string('a' + rune(i))
+		ballot, err := NewVote(public, choices, 1, VoteContext(e.ID, user))
+		require.Nil(t, err)
+		ballot.UserID = user
+		assert.Nil(t, e.VerifyBallot(ballot))
+		ballots = append(ballots, ballot)
+	}
+	assert.NotNil(t, e.VerifyBallot(&Ballot{UserID: "e", Alpha: public, Beta: public}))
+
+	sums := e.Sum(ballots)
+	require.Equal(t, 2, len(sums))
+	for i, expected := range []int{2, 1} {
+		votes, err := DiscreteLog(Decrypt(secret, sums[i].Alpha, sums[i].Beta), len(ballots))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, votes)
+	}
+
+	e.Mode = Mixnet
+	assert.NotNil(t, e.VerifyBallot(ballots[0]))
+}
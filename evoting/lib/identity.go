@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// The types of identity providers.
+const (
+	// IdentitySciper looks up EPFL's SCIPER numbers in the LDAP directory of
+	// EPFL. It is used when a master chain has no identity provider.
+	IdentitySciper = "sciper"
+	// IdentityRoll looks up the voters in a static voter roll.
+	IdentityRoll = "roll"
+	// IdentityLDAP looks up and authenticates the voters with an LDAP
+	// directory.
+	IdentityLDAP = "ldap"
+	// IdentityOIDC authenticates the voters with the ID tokens of an OpenID
+	// Connect provider.
+	IdentityOIDC = "oidc"
+)
+
+// Identity configures how the voters of a master chain are identified. Voters
+// are identified by opaque string IDs, whose meaning depends on the provider.
+type Identity struct {
+	Type string      // Type is one of the Identity* constants.
+	Roll []*Voter    // Roll is the voter roll of the roll provider.
+	LDAP *LDAPConfig // LDAP configures the ldap provider.
+	OIDC *OIDCConfig // OIDC configures the oidc provider.
+}
+
+// Voter is an entry of a voter roll.
+type Voter struct {
+	ID       string // ID identifies the voter.
+	FullName string // FullName is the name of the voter.
+	Email    string // Email is the email address of the voter.
+}
+
+// LDAPConfig tells where and how to look up the voters in an LDAP directory.
+// Empty attributes are set to their default value by SetDefaults.
+type LDAPConfig struct {
+	URL           string // URL of the server, ldap:// or ldaps://.
+	BaseDN        string // BaseDN is where the voters are searched.
+	ObjectClass   string // ObjectClass of the voters, "person" by default.
+	IDAttribute   string // IDAttribute holds the voter ID, "uid" by default.
+	NameAttribute string // NameAttribute holds the full name, "displayName" by default.
+	MailAttribute string // MailAttribute holds the email address, "mail" by default.
+}
+
+// OIDCConfig tells which ID tokens are accepted to authenticate the voters.
+type OIDCConfig struct {
+	Issuer   string // Issuer is the URL of the OpenID Connect provider.
+	ClientID string // ClientID is the audience of the ID tokens.
+	Claim    string // Claim holds the voter ID, "sub" by default.
+}
+
+// SciperLDAP is the LDAP directory of EPFL, used to look up SCIPER numbers.
+var SciperLDAP = LDAPConfig{
+	URL:           "ldaps://ldap.epfl.ch",
+	BaseDN:        "o=epfl, c=ch",
+	ObjectClass:   "person",
+	IDAttribute:   "uniqueIdentifier",
+	NameAttribute: "displayName",
+	MailAttribute: "mail",
+}
+
+// LegacyID returns the string ID of a voter identified by a SCIPER number,
+// as stored in the elections created before the voters had string IDs.
+func LegacyID(sciper uint32) string {
+	return strconv.FormatUint(uint64(sciper), 10)
+}
+
+// Check returns an error if the configuration is not usable.
+func (i *Identity) Check() error {
+	switch i.Type {
+	case IdentitySciper:
+		return nil
+	case IdentityRoll:
+		seen := make(map[string]bool)
+		for _, v := range i.Roll {
+			if v.ID == "" {
+				return errors.New("empty voter ID in the roll")
+			}
+			if seen[v.ID] {
+				return fmt.Errorf("voter %s is twice in the roll", v.ID)
+			}
+			seen[v.ID] = true
+		}
+		return nil
+	case IdentityLDAP:
+		if i.LDAP == nil {
+			return errors.New("missing ldap configuration")
+		}
+		u, err := url.Parse(i.LDAP.URL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+			return errors.New("the URL must be an ldap:// or ldaps:// URL")
+		}
+		if i.LDAP.BaseDN == "" {
+			return errors.New("missing base DN")
+		}
+		return nil
+	case IdentityOIDC:
+		if i.OIDC == nil {
+			return errors.New("missing oidc configuration")
+		}
+		if i.OIDC.Issuer == "" || i.OIDC.ClientID == "" {
+			return errors.New("missing issuer or client ID")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown identity provider %q", i.Type)
+}
+
+// SetDefaults sets the empty attributes to their default value.
+func (c *LDAPConfig) SetDefaults() {
+	if c.ObjectClass == "" {
+		c.ObjectClass = "person"
+	}
+	if c.IDAttribute == "" {
+		c.IDAttribute = "uid"
+	}
+	if c.NameAttribute == "" {
+		c.NameAttribute = "displayName"
+	}
+	if c.MailAttribute == "" {
+		c.MailAttribute = "mail"
+	}
+}
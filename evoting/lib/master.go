@@ -21,9 +21,12 @@ type Master struct {
 	ID     skipchain.SkipBlockID // ID is the hash of the genesis skipblock.
 	Roster *onet.Roster          // Roster is the set of responsible conodes.
 
-	Admins []uint32 // Admins is the list of administrators.
+	Admins []uint32 // Deprecated: use AdminIDs.
 
 	Key kyber.Point // Key is the front-end public key.
+
+	AdminIDs []string  // AdminIDs is the list of the IDs of the administrators.
+	Identity *Identity // Identity is the identity provider of the voters; optional.
 }
 
 // Link is a wrapper around the genesis Skipblock identifier of an
@@ -52,6 +55,7 @@ func GetMaster(s *skipchain.Service, id skipchain.SkipBlockID) (*Master, error)
 			continue
 		}
 		if transaction.Master != nil {
+			transaction.Master.Migrate()
 			return transaction.Master, nil
 		}
 		block = s.GetDB().GetByID(block.BackLinkIDs[0])
@@ -87,11 +91,29 @@ func (m *Master) Links(s *skipchain.Service) ([]*Link, error) {
 }
 
 // IsAdmin checks if a given user is part of the administrator list.
-func (m *Master) IsAdmin(user uint32) bool {
-	for _, admin := range m.Admins {
+func (m *Master) IsAdmin(user string) bool {
+	for _, admin := range m.AdminIDs {
 		if admin == user {
 			return true
 		}
 	}
 	return false
 }
+
+// Migrate converts the SCIPER numbers of the administrators of a master
+// created before the users were identified by strings to user IDs.
+func (m *Master) Migrate() {
+	for _, u := range m.Admins {
+		m.AdminIDs = append(m.AdminIDs, LegacyID(u))
+	}
+	m.Admins = nil
+}
+
+// IdentityProvider returns the configuration of the identity provider of the
+// voters, which is EPFL's SCIPER directory if none is set.
+func (m *Master) IdentityProvider() *Identity {
+	if m.Identity == nil {
+		return &Identity{Type: IdentitySciper}
+	}
+	return m.Identity
+}
@@ -7,7 +7,37 @@ import (
 )
 
 func TestIsAdmin(t *testing.T) {
-	m := &Master{Admins: []uint32{0}}
-	assert.True(t, m.IsAdmin(0))
-	assert.False(t, m.IsAdmin(1))
+	m := &Master{AdminIDs: []string{"0"}}
+	assert.True(t, m.IsAdmin("0"))
+	assert.False(t, m.IsAdmin("1"))
+}
+
+func TestMaster_Migrate(t *testing.T) {
+	m := &Master{Admins: []uint32{123456}}
+	m.Migrate()
+	assert.True(t, m.IsAdmin("123456"))
+	assert.Nil(t, m.Admins)
+	assert.Equal(t, IdentitySciper, m.IdentityProvider().Type)
+}
+
+func TestIdentity_Check(t *testing.T) {
+	assert.NoError(t, (&Identity{Type: IdentitySciper}).Check())
+	assert.Error(t, (&Identity{Type: "tequila"}).Check())
+
+	roll := &Identity{Type: IdentityRoll, Roll: []*Voter{{ID: "alice"}, {ID: "bob"}}}
+	assert.NoError(t, roll.Check())
+	roll.Roll = append(roll.Roll, &Voter{ID: "alice"})
+	assert.Error(t, roll.Check())
+
+	ldap := &Identity{Type: IdentityLDAP}
+	assert.Error(t, ldap.Check())
+	ldap.LDAP = &LDAPConfig{URL: "https://example.com", BaseDN: "dc=example,dc=com"}
+	assert.Error(t, ldap.Check())
+	ldap.LDAP.URL = "ldaps://ldap.example.com"
+	assert.NoError(t, ldap.Check())
+
+	oidc := &Identity{Type: IdentityOIDC, OIDC: &OIDCConfig{Issuer: "https://accounts.example.com"}}
+	assert.Error(t, oidc.Check())
+	oidc.OIDC.ClientID = "evoting"
+	assert.NoError(t, oidc.Check())
 }
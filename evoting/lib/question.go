@@ -0,0 +1,282 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/kyber/v3"
+
+	"go.dedis.ch/cothority/v3"
+)
+
+// QuestionType is the way a question of an election is answered.
+type QuestionType uint32
+
+const (
+	// SingleChoice depicts a question answered with at most one candidate
+	SingleChoice QuestionType = iota + 1
+	// Approval depicts a question answered with up to MaxChoices candidates
+	Approval
+	// Ranked depicts a question answered with up to MaxChoices candidates in
+	// order of preference
+	Ranked
+)
+
+// RankingMethod is the way the answers to a ranked question are counted.
+type RankingMethod uint32
+
+const (
+	// InstantRunoff eliminates the candidate with the fewest first
+	// preferences until one of them has a majority
+	InstantRunoff RankingMethod = iota + 1
+	// Borda gives to every candidate one point per candidate ranked after it
+	Borda
+)
+
+// Question is a question of a multi-question election. An answer is a list
+// of candidate indices, encrypted in a single ciphertext.
+type Question struct {
+	Title      string        // Title of the question.
+	Type       QuestionType  // Type is how the question is answered.
+	Candidates []string      // Candidates are the possible answers.
+	MaxChoices int           // MaxChoices is the max candidates chosen or ranked in an answer.
+	Method     RankingMethod // Method counts the answers of a ranked question.
+}
+
+// Result is the tally of a question.
+type Result struct {
+	// Counts are the votes of each candidate, their Borda points, or their
+	// votes in the last round of an instant-runoff count.
+	Counts  []int
+	Rounds  []*Round // Rounds of an instant-runoff count.
+	Winner  int      // Winner is the index of the winning candidate, -1 if none.
+	Invalid int      // Invalid is the number of answers that could not be counted.
+}
+
+// Round is a round of an instant-runoff count.
+type Round struct {
+	Counts     []int // Counts are the votes of the candidates still running.
+	Eliminated int   // Eliminated is the candidate eliminated after the round, -1 if none.
+}
+
+// Check returns an error if the question cannot be asked.
+func (q *Question) Check() error {
+	if len(q.Candidates) < 2 || len(q.Candidates) > 256 {
+		return errors.New("a question needs between 2 and 256 candidates")
+	}
+	switch q.Type {
+	case SingleChoice:
+		return nil
+	case Approval, Ranked:
+		if q.MaxChoices < 1 || q.MaxChoices > len(q.Candidates) {
+			return errors.New("invalid max choices")
+		}
+		if q.MaxChoices > cothority.Suite.Point().EmbedLen() {
+			return fmt.Errorf("at most %d choices fit in a ballot", cothority.Suite.Point().EmbedLen())
+		}
+		if q.Type == Ranked && q.Method != InstantRunoff && q.Method != Borda {
+			return fmt.Errorf("unknown ranking method %d", q.Method)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown question type %d", q.Type)
+}
+
+// maxChoices returns the max number of candidates in an answer.
+func (q *Question) maxChoices() int {
+	if q.Type == SingleChoice {
+		return 1
+	}
+	return q.MaxChoices
+}
+
+// Encode checks an answer, the indices of the chosen candidates, and returns
+// its encoding, one byte per candidate.
+func (q *Question) Encode(answer []int) ([]byte, error) {
+	if len(answer) > q.maxChoices() {
+		return nil, fmt.Errorf("%d choices, at most %d are allowed", len(answer), q.maxChoices())
+	}
+	data := make([]byte, len(answer))
+	seen := make(map[int]bool)
+	for i, c := range answer {
+		if c < 0 || c >= len(q.Candidates) {
+			return nil, fmt.Errorf("unknown candidate %d", c)
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("candidate %d is chosen twice", c)
+		}
+		seen[c] = true
+		data[i] = byte(c)
+	}
+	return data, nil
+}
+
+// Decode returns the answer encoded in data.
+func (q *Question) Decode(data []byte) ([]int, error) {
+	answer := make([]int, len(data))
+	for i, b := range data {
+		answer[i] = int(b)
+	}
+	if _, err := q.Encode(answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// Tally decrypts the answers to the question from the decrypted points and
+// counts them. Answers that cannot be decoded are counted as invalid. Ties are
+// broken in favour of the candidate with the lowest index, so that the tally
+// is deterministic.
+func (q *Question) Tally(points []kyber.Point) *Result {
+	var answers [][]int
+	var invalid int
+	for _, p := range points {
+		data, err := p.Data()
+		if err != nil {
+			invalid++
+			continue
+		}
+		answer, err := q.Decode(data)
+		if err != nil {
+			invalid++
+			continue
+		}
+		answers = append(answers, answer)
+	}
+	return q.count(answers, invalid)
+}
+
+// count counts valid answers.
+func (q *Question) count(answers [][]int, invalid int) *Result {
+	r := &Result{Counts: make([]int, len(q.Candidates)), Winner: -1, Invalid: invalid}
+	switch {
+	case q.Type == Ranked && q.Method == InstantRunoff:
+		q.instantRunoff(r, answers)
+		return r
+	case q.Type == Ranked && q.Method == Borda:
+		for _, answer := range answers {
+			for i, c := range answer {
+				r.Counts[c] += len(q.Candidates) - 1 - i
+			}
+		}
+	default:
+		for _, answer := range answers {
+			for _, c := range answer {
+				r.Counts[c]++
+			}
+		}
+	}
+	for c, count := range r.Counts {
+		if count > 0 && (r.Winner == -1 || count > r.Counts[r.Winner]) {
+			r.Winner = c
+		}
+	}
+	return r
+}
+
+// instantRunoff counts the first preferences among the candidates still
+// running, and eliminates the one with the fewest votes until a candidate has
+// a majority of the votes.
+func (q *Question) instantRunoff(r *Result, answers [][]int) {
+	running := make([]bool, len(q.Candidates))
+	for c := range running {
+		running[c] = true
+	}
+	for left := len(running); left > 0; left-- {
+		round := &Round{Counts: make([]int, len(q.Candidates)), Eliminated: -1}
+		r.Rounds = append(r.Rounds, round)
+		var total int
+		for _, answer := range answers {
+			for _, c := range answer {
+				if running[c] {
+					round.Counts[c]++
+					total++
+					break
+				}
+			}
+		}
+		r.Counts = round.Counts
+		if total == 0 {
+			return
+		}
+
+		best, worst := -1, -1
+		for c, count := range round.Counts {
+			if !running[c] {
+				continue
+			}
+			if best == -1 || count > round.Counts[best] {
+				best = c
+			}
+			if worst == -1 || count <= round.Counts[worst] {
+				worst = c
+			}
+		}
+		if 2*round.Counts[best] > total || left == 1 {
+			r.Winner = best
+			return
+		}
+		round.Eliminated = worst
+		running[worst] = false
+	}
+}
+
+// EncryptAnswers encrypts the answers to the questions of a multi-question
+// election in a ballot, one ciphertext per question.
+func (e *Election) EncryptAnswers(answers [][]int) (*Ballot, error) {
+	ballot, _, err := e.AuditableAnswers(answers)
+	return ballot, err
+}
+
+// Flatten returns the ciphertexts of the ballots to shuffle: the ballots
+// themselves, or in a multi-question election, the answers to the first
+// question of every ballot, followed by the answers to the second question,
+// and so on.
+func (e *Election) Flatten(ballots []*Ballot) []*Ballot {
+	if len(e.Questions) == 0 {
+		return ballots
+	}
+	flat := make([]*Ballot, 0, len(ballots)*len(e.Questions))
+	for i := range e.Questions {
+		for _, b := range ballots {
+			// Ballots are verified when they are cast.
+			answer := &Ballot{Alpha: cothority.Suite.Point().Null(), Beta: cothority.Suite.Point().Null()}
+			if i < len(b.Alphas) && i < len(b.Betas) {
+				answer.Alpha, answer.Beta = b.Alphas[i], b.Betas[i]
+			}
+			flat = append(flat, answer)
+		}
+	}
+	return flat
+}
+
+// Columns splits flattened ciphertexts into the ciphertexts of each question,
+// which are shuffled separately. Without questions, there is a single column.
+func (e *Election) Columns(ballots []*Ballot) [][]*Ballot {
+	if len(e.Questions) == 0 {
+		return [][]*Ballot{ballots}
+	}
+	n := len(ballots) / len(e.Questions)
+	columns := make([][]*Ballot, len(e.Questions))
+	for i := range columns {
+		columns[i] = ballots[i*n : (i+1)*n]
+	}
+	return columns
+}
+
+// Tally decodes the decrypted answers of a multi-question election, in the
+// order of the flattened ciphertexts, and counts the answers to each question.
+func (e *Election) Tally(points []kyber.Point) ([]*Result, error) {
+	if len(e.Questions) == 0 {
+		return nil, errors.New("election has no questions")
+	}
+	if len(points)%len(e.Questions) != 0 {
+		return nil, errors.New("the points don't match the questions")
+	}
+	n := len(points) / len(e.Questions)
+	results := make([]*Result, len(e.Questions))
+	for i, q := range e.Questions {
+		results[i] = q.Tally(points[i*n : (i+1)*n])
+	}
+	return results, nil
+}
@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+
+	"go.dedis.ch/cothority/v3"
+)
+
+func TestQuestion_Check(t *testing.T) {
+	candidates := []string{"a", "b", "c"}
+	assert.Nil(t, (&Question{Type: SingleChoice, Candidates: candidates}).Check())
+	assert.Nil(t, (&Question{Type: Approval, Candidates: candidates, MaxChoices: 3}).Check())
+	assert.Nil(t, (&Question{Type: Ranked, Candidates: candidates, MaxChoices: 2, Method: Borda}).Check())
+
+	assert.NotNil(t, (&Question{Type: SingleChoice, Candidates: candidates[:1]}).Check())
+	assert.NotNil(t, (&Question{Type: Approval, Candidates: candidates, MaxChoices: 4}).Check())
+	assert.NotNil(t, (&Question{Type: Ranked, Candidates: candidates, MaxChoices: 3}).Check())
+	assert.NotNil(t, (&Question{Candidates: candidates}).Check())
+
+	many := make([]string, 40)
+	assert.NotNil(t, (&Question{Type: Approval, Candidates: many, MaxChoices: 40}).Check())
+}
+
+func TestQuestion_Encode(t *testing.T) {
+	q := &Question{Type: Ranked, Candidates: []string{"a", "b", "c"}, MaxChoices: 2, Method: InstantRunoff}
+	data, err := q.Encode([]int{2, 0})
+	assert.Nil(t, err)
+	answer, err := q.Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 0}, answer)
+
+	_, err = q.Encode([]int{0, 1, 2})
+	assert.NotNil(t, err)
+	_, err = q.Encode([]int{1, 1})
+	assert.NotNil(t, err)
+	_, err = q.Encode([]int{3})
+	assert.NotNil(t, err)
+
+	q = &Question{Type: SingleChoice, Candidates: []string{"yes", "no"}}
+	_, err = q.Encode([]int{0, 1})
+	assert.NotNil(t, err)
+	data, err = q.Encode(nil)
+	assert.Nil(t, err)
+	answer, err = q.Decode(data)
+	assert.Nil(t, err)
+	assert.Empty(t, answer)
+}
+
+func TestQuestion_Count(t *testing.T) {
+	q := &Question{Type: Approval, Candidates: []string{"a", "b", "c"}, MaxChoices: 2}
+	r := q.count([][]int{{0, 1}, {1}, {2, 1}, {}}, 1)
+	assert.Equal(t, []int{1, 3, 1}, r.Counts)
+	assert.Equal(t, 1, r.Winner)
+	assert.Equal(t, 1, r.Invalid)
+
+	// Ties are won by the lowest index.
+	q = &Question{Type: SingleChoice, Candidates: []string{"yes", "no"}}
+	r = q.count([][]int{{1}, {0}}, 0)
+	assert.Equal(t, 0, r.Winner)
+	r = q.count(nil, 0)
+	assert.Equal(t, -1, r.Winner)
+
+	q = &Question{Type: Ranked, Candidates: []string{"a", "b", "c"}, MaxChoices: 3, Method: Borda}
+	r = q.count([][]int{{0, 1, 2}, {1, 2}, {1, 0}}, 0)
+	assert.Equal(t, []int{3, 5, 1}, r.Counts)
+	assert.Equal(t, 1, r.Winner)
+}
+
+func TestQuestion_InstantRunoff(t *testing.T) {
+	q := &Question{Type: Ranked, Candidates: []string{"a", "b", "c", "d"}, MaxChoices: 4, Method: InstantRunoff}
+	answers := [][]int{
+		{0, 1}, {0, 1}, {0},
+		{1, 2}, {1, 2},
+		{2, 1}, {2, 1},
+		{3, 2},
+	}
+	r := q.count(answers, 0)
+	require.Len(t, r.Rounds, 3)
+	assert.Equal(t, []int{3, 2, 2, 1}, r.Rounds[0].Counts)
+	assert.Equal(t, 3, r.Rounds[0].Eliminated)
+	assert.Equal(t, []int{3, 2, 3, 0}, r.Rounds[1].Counts)
+	assert.Equal(t, 1, r.Rounds[1].Eliminated)
+	assert.Equal(t, []int{3, 0, 5, 0}, r.Rounds[2].Counts)
+	assert.Equal(t, -1, r.Rounds[2].Eliminated)
+	assert.Equal(t, r.Rounds[2].Counts, r.Counts)
+	assert.Equal(t, 2, r.Winner)
+
+	// Among the tied candidates, the highest index is eliminated.
+	r = q.count([][]int{{0}, {1}, {2}, {2}, {3, 0}}, 0)
+	require.Len(t, r.Rounds, 4)
+	assert.Equal(t, []int{1, 1, 2, 1}, r.Rounds[0].Counts)
+	assert.Equal(t, 3, r.Rounds[0].Eliminated)
+	assert.Equal(t, 1, r.Rounds[1].Eliminated)
+	assert.Equal(t, []int{2, 0, 2, 0}, r.Rounds[2].Counts)
+	assert.Equal(t, 2, r.Rounds[2].Eliminated)
+	assert.Equal(t, 0, r.Winner)
+
+	// A majority in the first round wins at once.
+	r = q.count([][]int{{3}, {3, 0}, {1}}, 0)
+	assert.Len(t, r.Rounds, 1)
+	assert.Equal(t, 3, r.Winner)
+}
+
+func TestElection_Questions(t *testing.T) {
+	secret, public := RandomKeyPair()
+	pair := key.NewKeyPair(cothority.Suite)
+	node := network.NewServerIdentity(pair.Public, network.NewAddress(network.Local, "127.0.0.1:2000"))
+	e := &Election{
+		Roster: onet.NewRoster([]*network.ServerIdentity{node}),
+		Key:    public,
+		Questions: []*Question{
+			{Type: SingleChoice, Candidates: []string{"yes", "no"}},
+			{Type: Ranked, Candidates: []string{"a", "b", "c"}, MaxChoices: 3, Method: InstantRunoff},
+		},
+	}
+	var ballots []*Ballot
+	for _, answers := range [][][]int{
+		{{0}, {2, 1}},
+		{{1}, {1, 2, 0}},
+		{{0}, {2}},
+	} {
+		ballot, err := e.EncryptAnswers(answers)
+		require.Nil(t, err)
+		assert.Nil(t, e.VerifyBallot(ballot))
+		ballots = append(ballots, ballot)
+	}
+	_, err := e.EncryptAnswers([][]int{{0, 1}, {}})
+	assert.NotNil(t, err)
+	assert.NotNil(t, e.VerifyBallot(&Ballot{Alphas: ballots[0].Alphas[:1], Betas: ballots[0].Betas[:1]}))
+
+	flat := e.Flatten(ballots)
+	require.Len(t, flat, 6)
+	assert.Equal(t, ballots[1].Alphas[1], flat[4].Alpha)
+
+	mix, err := e.Shuffle(flat)
+	require.Nil(t, err)
+	data, _ := pair.Public.MarshalBinary()
+	mix.NodeID = node.ID
+	mix.Signature, _ = schnorr.Sign(cothority.Suite, pair.Private, data)
+	assert.Len(t, mix.Proofs, 2)
+	assert.Nil(t, e.VerifyMix(mix, flat))
+	// The questions are shuffled separately.
+	swapped := *mix
+	swapped.Ballots = append([]*Ballot{}, mix.Ballots...)
+	swapped.Ballots[0], swapped.Ballots[3] = swapped.Ballots[3], swapped.Ballots[0]
+	assert.NotNil(t, e.VerifyMix(&swapped, flat))
+
+	points := make([]kyber.Point, len(mix.Ballots))
+	for i, b := range mix.Ballots {
+		points[i] = Decrypt(secret, b.Alpha, b.Beta)
+	}
+	// An undecodable answer is invalid.
+	points[5] = cothority.Suite.Point().Base()
+	results, err := e.Tally(points)
+	require.Nil(t, err)
+	assert.Equal(t, []int{2, 1}, results[0].Counts)
+	assert.Equal(t, 0, results[0].Winner)
+	assert.Equal(t, 1, results[1].Invalid)
+
+	// The results can be sent over the network.
+	buf, err := protobuf.Encode(results[1])
+	require.Nil(t, err)
+	decoded := &Result{}
+	require.Nil(t, protobuf.Decode(buf, decoded))
+	assert.Equal(t, results[1].Winner, decoded.Winner)
+	assert.Equal(t, len(results[1].Rounds), len(decoded.Rounds))
+}
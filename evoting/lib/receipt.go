@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"go.dedis.ch/kyber/v3"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+/*
+A voter can check that their ballot encrypts their choice and that it has been
+recorded in the ballot box:
+
+- Before casting a ballot, the voter can challenge the device having encrypted
+it, in the style of Benaloh. The device reveals the opening of the ballot, the
+randomness and the embedded plaintext of every ciphertext, which anyone can
+check with Election.Audit. An audited ballot reveals its content and must not
+be cast: the voter starts over with a new encryption, and casts a ballot that
+has not been challenged.
+
+- Once cast, the ballot is identified by its tracking code, the hash of its
+ciphertexts and of the block storing it. The tracking codes of all the cast
+ballots are published on the bulletin board of the election.
+*/
+
+// Opening reveals the randomness and the plaintexts of the ciphertexts of a
+// ballot: its Alpha and Beta, or its Alphas and Betas in a multi-question
+// election.
+type Opening struct {
+	Randomness []kyber.Scalar // Randomness are the ephemeral private keys, one per ciphertext.
+	Messages   []kyber.Point  // Messages are the embedded plaintexts, one per ciphertext.
+}
+
+// NewAuditableBallot encrypts the message in a ballot and returns its opening.
+func NewAuditableBallot(public kyber.Point, message []byte) (*Ballot, *Opening) {
+	K, C, k, M := encrypt(public, message)
+	return &Ballot{Alpha: K, Beta: C}, &Opening{
+		Randomness: []kyber.Scalar{k},
+		Messages:   []kyber.Point{M},
+	}
+}
+
+// AuditableAnswers encrypts the answers to the questions of a multi-question
+// election in a ballot and returns its opening.
+func (e *Election) AuditableAnswers(answers [][]int) (*Ballot, *Opening, error) {
+	if len(answers) != len(e.Questions) {
+		return nil, nil, fmt.Errorf("%d answers for %d questions", len(answers), len(e.Questions))
+	}
+	ballot, opening := &Ballot{}, &Opening{}
+	for i, q := range e.Questions {
+		data, err := q.Encode(answers[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("question %d: %v", i, err)
+		}
+		K, C, k, M := encrypt(e.Key, data)
+		ballot.Alphas = append(ballot.Alphas, K)
+		ballot.Betas = append(ballot.Betas, C)
+		opening.Randomness = append(opening.Randomness, k)
+		opening.Messages = append(opening.Messages, M)
+	}
+	return ballot, opening, nil
+}
+
+// Audit checks that the opening matches the ciphertexts of the ballot and
+// returns their plaintexts: the message of a ballot, or the encoded answers to
+// each question of a multi-question election. The ballots of a homomorphic
+// election cannot be audited.
+func (e *Election) Audit(ballot *Ballot, opening *Opening) ([][]byte, error) {
+	if e.Mode == Homomorphic {
+		return nil, errors.New("homomorphic ballots cannot be audited")
+	}
+	if ballot == nil || opening == nil {
+		return nil, errors.New("missing ballot or opening")
+	}
+	alphas, betas := []kyber.Point{ballot.Alpha}, []kyber.Point{ballot.Beta}
+	if len(e.Questions) > 0 {
+		alphas, betas = ballot.Alphas, ballot.Betas
+	}
+	if len(betas) != len(alphas) || len(opening.Randomness) != len(alphas) ||
+		len(opening.Messages) != len(alphas) {
+		return nil, errors.New("opening does not match the ballot")
+	}
+
+	plaintexts := make([][]byte, len(alphas))
+	for i := range alphas {
+		k, M := opening.Randomness[i], opening.Messages[i]
+		if alphas[i] == nil || betas[i] == nil || k == nil || M == nil {
+			return nil, errors.New("incomplete ballot or opening")
+		}
+		K := cothority.Suite.Point().Mul(k, nil)
+		C := cothority.Suite.Point().Mul(k, e.Key)
+		C.Add(C, M)
+		if !K.Equal(alphas[i]) || !C.Equal(betas[i]) {
+			return nil, fmt.Errorf("ciphertext %d does not match the opening", i)
+		}
+		data, err := M.Data()
+		if err != nil {
+			return nil, fmt.Errorf("ciphertext %d: %v", i, err)
+		}
+		plaintexts[i] = data
+	}
+	return plaintexts, nil
+}
+
+// Fingerprint returns the hash of the ciphertexts of the ballot. It does not
+// depend on the voter, so that it can be shown to the voter before the ballot
+// is cast.
+func (b *Ballot) Fingerprint() []byte {
+	h := sha256.New()
+	hashPoints(h, b.Alpha, b.Beta)
+	hashPoints(h, b.Alphas...)
+	hashPoints(h, b.Betas...)
+	if b.Vote != nil {
+		hashPoints(h, b.Vote.Alpha...)
+		hashPoints(h, b.Vote.Beta...)
+	}
+	return h.Sum(nil)
+}
+
+// TrackingCode returns the tracking code of a ballot stored in the given
+// block, the hash of the fingerprint of the ballot and of the block ID.
+func TrackingCode(ballot *Ballot, block skipchain.SkipBlockID) []byte {
+	h := sha256.New()
+	h.Write(ballot.Fingerprint())
+	h.Write(block)
+	return h.Sum(nil)
+}
+
+// hashPoints writes the number of points and the points to the hash.
+func hashPoints(h hash.Hash, points ...kyber.Point) {
+	binary.Write(h, binary.LittleEndian, uint32(len(points)))
+	for _, P := range points {
+		if P == nil {
+			h.Write([]byte{0})
+			continue
+		}
+		h.Write([]byte{1})
+		P.MarshalTo(h)
+	}
+}
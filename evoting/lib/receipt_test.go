@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3"
+
+	"go.dedis.ch/cothority/v3"
+)
+
+func TestElection_Audit(t *testing.T) {
+	_, public := RandomKeyPair()
+	e := &Election{Key: public}
+
+	ballot, opening := NewAuditableBallot(public, []byte{1, 2, 3})
+	plaintexts, err := e.Audit(ballot, opening)
+	require.Nil(t, err)
+	assert.Equal(t, [][]byte{{1, 2, 3}}, plaintexts)
+
+	// The opening of another ballot, or of another message, is refused.
+	other, otherOpening := NewAuditableBallot(public, []byte{1, 2, 3})
+	_, err = e.Audit(other, opening)
+	assert.NotNil(t, err)
+	otherOpening.Messages[0] = cothority.Suite.Point().Embed([]byte{4}, cothority.Suite.RandomStream())
+	_, err = e.Audit(other, otherOpening)
+	assert.NotNil(t, err)
+	_, err = e.Audit(ballot, &Opening{})
+	assert.NotNil(t, err)
+	_, err = e.Audit(ballot, &Opening{Randomness: []kyber.Scalar{nil}, Messages: []kyber.Point{nil}})
+	assert.NotNil(t, err)
+
+	e.Questions = []*Question{
+		{Type: SingleChoice, Candidates: []string{"yes", "no"}},
+		{Type: Approval, Candidates: []string{"a", "b", "c"}, MaxChoices: 2},
+	}
+	ballot, opening, err = e.AuditableAnswers([][]int{{1}, {0, 2}})
+	require.Nil(t, err)
+	plaintexts, err = e.Audit(ballot, opening)
+	require.Nil(t, err)
+	assert.Equal(t, [][]byte{{1}, {0, 2}}, plaintexts)
+
+	e.Mode = Homomorphic
+	_, err = e.Audit(ballot, opening)
+	assert.NotNil(t, err)
+}
+
+func TestTrackingCode(t *testing.T) {
+	_, public := RandomKeyPair()
+	ballot, _ := NewAuditableBallot(public, []byte{1})
+	other, _ := NewAuditableBallot(public, []byte{1})
+
+	// The fingerprint only depends on the ciphertexts.
+	copied := *ballot
+	copied.UserID = "alice"
+	assert.Equal(t, ballot.Fingerprint(), copied.Fingerprint())
+	assert.NotEqual(t, ballot.Fingerprint(), other.Fingerprint())
+	assert.NotEqual(t, ballot.Fingerprint(), (&Ballot{Alphas: []kyber.Point{ballot.Alpha}, Betas: []kyber.Point{ballot.Beta}}).Fingerprint())
+
+	code := TrackingCode(ballot, []byte{1})
+	assert.Len(t, code, 32)
+	assert.Equal(t, code, TrackingCode(&copied, []byte{1}))
+	assert.NotEqual(t, code, TrackingCode(ballot, []byte{2}))
+	assert.NotEqual(t, code, TrackingCode(other, []byte{1}))
+}
@@ -9,8 +9,6 @@ import (
 	"sort"
 	"time"
 
-	"go.dedis.ch/kyber/v3"
-	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 	"go.dedis.ch/protobuf"
@@ -39,8 +37,10 @@ type Transaction struct {
 	Mix      *Mix
 	Partial  *Partial
 
-	User      uint32
+	User      uint32 // Deprecated: use UserID.
 	Signature []byte
+
+	UserID string // UserID identifies the user who sent the transaction.
 }
 
 // UnmarshalTransaction decodes a data blob to a transaction structure.
@@ -58,8 +58,8 @@ func UnmarshalTransaction(data []byte) *Transaction {
 }
 
 // NewTransaction constructs a new transaction for the given arguments.
-func NewTransaction(data interface{}, user uint32) *Transaction {
-	transaction := &Transaction{User: user}
+func NewTransaction(data interface{}, user string) *Transaction {
+	transaction := &Transaction{UserID: user}
 	switch data.(type) {
 	case *Master:
 		transaction.Master = data.(*Master)
@@ -102,6 +102,7 @@ func (t *Transaction) Hash() []byte {
 
 		// finally hash the user id in the tx
 		binary.Write(h, binary.LittleEndian, t.User)
+		h.Write([]byte(t.UserID))
 
 	} else {
 		sig := t.Signature
@@ -125,8 +126,18 @@ func hashMap(h io.Writer, m map[string]string) {
 	}
 }
 
+// user returns the ID of the user of the transaction, also for transactions
+// created before the users were identified by strings.
+func (t *Transaction) user() string {
+	if t.UserID != "" {
+		return t.UserID
+	}
+	return LegacyID(t.User)
+}
+
 // Verify checks that the corresponding transaction is valid before storing it.
 func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service) error {
+	user := t.user()
 	if t.Master != nil {
 		// Find the current master in order to compare against it.
 		m, err := GetMaster(s, genesis)
@@ -135,7 +146,7 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 			return nil
 		}
 
-		if !m.IsAdmin(t.User) {
+		if !m.IsAdmin(user) {
 			return errors.New("current user was not in previous admin list")
 		}
 
@@ -147,9 +158,14 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		// All the other fields (admin list, roster, and front end key) may change, but
 		// let's apply some sanity checks to them.
 
-		if len(t.Master.Admins) == 0 {
+		if len(t.Master.Admins) == 0 && len(t.Master.AdminIDs) == 0 {
 			return errors.New("empty admin list in master update")
 		}
+		if t.Master.Identity != nil {
+			if err := t.Master.Identity.Check(); err != nil {
+				return fmt.Errorf("invalid identity provider in master update: %v", err)
+			}
+		}
 		if len(t.Master.Roster.List) == 0 {
 			return errors.New("empty roster in master update")
 		}
@@ -168,7 +184,7 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 			return err
 		}
 
-		if !master.IsAdmin(t.User) {
+		if !master.IsAdmin(user) {
 			return errors.New("link error: user not admin")
 		}
 		return nil
@@ -182,26 +198,27 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		if err != nil {
 			return err
 		}
-		if !master.IsAdmin(t.User) {
+		if !master.IsAdmin(user) {
 			return errors.New("open error: user not admin")
 		}
+		if err := election.Check(); err != nil {
+			return fmt.Errorf("open error: %v", err)
+		}
 		return nil
 	} else if t.Ballot != nil {
-		null := cothority.Suite.Point().Null()
-		if t.Ballot.Alpha == nil || t.Ballot.Beta == nil {
-			return errors.New("alpha and beta must be non-nil")
-		}
-		if t.Ballot.Alpha.Equal(null) || t.Ballot.Beta.Equal(null) {
-			return errors.New("alpha and beta must be null points")
+		if err := t.Ballot.Check(); err != nil {
+			return err
 		}
 
-		// t.User is trusted at this point, so make sure that they did not try to sneak
+		// The user is trusted at this point, so make sure that they did not try to sneak
 		// through a different user-id in the ballot.
-		if t.User != t.Ballot.User {
+		ballot := *t.Ballot
+		ballot.Migrate()
+		if user != ballot.UserID {
 			return errors.New("ballot user-id differs from transaction user-id")
 		}
 
-		election, err := GetElection(s, genesis, false, t.User)
+		election, err := GetElection(s, genesis, false, user)
 		if err != nil {
 			return fmt.Errorf("could not get election: %v", err)
 		}
@@ -223,32 +240,30 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		}
 		if transaction.Mix != nil || transaction.Partial != nil {
 			return errors.New("cast error: election not in running stage")
-		} else if !election.IsUser(t.User) {
+		} else if !election.IsUser(user) {
 			return errors.New("cast error: user not part")
 		}
+		if err := election.VerifyBallot(&ballot); err != nil {
+			return fmt.Errorf("cast error: %v", err)
+		}
 		return nil
 	} else if t.Mix != nil {
-		election, err := GetElection(s, genesis, false, t.User)
+		election, err := GetElection(s, genesis, false, user)
 		if err != nil {
 			return err
 		}
-		if !election.IsCreator(t.User) {
+		if !election.IsCreator(user) {
 			return errors.New("shuffle error: user is not election creator")
 		}
+		if election.Mode == Homomorphic {
+			return errors.New("shuffle error: homomorphic elections are not shuffled")
+		}
 
 		// verify proposer
 		_, proposer := election.Roster.Search(t.Mix.NodeID)
 		if proposer == nil {
 			return errors.New("didn't find signer in mix")
 		}
-		data, err := proposer.Public.MarshalBinary()
-		if err != nil {
-			return err
-		}
-		err = schnorr.Verify(cothority.Suite, proposer.Public, data, t.Mix.Signature)
-		if err != nil {
-			return err
-		}
 
 		mixes, err := election.Mixes(s)
 		if err != nil {
@@ -271,40 +286,31 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 		}
 
 		// check if Mix is valid
-		var x, y []kyber.Point
+		var ballots []*Ballot
 		if len(mixes) == 0 {
 			// verify against Boxes
 			boxes, err := election.Box(s)
 			if err != nil {
 				return err
 			}
-			x, y = Split(boxes.Ballots)
+			ballots = election.Flatten(boxes.Ballots)
 		} else {
 			// verify against the last mix
-			x, y = Split(mixes[len(mixes)-1].Ballots)
+			ballots = mixes[len(mixes)-1].Ballots
 		}
-		v, w := Split(t.Mix.Ballots)
-		err = Verify(t.Mix.Proof, election.Key, x, y, v, w)
-		if err != nil {
-			return err
-		}
-
-		return nil
+		return election.VerifyMix(t.Mix, ballots)
 	} else if t.Partial != nil {
-		election, err := GetElection(s, genesis, false, t.User)
+		election, err := GetElection(s, genesis, false, user)
 		if err != nil {
 			return err
 		}
-		if !election.IsCreator(t.User) {
+		if !election.IsCreator(user) {
 			return errors.New("decrypt error: user is not election creator")
 		}
 
-		mixes, err := election.Mixes(s)
-		target := 2 * len(election.Roster.List) / 3
+		ballots, err := election.Ciphertexts(s)
 		if err != nil {
-			return err
-		} else if len(mixes) <= target {
-			return errors.New("decrypt error: election not shuffled yet")
+			return fmt.Errorf("decrypt error: %v", err)
 		}
 		partials, err := election.Partials(s)
 
@@ -318,20 +324,8 @@ func (t *Transaction) Verify(genesis skipchain.SkipBlockID, s *skipchain.Service
 			}
 		}
 
-		// verify proposer
-		_, proposer := election.Roster.Search(t.Partial.NodeID)
-		if proposer == nil {
-			return errors.New("didn't find node who created the partial")
-		}
-		data, err := proposer.Public.MarshalBinary()
-		if err != nil {
-			return err
-		}
-		err = schnorr.Verify(cothority.Suite, proposer.Public, data, t.Partial.Signature)
-		if err != nil {
-			return err
-		}
-		return nil
+		// verify proposer and decryption proofs
+		return election.VerifyPartial(t.Partial, ballots)
 	}
 	return errors.New("transaction error: empty transaction")
 }
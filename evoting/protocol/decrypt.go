@@ -6,6 +6,7 @@ import (
 
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof/dleq"
 	"go.dedis.ch/kyber/v3/sign/schnorr"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/network"
@@ -37,7 +38,7 @@ const NameDecrypt = "decrypt"
 type Decrypt struct {
 	*onet.TreeNodeInstance
 
-	User uint32
+	User string // User who started the decryption.
 
 	Secret   *lib.SharedSecret // Secret is the private key share from the DKG.
 	Election *lib.Election     // Election to be decrypted.
@@ -68,7 +69,8 @@ func (d *Decrypt) Start() error {
 }
 
 // HandlePrompt retrieves the mixes, verifies them and performs a partial decryption
-// on the last mix before appending it to the election skipchain.
+// on the last mix before appending it to the election skipchain. In a homomorphic
+// election, the sums of the ballots are decrypted instead.
 // LG: rewrote this part to correctly call `Done` even if something fails.
 // There are three parts now:
 //  1. Verification of state - if this fails, it's over and `Done` is called
@@ -77,18 +79,14 @@ func (d *Decrypt) Start() error {
 //  3. Send the decryption block to the skipchain - also will have `Done`
 //   called if it fails
 func (d *Decrypt) HandlePrompt(prompt MessagePromptDecrypt) error {
-	var mixes []*lib.Mix
+	var ballots []*lib.Ballot
 	var partials []*lib.Partial
 	err := func() error {
 		var err error
-		mixes, err = d.Election.Mixes(d.Skipchain)
+		ballots, err = d.Election.Ciphertexts(d.Skipchain)
 		if err != nil {
 			return err
 		}
-		target := 2 * len(d.Election.Roster.List) / 3
-		if len(mixes) <= target {
-			return errors.New("Not enough mixes")
-		}
 		partials, err = d.Election.Partials(d.Skipchain)
 		return err
 	}()
@@ -100,10 +98,14 @@ func (d *Decrypt) HandlePrompt(prompt MessagePromptDecrypt) error {
 	var partial *lib.Partial
 	if !d.IsRoot() || d.LeaderParticipates {
 		err := func() error {
-			mix := mixes[len(mixes)-1]
-			points := make([]kyber.Point, len(mix.Ballots))
+			points := make([]kyber.Point, len(ballots))
+			proofs := make([]*dleq.Proof, len(ballots))
 			for i := range points {
-				points[i] = lib.Decrypt(d.Secret.V, mix.Ballots[i].Alpha, mix.Ballots[i].Beta)
+				var err error
+				points[i], proofs[i], err = lib.DecryptWithProof(d.Secret.V, ballots[i].Alpha, ballots[i].Beta)
+				if err != nil {
+					return d.SendTo(d.Root(), &TerminateDecrypt{Error: err.Error()})
+				}
 			}
 			index := -1
 			for i, node := range d.Election.Roster.List {
@@ -119,6 +121,7 @@ func (d *Decrypt) HandlePrompt(prompt MessagePromptDecrypt) error {
 			partial = &lib.Partial{
 				Points: points,
 				NodeID: d.ServerIdentity().ID,
+				Proofs: proofs,
 			}
 			data, err := d.ServerIdentity().Public.MarshalBinary()
 			if err != nil {
@@ -27,7 +27,7 @@ var decryptServiceID onet.ServiceID
 type decryptService struct {
 	*onet.ServiceProcessor
 
-	user      uint32
+	user      string
 	signature []byte
 
 	secret    *lib.SharedSecret
@@ -90,18 +90,18 @@ func runDecrypt(t *testing.T, n int) {
 	for i := range services {
 		services[i].(*decryptService).secret, _ = lib.NewSharedSecret(dkgs[i])
 		services[i].(*decryptService).election = election
-		services[i].(*decryptService).user = 0
+		services[i].(*decryptService).user = "0"
 		services[i].(*decryptService).signature = []byte{}
 	}
 
-	tx := lib.NewTransaction(election, election.Creator)
+	tx := lib.NewTransaction(election, lib.LegacyID(election.Creator))
 	lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 
 	ballots := make([]*lib.Ballot, 3)
 	for i := 0; i < 3; i++ {
 		a, b := lib.Encrypt(key, []byte{byte(i)})
 		ballots[i] = &lib.Ballot{User: uint32(i), Alpha: a, Beta: b}
-		tx = lib.NewTransaction(ballots[i], election.Creator)
+		tx = lib.NewTransaction(ballots[i], lib.LegacyID(election.Creator))
 		err := lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 		require.NoError(t, err)
 	}
@@ -120,7 +120,7 @@ func runDecrypt(t *testing.T, n int) {
 			NodeID:    roster.Get(i).ID,
 			Signature: sig,
 		}
-		tx = lib.NewTransaction(mix, election.Creator)
+		tx = lib.NewTransaction(mix, lib.LegacyID(election.Creator))
 		err := lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 		require.NoError(t, err)
 		x, y = v, w
@@ -129,7 +129,7 @@ func runDecrypt(t *testing.T, n int) {
 	instance, _ := services[0].(*decryptService).CreateProtocol(NameDecrypt, tree)
 	decrypt := instance.(*Decrypt)
 	decrypt.Secret, _ = lib.NewSharedSecret(dkgs[0])
-	decrypt.User = 0
+	decrypt.User = "0"
 	decrypt.Election = election
 	decrypt.Skipchain = services[0].(*decryptService).skipchain
 	decrypt.LeaderParticipates = true
@@ -176,18 +176,18 @@ func TestDecryptNodeFailure(t *testing.T) {
 	for i := range services {
 		services[i].(*decryptService).secret, _ = lib.NewSharedSecret(dkgs[i])
 		services[i].(*decryptService).election = election
-		services[i].(*decryptService).user = 0
+		services[i].(*decryptService).user = "0"
 		services[i].(*decryptService).signature = []byte{}
 	}
 
-	tx := lib.NewTransaction(election, election.Creator)
+	tx := lib.NewTransaction(election, lib.LegacyID(election.Creator))
 	lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 
 	ballots := make([]*lib.Ballot, 3)
 	for i := 0; i < 3; i++ {
 		a, b := lib.Encrypt(key, []byte{byte(i)})
 		ballots[i] = &lib.Ballot{User: uint32(i), Alpha: a, Beta: b}
-		tx = lib.NewTransaction(ballots[i], election.Creator)
+		tx = lib.NewTransaction(ballots[i], lib.LegacyID(election.Creator))
 		lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 	}
 
@@ -206,7 +206,7 @@ func TestDecryptNodeFailure(t *testing.T) {
 			Signature: sig,
 		}
 		mixes[i] = mix
-		tx = lib.NewTransaction(mix, election.Creator)
+		tx = lib.NewTransaction(mix, lib.LegacyID(election.Creator))
 		err := lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 		require.NoError(t, err)
 		x, y = v, w
@@ -230,7 +230,7 @@ func TestDecryptNodeFailure(t *testing.T) {
 		data = append(data, byte(index))
 		sig, _ := schnorr.Sign(cothority.Suite, local.GetPrivate(nodes[i]), data)
 		partial.Signature = sig
-		transaction := lib.NewTransaction(partial, election.Creator)
+		transaction := lib.NewTransaction(partial, lib.LegacyID(election.Creator))
 		lib.StoreUsingWebsocket(election.ID, election.Roster, transaction)
 	}
 
@@ -239,7 +239,7 @@ func TestDecryptNodeFailure(t *testing.T) {
 	instance, _ := services[0].(*decryptService).CreateProtocol(NameDecrypt, protocolTree)
 	decrypt := instance.(*Decrypt)
 	decrypt.Secret, _ = lib.NewSharedSecret(dkgs[0])
-	decrypt.User = 0
+	decrypt.User = "0"
 	decrypt.Election = election
 	decrypt.Skipchain = services[0].(*decryptService).skipchain
 	decrypt.LeaderParticipates = false
@@ -4,10 +4,7 @@ import (
 	"errors"
 
 	"go.dedis.ch/cothority/v3"
-	"go.dedis.ch/kyber/v3/proof"
-	"go.dedis.ch/kyber/v3/shuffle"
 	"go.dedis.ch/kyber/v3/sign/schnorr"
-	"go.dedis.ch/kyber/v3/util/random"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
@@ -40,7 +37,7 @@ const NameShuffle = "shuffle"
 type Shuffle struct {
 	*onet.TreeNodeInstance
 
-	User     uint32
+	User     string        // User who started the shuffle.
 	Election *lib.Election // Election to be shuffled.
 
 	Finished chan error // Flag to signal protocol termination.
@@ -92,7 +89,7 @@ func (s *Shuffle) HandlePrompt(prompt MessagePrompt) error {
 			if err != nil {
 				return err
 			}
-			ballots = box.Ballots
+			ballots = s.Election.Flatten(box.Ballots)
 		} else {
 			ballots = mixes[len(mixes)-1].Ballots
 		}
@@ -100,7 +97,7 @@ func (s *Shuffle) HandlePrompt(prompt MessagePrompt) error {
 		// base condition
 		target = 2 * len(s.Election.Roster.List) / 3
 
-		if len(ballots) < 2 {
+		if len(s.Election.Columns(ballots)[0]) < 2 {
 			if err := s.SendTo(s.Root(), &TerminateShuffle{
 				Error: "shuffle error: not enough (> 2) ballots to shuffle",
 			}); err != nil {
@@ -129,28 +126,12 @@ func (s *Shuffle) HandlePrompt(prompt MessagePrompt) error {
 			return nil
 		}
 
-		a, b := lib.Split(ballots)
-		// Protect from missing input.
-		for i := range a {
-			if a[i] == nil {
-				a[i] = cothority.Suite.Point().Null()
-			}
-		}
-		for i := range b {
-			if b[i] == nil {
-				b[i] = cothority.Suite.Point().Null()
-			}
-		}
-		g, d, prov := shuffle.Shuffle(cothority.Suite, nil, s.Election.Key, a, b, random.New())
-		proof, err := proof.HashProve(cothority.Suite, "", prov)
+		var err error
+		mix, err = s.Election.Shuffle(ballots)
 		if err != nil {
 			return err
 		}
-		mix = &lib.Mix{
-			Ballots: lib.Combine(g, d),
-			Proof:   proof,
-			NodeID:  s.ServerIdentity().ID,
-		}
+		mix.NodeID = s.ServerIdentity().ID
 		data, err := s.ServerIdentity().Public.MarshalBinary()
 		if err != nil {
 			return err
@@ -18,7 +18,7 @@ var shuffleServiceID onet.ServiceID
 
 type shuffleService struct {
 	*onet.ServiceProcessor
-	user      uint32
+	user      string
 	signature []byte
 	election  *lib.Election
 	skipchain *skipchain.Service
@@ -81,24 +81,24 @@ func TestShuffleNodeFailure(t *testing.T) {
 	}
 	for i := range services {
 		services[i].(*shuffleService).election = election
-		services[i].(*shuffleService).user = 0
+		services[i].(*shuffleService).user = "0"
 		services[i].(*shuffleService).signature = []byte{}
 	}
 
-	tx := lib.NewTransaction(election, election.Creator)
+	tx := lib.NewTransaction(election, lib.LegacyID(election.Creator))
 	lib.Store(services[0].(*shuffleService).skipchain, election.ID, tx, nil)
 
 	for i := 0; i < 3; i++ {
 		a, b := lib.Encrypt(key, []byte{byte(i)})
 		ballot := &lib.Ballot{User: uint32(i), Alpha: a, Beta: b}
-		tx = lib.NewTransaction(ballot, election.Creator)
+		tx = lib.NewTransaction(ballot, lib.LegacyID(election.Creator))
 		lib.Store(services[0].(*shuffleService).skipchain, election.ID, tx, nil)
 	}
 	nodes[3].Stop()
 
 	instance, _ := services[0].(*shuffleService).CreateProtocol(NameShuffle, tree)
 	shuffle := instance.(*Shuffle)
-	shuffle.User = 0
+	shuffle.User = "0"
 	shuffle.Election = election
 	shuffle.Skipchain = services[0].(*shuffleService).skipchain
 	shuffle.LeaderParticipates = true
@@ -142,23 +142,23 @@ func runShuffle(t *testing.T, n int) {
 	}
 	for i := range services {
 		services[i].(*shuffleService).election = election
-		services[i].(*shuffleService).user = 0
+		services[i].(*shuffleService).user = "0"
 		services[i].(*shuffleService).signature = []byte{}
 	}
 
-	tx := lib.NewTransaction(election, election.Creator)
+	tx := lib.NewTransaction(election, lib.LegacyID(election.Creator))
 	lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 
 	for i := 0; i < 3; i++ {
 		a, b := lib.Encrypt(key, []byte{byte(i)})
 		ballot := &lib.Ballot{User: uint32(i), Alpha: a, Beta: b}
-		tx = lib.NewTransaction(ballot, election.Creator)
+		tx = lib.NewTransaction(ballot, lib.LegacyID(election.Creator))
 		lib.StoreUsingWebsocket(election.ID, election.Roster, tx)
 	}
 
 	instance, _ := services[0].(*shuffleService).CreateProtocol(NameShuffle, tree)
 	shuffle := instance.(*Shuffle)
-	shuffle.User = 0
+	shuffle.User = "0"
 	shuffle.Election = election
 	shuffle.Skipchain = services[0].(*shuffleService).skipchain
 	shuffle.LeaderParticipates = true
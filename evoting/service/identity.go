@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc"
+	"github.com/go-ldap/ldap/v3"
+	"go.dedis.ch/cothority/v3/evoting"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+)
+
+// IdentityProvider looks up and authenticates the voters of a master
+// skipchain. Voters are identified by opaque string IDs.
+type IdentityProvider interface {
+	// Lookup returns the name and the email address of a voter.
+	Lookup(id string) (*evoting.LookupVoterReply, error)
+	// Authenticate checks the credential of a voter and returns their ID.
+	Authenticate(credential []byte) (string, error)
+}
+
+// NewIdentityProvider returns the identity provider for the configuration.
+func NewIdentityProvider(identity *lib.Identity) (IdentityProvider, error) {
+	if err := identity.Check(); err != nil {
+		return nil, err
+	}
+	switch identity.Type {
+	case lib.IdentitySciper:
+		return newLDAPProvider(lib.SciperLDAP), nil
+	case lib.IdentityRoll:
+		return newRollProvider(identity.Roll), nil
+	case lib.IdentityLDAP:
+		return newLDAPProvider(*identity.LDAP), nil
+	case lib.IdentityOIDC:
+		return &oidcProvider{config: *identity.OIDC}, nil
+	}
+	return nil, fmt.Errorf("unknown identity provider %q", identity.Type)
+}
+
+// rollProvider looks up the voters in a static voter roll. It cannot
+// authenticate them, so the front-end has to sign their IDs.
+type rollProvider struct {
+	voters map[string]*lib.Voter
+}
+
+func newRollProvider(roll []*lib.Voter) *rollProvider {
+	p := &rollProvider{voters: make(map[string]*lib.Voter)}
+	for _, v := range roll {
+		p.voters[v.ID] = v
+	}
+	return p
+}
+
+func (p *rollProvider) Lookup(id string) (*evoting.LookupVoterReply, error) {
+	v, ok := p.voters[id]
+	if !ok {
+		return nil, errors.New("voter not found")
+	}
+	return &evoting.LookupVoterReply{FullName: v.FullName, Email: v.Email}, nil
+}
+
+func (p *rollProvider) Authenticate(credential []byte) (string, error) {
+	return "", errors.New("the voter roll cannot authenticate voters")
+}
+
+// LDAPCredential is the credential of the ldap identity provider, sent as
+// JSON.
+type LDAPCredential struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// ldapProvider looks up the voters by their ID attribute in an LDAP
+// directory. They are authenticated by a simple bind with the DN of their
+// entry and their password.
+type ldapProvider struct {
+	config lib.LDAPConfig
+}
+
+func newLDAPProvider(config lib.LDAPConfig) *ldapProvider {
+	config.SetDefaults()
+	return &ldapProvider{config: config}
+}
+
+// search returns the entry of the voter.
+func (p *ldapProvider) search(conn *ldap.Conn, id string) (*ldap.Entry, error) {
+	if id == "" {
+		return nil, errors.New("empty voter ID")
+	}
+	sr, err := conn.Search(ldap.NewSearchRequest(
+		p.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=%s)(%s=%s))", ldap.EscapeFilter(p.config.ObjectClass),
+			p.config.IDAttribute, ldap.EscapeFilter(id)),
+		[]string{p.config.NameAttribute, p.config.MailAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	// If more than one are returned, we look only at the first one.
+	if len(sr.Entries) == 0 {
+		return nil, errors.New("voter not found")
+	}
+	return sr.Entries[0], nil
+}
+
+func (p *ldapProvider) Lookup(id string) (*evoting.LookupVoterReply, error) {
+	conn, err := ldap.DialURL(p.config.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := p.search(conn, id)
+	if err != nil {
+		return nil, err
+	}
+	return &evoting.LookupVoterReply{
+		FullName: entry.GetAttributeValue(p.config.NameAttribute),
+		Email:    entry.GetAttributeValue(p.config.MailAttribute),
+	}, nil
+}
+
+func (p *ldapProvider) Authenticate(credential []byte) (string, error) {
+	var lc LDAPCredential
+	if err := json.Unmarshal(credential, &lc); err != nil {
+		return "", fmt.Errorf("could not decode the credential: %v", err)
+	}
+	// An empty password would be an unauthenticated bind, which succeeds
+	// for any user.
+	if lc.Password == "" {
+		return "", errors.New("empty password")
+	}
+
+	conn, err := ldap.DialURL(p.config.URL)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	entry, err := p.search(conn, lc.User)
+	if err != nil {
+		return "", err
+	}
+	if err := conn.Bind(entry.DN, lc.Password); err != nil {
+		return "", fmt.Errorf("bind failed: %v", err)
+	}
+	return lc.User, nil
+}
+
+// oidcProvider authenticates the voters with the ID tokens of an OpenID
+// Connect provider, like the oidc validator of authprox. The voter ID is the
+// configured claim of the token. It cannot look up voters.
+type oidcProvider struct {
+	config   lib.OIDCConfig
+	verifier *oidc.IDTokenVerifier
+	sync.Mutex
+}
+
+func (p *oidcProvider) Lookup(id string) (*evoting.LookupVoterReply, error) {
+	return nil, errors.New("the oidc provider cannot look up voters")
+}
+
+// getVerifier returns the verifier of the ID tokens. The provider is only
+// contacted when the first token is verified.
+func (p *oidcProvider) getVerifier() (*oidc.IDTokenVerifier, error) {
+	p.Lock()
+	defer p.Unlock()
+	if p.verifier == nil {
+		provider, err := oidc.NewProvider(context.Background(), p.config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	}
+	return p.verifier, nil
+}
+
+func (p *oidcProvider) Authenticate(credential []byte) (string, error) {
+	v, err := p.getVerifier()
+	if err != nil {
+		return "", err
+	}
+	// The nonce of the token is not checked, this has to be done by the
+	// client that received the token.
+	token, err := v.Verify(context.Background(), string(credential))
+	if err != nil {
+		return "", err
+	}
+	if p.config.Claim == "" || p.config.Claim == "sub" {
+		return token.Subject, nil
+	}
+	claims := make(map[string]interface{})
+	if err := token.Claims(&claims); err != nil {
+		return "", fmt.Errorf("could not decode the claims: %v", err)
+	}
+	id, ok := claims[p.config.Claim].(string)
+	if !ok || id == "" {
+		return "", fmt.Errorf("missing claim %s", p.config.Claim)
+	}
+	return id, nil
+}
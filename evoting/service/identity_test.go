@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/evoting/lib"
+)
+
+func TestNewIdentityProvider(t *testing.T) {
+	_, err := NewIdentityProvider(&lib.Identity{Type: "tequila"})
+	require.Error(t, err)
+	_, err = NewIdentityProvider(&lib.Identity{Type: lib.IdentityOIDC})
+	require.Error(t, err)
+
+	p, err := NewIdentityProvider(&lib.Identity{Type: lib.IdentitySciper})
+	require.NoError(t, err)
+	require.Equal(t, lib.SciperLDAP, p.(*ldapProvider).config)
+
+	p, err = NewIdentityProvider(&lib.Identity{
+		Type: lib.IdentityLDAP,
+		LDAP: &lib.LDAPConfig{URL: "ldap://localhost", BaseDN: "dc=example,dc=com"},
+	})
+	require.NoError(t, err)
+	config := p.(*ldapProvider).config
+	require.Equal(t, "uid", config.IDAttribute)
+	require.Equal(t, "displayName", config.NameAttribute)
+	require.Equal(t, "mail", config.MailAttribute)
+
+	// The credential is checked before the server is contacted.
+	_, err = p.Authenticate([]byte("alice:secret"))
+	require.Error(t, err)
+	_, err = p.Authenticate([]byte(`{"user":"alice","password":""}`))
+	require.Error(t, err)
+}
+
+func TestRollProvider(t *testing.T) {
+	p := newRollProvider([]*lib.Voter{{ID: "alice", FullName: "Alice", Email: "alice@example.com"}})
+	reply, err := p.Lookup("alice")
+	require.NoError(t, err)
+	require.Equal(t, "Alice", reply.FullName)
+	require.Equal(t, "alice@example.com", reply.Email)
+	_, err = p.Lookup("bob")
+	require.Error(t, err)
+	_, err = p.Authenticate([]byte("alice"))
+	require.Error(t, err)
+}
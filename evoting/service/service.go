@@ -1,4 +1,6 @@
-// Package service is the evoting service designed for use at EPFL.
+// Package service is the evoting service designed for use at EPFL. The voters
+// are identified by opaque string IDs, which are looked up and authenticated
+// by the identity provider of the master skipchain.
 package service
 
 import (
@@ -19,13 +21,14 @@ import (
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
 
-	"github.com/go-ldap/ldap/v3"
 	"go.dedis.ch/cothority/v3"
 	dkgprotocol "go.dedis.ch/cothority/v3/dkg/rabin"
 	"go.dedis.ch/cothority/v3/evoting"
 	"go.dedis.ch/cothority/v3/evoting/lib"
 	"go.dedis.ch/cothority/v3/evoting/protocol"
+	"go.dedis.ch/cothority/v3/keystore"
 	"go.dedis.ch/cothority/v3/skipchain"
 )
 
@@ -56,8 +59,11 @@ type Service struct {
 	finalizeMutex sync.Mutex // used for protecting shuffle and decrypt operations
 	storage       *storage
 
-	sciperMu    sync.Mutex
-	sciperCache []cacheEntry
+	lookupMu    sync.Mutex
+	lookupCache []cacheEntry
+
+	providersMu sync.Mutex
+	providers   map[string]IdentityProvider
 
 	pin string // pin is the current service number.
 }
@@ -72,7 +78,7 @@ type storage struct {
 // synchronizer is broadcasted to all roster nodes before every protocol.
 type synchronizer struct {
 	ID   skipchain.SkipBlockID
-	User uint32
+	User string
 }
 
 // Ping message handler.
@@ -86,8 +92,15 @@ func (s *Service) Link(req *evoting.Link) (*evoting.LinkReply, error) {
 		return nil, errors.New("link error: invalid pin")
 	}
 
+	if req.Identity != nil {
+		if err := req.Identity.Check(); err != nil {
+			return nil, fmt.Errorf("link error: %v", err)
+		}
+	}
+
 	var id skipchain.SkipBlockID
-	var user uint32
+	var user string
+	identity := req.Identity
 
 	if req.ID != nil {
 		// Update an existing master chain
@@ -96,16 +109,34 @@ func (s *Service) Link(req *evoting.Link) (*evoting.LinkReply, error) {
 			return nil, err
 		}
 
-		if req.User == nil || req.Signature == nil {
+		var legacy uint32
+		if req.User != nil {
+			legacy = *req.User
+		}
+		var userID string
+		if req.UserID != nil {
+			userID = *req.UserID
+		}
+		var credential, sig []byte
+		if req.Credential != nil {
+			credential = *req.Credential
+		}
+		if req.Signature != nil {
+			sig = *req.Signature
+		}
+		if credential == nil && (sig == nil || req.User == nil && req.UserID == nil) {
 			return nil, errors.New("missing user or sig")
 		}
-		user = *req.User
 
-		err = auth(*req.User, *req.Signature, m.ID, m.Key)
+		user, err = s.authenticate(m, legacy, userID, credential, sig)
 		if err != nil {
 			return nil, err
 		}
 		id = m.ID
+		// Keep the identity provider if none is given.
+		if identity == nil {
+			identity = m.Identity
+		}
 	} else {
 		var err error
 		genesis, err := lib.NewSkipchain(s.skipchain, req.Roster, false)
@@ -116,10 +147,14 @@ func (s *Service) Link(req *evoting.Link) (*evoting.LinkReply, error) {
 	}
 
 	master := &lib.Master{
-		ID:     id,
-		Roster: req.Roster,
-		Admins: req.Admins,
-		Key:    req.Key,
+		ID:       id,
+		Roster:   req.Roster,
+		Key:      req.Key,
+		AdminIDs: req.AdminIDs,
+		Identity: identity,
+	}
+	for _, admin := range req.Admins {
+		master.AdminIDs = append(master.AdminIDs, lib.LegacyID(admin))
 	}
 	transaction := lib.NewTransaction(master, user)
 	if _, err := lib.Store(s.skipchain, master.ID, transaction, s.ServerIdentity().GetPrivate()); err != nil {
@@ -145,7 +180,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 		return nil, errOnlyLeader
 	}
 
-	err = auth(req.User, req.Signature, master.ID, master.Key)
+	user, err := s.authenticate(master, req.User, req.UserID, req.Credential, req.Signature)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +191,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 			return nil, errors.New("master id mismatch")
 		}
 
-		cur, err := lib.GetElection(s.skipchain, req.Election.ID, false, 0)
+		cur, err := lib.GetElection(s.skipchain, req.Election.ID, false, "")
 		if err != nil {
 			return nil, err
 		}
@@ -174,6 +209,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 		cur.Name = req.Election.Name
 		cur.Candidates = req.Election.Candidates
 		cur.MaxChoices = req.Election.MaxChoices
+		cur.Questions = req.Election.Questions
 		cur.Subtitle = req.Election.Subtitle
 		cur.MoreInfo = req.Election.MoreInfo
 		cur.MoreInfoLang = req.Election.MoreInfoLang
@@ -182,7 +218,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 		cur.Theme = req.Election.Theme
 		cur.Footer = req.Election.Footer
 
-		transaction := lib.NewTransaction(cur, req.User)
+		transaction := lib.NewTransaction(cur, user)
 		if _, err := lib.Store(s.skipchain, req.Election.ID, transaction, s.ServerIdentity().GetPrivate()); err != nil {
 			return nil, err
 		}
@@ -204,7 +240,7 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 	proto := instance.(*dkgprotocol.Setup)
 	config, _ := network.Marshal(&synchronizer{
 		ID:   genesis.Hash,
-		User: req.User,
+		User: user,
 	})
 	proto.SetConfig(&onet.GenericConfig{Data: config})
 
@@ -218,16 +254,18 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 		req.Election.Master = req.ID
 		req.Election.Roster = master.Roster
 		req.Election.Key = secret.X
+		req.Election.Commits = secret.Commits
 		req.Election.MasterKey = master.Key
-		req.Election.Creator = req.User
+		req.Election.Migrate()
+		req.Election.CreatorID = user
 
-		transaction := lib.NewTransaction(req.Election, req.User)
+		transaction := lib.NewTransaction(req.Election, user)
 		if _, err := lib.Store(s.skipchain, req.Election.ID, transaction, s.ServerIdentity().GetPrivate()); err != nil {
 			return nil, err
 		}
 
 		link := &lib.Link{ID: genesis.Hash}
-		transaction = lib.NewTransaction(link, req.User)
+		transaction = lib.NewTransaction(link, user)
 		if _, err := lib.Store(s.skipchain, master.ID, transaction, s.ServerIdentity().GetPrivate()); err != nil {
 			return nil, err
 		}
@@ -245,14 +283,14 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 			public := cothority.Suite.Point().Mul(secret, nil)
 			K, C := lib.Encrypt(public, nil)
 			b := &lib.Ballot{
-				User:  req.Election.Users[0],
-				Alpha: K,
-				Beta:  C,
+				UserID: req.Election.Voters[0],
+				Alpha:  K,
+				Beta:   C,
 			}
-			transaction = lib.NewTransaction(b, b.User)
+			transaction = lib.NewTransaction(b, b.UserID)
 			_, err := lib.Store(s.skipchain, req.Election.ID, transaction, s.ServerIdentity().GetPrivate())
 			if err != nil {
-				return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.Election.ID, b.User, err)
+				return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.Election.ID, b.UserID, err)
 			}
 		}
 
@@ -263,54 +301,76 @@ func (s *Service) Open(req *evoting.Open) (*evoting.OpenReply, error) {
 }
 
 type cacheEntry struct {
-	id      int
-	reply   *evoting.LookupSciperReply
+	key     string
+	reply   *evoting.LookupVoterReply
 	expires time.Time
 }
 
-const sciperCacheLen = 100
+const lookupCacheLen = 100
 
-func (s *Service) sciperGetNoLock(sciper int) *evoting.LookupSciperReply {
-	for _, r := range s.sciperCache {
-		if r.id == sciper && r.expires.After(time.Now()) {
+func (s *Service) lookupGetNoLock(key string) *evoting.LookupVoterReply {
+	for _, r := range s.lookupCache {
+		if r.key == key && r.expires.After(time.Now()) {
 			return r.reply
 		}
 	}
 	return nil
 }
 
-// sciperGet runs through the cache looking for a match. The search is linear
+// lookupGet runs through the cache looking for a match. The search is linear
 // because the cache is small, and the whole thing will fit in a couple of cache lines.
-func (s *Service) sciperGet(sciper int) (reply *evoting.LookupSciperReply) {
-	s.sciperMu.Lock()
-	reply = s.sciperGetNoLock(sciper)
-	s.sciperMu.Unlock()
+func (s *Service) lookupGet(key string) (reply *evoting.LookupVoterReply) {
+	s.lookupMu.Lock()
+	reply = s.lookupGetNoLock(key)
+	s.lookupMu.Unlock()
 	return
 }
 
-// sciperPut puts an entry into the cache, if it is not present
-func (s *Service) sciperPut(sciper int, reply *evoting.LookupSciperReply) {
-	s.sciperMu.Lock()
-	defer s.sciperMu.Unlock()
+// lookupPut puts an entry into the cache, if it is not present
+func (s *Service) lookupPut(key string, reply *evoting.LookupVoterReply) {
+	s.lookupMu.Lock()
+	defer s.lookupMu.Unlock()
 
 	// check that no one raced us to put their own copy in.
-	if s.sciperGetNoLock(sciper) == nil {
-		s.sciperCache = append(s.sciperCache, cacheEntry{
-			id:      sciper,
+	if s.lookupGetNoLock(key) == nil {
+		s.lookupCache = append(s.lookupCache, cacheEntry{
+			key:     key,
 			reply:   reply,
 			expires: time.Now().Add(1 * time.Hour),
 		})
-		if len(s.sciperCache) > sciperCacheLen {
-			from := len(s.sciperCache) - sciperCacheLen
-			s.sciperCache = s.sciperCache[from:]
+		if len(s.lookupCache) > lookupCacheLen {
+			from := len(s.lookupCache) - lookupCacheLen
+			s.lookupCache = s.lookupCache[from:]
 		}
 	}
 
 	return
 }
 
-// LookupSciper calls https://people.epfl.ch/cgi-bin/people/vCard?id=sciper
-// to convert Sciper numbers to names.
+// lookup looks up a voter with the identity provider, using the cache. The
+// key identifies the provider in the cache.
+func (s *Service) lookup(key string, p IdentityProvider, id string) (*evoting.LookupVoterReply, error) {
+	key += "/" + id
+	// Try to find it in cache first
+	if res := s.lookupGet(key); res != nil {
+		log.Lvl3("Got voter (cache hit)", res)
+		return res, nil
+	}
+
+	reply, err := p.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Put it into the cache
+	s.lookupPut(key, reply)
+
+	log.Lvl3("Got voter (cache miss): ", reply)
+	return reply, nil
+}
+
+// LookupSciper looks up SCIPER numbers in the LDAP directory of EPFL to
+// convert them to names.
 func (s *Service) LookupSciper(req *evoting.LookupSciper) (*evoting.LookupSciperReply, error) {
 	if len(req.Sciper) != 6 {
 		return nil, errors.New("sciper should be 6 digits only")
@@ -320,54 +380,89 @@ func (s *Service) LookupSciper(req *evoting.LookupSciper) (*evoting.LookupSciper
 		return nil, errors.New("couldn't convert Sciper to integer")
 	}
 
-	// Try to find it in cache first
-	if res := s.sciperGet(sciper); res != nil {
-		log.Lvl3("Got vcard (cache hit)", res)
-		return res, nil
-	}
-
-	url := "ldaps://ldap.epfl.ch"
+	config := lib.SciperLDAP
 	if req.LookupURL != "" {
-		url = req.LookupURL
+		config.URL = req.LookupURL
 	}
-	l, err := ldap.DialURL(url)
+	reply, err := s.lookup(config.URL, newLDAPProvider(config), strconv.Itoa(sciper))
 	if err != nil {
 		return nil, err
 	}
-	defer l.Close()
-
-	// Search for the given username
-	searchRequest := ldap.NewSearchRequest(
-		"o=epfl, c=ch",
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf("(&(objectClass=person)(uniqueIdentifier=%d))", sciper),
-		[]string{"displayName", "mail"},
-		nil,
-	)
+	return &evoting.LookupSciperReply{FullName: reply.FullName, Email: reply.Email}, nil
+}
 
-	sr, err := l.Search(searchRequest)
+// LookupVoter message handler. Looks up a voter with the identity provider of
+// the master skipchain.
+func (s *Service) LookupVoter(req *evoting.LookupVoter) (*evoting.LookupVoterReply, error) {
+	master, err := lib.GetMaster(s.skipchain, req.Master)
 	if err != nil {
 		return nil, err
 	}
+	p, err := s.provider(master)
+	if err != nil {
+		return nil, err
+	}
+	return s.lookup(master.ID.Short(), p, req.ID)
+}
 
-	// If no results, err.
-	// If more than one are returned, we look only at the first one.
-	if len(sr.Entries) == 0 {
-		return nil, errors.New("SCIPER not found")
+// provider returns the identity provider of the master skipchain. The
+// providers are kept as long as their configuration doesn't change.
+func (s *Service) provider(master *lib.Master) (IdentityProvider, error) {
+	identity := master.IdentityProvider()
+	buf, err := protobuf.Encode(identity)
+	if err != nil {
+		return nil, err
 	}
 
-	reply := &evoting.LookupSciperReply{}
-	reply.FullName = sr.Entries[0].GetAttributeValue("displayName")
-	reply.Email = sr.Entries[0].GetAttributeValue("mail")
+	s.providersMu.Lock()
+	defer s.providersMu.Unlock()
+	p, ok := s.providers[string(buf)]
+	if !ok {
+		p, err = NewIdentityProvider(identity)
+		if err != nil {
+			return nil, err
+		}
+		s.providers[string(buf)] = p
+	}
+	return p, nil
+}
 
-	// Put it into the cache
-	s.sciperPut(sciper, reply)
+// authenticate returns the ID of the user of a request. If a credential is
+// given, it is checked by the identity provider of the master skipchain,
+// else the signature of the front-end is checked. Requests of clients that
+// identify the users by their SCIPER number have no user ID.
+func (s *Service) authenticate(master *lib.Master, legacy uint32, user string,
+	credential, sig []byte) (string, error) {
+	if len(credential) > 0 {
+		p, err := s.provider(master)
+		if err != nil {
+			return "", err
+		}
+		id, err := p.Authenticate(credential)
+		if err != nil {
+			return "", fmt.Errorf("authentication failed: %v", err)
+		}
+		if user != "" && user != id {
+			return "", errors.New("the credential is for another user")
+		}
+		return id, nil
+	}
+	if user == "" {
+		return lib.LegacyID(legacy), authSciper(legacy, sig, master.ID, master.Key)
+	}
+	return user, auth(user, sig, master.ID, master.Key)
+}
 
-	log.Lvl3("Got vcard (cache miss): ", reply)
-	return reply, nil
+// auth checks the signature of the front-end on the master ID followed by the
+// user ID.
+func auth(user string, sig []byte, master skipchain.SkipBlockID, pub kyber.Point) error {
+	message := append(append([]byte{}, master...), user...)
+	return schnorr.Verify(cothority.Suite, pub, message, sig)
 }
 
-func auth(u uint32, sig []byte, master skipchain.SkipBlockID, pub kyber.Point) error {
+// authSciper checks the signature of the front-end on the master ID followed
+// by the digits of the SCIPER number.
+func authSciper(u uint32, sig []byte, master skipchain.SkipBlockID, pub kyber.Point) error {
 	var message []byte
 	message = append(message, master...)
 
@@ -379,27 +474,46 @@ func auth(u uint32, sig []byte, master skipchain.SkipBlockID, pub kyber.Point) e
 	return schnorr.Verify(cothority.Suite, pub, message, sig)
 }
 
+// authElection authenticates the user of a request on an election, with the
+// master skipchain of the election.
+func (s *Service) authElection(election *lib.Election, legacy uint32, user string,
+	credential, sig []byte) (string, error) {
+	master := &lib.Master{ID: election.Master, Key: election.MasterKey}
+	if len(credential) > 0 {
+		var err error
+		master, err = lib.GetMaster(s.skipchain, election.Master)
+		if err != nil {
+			return "", err
+		}
+	}
+	return s.authenticate(master, legacy, user, credential, sig)
+}
+
 // Cast message handler. Cast a ballot in a given election.
 func (s *Service) Cast(req *evoting.Cast) (*evoting.CastReply, error) {
 	if !s.leader() {
 		return nil, errOnlyLeader
 	}
 
-	election, err := lib.GetElection(s.skipchain, req.ID, false, req.User)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
-		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.ID, req.User, err)
+		return nil, fmt.Errorf("could not cast ballot on election %x: %v", req.ID, err)
 	}
-	err = auth(req.User, req.Signature, election.Master, election.MasterKey)
+	user, err := s.authElection(election, req.User, req.UserID, req.Credential, req.Signature)
 	if err != nil {
-		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.ID, req.User, err)
+		return nil, fmt.Errorf("could not cast ballot on election %x: %v", req.ID, err)
 	}
 
-	transaction := lib.NewTransaction(req.Ballot, req.User)
+	if req.Ballot == nil {
+		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: missing ballot", req.ID, user)
+	}
+	req.Ballot.Migrate()
+	transaction := lib.NewTransaction(req.Ballot, user)
 	skipblockID, err := lib.Store(s.skipchain, req.ID, transaction, s.ServerIdentity().GetPrivate())
 	if err != nil {
-		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.ID, req.User, err)
+		return nil, fmt.Errorf("could not cast ballot on election %x for user %v: %v", req.ID, user, err)
 	}
-	return &evoting.CastReply{ID: skipblockID}, nil
+	return &evoting.CastReply{ID: skipblockID, Code: lib.TrackingCode(req.Ballot, skipblockID)}, nil
 }
 
 // GetElections message handler. Return all elections in which the given user participates.
@@ -416,7 +530,7 @@ func (s *Service) GetElections(req *evoting.GetElections) (*evoting.GetElections
 	}
 
 	userValid := false
-	err = auth(req.User, req.Signature, master.ID, master.Key)
+	user, err := s.authenticate(master, req.User, req.UserID, req.Credential, req.Signature)
 	if err == nil {
 		userValid = true
 	}
@@ -424,12 +538,12 @@ func (s *Service) GetElections(req *evoting.GetElections) (*evoting.GetElections
 	elections := make([]*lib.Election, 0)
 	if userValid {
 		for _, l := range links {
-			election, err := lib.GetElection(s.skipchain, l.ID, req.CheckVoted, req.User)
+			election, err := lib.GetElection(s.skipchain, l.ID, req.CheckVoted, user)
 			if err != nil {
 				return nil, err
 			}
 			// Check if user is a voter or election creator.
-			if election.IsUser(req.User) || election.IsCreator(req.User) {
+			if election.IsUser(user) || election.IsCreator(user) {
 				// Filter the election by Stage. 0 denotes no filtering.
 				if req.Stage == 0 || req.Stage == election.Stage {
 					elections = append(elections, election)
@@ -439,14 +553,14 @@ func (s *Service) GetElections(req *evoting.GetElections) (*evoting.GetElections
 	}
 	out := &evoting.GetElectionsReply{Elections: elections, Master: *master}
 	if userValid {
-		out.IsAdmin = master.IsAdmin(req.User)
+		out.IsAdmin = master.IsAdmin(user)
 	}
 	return out, nil
 }
 
 // GetBox message handler to retrieve the casted ballot in an election.
 func (s *Service) GetBox(req *evoting.GetBox) (*evoting.GetBoxReply, error) {
-	election, err := lib.GetElection(s.skipchain, req.ID, false, 0)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
 		return nil, err
 	}
@@ -458,10 +572,40 @@ func (s *Service) GetBox(req *evoting.GetBox) (*evoting.GetBoxReply, error) {
 	return &evoting.GetBoxReply{Box: box, Election: election}, nil
 }
 
+// GetTrackingCodes message handler to retrieve the bulletin board of an
+// election, the tracking codes of all the cast ballots.
+func (s *Service) GetTrackingCodes(req *evoting.GetTrackingCodes) (*evoting.GetTrackingCodesReply, error) {
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := election.TrackingCodes(s.skipchain)
+	if err != nil {
+		return nil, err
+	}
+	return &evoting.GetTrackingCodesReply{Codes: codes}, nil
+}
+
+// Audit message handler. It checks the opening of a ballot challenged by the
+// voter before casting, and returns its plaintexts. Nothing is stored.
+func (s *Service) Audit(req *evoting.Audit) (*evoting.AuditReply, error) {
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	plaintexts, err := election.Audit(req.Ballot, req.Opening)
+	if err != nil {
+		return nil, fmt.Errorf("could not audit ballot on election %x: %v", req.ID, err)
+	}
+	return &evoting.AuditReply{Plaintexts: plaintexts}, nil
+}
+
 // GetMixes message handler. It is the caller's responsibility to check the proof
 // in any Mix before relying on it.
 func (s *Service) GetMixes(req *evoting.GetMixes) (*evoting.GetMixesReply, error) {
-	election, err := lib.GetElection(s.skipchain, req.ID, false, 0)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
 		return nil, err
 	}
@@ -475,7 +619,7 @@ func (s *Service) GetMixes(req *evoting.GetMixes) (*evoting.GetMixesReply, error
 
 // GetPartials message handler.
 func (s *Service) GetPartials(req *evoting.GetPartials) (*evoting.GetPartialsReply, error) {
-	election, err := lib.GetElection(s.skipchain, req.ID, false, 0)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
 		return nil, err
 	}
@@ -495,15 +639,18 @@ func (s *Service) Shuffle(req *evoting.Shuffle) (*evoting.ShuffleReply, error) {
 		return nil, errOnlyLeader
 	}
 
-	election, err := lib.GetElection(s.skipchain, req.ID, false, 0)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
 		return nil, err
 	}
 
-	err = auth(req.User, req.Signature, election.Master, election.MasterKey)
+	user, err := s.authElection(election, req.User, req.UserID, req.Credential, req.Signature)
 	if err != nil {
 		return nil, err
 	}
+	if election.Mode == lib.Homomorphic {
+		return nil, errors.New("shuffle error: homomorphic elections are not shuffled")
+	}
 
 	// create a roster excluding nodes that have already participated
 	mixes, err := election.Mixes(s.skipchain)
@@ -542,14 +689,14 @@ func (s *Service) Shuffle(req *evoting.Shuffle) (*evoting.ShuffleReply, error) {
 	hasParticipated, _ := participated[election.Roster.List[0].ID.String()]
 	instance, _ := s.CreateProtocol(protocol.NameShuffle, tree)
 	protoShuffle := instance.(*protocol.Shuffle)
-	protoShuffle.User = req.User
+	protoShuffle.User = user
 	protoShuffle.Election = election
 	protoShuffle.Skipchain = s.skipchain
 	protoShuffle.LeaderParticipates = !hasParticipated
 
 	config, _ := network.Marshal(&synchronizer{
 		ID:   req.ID,
-		User: req.User,
+		User: user,
 	})
 	protoShuffle.SetConfig(&onet.GenericConfig{Data: config})
 	if err = protoShuffle.Start(); err != nil {
@@ -572,22 +719,24 @@ func (s *Service) Decrypt(req *evoting.Decrypt) (*evoting.DecryptReply, error) {
 		return nil, errOnlyLeader
 	}
 
-	election, err := lib.GetElection(s.skipchain, req.ID, false, 0)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
 		return nil, err
 	}
 
-	err = auth(req.User, req.Signature, election.Master, election.MasterKey)
+	user, err := s.authElection(election, req.User, req.UserID, req.Credential, req.Signature)
 	if err != nil {
 		return nil, err
 	}
 
-	mixes, err := election.Mixes(s.skipchain)
-	if err != nil {
-		return nil, err
-	}
-	if len(mixes) < 2*len(election.Roster.List)/3+1 {
-		return nil, errors.New("decrypt error: election not shuffled")
+	if election.Mode != lib.Homomorphic {
+		mixes, err := election.Mixes(s.skipchain)
+		if err != nil {
+			return nil, err
+		}
+		if len(mixes) < 2*len(election.Roster.List)/3+1 {
+			return nil, errors.New("decrypt error: election not shuffled")
+		}
 	}
 
 	partials, err := election.Partials(s.skipchain)
@@ -623,7 +772,7 @@ func (s *Service) Decrypt(req *evoting.Decrypt) (*evoting.DecryptReply, error) {
 
 	instance, _ := s.CreateProtocol(protocol.NameDecrypt, tree)
 	protoDecrypt := instance.(*protocol.Decrypt)
-	protoDecrypt.User = req.User
+	protoDecrypt.User = user
 	protoDecrypt.Secret = s.secret(election.ID)
 	protoDecrypt.Election = election
 	protoDecrypt.Skipchain = s.skipchain
@@ -631,7 +780,7 @@ func (s *Service) Decrypt(req *evoting.Decrypt) (*evoting.DecryptReply, error) {
 
 	config, _ := network.Marshal(&synchronizer{
 		ID:   req.ID,
-		User: req.User,
+		User: user,
 	})
 	protoDecrypt.SetConfig(&onet.GenericConfig{Data: config})
 	if err = protoDecrypt.Start(); err != nil {
@@ -652,7 +801,7 @@ func (s *Service) Reconstruct(req *evoting.Reconstruct) (*evoting.ReconstructRep
 		return nil, errOnlyLeader
 	}
 
-	election, err := lib.GetElection(s.skipchain, req.ID, false, 0)
+	election, err := lib.GetElection(s.skipchain, req.ID, false, "")
 	if err != nil {
 		return nil, err
 	}
@@ -682,7 +831,30 @@ func (s *Service) Reconstruct(req *evoting.Reconstruct) (*evoting.ReconstructRep
 		points = append(points, message)
 	}
 
-	return &evoting.ReconstructReply{Points: points}, nil
+	if len(election.Questions) > 0 {
+		results, err := election.Tally(points)
+		if err != nil {
+			return nil, err
+		}
+		return &evoting.ReconstructReply{Points: points, Results: results}, nil
+	}
+	if election.Mode != lib.Homomorphic {
+		return &evoting.ReconstructReply{Points: points}, nil
+	}
+	// The number of votes of a candidate is at most the number of ballots.
+	box, err := election.Box(s.skipchain)
+	if err != nil {
+		return nil, err
+	}
+	tally := make([]uint32, len(points))
+	for i, point := range points {
+		votes, err := lib.DiscreteLog(point, len(box.Ballots))
+		if err != nil {
+			return nil, fmt.Errorf("reconstruct error, candidate %d: %v", i, err)
+		}
+		tally[i] = uint32(votes)
+	}
+	return &evoting.ReconstructReply{Points: points, Tally: tally}, nil
 }
 
 // NewProtocol hooks non-root nodes into created protocols.
@@ -710,7 +882,7 @@ func (s *Service) NewProtocol(node *onet.TreeNodeInstance, conf *onet.GenericCon
 		}()
 		return protocol, nil
 	case protocol.NameShuffle:
-		election, err := lib.GetElection(s.skipchain, sync.ID, false, 0)
+		election, err := lib.GetElection(s.skipchain, sync.ID, false, "")
 		if err != nil {
 			return nil, err
 		}
@@ -729,7 +901,7 @@ func (s *Service) NewProtocol(node *onet.TreeNodeInstance, conf *onet.GenericCon
 
 		return protocol, nil
 	case protocol.NameDecrypt:
-		election, err := lib.GetElection(s.skipchain, sync.ID, false, 0)
+		election, err := lib.GetElection(s.skipchain, sync.ID, false, "")
 		if err != nil {
 			return nil, err
 		}
@@ -827,7 +999,7 @@ func (s *Service) secret(id skipchain.SkipBlockID) *lib.SharedSecret {
 func (s *Service) save() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	if err := s.Save(storageKey, s.storage); err != nil {
+	if err := keystore.Save(s, storageKey, s.storage); err != nil {
 		log.Error(err)
 	}
 	if err := s.SaveVersion(dbVersion); err != nil {
@@ -840,7 +1012,7 @@ func (s *Service) load() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	blob, err := s.Load(storageKey)
+	blob, err := keystore.Load(s, storageKey)
 	if err != nil {
 		return err
 	} else if blob == nil {
@@ -874,6 +1046,7 @@ func new(context *onet.Context) (onet.Service, error) {
 			Secrets: make(map[string]*lib.SharedSecret),
 		},
 		skipchain: context.Service(skipchain.ServiceName).(*skipchain.Service),
+		providers: make(map[string]IdentityProvider),
 	}
 
 	service.RegisterHandlers(
@@ -883,12 +1056,15 @@ func new(context *onet.Context) (onet.Service, error) {
 		service.Cast,
 		service.GetElections,
 		service.GetBox,
+		service.GetTrackingCodes,
+		service.Audit,
 		service.GetMixes,
 		service.Shuffle,
 		service.GetPartials,
 		service.Decrypt,
 		service.Reconstruct,
 		service.LookupSciper,
+		service.LookupVoter,
 	)
 	skipchain.RegisterVerification(context, lib.TransactionVerifierID, service.verify)
 
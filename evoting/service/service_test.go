@@ -44,6 +44,15 @@ func generateSignature(private kyber.Scalar, ID []byte, sciper uint32) []byte {
 	return sig
 }
 
+// generateSignatureID signs the master ID followed by a string user ID.
+func generateSignatureID(private kyber.Scalar, ID []byte, user string) []byte {
+	sig, err := schnorr.Sign(cothority.Suite, private, append(append([]byte{}, ID...), user...))
+	if err != nil {
+		panic("cannot sign:" + err.Error())
+	}
+	return sig
+}
+
 var (
 	idAdmin  = uint32(111111)
 	idAdmin2 = uint32(111112)
@@ -146,6 +155,10 @@ func TestService(t *testing.T) {
 	box, err := s0.GetBox(&evoting.GetBox{ID: elec.ID})
 	require.NoError(t, err)
 	require.Equal(t, box.Election.Name["en"], elec.Name["en"])
+	// The SCIPER numbers have been migrated to voter IDs.
+	require.Equal(t, lib.LegacyID(idAdmin), box.Election.CreatorID)
+	require.Equal(t, []string{lib.LegacyID(idUser1), lib.LegacyID(idUser2),
+		lib.LegacyID(idUser3), lib.LegacyID(idAdmin)}, box.Election.Voters)
 
 	// Try to cast a vote on a non-leader, should fail.
 	log.Lvl1("Casting vote on non-leader")
@@ -834,7 +847,7 @@ func TestShuffleCatastrophicNodeFailure(t *testing.T) {
 	adminSig := generateSignature(nodeKP.Private, rl.ID, idAdmin)
 
 	// Append two Mixes manually to simulate a shuffle gone bad
-	election, err := lib.GetElection(s0.skipchain, electionID, false, 0)
+	election, err := lib.GetElection(s0.skipchain, electionID, false, "")
 	require.NoError(t, err)
 
 	genMix := func(ballots []*lib.Ballot, election *lib.Election, serverIdentity *network.ServerIdentity, private kyber.Scalar) *lib.Mix {
@@ -858,11 +871,11 @@ func TestShuffleCatastrophicNodeFailure(t *testing.T) {
 	box, err := election.Box(s0.skipchain)
 	require.NoError(t, err)
 	mix := genMix(box.Ballots, election, roster.Get(0), local.GetPrivate(nodes[0]))
-	tx := lib.NewTransaction(mix, idAdmin)
+	tx := lib.NewTransaction(mix, lib.LegacyID(idAdmin))
 	_, err = lib.Store(s0.skipchain, election.ID, tx, nil)
 	require.NoError(t, err)
 	mix2 := genMix(mix.Ballots, election, roster.Get(1), local.GetPrivate(nodes[1]))
-	tx = lib.NewTransaction(mix2, idAdmin)
+	tx = lib.NewTransaction(mix2, lib.LegacyID(idAdmin))
 	_, err = lib.Store(s0.skipchain, election.ID, tx, nil)
 	require.NoError(t, err)
 
@@ -1095,6 +1108,372 @@ func TestCastNodeFailureShuffleAllOk(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestService_Identity(t *testing.T) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer local.CloseAll()
+
+	nodeKP := key.NewKeyPair(cothority.Suite)
+	nodes, roster, _ := local.GenBigTree(3, 3, 1, true)
+	s0 := local.GetServices(nodes, serviceID)[0].(*Service)
+
+	admin, alice, bob := "admin@example.com", "alice@example.com", "bob@example.com"
+	identity := &lib.Identity{
+		Type: lib.IdentityRoll,
+		Roll: []*lib.Voter{
+			{ID: alice, FullName: "Alice", Email: alice},
+			{ID: bob, FullName: "Bob", Email: bob},
+		},
+	}
+
+	// An invalid identity provider is refused.
+	_, err := s0.Link(&evoting.Link{
+		Pin:      s0.pin,
+		Roster:   roster,
+		Key:      nodeKP.Public,
+		AdminIDs: []string{admin},
+		Identity: &lib.Identity{Type: "tequila"},
+	})
+	require.Error(t, err)
+
+	replyLink, err := s0.Link(&evoting.Link{
+		Pin:      s0.pin,
+		Roster:   roster,
+		Key:      nodeKP.Public,
+		AdminIDs: []string{admin},
+		Identity: identity,
+	})
+	require.NoError(t, err)
+	adminSig := generateSignatureID(nodeKP.Private, replyLink.ID, admin)
+	aliceSig := generateSignatureID(nodeKP.Private, replyLink.ID, alice)
+
+	voter, err := s0.LookupVoter(&evoting.LookupVoter{Master: replyLink.ID, ID: bob})
+	require.NoError(t, err)
+	require.Equal(t, "Bob", voter.FullName)
+	require.Equal(t, bob, voter.Email)
+	_, err = s0.LookupVoter(&evoting.LookupVoter{Master: replyLink.ID, ID: admin})
+	require.Error(t, err)
+
+	replyOpen, err := s0.Open(&evoting.Open{
+		ID: replyLink.ID,
+		Election: &lib.Election{
+			Voters: []string{alice, bob},
+			Start:  yesterday.Unix(),
+			End:    tomorrow.Unix(),
+		},
+		UserID:    admin,
+		Signature: adminSig,
+	})
+	require.NoError(t, err)
+
+	k, c := lib.Encrypt(replyOpen.Key, bufCand1)
+	// A signature for another user is refused.
+	_, err = s0.Cast(&evoting.Cast{
+		ID:        replyOpen.ID,
+		Ballot:    &lib.Ballot{UserID: bob, Alpha: k, Beta: c},
+		UserID:    bob,
+		Signature: aliceSig,
+	})
+	require.Error(t, err)
+	// The voter roll cannot authenticate voters.
+	_, err = s0.Cast(&evoting.Cast{
+		ID:         replyOpen.ID,
+		Ballot:     &lib.Ballot{UserID: alice, Alpha: k, Beta: c},
+		UserID:     alice,
+		Credential: []byte("password"),
+	})
+	require.Error(t, err)
+	// Casting a ballot for another voter is refused.
+	_, err = s0.Cast(&evoting.Cast{
+		ID:        replyOpen.ID,
+		Ballot:    &lib.Ballot{UserID: bob, Alpha: k, Beta: c},
+		UserID:    alice,
+		Signature: aliceSig,
+	})
+	require.Error(t, err)
+	_, err = s0.Cast(&evoting.Cast{
+		ID:        replyOpen.ID,
+		Ballot:    &lib.Ballot{UserID: alice, Alpha: k, Beta: c},
+		UserID:    alice,
+		Signature: aliceSig,
+	})
+	require.NoError(t, err)
+
+	elections, err := s0.GetElections(&evoting.GetElections{
+		Master:     replyLink.ID,
+		UserID:     alice,
+		Signature:  aliceSig,
+		CheckVoted: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, elections.Elections, 1)
+	require.Equal(t, admin, elections.Elections[0].CreatorID)
+	require.NotNil(t, elections.Elections[0].Voted)
+	require.False(t, elections.IsAdmin)
+
+	box, err := s0.GetBox(&evoting.GetBox{ID: replyOpen.ID})
+	require.NoError(t, err)
+	require.Len(t, box.Box.Ballots, 1)
+	require.Equal(t, alice, box.Box.Ballots[0].UserID)
+
+	// Updating the master keeps the identity provider.
+	_, err = s0.Link(&evoting.Link{
+		Pin:       s0.pin,
+		Roster:    roster,
+		Key:       nodeKP.Public,
+		AdminIDs:  []string{admin, bob},
+		ID:        &replyLink.ID,
+		UserID:    &admin,
+		Signature: &adminSig,
+	})
+	require.NoError(t, err)
+	elections, err = s0.GetElections(&evoting.GetElections{
+		Master:    replyLink.ID,
+		UserID:    admin,
+		Signature: adminSig,
+	})
+	require.NoError(t, err)
+	require.True(t, elections.IsAdmin)
+	require.Equal(t, []string{admin, bob}, elections.Master.AdminIDs)
+	require.Equal(t, lib.IdentityRoll, elections.Master.IdentityProvider().Type)
+}
+
+func TestService_Homomorphic(t *testing.T) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer local.CloseAll()
+
+	nodeKP := key.NewKeyPair(cothority.Suite)
+	nodes, roster, _ := local.GenBigTree(3, 3, 1, true)
+	s0 := local.GetServices(nodes, serviceID)[0].(*Service)
+
+	admin := "admin"
+	voters := []string{"alice", "bob", "carol"}
+	replyLink, err := s0.Link(&evoting.Link{
+		Pin:      s0.pin,
+		Roster:   roster,
+		Key:      nodeKP.Public,
+		AdminIDs: []string{admin},
+	})
+	require.NoError(t, err)
+	adminSig := generateSignatureID(nodeKP.Private, replyLink.ID, admin)
+
+	elec := &lib.Election{
+		Voters:     voters,
+		Candidates: []uint32{1, 2},
+		MaxChoices: 1,
+		Mode:       lib.Homomorphic,
+		Start:      yesterday.Unix(),
+		End:        tomorrow.Unix(),
+	}
+	// A homomorphic election needs a valid number of choices.
+	invalid := *elec
+	invalid.MaxChoices = 3
+	_, err = s0.Open(&evoting.Open{ID: replyLink.ID, Election: &invalid, UserID: admin, Signature: adminSig})
+	require.Error(t, err)
+	replyOpen, err := s0.Open(&evoting.Open{ID: replyLink.ID, Election: elec, UserID: admin, Signature: adminSig})
+	require.NoError(t, err)
+
+	cast := func(user string, ballot *lib.Ballot) error {
+		ballot.UserID = user
+		_, err := s0.Cast(&evoting.Cast{
+			ID:        replyOpen.ID,
+			Ballot:    ballot,
+			UserID:    user,
+			Signature: generateSignatureID(nodeKP.Private, replyLink.ID, user),
+		})
+		return err
+	}
+	vote := func(user string, choices ...bool) *lib.Ballot {
+		ballot, err := lib.NewVote(replyOpen.Key, choices, 1, lib.VoteContext(replyOpen.ID, user))
+		require.NoError(t, err)
+		return ballot
+	}
+
+	// Plain ballots and ballots copied from another voter are refused.
+	k, c := lib.Encrypt(replyOpen.Key, bufCand1)
+	require.Error(t, cast("alice", &lib.Ballot{Alpha: k, Beta: c}))
+	require.Error(t, cast("alice", vote("bob", true, false)))
+	require.NoError(t, cast("alice", vote("alice", true, false)))
+	require.NoError(t, cast("bob", vote("bob", false, true)))
+	require.NoError(t, cast("carol", vote("carol", false, false)))
+	// Only the last ballot of a voter is counted.
+	require.NoError(t, cast("carol", vote("carol", true, false)))
+
+	_, err = s0.Shuffle(&evoting.Shuffle{ID: replyOpen.ID, UserID: admin, Signature: adminSig})
+	require.Error(t, err)
+	_, err = s0.Decrypt(&evoting.Decrypt{ID: replyOpen.ID, UserID: admin, Signature: adminSig})
+	require.NoError(t, err)
+
+	box, err := s0.GetBox(&evoting.GetBox{ID: replyOpen.ID})
+	require.NoError(t, err)
+	require.Equal(t, lib.Decrypted, box.Election.Stage)
+	require.Error(t, cast("alice", vote("alice", false, true)))
+
+	partials, err := s0.GetPartials(&evoting.GetPartials{ID: replyOpen.ID})
+	require.NoError(t, err)
+	sums := box.Election.Sum(box.Box.Ballots)
+	for _, partial := range partials.Partials {
+		require.NoError(t, box.Election.VerifyPartial(partial, sums))
+	}
+
+	reconstruct, err := s0.Reconstruct(&evoting.Reconstruct{ID: replyOpen.ID})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{2, 1}, reconstruct.Tally)
+}
+
+func TestService_Questions(t *testing.T) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer local.CloseAll()
+
+	nodeKP := key.NewKeyPair(cothority.Suite)
+	nodes, roster, _ := local.GenBigTree(3, 3, 1, true)
+	s0 := local.GetServices(nodes, serviceID)[0].(*Service)
+
+	admin := "admin"
+	voters := []string{"alice", "bob", "carol"}
+	replyLink, err := s0.Link(&evoting.Link{
+		Pin:      s0.pin,
+		Roster:   roster,
+		Key:      nodeKP.Public,
+		AdminIDs: []string{admin},
+	})
+	require.NoError(t, err)
+	adminSig := generateSignatureID(nodeKP.Private, replyLink.ID, admin)
+
+	elec := &lib.Election{
+		Voters: voters,
+		Questions: []*lib.Question{
+			{Title: "Referendum", Type: lib.SingleChoice, Candidates: []string{"yes", "no"}},
+			{Title: "Board", Type: lib.Approval, Candidates: []string{"a", "b", "c"}, MaxChoices: 2},
+			{Title: "Chair", Type: lib.Ranked, Candidates: []string{"a", "b", "c"}, MaxChoices: 3, Method: lib.InstantRunoff},
+		},
+		Start: yesterday.Unix(),
+		End:   tomorrow.Unix(),
+	}
+	// Every question must be valid.
+	invalid := *elec
+	invalid.Questions = []*lib.Question{{Type: lib.SingleChoice, Candidates: []string{"yes"}}}
+	_, err = s0.Open(&evoting.Open{ID: replyLink.ID, Election: &invalid, UserID: admin, Signature: adminSig})
+	require.Error(t, err)
+	replyOpen, err := s0.Open(&evoting.Open{ID: replyLink.ID, Election: elec, UserID: admin, Signature: adminSig})
+	require.NoError(t, err)
+
+	questions := &lib.Election{Key: replyOpen.Key, Questions: elec.Questions}
+	cast := func(user string, answers ...[]int) error {
+		ballot, err := questions.EncryptAnswers(answers)
+		require.NoError(t, err)
+		ballot.UserID = user
+		_, err = s0.Cast(&evoting.Cast{
+			ID:        replyOpen.ID,
+			Ballot:    ballot,
+			UserID:    user,
+			Signature: generateSignatureID(nodeKP.Private, replyLink.ID, user),
+		})
+		return err
+	}
+
+	// A ballot must answer every question.
+	k, c := lib.Encrypt(replyOpen.Key, bufCand1)
+	_, err = s0.Cast(&evoting.Cast{
+		ID:        replyOpen.ID,
+		Ballot:    &lib.Ballot{UserID: "alice", Alpha: k, Beta: c},
+		UserID:    "alice",
+		Signature: generateSignatureID(nodeKP.Private, replyLink.ID, "alice"),
+	})
+	require.Error(t, err)
+	require.NoError(t, cast("alice", []int{0}, []int{0, 1}, []int{0, 1}))
+	require.NoError(t, cast("bob", []int{1}, []int{1}, []int{1, 2}))
+	require.NoError(t, cast("carol", []int{0}, []int{}, []int{2, 1}))
+
+	_, err = s0.Shuffle(&evoting.Shuffle{ID: replyOpen.ID, UserID: admin, Signature: adminSig})
+	require.NoError(t, err)
+	_, err = s0.Decrypt(&evoting.Decrypt{ID: replyOpen.ID, UserID: admin, Signature: adminSig})
+	require.NoError(t, err)
+
+	box, err := s0.GetBox(&evoting.GetBox{ID: replyOpen.ID})
+	require.NoError(t, err)
+	mixes, err := s0.GetMixes(&evoting.GetMixes{ID: replyOpen.ID})
+	require.NoError(t, err)
+	ballots := box.Election.Flatten(box.Box.Ballots)
+	for _, mix := range mixes.Mixes {
+		require.NoError(t, box.Election.VerifyMix(mix, ballots))
+		ballots = mix.Ballots
+	}
+
+	reconstruct, err := s0.Reconstruct(&evoting.Reconstruct{ID: replyOpen.ID})
+	require.NoError(t, err)
+	require.Len(t, reconstruct.Results, 3)
+	require.Equal(t, []int{2, 1}, reconstruct.Results[0].Counts)
+	require.Equal(t, 0, reconstruct.Results[0].Winner)
+	require.Equal(t, []int{1, 2, 0}, reconstruct.Results[1].Counts)
+	require.Equal(t, 1, reconstruct.Results[1].Winner)
+	require.Len(t, reconstruct.Results[2].Rounds, 2)
+	require.Equal(t, 1, reconstruct.Results[2].Winner)
+}
+
+func TestService_TrackingCodes(t *testing.T) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer local.CloseAll()
+
+	nodeKP := key.NewKeyPair(cothority.Suite)
+	nodes, roster, _ := local.GenBigTree(3, 3, 1, true)
+	s0 := local.GetServices(nodes, serviceID)[0].(*Service)
+
+	admin := "admin"
+	replyLink, err := s0.Link(&evoting.Link{
+		Pin:      s0.pin,
+		Roster:   roster,
+		Key:      nodeKP.Public,
+		AdminIDs: []string{admin},
+	})
+	require.NoError(t, err)
+	adminSig := generateSignatureID(nodeKP.Private, replyLink.ID, admin)
+
+	elec := &lib.Election{
+		Voters: []string{"alice", "bob"},
+		Start:  yesterday.Unix(),
+		End:    tomorrow.Unix(),
+	}
+	replyOpen, err := s0.Open(&evoting.Open{ID: replyLink.ID, Election: elec, UserID: admin, Signature: adminSig})
+	require.NoError(t, err)
+
+	// A challenged ballot is opened and discarded.
+	ballot, opening := lib.NewAuditableBallot(replyOpen.Key, bufCand1)
+	audit, err := s0.Audit(&evoting.Audit{ID: replyOpen.ID, Ballot: ballot, Opening: opening})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{bufCand1}, audit.Plaintexts)
+	other, _ := lib.NewAuditableBallot(replyOpen.Key, bufCand1)
+	_, err = s0.Audit(&evoting.Audit{ID: replyOpen.ID, Ballot: other, Opening: opening})
+	require.Error(t, err)
+
+	cast := func(user string, ballot *lib.Ballot) []byte {
+		ballot.UserID = user
+		reply, err := s0.Cast(&evoting.Cast{
+			ID:        replyOpen.ID,
+			Ballot:    ballot,
+			UserID:    user,
+			Signature: generateSignatureID(nodeKP.Private, replyLink.ID, user),
+		})
+		require.NoError(t, err)
+		require.Equal(t, lib.TrackingCode(ballot, reply.ID), reply.Code)
+		return reply.Code
+	}
+	var codes [][]byte
+	for _, user := range []string{"alice", "bob", "alice"} {
+		ballot, _ := lib.NewAuditableBallot(replyOpen.Key, bufCand1)
+		codes = append(codes, cast(user, ballot))
+	}
+
+	// The board lists all the cast ballots, the box only the last ones.
+	board, err := s0.GetTrackingCodes(&evoting.GetTrackingCodes{ID: replyOpen.ID})
+	require.NoError(t, err)
+	require.Equal(t, codes, board.Codes)
+	box, err := s0.GetBox(&evoting.GetBox{ID: replyOpen.ID})
+	require.NoError(t, err)
+	require.Len(t, box.Box.Ballots, 2)
+	require.Equal(t, "bob", box.Box.Ballots[0].UserID)
+	require.Equal(t, "alice", box.Box.Ballots[1].UserID)
+}
+
 func TestLookupSciper(t *testing.T) {
 	// Comment this out when you want to run this unit test for dev work.
 	t.Skip("unit tests should not call external servers")
@@ -13,6 +13,7 @@ func init() {
 	network.RegisterMessage(Ping{})
 	network.RegisterMessages(Link{}, LinkReply{})
 	network.RegisterMessages(LookupSciper{}, LookupSciperReply{})
+	network.RegisterMessages(LookupVoter{}, LookupVoterReply{})
 	network.RegisterMessages(Open{}, OpenReply{})
 	network.RegisterMessages(Cast{}, CastReply{})
 	network.RegisterMessages(Shuffle{}, ShuffleReply{})
@@ -22,9 +23,13 @@ func init() {
 	network.RegisterMessages(GetMixes{}, GetMixesReply{})
 	network.RegisterMessages(GetPartials{}, GetPartialsReply{})
 	network.RegisterMessages(Reconstruct{}, ReconstructReply{})
+	network.RegisterMessages(GetTrackingCodes{}, GetTrackingCodesReply{})
+	network.RegisterMessages(Audit{}, AuditReply{})
 }
 
 // LookupSciper takes a SCIPER number and looks up the full name.
+//
+// Deprecated: use LookupVoter.
 type LookupSciper struct {
 	Sciper string
 	// If LookupURL is set, use it instead of the default (for testing).
@@ -39,15 +44,33 @@ type LookupSciperReply struct {
 	Title    string // Deprecated: not currently returned.
 }
 
+// LookupVoter takes a voter ID and looks up the voter with the identity
+// provider of the master skipchain.
+type LookupVoter struct {
+	Master skipchain.SkipBlockID // ID of the master skipchain.
+	ID     string                // ID of the voter.
+}
+
+// LookupVoterReply returns user info, as looked up by the identity provider.
+type LookupVoterReply struct {
+	FullName string
+	Email    string
+}
+
 // Link message.
 type Link struct {
 	Pin       string                 // Pin of the running service.
 	Roster    *onet.Roster           // Roster that handles elections.
 	Key       kyber.Point            // Key is a front-end public key.
-	Admins    []uint32               // Admins is a list of election administrators.
+	Admins    []uint32               // Deprecated: use AdminIDs.
 	ID        *skipchain.SkipBlockID // ID of the master skipchain to update; optional.
-	User      *uint32                // User identifier; optional (required with ID).
+	User      *uint32                // Deprecated: use UserID.
 	Signature *[]byte                // Signature authenticating the message; optional (required with ID).
+
+	AdminIDs   []string      // AdminIDs is a list of election administrators.
+	UserID     *string       // User identifier; optional (required with ID).
+	Credential *[]byte       // Credential authenticating the user with the identity provider; optional.
+	Identity   *lib.Identity // Identity provider of the voters; optional.
 }
 
 // LinkReply message.
@@ -60,8 +83,11 @@ type Open struct {
 	ID       skipchain.SkipBlockID // ID of the master skipchain.
 	Election *lib.Election         // Election object.
 
-	User      uint32 // User identifier.
+	User      uint32 // Deprecated: use UserID.
 	Signature []byte // Signature authenticating the message.
+
+	UserID     string // User identifier.
+	Credential []byte // Credential authenticating the user with the identity provider; optional.
 }
 
 // OpenReply message.
@@ -75,21 +101,29 @@ type Cast struct {
 	ID     skipchain.SkipBlockID // ID of the election skipchain.
 	Ballot *lib.Ballot           // Ballot to be casted.
 
-	User      uint32 // User identifier.
+	User      uint32 // Deprecated: use UserID.
 	Signature []byte // Signature authenticating the message.
+
+	UserID     string // User identifier.
+	Credential []byte // Credential authenticating the user with the identity provider; optional.
 }
 
 // CastReply message.
 type CastReply struct {
 	ID skipchain.SkipBlockID // Hash of the block storing the transaction
+
+	Code []byte // Code is the tracking code of the ballot, see lib.TrackingCode.
 }
 
 // Shuffle message.
 type Shuffle struct {
 	ID skipchain.SkipBlockID // ID of the election skipchain.
 
-	User      uint32 // User identifier.
+	User      uint32 // Deprecated: use UserID.
 	Signature []byte // Signature authenticating the message.
+
+	UserID     string // User identifier.
+	Credential []byte // Credential authenticating the user with the identity provider; optional.
 }
 
 // ShuffleReply message.
@@ -99,8 +133,11 @@ type ShuffleReply struct{}
 type Decrypt struct {
 	ID skipchain.SkipBlockID // ID of the election skipchain.
 
-	User      uint32 // User identifier.
+	User      uint32 // Deprecated: use UserID.
 	Signature []byte // Signature authenticating the message.
+
+	UserID     string // User identifier.
+	Credential []byte // Credential authenticating the user with the identity provider; optional.
 }
 
 // DecryptReply message.
@@ -108,11 +145,14 @@ type DecryptReply struct{}
 
 // GetElections message.
 type GetElections struct {
-	User       uint32                // User identifier.
+	User       uint32                // Deprecated: use UserID.
 	Master     skipchain.SkipBlockID // Master skipchain ID.
 	Stage      lib.ElectionState     // Election Stage filter. 0 for all elections.
 	Signature  []byte                // Signature authenticating the message.
 	CheckVoted bool                  // Check if user has voted in the elections.
+
+	UserID     string // User identifier.
+	Credential []byte // Credential authenticating the user with the identity provider; optional.
 }
 
 // GetElectionsReply message.
@@ -133,6 +173,28 @@ type GetBoxReply struct {
 	Election *lib.Election // The current config of the election.
 }
 
+// GetTrackingCodes message.
+type GetTrackingCodes struct {
+	ID skipchain.SkipBlockID // ID of the election skipchain.
+}
+
+// GetTrackingCodesReply message.
+type GetTrackingCodesReply struct {
+	Codes [][]byte // Codes of all the cast ballots, in the order of casting.
+}
+
+// Audit message.
+type Audit struct {
+	ID      skipchain.SkipBlockID // ID of the election skipchain.
+	Ballot  *lib.Ballot           // Ballot to be audited, it must not be cast.
+	Opening *lib.Opening          // Opening of the ballot.
+}
+
+// AuditReply message.
+type AuditReply struct {
+	Plaintexts [][]byte // Plaintexts are the decrypted ciphertexts of the ballot.
+}
+
 // GetMixes message.
 type GetMixes struct {
 	ID skipchain.SkipBlockID // ID of the election skipchain.
@@ -161,6 +223,9 @@ type Reconstruct struct {
 // ReconstructReply message.
 type ReconstructReply struct {
 	Points []kyber.Point // Points are the decrypted plaintexts.
+	Tally  []uint32      // Tally is the number of votes of each candidate in a homomorphic election.
+
+	Results []*lib.Result // Results of the questions of a multi-question election.
 }
 
 // Ping message.
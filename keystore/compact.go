@@ -0,0 +1,142 @@
+package keystore
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"go.dedis.ch/onet/v3/log"
+	bbolt "go.etcd.io/bbolt"
+	"golang.org/x/xerrors"
+)
+
+// compactTxSize is the number of bytes copied by Compact in one transaction.
+const compactTxSize = 64 << 20
+
+var warnCompact sync.Once
+
+// SealedInPlace warns, once, that data stored in plaintext has been replaced
+// by its sealed version in the database of the conode. As bbolt doesn't
+// scrub the pages it frees, the plaintext is still in the database file until
+// it is compacted.
+func SealedInPlace() {
+	warnCompact.Do(func() {
+		log.Warn("Data of the services has been sealed in place: stop the " +
+			"conode and run 'conode keystore compact' to remove the " +
+			"plaintext from the database file.")
+	})
+}
+
+// Compact copies the bbolt database in path to a fresh file, and replaces the
+// database with it. The copy only holds the current values, so the plaintext
+// left in the free pages of the database by the sealing in place is gone.
+// The database must not be used by a running conode.
+//
+// The old file is replaced, but not overwritten: depending on the file system
+// and the disk, its content might still be recovered from the disk.
+func Compact(path string) error {
+	src, err := bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true,
+		Timeout: time.Second})
+	if err != nil {
+		return xerrors.Errorf("opening database, is the conode stopped? %v", err)
+	}
+	tmp := path + ".compact"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		src.Close()
+		return xerrors.Errorf("removing old copy: %v", err)
+	}
+	dst, err := bbolt.Open(tmp, 0600, nil)
+	if err != nil {
+		src.Close()
+		return xerrors.Errorf("creating copy: %v", err)
+	}
+
+	err = copyDB(dst, src)
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if errClose := src.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return xerrors.Errorf("copying database: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return xerrors.Errorf("replacing database: %v", err)
+	}
+	return nil
+}
+
+// copier copies the buckets of a database, committing the transaction every
+// compactTxSize bytes.
+type copier struct {
+	dst  *bbolt.DB
+	tx   *bbolt.Tx
+	size int
+}
+
+func copyDB(dst, src *bbolt.DB) error {
+	tx, err := dst.Begin(true)
+	if err != nil {
+		return err
+	}
+	c := &copier{dst: dst, tx: tx}
+	err = src.View(func(stx *bbolt.Tx) error {
+		return stx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			nb, err := c.tx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			if err := nb.SetSequence(b.Sequence()); err != nil {
+				return err
+			}
+			return c.copy(b, [][]byte{name})
+		})
+	})
+	if err != nil {
+		c.tx.Rollback()
+		return err
+	}
+	return c.tx.Commit()
+}
+
+// bucket returns the bucket at the path in the current transaction.
+func (c *copier) bucket(path [][]byte) *bbolt.Bucket {
+	b := c.tx.Bucket(path[0])
+	for _, name := range path[1:] {
+		b = b.Bucket(name)
+	}
+	return b
+}
+
+// copy copies the keys and the nested buckets of src to the bucket at the
+// path.
+func (c *copier) copy(src *bbolt.Bucket, path [][]byte) error {
+	return src.ForEach(func(k, v []byte) error {
+		c.size += len(k) + len(v)
+		if c.size > compactTxSize {
+			if err := c.tx.Commit(); err != nil {
+				return err
+			}
+			tx, err := c.dst.Begin(true)
+			if err != nil {
+				return err
+			}
+			c.tx = tx
+			c.size = 0
+		}
+		if v != nil {
+			return c.bucket(path).Put(k, v)
+		}
+		sub := src.Bucket(k)
+		nb, err := c.bucket(path).CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		if err := nb.SetSequence(sub.Sequence()); err != nil {
+			return err
+		}
+		return c.copy(sub, append(append([][]byte{}, path...), k))
+	})
+}
@@ -0,0 +1,69 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.db")
+
+	secret := []byte("plaintext private key")
+	db, err := bbolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("service"))
+		if err != nil {
+			return err
+		}
+		if _, err := b.NextSequence(); err != nil {
+			return err
+		}
+		sub, err := b.CreateBucket([]byte("sub"))
+		if err != nil {
+			return err
+		}
+		if err := sub.Put([]byte("other"), []byte("value")); err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), secret)
+	}))
+	// Sealing in place leaves the plaintext in a free page.
+	sealed := []byte("sealed private key")
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("service")).Put([]byte("key"), sealed)
+	}))
+	require.NoError(t, db.Close())
+	buf, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(buf), string(secret))
+
+	require.NoError(t, Compact(path))
+	buf, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(buf), string(secret))
+	_, err = os.Stat(path + ".compact")
+	require.True(t, os.IsNotExist(err))
+
+	db, err = bbolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("service"))
+		require.Equal(t, sealed, b.Get([]byte("key")))
+		require.Equal(t, uint64(1), b.Sequence())
+		require.Equal(t, []byte("value"), b.Bucket([]byte("sub")).Get([]byte("other")))
+		return nil
+	}))
+
+	// The database of a running conode is not compacted.
+	require.Error(t, Compact(path))
+}
@@ -0,0 +1,48 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"go.dedis.ch/onet/v3/app"
+	"golang.org/x/xerrors"
+)
+
+// PassphraseEnv is the environment variable that can hold the passphrase of
+// a conode.
+const PassphraseEnv = "CONODE_PASSPHRASE"
+
+// DecodeCothority decodes the configuration of a conode. If it is sealed, it
+// is opened with the passphrase.
+func DecodeCothority(buf []byte, passphrase []byte) (*app.CothorityConfig, error) {
+	if IsSealed(buf) {
+		if len(passphrase) == 0 {
+			return nil, ErrLocked
+		}
+		var err error
+		buf, err = New(passphrase).Open(buf)
+		if err != nil {
+			return nil, xerrors.Errorf("opening configuration: %v", err)
+		}
+	}
+	hc := &app.CothorityConfig{}
+	if _, err := toml.Decode(string(buf), hc); err != nil {
+		return nil, xerrors.Errorf("toml decoding: %v", err)
+	}
+	// Backwards compatibility with configs before we included the suite name
+	if hc.Suite == "" {
+		hc.Suite = "Ed25519"
+	}
+	return hc, nil
+}
+
+// LoadCothority loads the configuration of a conode like app.LoadCothority.
+// If it is sealed, it is opened with the passphrase in PassphraseEnv.
+func LoadCothority(file string) (*app.CothorityConfig, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, xerrors.Errorf("reading configuration: %v", err)
+	}
+	return DecodeCothority(buf, []byte(os.Getenv(PassphraseEnv)))
+}
@@ -0,0 +1,275 @@
+// Package keystore seals the secret material of a conode at rest. The data is
+// encrypted with AES-256-GCM, under a key derived from a passphrase with
+// scrypt, and armored in a PEM block.
+//
+// The conode unlocks the keystore with its passphrase when it starts, before
+// the services are created. The services then use Save and Load for their
+// storage, or Seal and Open for the data they store themselves: the data is
+// sealed if the keystore is unlocked, and data that has been stored before
+// the keystore was used is still read, so that an existing conode can be
+// migrated.
+//
+// The migration seals the data in place, but bbolt doesn't scrub the pages it
+// frees, so the plaintext stays in the database file until it is copied to a
+// fresh one with Compact, which is done by 'conode keystore compact'.
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"strconv"
+	"sync"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/onet/v3/network"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/xerrors"
+)
+
+// pemType is the type of the PEM blocks holding sealed data.
+const pemType = "COTHORITY SEALED DATA"
+
+// The scrypt parameters used for new data, as recommended for interactive
+// logins. They are stored with the data, so they can be raised later.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrLocked is returned when sealed data is read before the keystore has been
+// unlocked.
+var ErrLocked = xerrors.New("the keystore is locked: the passphrase of the conode is needed")
+
+// Sealed is stored by Save in place of the message of a service.
+type Sealed struct {
+	Data []byte
+}
+
+func init() {
+	network.RegisterMessages(&Sealed{})
+}
+
+// Keystore seals and opens data with a passphrase. The keys derived from the
+// passphrase are cached, so that only the first call is slow.
+type Keystore struct {
+	passphrase []byte
+	// salt is used for all the data sealed by this keystore.
+	salt []byte
+	keys map[string][]byte
+	sync.Mutex
+}
+
+// New returns a keystore using the given passphrase.
+func New(passphrase []byte) *Keystore {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		panic("couldn't read random salt: " + err.Error())
+	}
+	return &Keystore{
+		passphrase: append([]byte{}, passphrase...),
+		salt:       salt,
+		keys:       make(map[string][]byte),
+	}
+}
+
+// Seal encrypts the data and returns it as a PEM block.
+func (ks *Keystore) Seal(data []byte) ([]byte, error) {
+	aead, err := ks.aead(ks.salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, xerrors.Errorf("reading nonce: %v", err)
+	}
+	block := &pem.Block{
+		Type: pemType,
+		Headers: map[string]string{
+			"KDF":   "scrypt",
+			"Salt":  hex.EncodeToString(ks.salt),
+			"N":     strconv.Itoa(scryptN),
+			"R":     strconv.Itoa(scryptR),
+			"P":     strconv.Itoa(scryptP),
+			"Nonce": hex.EncodeToString(nonce),
+		},
+		Bytes: aead.Seal(nil, nonce, data, nil),
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// Open decrypts data returned by Seal. It fails if the data has been sealed
+// with another passphrase, or if it has been modified.
+func (ks *Keystore) Open(sealed []byte) ([]byte, error) {
+	block, _ := pem.Decode(sealed)
+	if block == nil || block.Type != pemType {
+		return nil, xerrors.New("not sealed data")
+	}
+	if block.Headers["KDF"] != "scrypt" {
+		return nil, xerrors.Errorf("unknown KDF: %s", block.Headers["KDF"])
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil {
+		return nil, xerrors.Errorf("decoding salt: %v", err)
+	}
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, xerrors.Errorf("decoding nonce: %v", err)
+	}
+	var params [3]int
+	for i, name := range []string{"N", "R", "P"} {
+		params[i], err = strconv.Atoi(block.Headers[name])
+		if err != nil {
+			return nil, xerrors.Errorf("decoding %s: %v", name, err)
+		}
+	}
+	aead, err := ks.aead(salt, params[0], params[1], params[2])
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, xerrors.New("wrong nonce size")
+	}
+	data, err := aead.Open(nil, nonce, block.Bytes, nil)
+	if err != nil {
+		return nil, xerrors.New("wrong passphrase or modified data")
+	}
+	return data, nil
+}
+
+// aead returns the cipher for the given salt and scrypt parameters.
+func (ks *Keystore) aead(salt []byte, n, r, p int) (cipher.AEAD, error) {
+	ks.Lock()
+	defer ks.Unlock()
+	id := hex.EncodeToString(salt) + "/" + strconv.Itoa(n) + "/" +
+		strconv.Itoa(r) + "/" + strconv.Itoa(p)
+	key, ok := ks.keys[id]
+	if !ok {
+		var err error
+		key, err = scrypt.Key(ks.passphrase, salt, n, r, p, 32)
+		if err != nil {
+			return nil, xerrors.Errorf("deriving key: %v", err)
+		}
+		ks.keys[id] = key
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, xerrors.Errorf("creating cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsSealed returns whether the data has been returned by Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN "+pemType+"-----"))
+}
+
+var current struct {
+	ks *Keystore
+	sync.Mutex
+}
+
+// Unlock sets the passphrase of the conode. From then on, Seal and Save seal
+// the data, and Open and Load can read sealed data.
+func Unlock(passphrase []byte) {
+	current.Lock()
+	defer current.Unlock()
+	current.ks = New(passphrase)
+}
+
+// Lock forgets the passphrase of the conode.
+func Lock() {
+	current.Lock()
+	defer current.Unlock()
+	current.ks = nil
+}
+
+// Unlocked returns whether the keystore has been unlocked.
+func Unlocked() bool {
+	return get() != nil
+}
+
+func get() *Keystore {
+	current.Lock()
+	defer current.Unlock()
+	return current.ks
+}
+
+// Seal seals the data if the keystore is unlocked, else it returns the data
+// as is.
+func Seal(data []byte) ([]byte, error) {
+	ks := get()
+	if ks == nil {
+		return data, nil
+	}
+	return ks.Seal(data)
+}
+
+// Open returns the data if it isn't sealed, else it opens it with the
+// passphrase of the conode.
+func Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	ks := get()
+	if ks == nil {
+		return nil, ErrLocked
+	}
+	return ks.Open(data)
+}
+
+// Service is the part of onet.ServiceProcessor used to store data.
+type Service interface {
+	Save(key []byte, data interface{}) error
+	Load(key []byte) (interface{}, error)
+}
+
+// Save stores the message in the database of the service. If the keystore is
+// unlocked, the message is sealed first.
+func Save(s Service, key []byte, msg interface{}) error {
+	if !Unlocked() {
+		return s.Save(key, msg)
+	}
+	buf, err := network.Marshal(msg)
+	if err != nil {
+		return xerrors.Errorf("marshaling message: %v", err)
+	}
+	sealed, err := Seal(buf)
+	if err != nil {
+		return xerrors.Errorf("sealing message: %v", err)
+	}
+	return cothority.ErrorOrNil(s.Save(key, &Sealed{Data: sealed}), "saving message")
+}
+
+// Load returns the message stored by Save, or nil if there is none. If the
+// keystore is unlocked and the message isn't sealed yet, it is sealed in
+// place, and the database needs to be compacted.
+func Load(s Service, key []byte) (interface{}, error) {
+	msg, err := s.Load(key)
+	if err != nil {
+		return nil, xerrors.Errorf("loading message: %v", err)
+	}
+	sealed, ok := msg.(*Sealed)
+	if !ok {
+		if msg != nil && Unlocked() {
+			if err := Save(s, key, msg); err != nil {
+				return nil, xerrors.Errorf("sealing message: %v", err)
+			}
+			SealedInPlace()
+		}
+		return msg, nil
+	}
+	buf, err := Open(sealed.Data)
+	if err != nil {
+		return nil, xerrors.Errorf("opening message: %v", err)
+	}
+	_, msg, err = network.Unmarshal(buf, cothority.Suite)
+	if err != nil {
+		return nil, xerrors.Errorf("unmarshaling message: %v", err)
+	}
+	return msg, nil
+}
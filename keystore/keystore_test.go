@@ -0,0 +1,100 @@
+package keystore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/onet/v3/network"
+)
+
+type testMessage struct {
+	Secret []byte
+}
+
+func init() {
+	network.RegisterMessages(&testMessage{})
+}
+
+// memService stores the marshaled messages, like the database of a service.
+type memService map[string][]byte
+
+func (m memService) Save(key []byte, data interface{}) error {
+	buf, err := network.Marshal(data)
+	if err != nil {
+		return err
+	}
+	m[string(key)] = buf
+	return nil
+}
+
+func (m memService) Load(key []byte) (interface{}, error) {
+	buf, ok := m[string(key)]
+	if !ok {
+		return nil, nil
+	}
+	_, msg, err := network.Unmarshal(buf, nil)
+	return msg, err
+}
+
+func TestKeystore_SealOpen(t *testing.T) {
+	ks := New([]byte("passphrase"))
+	data := []byte("private key")
+	sealed, err := ks.Seal(data)
+	require.NoError(t, err)
+	require.True(t, IsSealed(sealed))
+	require.False(t, IsSealed(data))
+	require.NotContains(t, string(sealed), string(data))
+
+	opened, err := ks.Open(sealed)
+	require.NoError(t, err)
+	require.Equal(t, data, opened)
+
+	// A new keystore derives the key again from the passphrase.
+	opened, err = New([]byte("passphrase")).Open(sealed)
+	require.NoError(t, err)
+	require.Equal(t, data, opened)
+
+	_, err = New([]byte("wrong")).Open(sealed)
+	require.Error(t, err)
+	_, err = ks.Open(data)
+	require.Error(t, err)
+
+	sealed[len(sealed)/2] ^= 1
+	_, err = ks.Open(sealed)
+	require.Error(t, err)
+}
+
+func TestKeystore_SaveLoad(t *testing.T) {
+	defer Lock()
+	s := memService{}
+	key := []byte("storage")
+	msg := &testMessage{Secret: []byte("share")}
+
+	// Without a passphrase, the data is stored as is.
+	require.NoError(t, Save(s, key, msg))
+	require.Contains(t, string(s[string(key)]), "share")
+
+	// Once unlocked, existing data is sealed when it is loaded.
+	Unlock([]byte("passphrase"))
+	loaded, err := Load(s, key)
+	require.NoError(t, err)
+	require.Equal(t, msg, loaded)
+	require.NotContains(t, string(s[string(key)]), "share")
+
+	require.NoError(t, Save(s, key, msg))
+	loaded, err = Load(s, key)
+	require.NoError(t, err)
+	require.Equal(t, msg, loaded)
+
+	// Sealed data cannot be read without the passphrase.
+	Lock()
+	_, err = Load(s, key)
+	require.Error(t, err)
+	Unlock([]byte("wrong"))
+	_, err = Load(s, key)
+	require.Error(t, err)
+
+	loaded, err = Load(s, []byte("unknown"))
+	require.NoError(t, err)
+	require.Nil(t, loaded)
+}
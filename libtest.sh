@@ -374,6 +374,7 @@ buildConode(){
   echo ")" ) > conode_/import.go
 
   cp "$root/conode/conode.go" conode_/conode.go
+  cp "$root/conode/keystore.go" conode_/keystore.go
   go build -o conode ./conode_
   setupConode
 }
@@ -9,6 +9,7 @@ import (
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/personhood/contracts"
 	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/sign/anon"
 	"go.dedis.ch/kyber/v3/util/key"
 	"go.dedis.ch/onet/v3/network"
@@ -20,6 +21,26 @@ import (
 // wasn't successful.
 func PopPartySpawn(cl *byzcoin.Client, desc contracts.PopDesc, dID darc.ID,
 	reward uint64, signers ...darc.Signer) (popIID byzcoin.InstanceID, err error) {
+	return popPartySpawn(cl, desc, dID, reward, nil, signers...)
+}
+
+// PopPartySpawnNext returns the instanceID of a newly created pop-party
+// following the finalized party in previous. For every consecutive previous
+// party an attendee went to, mining the new party gives continuityReward
+// more coins.
+func PopPartySpawnNext(cl *byzcoin.Client, desc contracts.PopDesc, dID darc.ID,
+	reward, continuityReward uint64, previous byzcoin.InstanceID,
+	signers ...darc.Signer) (popIID byzcoin.InstanceID, err error) {
+	cr := make([]byte, 8)
+	binary.LittleEndian.PutUint64(cr, continuityReward)
+	return popPartySpawn(cl, desc, dID, reward, byzcoin.Arguments{
+		{Name: "previous", Value: previous.Slice()},
+		{Name: "continuityReward", Value: cr},
+	}, signers...)
+}
+
+func popPartySpawn(cl *byzcoin.Client, desc contracts.PopDesc, dID darc.ID,
+	reward uint64, args byzcoin.Arguments, signers ...darc.Signer) (popIID byzcoin.InstanceID, err error) {
 	var sigStrs []string
 	for _, sig := range signers {
 		sigStrs = append(sigStrs, sig.Identity().String())
@@ -40,7 +61,7 @@ func PopPartySpawn(cl *byzcoin.Client, desc contracts.PopDesc, dID darc.ID,
 			InstanceID: byzcoin.NewInstanceID(dID),
 			Spawn: &byzcoin.Spawn{
 				ContractID: contracts.ContractPopPartyID,
-				Args: byzcoin.Arguments{{
+				Args: append(byzcoin.Arguments{{
 					Name:  "description",
 					Value: descBuf,
 				}, {
@@ -49,7 +70,7 @@ func PopPartySpawn(cl *byzcoin.Client, desc contracts.PopDesc, dID darc.ID,
 				}, {
 					Name:  "miningReward",
 					Value: mr,
-				}},
+				}}, args...),
 			},
 			SignerCounter: []uint64{signerCtrs.Counters[0] + 1},
 		},
@@ -190,28 +211,66 @@ func PopPartyMineDetailed(
 		return nil, xerrors.New("either set coinIID or d, but not both")
 	}
 	if atts == nil {
-		popProof, err := cl.GetProofAfter(popIID.Slice(), true, barrier)
-		if err != nil {
-			return nil, err
-		}
-		_, value, cID, _, err := popProof.Proof.KeyValue()
+		pop, err := popPartyGet(cl, popIID, barrier)
 		if err != nil {
 			return nil, err
 		}
-		if cID != contracts.ContractPopPartyID {
-			return nil, xerrors.New(
-				"given popIID is not of contract-type PopParty")
-		}
-		var pop contracts.PopPartyStruct
-		err = protobuf.DecodeWithConstructors(value, &pop, network.DefaultConstructors(cothority.Suite))
-		if err != nil {
-			return nil, err
-		}
-
 		atts = &pop.Attendees
 	}
+	return popPartyMine(cl, popIID, kp, atts.Keys, coinIID, d, nil)
+}
+
+// PopPartyMineContinuity collects the reward for a given attendee of the
+// party, including the continuity reward for the number of consecutive
+// parties, up to and including this one, the attendee went to. The previous
+// parties are fetched from byzcoin. As in PopPartyMine, either coinIID or d
+// must be set.
+func PopPartyMineContinuity(
+	cl *byzcoin.Client,
+	popIID byzcoin.InstanceID,
+	kp key.Pair,
+	continuity int,
+	coinIID *byzcoin.InstanceID,
+	d *darc.Darc,
+) error {
+	if (coinIID == nil && d == nil) ||
+		(coinIID != nil && d != nil) {
+		return xerrors.New("either set coinIID or d, but not both")
+	}
+	_, parties, err := PopPartyHistory(cl, popIID, continuity)
+	if err != nil {
+		return err
+	}
+	if len(parties) < continuity {
+		return xerrors.Errorf("only %d consecutive parties", len(parties))
+	}
+	scores := contracts.Continuity(parties)
+	var ring []kyber.Point
+	for _, p := range parties[len(parties)-1].Attendees.Keys {
+		if scores[p.String()] >= continuity {
+			ring = append(ring, p)
+		}
+	}
+	score := make([]byte, 8)
+	binary.LittleEndian.PutUint64(score, uint64(continuity))
+	_, err = popPartyMine(cl, popIID, kp, ring, coinIID, d, byzcoin.Arguments{{
+		Name:  "continuity",
+		Value: score,
+	}})
+	return err
+}
+
+func popPartyMine(
+	cl *byzcoin.Client,
+	popIID byzcoin.InstanceID,
+	kp key.Pair,
+	ring []kyber.Point,
+	coinIID *byzcoin.InstanceID,
+	d *darc.Darc,
+	args byzcoin.Arguments,
+) (*byzcoin.AddTxResponse, error) {
 	var mine = -1
-	for i, p := range atts.Keys {
+	for i, p := range ring {
 		if p.Equal(kp.Public) {
 			mine = i
 			break
@@ -222,11 +281,11 @@ func PopPartyMineDetailed(
 			"didn't find public key of keypair in attendees")
 	}
 
-	lrs := anon.Sign(&contracts.SuiteBlake2s{}, []byte("mine"), atts.Keys, popIID[:], mine, kp.Private)
-	args := byzcoin.Arguments{{
+	lrs := anon.Sign(&contracts.SuiteBlake2s{}, []byte("mine"), ring, popIID[:], mine, kp.Private)
+	args = append(args, byzcoin.Argument{
 		Name:  "lrs",
 		Value: lrs,
-	}}
+	})
 	if coinIID == nil {
 		darcBuf, err := d.ToProto()
 		if err != nil {
@@ -258,6 +317,109 @@ func PopPartyMineDetailed(
 	return cl.AddTransactionAndWait(ctx, 5)
 }
 
+// PopPartyAddParty links the party in next as the party following popIID.
+// The next party must have been spawned with PopPartySpawnNext.
+func PopPartyAddParty(cl *byzcoin.Client, popIID, next byzcoin.InstanceID, signers ...darc.Signer) error {
+	var sigStrs []string
+	for _, sig := range signers {
+		sigStrs = append(sigStrs, sig.Identity().String())
+	}
+	signerCtrs, err := cl.GetSignerCounters(sigStrs...)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: popIID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractPopPartyID,
+			Command:    "addParty",
+			Args:       byzcoin.Arguments{{Name: "partyID", Value: next.Slice()}},
+		},
+		SignerCounter: []uint64{signerCtrs.Counters[0] + 1},
+	})
+	if err != nil {
+		return err
+	}
+	err = ctx.FillSignersAndSignWith(signers...)
+	if err != nil {
+		return err
+	}
+	_, err = cl.AddTransactionAndWait(ctx, 5)
+	return err
+}
+
+// PopPartyRotate proves that the attendee with oldKey in the previous party
+// is the attendee with newKey in the finalized party popIID, so that the
+// attendee keeps their continuity score.
+func PopPartyRotate(cl *byzcoin.Client, popIID byzcoin.InstanceID, oldKey, newKey key.Pair) error {
+	kr, err := contracts.NewKeyRotation(popIID, oldKey, newKey)
+	if err != nil {
+		return err
+	}
+	krBuf, err := protobuf.Encode(&kr)
+	if err != nil {
+		return err
+	}
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: popIID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractPopPartyID,
+			Command:    "rotate",
+			Args:       byzcoin.Arguments{{Name: "rotation", Value: krBuf}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = cl.AddTransactionAndWait(ctx, 5)
+	return err
+}
+
+// PopPartyHistory follows the previous links of the party popIID and returns
+// at most max parties, including popIID, ordered from the oldest to popIID.
+// The continuity scores of the attendees of popIID over these parties are
+// given by contracts.Continuity.
+func PopPartyHistory(cl *byzcoin.Client, popIID byzcoin.InstanceID, max int) (
+	ids []byzcoin.InstanceID, parties []contracts.PopPartyStruct, err error) {
+	for id := popIID; len(parties) < max; {
+		pop, err := popPartyGet(cl, id, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append([]byzcoin.InstanceID{id}, ids...)
+		parties = append([]contracts.PopPartyStruct{*pop}, parties...)
+		if pop.Previous.Equal(byzcoin.InstanceID{}) {
+			break
+		}
+		id = pop.Previous
+	}
+	return
+}
+
+// popPartyGet returns the pop-party stored in popIID after the barrier.
+func popPartyGet(cl *byzcoin.Client, popIID byzcoin.InstanceID, barrier *skipchain.SkipBlock) (
+	*contracts.PopPartyStruct, error) {
+	popProof, err := cl.GetProofAfter(popIID.Slice(), true, barrier)
+	if err != nil {
+		return nil, err
+	}
+	_, value, cID, _, err := popProof.Proof.KeyValue()
+	if err != nil {
+		return nil, err
+	}
+	if cID != contracts.ContractPopPartyID {
+		return nil, xerrors.New(
+			"given popIID is not of contract-type PopParty")
+	}
+	var pop contracts.PopPartyStruct
+	err = protobuf.DecodeWithConstructors(value, &pop, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, err
+	}
+	return &pop, nil
+}
+
 // PopPartyMineDarcToCoin calculates the coin given a darc and returns the coin instance.
 func PopPartyMineDarcToCoin(cl *byzcoin.Client, d *darc.Darc) (coinIID byzcoin.InstanceID, coin byzcoin.Coin, err error) {
 	return PopPartyMineDarcToCoinAfter(cl, d, nil)
@@ -0,0 +1,130 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/kyber/v3/util/key"
+	"golang.org/x/xerrors"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+)
+
+// MaxContinuity is the highest continuity score that can be claimed when
+// mining a party, so that the number of parties to read is bounded.
+const MaxContinuity = 64
+
+// Continuity returns the continuity score of every attendee of the last of
+// the parties, indexed by the string of their public key. The score is the
+// number of consecutive parties up to the last one the attendee attended,
+// following the rotations of their key. A rotation from a key which also
+// attended the party is ignored. The parties must be ordered from the oldest
+// to the last one, each linking to the previous one.
+func Continuity(parties []PopPartyStruct) map[string]int {
+	scores := make(map[string]int)
+	for i, p := range parties {
+		rotated := make(map[string]string)
+		if i > 0 {
+			for _, r := range p.Rotations {
+				if !p.Attendees.contains(r.Old) {
+					rotated[r.New.String()] = r.Old.String()
+				}
+			}
+		}
+		next := make(map[string]int)
+		for _, k := range p.Attendees.Keys {
+			old, ok := rotated[k.String()]
+			if !ok {
+				old = k.String()
+			}
+			next[k.String()] = scores[old] + 1
+		}
+		scores = next
+	}
+	return scores
+}
+
+// NewKeyRotation returns a rotation from the key of an attendee of the party
+// preceding the party stored in partyID, to the key of the same attendee in
+// that party.
+func NewKeyRotation(partyID byzcoin.InstanceID, oldKey, newKey key.Pair) (kr KeyRotation, err error) {
+	kr.Old, kr.New = oldKey.Public, newKey.Public
+	msg, err := kr.message(partyID)
+	if err != nil {
+		return
+	}
+	kr.OldSignature, err = schnorr.Sign(cothority.Suite, oldKey.Private, msg)
+	if err != nil {
+		return
+	}
+	kr.NewSignature, err = schnorr.Sign(cothority.Suite, newKey.Private, msg)
+	return
+}
+
+// Verify checks that both keys signed the rotation for the party stored in
+// partyID.
+func (kr KeyRotation) Verify(partyID byzcoin.InstanceID) error {
+	if kr.Old == nil || kr.New == nil {
+		return errors.New("missing key in rotation")
+	}
+	msg, err := kr.message(partyID)
+	if err != nil {
+		return err
+	}
+	if err := schnorr.Verify(cothority.Suite, kr.Old, msg, kr.OldSignature); err != nil {
+		return xerrors.Errorf("wrong signature of old key: %v", err)
+	}
+	if err := schnorr.Verify(cothority.Suite, kr.New, msg, kr.NewSignature); err != nil {
+		return xerrors.Errorf("wrong signature of new key: %v", err)
+	}
+	return nil
+}
+
+// message returns the message signed by both keys of a rotation:
+//   sha256('rotate' + partyID + old + new)
+func (kr KeyRotation) message(partyID byzcoin.InstanceID) ([]byte, error) {
+	h := sha256.New()
+	h.Write([]byte("rotate"))
+	h.Write(partyID.Slice())
+	for _, p := range []kyber.Point{kr.Old, kr.New} {
+		if _, err := p.MarshalTo(h); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// continuityRing returns the attendees of the party having a continuity
+// score of at least score, reading the previous parties from rst.
+func (pp PopPartyStruct) continuityRing(rst byzcoin.ReadOnlyStateTrie, score int) ([]kyber.Point, error) {
+	if score > MaxContinuity {
+		return nil, xerrors.Errorf("continuity cannot be higher than %d", MaxContinuity)
+	}
+	parties := []PopPartyStruct{pp}
+	for len(parties) < score {
+		prev := parties[0].Previous
+		if prev.Equal(byzcoin.InstanceID{}) {
+			return nil, xerrors.Errorf("only %d consecutive parties", len(parties))
+		}
+		party, err := getFinalizedParty(rst, prev)
+		if err != nil {
+			return nil, xerrors.Errorf("couldn't get previous party: %v", err)
+		}
+		parties = append([]PopPartyStruct{*party}, parties...)
+	}
+
+	scores := Continuity(parties)
+	var ring []kyber.Point
+	for _, k := range pp.Attendees.Keys {
+		if scores[k.String()] >= score {
+			ring = append(ring, k)
+		}
+	}
+	if len(ring) == 0 {
+		return nil, xerrors.Errorf("no attendee has a continuity of %d", score)
+	}
+	return ring, nil
+}
@@ -0,0 +1,208 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/byzcoin/contracts"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/sign/anon"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/protobuf"
+)
+
+func TestContinuity(t *testing.T) {
+	var kps []*key.Pair
+	for i := 0; i < 4; i++ {
+		kps = append(kps, key.NewKeyPair(cothority.Suite))
+	}
+	party := func(keys ...int) PopPartyStruct {
+		pp := PopPartyStruct{}
+		for _, k := range keys {
+			pp.Attendees.Keys = append(pp.Attendees.Keys, kps[k].Public)
+		}
+		return pp
+	}
+	p1, p2, p3 := party(0, 1), party(0, 2), party(0, 2, 3)
+	p2.Rotations = []KeyRotation{{Old: kps[1].Public, New: kps[2].Public}}
+
+	require.Equal(t, map[string]int{
+		kps[0].Public.String(): 3,
+		kps[2].Public.String(): 3,
+		kps[3].Public.String(): 1,
+	}, Continuity([]PopPartyStruct{p1, p2, p3}))
+	require.Equal(t, map[string]int{
+		kps[0].Public.String(): 2,
+		kps[2].Public.String(): 1,
+	}, Continuity([]PopPartyStruct{p1, party(0, 2)}))
+
+	// An attendee of both parties cannot give their continuity away.
+	p2.Rotations = append(p2.Rotations, KeyRotation{Old: kps[0].Public,
+		New: kps[3].Public})
+	p2.Attendees.Keys = append(p2.Attendees.Keys, kps[3].Public)
+	require.Equal(t, map[string]int{
+		kps[0].Public.String(): 2,
+		kps[2].Public.String(): 2,
+		kps[3].Public.String(): 1,
+	}, Continuity([]PopPartyStruct{p1, p2}))
+
+	partyID := byzcoin.NewInstanceID([]byte("party"))
+	kr, err := NewKeyRotation(partyID, *kps[1], *kps[2])
+	require.NoError(t, err)
+	require.NoError(t, kr.Verify(partyID))
+	require.Error(t, kr.Verify(byzcoin.NewInstanceID(nil)))
+	kr.New = kps[3].Public
+	require.Error(t, kr.Verify(partyID))
+}
+
+// Links two parties, rotates the key of an attendee, and mines the second
+// party with a continuity bonus.
+func TestContractPopParty_Continuity(t *testing.T) {
+	rost := byzcoin.NewROSTSimul()
+	d, err := rost.CreateBasicDarc(nil, "pp")
+	require.NoError(t, err)
+
+	var kps []*key.Pair
+	for i := 0; i < 4; i++ {
+		kps = append(kps, key.NewKeyPair(cothority.Suite))
+	}
+	keys := func(ids ...int) (pts []kyber.Point) {
+		for _, i := range ids {
+			pts = append(pts, kps[i].Public)
+		}
+		return
+	}
+	store := func(id byzcoin.InstanceID, action byzcoin.StateAction, pp PopPartyStruct) {
+		buf, err := protobuf.Encode(&pp)
+		require.NoError(t, err)
+		_, err = rost.StoreAllToReplica(byzcoin.StateChanges{byzcoin.NewStateChange(
+			action, id, ContractPopPartyID, buf, d.GetBaseID())})
+		require.NoError(t, err)
+	}
+	get := func(id byzcoin.InstanceID) PopPartyStruct {
+		pp, err := getPopParty(rost, id)
+		require.NoError(t, err)
+		return *pp
+	}
+	invoke := func(id byzcoin.InstanceID, cmd string, args ...byzcoin.Argument) error {
+		buf, _, _, _, err := rost.GetValues(id.Slice())
+		require.NoError(t, err)
+		c, err := ContractPopPartyFromBytes(buf)
+		require.NoError(t, err)
+		scs, _, err := c.Invoke(rost, byzcoin.Instruction{
+			InstanceID: id,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractPopPartyID,
+				Command:    cmd,
+				Args:       args,
+			},
+		}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = rost.StoreAllToReplica(scs)
+		return err
+	}
+
+	p1 := byzcoin.NewInstanceID([]byte("party1"))
+	store(p1, byzcoin.Create, PopPartyStruct{State: ScanningState, MiningReward: 100})
+
+	// The second party can only follow a finalized party.
+	u64 := func(v uint64) []byte {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, v)
+		return buf
+	}
+	inst, err := NewInstructionPoppartySpawn(byzcoin.NewInstanceID(nil), d.GetBaseID(),
+		PopDesc{Name: "second"}, 100)
+	require.NoError(t, err)
+	inst.Spawn.Args = append(inst.Spawn.Args, newArg("previous", p1.Slice()),
+		newArg("continuityReward", u64(10)))
+	_, _, err = ContractPopParty{}.Spawn(rost, inst, nil)
+	require.Error(t, err)
+	store(p1, byzcoin.Update, PopPartyStruct{State: FinalizedState, MiningReward: 100,
+		Attendees: Attendees{Keys: keys(0, 1)}})
+	scs, _, err := ContractPopParty{}.Spawn(rost, inst, nil)
+	require.NoError(t, err)
+	_, err = rost.StoreAllToReplica(scs)
+	require.NoError(t, err)
+	p2 := byzcoin.NewInstanceID(scs[0].InstanceID)
+	require.Equal(t, p1, get(p2).Previous)
+	require.Equal(t, uint64(10), get(p2).ContinuityReward)
+
+	require.Error(t, invoke(p2, "addParty", newArg("partyID", p1.Slice())))
+	require.NoError(t, invoke(p1, "addParty", newArg("partyID", p2.Slice())))
+	require.Equal(t, p2, get(p1).Next)
+	require.Error(t, invoke(p1, "addParty", newArg("partyID", p2.Slice())))
+
+	rotate := func(oldKey, newKey int) error {
+		kr, err := NewKeyRotation(p2, *kps[oldKey], *kps[newKey])
+		require.NoError(t, err)
+		buf, err := protobuf.Encode(&kr)
+		require.NoError(t, err)
+		return invoke(p2, "rotate", newArg("rotation", buf))
+	}
+	require.Error(t, rotate(1, 2), "party not finalized")
+	pp := get(p2)
+	pp.State = FinalizedState
+	pp.Attendees.Keys = keys(0, 2, 3)
+	store(p2, byzcoin.Update, pp)
+	require.Error(t, rotate(2, 1), "new key didn't attend")
+	require.Error(t, rotate(1, 0), "new key already attended")
+	require.Error(t, rotate(0, 3), "old key also attended")
+	require.NoError(t, rotate(1, 2))
+	require.Error(t, rotate(1, 2))
+	require.Len(t, get(p2).Rotations, 1)
+
+	mine := func(k int, continuity uint64, ring []kyber.Point) error {
+		mine := -1
+		for i, p := range ring {
+			if p.Equal(kps[k].Public) {
+				mine = i
+			}
+		}
+		lrs := anon.Sign(&SuiteBlake2s{}, []byte("mine"), ring, p2[:], mine, kps[k].Private)
+		newDarc := darc.NewDarc(darc.NewRules(), []byte(kps[k].Public.String()))
+		buf, err := newDarc.ToProto()
+		require.NoError(t, err)
+		return invoke(p2, "mine", newArg("lrs", lrs), newArg("newDarc", buf),
+			newArg("continuity", u64(continuity)))
+	}
+	balance := func(k int) uint64 {
+		newDarc := darc.NewDarc(darc.NewRules(), []byte(kps[k].Public.String()))
+		coin, err := getPopCoin(rost, newDarc.GetBaseID())
+		require.NoError(t, err)
+		return coin.Value
+	}
+
+	// Only attendees of both parties can claim a continuity of 2.
+	require.Error(t, mine(3, 2, keys(0, 2, 3)))
+	require.Error(t, mine(0, 3, keys(0, 2)))
+	require.NoError(t, mine(2, 2, keys(0, 2)))
+	require.Equal(t, uint64(110), balance(2))
+	require.Error(t, mine(2, 1, keys(0, 2, 3)), "already mined")
+	require.NoError(t, mine(3, 1, keys(0, 2, 3)))
+	require.Equal(t, uint64(100), balance(3))
+}
+
+// getPopCoin returns the coin created for the darc when mining a party.
+func getPopCoin(rst byzcoin.ReadOnlyStateTrie, darcID darc.ID) (coin byzcoin.Coin, err error) {
+	h := sha256.New()
+	h.Write([]byte("coin"))
+	h.Write(darcID)
+	buf, _, cid, _, err := rst.GetValues(h.Sum(nil))
+	if err != nil {
+		return
+	}
+	if cid != contracts.ContractCoinID {
+		return coin, errors.New("not a coin instance")
+	}
+	err = protobuf.Decode(buf, &coin)
+	return
+}
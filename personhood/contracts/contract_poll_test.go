@@ -0,0 +1,147 @@
+package contracts
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/protobuf"
+)
+
+func TestContractPoll(t *testing.T) {
+	rost := byzcoin.NewROSTSimul()
+	d, err := rost.CreateBasicDarc(nil, "poll")
+	require.NoError(t, err)
+
+	var attendees []kyber.Point
+	var privates []kyber.Scalar
+	for i := 0; i < 3; i++ {
+		kp := key.NewKeyPair(cothority.Suite)
+		attendees = append(attendees, kp.Public)
+		privates = append(privates, kp.Private)
+	}
+	party := PopPartyStruct{State: ScanningState, Attendees: Attendees{Keys: attendees}}
+	partyID := byzcoin.NewInstanceID([]byte("party"))
+	storeParty := func() {
+		buf, err := protobuf.Encode(&party)
+		require.NoError(t, err)
+		_, err = rost.StoreAllToReplica(byzcoin.StateChanges{byzcoin.NewStateChange(
+			byzcoin.Create, partyID, ContractPopPartyID, buf, d.GetBaseID())})
+		require.NoError(t, err)
+	}
+	storeParty()
+
+	spawn := func(p PollContractStruct) ([]byzcoin.StateChange, error) {
+		buf, err := protobuf.Encode(&p)
+		require.NoError(t, err)
+		c := &ContractPoll{}
+		scs, _, err := c.Spawn(rost, byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(d.GetBaseID()),
+			Spawn: &byzcoin.Spawn{
+				ContractID: ContractPollID,
+				Args:       byzcoin.Arguments{{Name: "poll", Value: buf}},
+			},
+		}, nil)
+		return scs, err
+	}
+	poll := PollContractStruct{Party: partyID, Title: "Lunch", Choices: []string{"pizza", "sushi"}}
+	_, err = spawn(poll)
+	require.Error(t, err, "party is not finalized")
+	party.State = FinalizedState
+	storeParty()
+	_, err = spawn(PollContractStruct{Party: partyID, Choices: []string{"pizza"}})
+	require.Error(t, err)
+	scs, err := spawn(poll)
+	require.NoError(t, err)
+	_, err = rost.StoreAllToReplica(scs)
+	require.NoError(t, err)
+	pollID := byzcoin.NewInstanceID(scs[0].InstanceID)
+
+	get := func() PollContractStruct {
+		buf, _, _, _, err := rost.GetValues(pollID.Slice())
+		require.NoError(t, err)
+		var p PollContractStruct
+		require.NoError(t, protobuf.Decode(buf, &p))
+		return p
+	}
+	invoke := func(cmd string, args ...byzcoin.Argument) error {
+		buf, _, _, _, err := rost.GetValues(pollID.Slice())
+		require.NoError(t, err)
+		c, err := ContractPollFromBytes(buf)
+		require.NoError(t, err)
+		scs, _, err := c.Invoke(rost, byzcoin.Instruction{
+			InstanceID: pollID,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractPollID,
+				Command:    cmd,
+				Args:       args,
+			},
+		}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = rost.StoreAllToReplica(scs)
+		return err
+	}
+	voteArgs := func(choice, count int, lrs []byte) []byzcoin.Argument {
+		countBuf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(countBuf, uint64(count))
+		return []byzcoin.Argument{
+			{Name: "choice", Value: []byte{byte(choice)}},
+			{Name: "count", Value: countBuf},
+			{Name: "lrs", Value: lrs},
+		}
+	}
+	vote := func(choice, count, mine int) error {
+		return invoke("vote", voteArgs(choice, count,
+			SignPollVote(pollID, choice, count, attendees, mine, privates[mine]))...)
+	}
+
+	require.NoError(t, vote(0, 0, 0))
+	require.NoError(t, vote(1, 0, 1))
+	first := voteArgs(1, 0, SignPollVote(pollID, 1, 0, attendees, 2, privates[2]))
+	require.NoError(t, invoke("vote", first...))
+	require.Equal(t, []int{1, 2}, get().Tally())
+
+	// Voting again replaces the vote of the attendee.
+	require.Error(t, vote(0, 0, 2))
+	require.Error(t, vote(0, 2, 2))
+	require.NoError(t, vote(0, 1, 2))
+	require.Len(t, get().Votes, 3)
+	require.Equal(t, []int{2, 1}, get().Tally())
+
+	// The first vote cannot be replayed to revert the second one, nor be
+	// given a newer count.
+	require.Error(t, invoke("vote", first...))
+	replay := voteArgs(1, 2, first[2].Value)
+	require.Error(t, invoke("vote", replay...))
+	require.Equal(t, []int{2, 1}, get().Tally())
+
+	// Choices that don't exist, signatures for another choice or from
+	// somebody not attending are refused.
+	require.Error(t, vote(2, 1, 0))
+	require.Error(t, invoke("vote", voteArgs(1, 1,
+		SignPollVote(pollID, 0, 1, attendees, 0, privates[0]))...))
+	other := key.NewKeyPair(cothority.Suite)
+	ring := []kyber.Point{attendees[0], other.Public}
+	require.Error(t, invoke("vote", voteArgs(1, 0,
+		SignPollVote(pollID, 1, 0, ring, 1, other.Private))...))
+
+	// Anybody can verify the tally.
+	p := get()
+	require.NoError(t, p.Verify(pollID, attendees))
+	require.Error(t, p.Verify(byzcoin.NewInstanceID(nil), attendees))
+	p.Votes[0].Choice = 1
+	require.Error(t, p.Verify(pollID, attendees))
+	p = get()
+	p.Votes = append(p.Votes, p.Votes[0])
+	require.Error(t, p.Verify(pollID, attendees))
+
+	require.NoError(t, invoke("close"))
+	require.True(t, get().Closed)
+	require.Error(t, vote(1, 1, 0))
+}
@@ -58,12 +58,17 @@ func ContractPopPartyFromBytes(in []byte) (byzcoin.Contract, error) {
 }
 
 // VerifyInstruction overrides the basic VerifyInstruction in case of a "mine" command, because this command
-// is not protected by a darc, but by a linkable ring signature.
+// is not protected by a darc, but by a linkable ring signature. The same holds for the "rotate" command,
+// which is protected by the signatures of the keys of the attendee.
 func (c ContractPopParty) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, ctxHash []byte) error {
 	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "mine" {
 		log.Lvl2("not verifying darc for mining")
 		return nil
 	}
+	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "rotate" {
+		log.Lvl2("not verifying darc for key rotation")
+		return nil
+	}
 	return c.BasicContract.VerifyInstruction(rst, inst, ctxHash)
 }
 
@@ -71,6 +76,9 @@ func (c ContractPopParty) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst
 //  - description holds a protobuf encoded 'Description'
 //  - darcID holds the id of the darc responsible for the pop party
 //  - miningReward defines how much the 'mine' command will put into a coin-account
+//  - previous is optional and holds the instance of the finalized party preceding this one
+//  - continuityReward is optional and defines how much the 'mine' command adds for every
+//    consecutive previous party the miner attended
 func (c ContractPopParty) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction,
 	coins []byzcoin.Coin) (scs []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
 	cout = coins
@@ -112,6 +120,19 @@ func (c ContractPopParty) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Inst
 	}
 	c.MiningReward = binary.LittleEndian.Uint64(miningRewardBuf)
 
+	if prev := inst.Spawn.Args.Search("previous"); prev != nil {
+		c.Previous = byzcoin.NewInstanceID(prev)
+		if _, err := getFinalizedParty(rst, c.Previous); err != nil {
+			return nil, nil, errors.New("invalid previous party: " + err.Error())
+		}
+	}
+	if crBuf := inst.Spawn.Args.Search("continuityReward"); crBuf != nil {
+		if len(crBuf) != 8 {
+			return nil, nil, errors.New("continuityReward needs 8 bytes")
+		}
+		c.ContinuityReward = binary.LittleEndian.Uint64(crBuf)
+	}
+
 	ppiBuf, err := protobuf.Encode(&c.PopPartyStruct)
 	if err != nil {
 		return nil, nil, errors.New("couldn't marshal PopPartyStruct: " + err.Error())
@@ -145,10 +166,16 @@ func (sb SuiteBlake2s) XOF(key []byte) kyber.XOF {
 //  - barrier to activate the pop-party
 //  - finalize to store the attendees. If all organizers finalize using the same list of attendees,
 //    the party is finalized
-//  - addParty to add a new party to the list - not supported yet
+//  - addParty to link the party given in 'partyID' as the next party. The next party must have
+//    been spawned with this party as its previous party.
+//  - rotate to store the 'rotation' of the key of an attendee of the previous party to their
+//    key in this finalized party. Every key can only be rotated once, and the old key must not
+//    have attended this party.
 //  - mine to collect the reward. 'lrs' must hold a correct, unique linkable ring signature. If
 //    'coinIID' is set, this coin will be filled. Else 'newDarc' will be used to create a darc,
-//    derive a coin, and fill this coin.
+//    derive a coin, and fill this coin. If 'continuity' holds a score higher than 1, the
+//    signature must be done over the attendees having at least this continuity score, and
+//    the reward is increased by ContinuityReward for every previous party.
 func (c *ContractPopParty) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction,
 	coins []byzcoin.Coin) (scs []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
 	cout = coins
@@ -219,6 +246,61 @@ func (c *ContractPopParty) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.In
 			c.State = FinalizedState
 		}
 
+	case "addParty":
+		if !c.Next.Equal(byzcoin.InstanceID{}) {
+			return nil, nil, errors.New("next party is already set")
+		}
+		next := byzcoin.NewInstanceID(inst.Invoke.Args.Search("partyID"))
+		party, err := getPopParty(rst, next)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !party.Previous.Equal(inst.InstanceID) {
+			return nil, nil, errors.New("the previous party of the next party is not this party")
+		}
+		c.Next = next
+
+	case "rotate":
+		if c.State != FinalizedState {
+			return nil, nil, errors.New("cannot rotate keys when party is not finalized")
+		}
+		if c.Previous.Equal(byzcoin.InstanceID{}) {
+			return nil, nil, errors.New("cannot rotate keys without previous party")
+		}
+		var kr KeyRotation
+		err = protobuf.DecodeWithConstructors(inst.Invoke.Args.Search("rotation"), &kr,
+			network.DefaultConstructors(cothority.Suite))
+		if err != nil {
+			return nil, nil, errors.New("couldn't unmarshal rotation: " + err.Error())
+		}
+		if err := kr.Verify(inst.InstanceID); err != nil {
+			return nil, nil, err
+		}
+		prev, err := getFinalizedParty(rst, c.Previous)
+		if err != nil {
+			return nil, nil, errors.New("couldn't get previous party: " + err.Error())
+		}
+		if !prev.Attendees.contains(kr.Old) {
+			return nil, nil, errors.New("old key didn't attend the previous party")
+		}
+		if prev.Attendees.contains(kr.New) {
+			return nil, nil, errors.New("new key already attended the previous party")
+		}
+		if !c.Attendees.contains(kr.New) {
+			return nil, nil, errors.New("new key didn't attend this party")
+		}
+		// Else an attendee of both parties could give their continuity
+		// to a newcomer, and keep it.
+		if c.Attendees.contains(kr.Old) {
+			return nil, nil, errors.New("old key also attended this party")
+		}
+		for _, r := range c.Rotations {
+			if r.Old.Equal(kr.Old) || r.New.Equal(kr.New) {
+				return nil, nil, errors.New("key already rotated")
+			}
+		}
+		c.Rotations = append(c.Rotations, kr)
+
 	case "mine":
 		if c.State != FinalizedState {
 			return nil, nil, errors.New("cannot mine when party is not finalized")
@@ -227,7 +309,31 @@ func (c *ContractPopParty) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.In
 		if lrs == nil {
 			return nil, nil, errors.New("need lrs argument")
 		}
-		tag, err := anon.Verify(&SuiteBlake2s{}, []byte("mine"), c.Attendees.Keys, inst.InstanceID[:], lrs)
+		ring := c.Attendees.Keys
+		reward := c.MiningReward
+		if scoreBuf := inst.Invoke.Args.Search("continuity"); scoreBuf != nil {
+			if len(scoreBuf) != 8 {
+				return nil, nil, errors.New("continuity needs 8 bytes")
+			}
+			score := binary.LittleEndian.Uint64(scoreBuf)
+			if score > MaxContinuity {
+				return nil, nil, fmt.Errorf("continuity cannot be higher than %d", MaxContinuity)
+			}
+			if score > 1 {
+				ring, err = c.continuityRing(rst, int(score))
+				if err != nil {
+					return nil, nil, err
+				}
+				bonus := byzcoin.Coin{Value: c.MiningReward}
+				for i := uint64(1); i < score; i++ {
+					if err := bonus.SafeAdd(c.ContinuityReward); err != nil {
+						return nil, nil, errors.New("couldn't add continuity reward: " + err.Error())
+					}
+				}
+				reward = bonus.Value
+			}
+		}
+		tag, err := anon.Verify(&SuiteBlake2s{}, []byte("mine"), ring, inst.InstanceID[:], lrs)
 		if err != nil {
 			return nil, nil, errors.New("error while verifying signature: " + err.Error())
 		}
@@ -276,7 +382,7 @@ func (c *ContractPopParty) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.In
 				return nil, nil, errors.New("couldn't unmarshal coin: " + err.Error())
 			}
 		}
-		err = coin.SafeAdd(c.MiningReward)
+		err = coin.SafeAdd(reward)
 		if err != nil {
 			return nil, nil, errors.New("couldn't add mining reward: " + err.Error())
 		}
@@ -304,6 +410,46 @@ func (c *ContractPopParty) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.In
 	return scs, coins, nil
 }
 
+// contains returns true if the key is one of the attendees.
+func (atts Attendees) contains(p kyber.Point) bool {
+	for _, k := range atts.Keys {
+		if k.Equal(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPopParty returns the pop-party stored in the given instance.
+func getPopParty(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) (*PopPartyStruct, error) {
+	buf, _, cid, _, err := rst.GetValues(id.Slice())
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't get party: %v", err)
+	}
+	if cid != ContractPopPartyID {
+		return nil, errors.New("party is not a pop-party instance")
+	}
+	var party PopPartyStruct
+	err = protobuf.DecodeWithConstructors(buf, &party, network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal party: %v", err)
+	}
+	return &party, nil
+}
+
+// getFinalizedParty returns the finalized pop-party stored in the given
+// instance.
+func getFinalizedParty(rst byzcoin.ReadOnlyStateTrie, id byzcoin.InstanceID) (*PopPartyStruct, error) {
+	party, err := getPopParty(rst, id)
+	if err != nil {
+		return nil, err
+	}
+	if party.State != FinalizedState {
+		return nil, errors.New("party is not finalized")
+	}
+	return party, nil
+}
+
 // NewInstructionPoppartySpawn returns a new instruction that is ready to be
 // sent to byzcoin to spawn a new pop-party instance.
 func NewInstructionPoppartySpawn(dst byzcoin.InstanceID, did darc.ID,
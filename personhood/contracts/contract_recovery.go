@@ -0,0 +1,258 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+)
+
+// ContractRecoveryRequestID denotes a contract that recovers a darc with the
+// approval of a threshold of guardians.
+var ContractRecoveryRequestID = "recoveryRequest"
+
+// RuleRecover is the rule of a darc holding the threshold of guardians
+// needed to recover it, for example:
+//
+//	threshold<2/3, ed25519:a, ed25519:b, darc:c>
+const RuleRecover = darc.Action("_recover")
+
+// RecoveryDelay is the time between the creation of a recovery request and
+// its execution, during which the current signers of the darc can cancel
+// it.
+var RecoveryDelay = 72 * time.Hour
+
+// RecoveryRequestID returns the instance of the recovery request of a darc.
+// There can only be one pending request for every darc.
+func RecoveryRequestID(darcID darc.ID) byzcoin.InstanceID {
+	h := sha256.New()
+	h.Write([]byte(ContractRecoveryRequestID))
+	h.Write(darcID)
+	return byzcoin.NewInstanceID(h.Sum(nil))
+}
+
+// ContractRecoveryRequestFromBytes returns a recovery request contract given
+// a slice of bytes, or an error if something went wrong.
+func ContractRecoveryRequestFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractRecoveryRequest{}
+	err := protobuf.Decode(in, &c.RecoveryRequestStruct)
+	if err != nil {
+		return nil, errors.New("couldn't unmarshal instance data: " + err.Error())
+	}
+	return c, nil
+}
+
+// ContractRecoveryRequest is a request of the guardians of a darc to replace
+// its signers and owners with a new identity, when all the devices of a user
+// are lost. It is spawned on the darc to recover, which needs the following
+// rules:
+//   - spawn:recoveryRequest and invoke:recoveryRequest.approve for every
+//     guardian
+//   - invoke:recoveryRequest.cancel for the current signers
+//   - _recover with the threshold of guardians needed to recover the darc
+//
+// Once enough guardians approved the request, and RecoveryDelay passed
+// without the current signers cancelling it, anybody can execute it.
+type ContractRecoveryRequest struct {
+	byzcoin.BasicContract
+	RecoveryRequestStruct
+}
+
+// VerifyInstruction allows for an unsigned "execute" command, as the contract
+// verifies the approvals of the guardians itself.
+func (c ContractRecoveryRequest) VerifyInstruction(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, ctxHash []byte) error {
+	if inst.GetType() == byzcoin.InvokeType && inst.Invoke.Command == "execute" {
+		log.Lvl2("not verifying darc for executing recovery")
+		return nil
+	}
+	return c.BasicContract.VerifyInstruction(rst, inst, ctxHash)
+}
+
+// Spawn creates a new recovery request for the darc it is spawned on. The
+// "identity" argument holds the identity that will replace the signers and
+// the owners of the darc. The guardians signing the spawn instruction
+// approve the request.
+func (c *ContractRecoveryRequest) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	var cid string
+	_, _, cid, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get darc: %v", err)
+	}
+	if cid != byzcoin.ContractDarcID {
+		return nil, nil, errors.New("can only recover darcs")
+	}
+	d, err := rst.LoadDarc(darcID)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't load darc: %v", err)
+	}
+	if !d.Rules.Contains(RuleRecover) {
+		return nil, nil, errors.New("darc has no guardians")
+	}
+
+	identity := string(inst.Spawn.Args.Search("identity"))
+	if _, err := darc.ParseIdentity(identity); err != nil {
+		return nil, nil, xerrors.Errorf("invalid identity: %v", err)
+	}
+	now, err := recoveryTime(rst)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.RecoveryRequestStruct = RecoveryRequestStruct{
+		Darc:     darcID,
+		Identity: identity,
+		Created:  now,
+	}
+	c.approve(inst)
+
+	buf, err := protobuf.Encode(&c.RecoveryRequestStruct)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode recovery request: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, RecoveryRequestID(darcID),
+			ContractRecoveryRequestID, buf, darcID),
+	}
+	return
+}
+
+// Invoke has the following commands:
+//   - approve adds the signers of the instruction to the guardians approving
+//     the request
+//   - cancel removes the request
+//   - execute replaces the signers and the owners of the darc, as well as the
+//     rules to evolve it and to cancel a recovery, with the identity of the
+//     request, and removes the request. The approvals must meet the _recover
+//     rule of the darc, and RecoveryDelay must have passed.
+func (c *ContractRecoveryRequest) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get darc: %v", err)
+	}
+
+	switch inst.Invoke.Command {
+	case "approve":
+		c.approve(inst)
+		buf, err := protobuf.Encode(&c.RecoveryRequestStruct)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode recovery request: %v", err)
+		}
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
+			ContractRecoveryRequestID, buf, darcID))
+	case "cancel":
+		log.Lvlf2("Cancelling recovery of darc %x", c.Darc)
+		sc = append(sc, byzcoin.NewStateChange(byzcoin.Remove, inst.InstanceID,
+			ContractRecoveryRequestID, nil, darcID))
+	case "execute":
+		now, err := recoveryTime(rst)
+		if err != nil {
+			return nil, nil, err
+		}
+		if now < c.Created+RecoveryDelay.Nanoseconds() {
+			return nil, nil, errors.New("recovery delay has not passed yet")
+		}
+		d, err := rst.LoadDarc(c.Darc)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't load darc: %v", err)
+		}
+		err = darc.EvalExpr(d.Rules.Get(RuleRecover), trieGetDarc(rst), c.Approvals...)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("not enough guardians approved: %v", err)
+		}
+
+		newDarc := d.Copy()
+		expr := expression.Expr(c.Identity)
+		if err = newDarc.Rules.UpdateSign(expr); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't update sign rule: %v", err)
+		}
+		if err = newDarc.Rules.UpdateEvolution(expr); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't update evolve rule: %v", err)
+		}
+		cancel := darc.Action("invoke:" + ContractRecoveryRequestID + ".cancel")
+		for _, rule := range []darc.Action{"invoke:" + byzcoin.ContractDarcID + ".evolve",
+			"invoke:" + byzcoin.ContractDarcID + ".evolve_unrestricted", cancel} {
+			if newDarc.Rules.Contains(rule) {
+				err = newDarc.Rules.UpdateRule(rule, expr)
+			} else if rule == cancel {
+				err = newDarc.Rules.AddRule(rule, expr)
+			}
+			if err != nil {
+				return nil, nil, xerrors.Errorf("couldn't update rule %s: %v", rule, err)
+			}
+		}
+		if err = newDarc.EvolveFrom(d); err != nil {
+			return nil, nil, xerrors.Errorf("couldn't evolve darc: %v", err)
+		}
+		newDarcBuf, err := newDarc.ToProto()
+		if err != nil {
+			return nil, nil, xerrors.Errorf("couldn't encode darc: %v", err)
+		}
+		log.Lvlf2("Recovering darc %x for %s", c.Darc, c.Identity)
+		sc = append(sc,
+			byzcoin.NewStateChange(byzcoin.Update, byzcoin.NewInstanceID(c.Darc),
+				byzcoin.ContractDarcID, newDarcBuf, c.Darc),
+			byzcoin.NewStateChange(byzcoin.Remove, inst.InstanceID,
+				ContractRecoveryRequestID, nil, darcID))
+	default:
+		return nil, nil, errors.New("unknown command: " + inst.Invoke.Command)
+	}
+	return
+}
+
+// approve adds the signers of the instruction to the approvals.
+func (rr *RecoveryRequestStruct) approve(inst byzcoin.Instruction) {
+	for _, id := range inst.SignerIdentities {
+		approved := false
+		for _, a := range rr.Approvals {
+			if a == id.String() {
+				approved = true
+				break
+			}
+		}
+		if !approved {
+			rr.Approvals = append(rr.Approvals, id.String())
+		}
+	}
+}
+
+// recoveryTime returns the timestamp of the block being created, in
+// nanoseconds.
+func recoveryTime(rst byzcoin.ReadOnlyStateTrie) (int64, error) {
+	tr, ok := rst.(byzcoin.TimeReader)
+	if !ok {
+		return 0, errors.New("time of the block is not available")
+	}
+	return tr.GetCurrentBlockTimestamp(), nil
+}
+
+// trieGetDarc returns a darc.GetDarc that loads the darcs from the trie.
+func trieGetDarc(rst byzcoin.ReadOnlyStateTrie) darc.GetDarc {
+	return func(str string, latest bool) *darc.Darc {
+		if !strings.HasPrefix(str, "darc:") {
+			return nil
+		}
+		darcID, err := hex.DecodeString(str[5:])
+		if err != nil {
+			return nil
+		}
+		d, err := rst.LoadDarc(darcID)
+		if err != nil {
+			return nil
+		}
+		return d
+	}
+}
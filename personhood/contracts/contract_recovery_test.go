@@ -0,0 +1,136 @@
+package contracts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/protobuf"
+)
+
+// rostTime adds the time of the block to the simulated trie.
+type rostTime struct {
+	*byzcoin.ROSTSimul
+	now int64
+}
+
+func (rt *rostTime) GetCurrentBlockTimestamp() int64 {
+	return rt.now
+}
+
+// Recovers a darc with two out of three guardians, after the delay.
+func TestContractRecoveryRequest(t *testing.T) {
+	rost := &rostTime{ROSTSimul: byzcoin.NewROSTSimul()}
+	device := darc.NewSignerEd25519(nil, nil)
+	newDevice := darc.NewSignerEd25519(nil, nil)
+	var guardians []darc.Signer
+	var guardianIDs []string
+	for i := 0; i < 3; i++ {
+		guardians = append(guardians, darc.NewSignerEd25519(nil, nil))
+		guardianIDs = append(guardianIDs, guardians[i].Identity().String())
+	}
+
+	d := darc.NewDarc(darc.InitRules([]darc.Identity{device.Identity()},
+		[]darc.Identity{device.Identity()}), []byte("user"))
+	require.NoError(t, d.Rules.AddRule("invoke:darc.evolve_unrestricted",
+		expression.Expr(device.Identity().String())))
+	require.NoError(t, d.Rules.AddRule(RuleRecover,
+		expression.Expr("threshold<2/3,"+guardianIDs[0]+","+guardianIDs[1]+","+guardianIDs[2]+">")))
+	dBuf, err := d.ToProto()
+	require.NoError(t, err)
+	_, err = rost.StoreAllToReplica(byzcoin.StateChanges{byzcoin.NewStateChange(byzcoin.Create,
+		byzcoin.NewInstanceID(d.GetBaseID()), byzcoin.ContractDarcID, dBuf, d.GetBaseID())})
+	require.NoError(t, err)
+	rrID := RecoveryRequestID(d.GetBaseID())
+
+	store := func(scs byzcoin.StateChanges, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, sc := range scs {
+			if sc.StateAction == byzcoin.Remove {
+				delete(rost.Values, string(sc.InstanceID))
+			} else if _, err = rost.StoreAllToReplica(byzcoin.StateChanges{sc}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	spawn := func(identity string, signers ...darc.Signer) error {
+		inst := byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(d.GetBaseID()),
+			Spawn: &byzcoin.Spawn{
+				ContractID: ContractRecoveryRequestID,
+				Args:       byzcoin.Arguments{{Name: "identity", Value: []byte(identity)}},
+			},
+		}
+		for _, s := range signers {
+			inst.SignerIdentities = append(inst.SignerIdentities, s.Identity())
+		}
+		scs, _, err := (&ContractRecoveryRequest{}).Spawn(rost, inst, nil)
+		return store(scs, err)
+	}
+	invoke := func(cmd string, signers ...darc.Signer) error {
+		buf, _, _, _, err := rost.GetValues(rrID.Slice())
+		if err != nil {
+			return err
+		}
+		c, err := ContractRecoveryRequestFromBytes(buf)
+		require.NoError(t, err)
+		inst := byzcoin.Instruction{
+			InstanceID: rrID,
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractRecoveryRequestID,
+				Command:    cmd,
+			},
+		}
+		for _, s := range signers {
+			inst.SignerIdentities = append(inst.SignerIdentities, s.Identity())
+		}
+		scs, _, err := c.Invoke(rost, inst, nil)
+		return store(scs, err)
+	}
+	get := func() (rr RecoveryRequestStruct) {
+		buf, _, _, _, err := rost.GetValues(rrID.Slice())
+		require.NoError(t, err)
+		require.NoError(t, protobuf.Decode(buf, &rr))
+		return
+	}
+
+	require.Error(t, spawn("not an identity", guardians[0]))
+	require.NoError(t, spawn(newDevice.Identity().String(), guardians[0]))
+	require.Equal(t, []string{guardianIDs[0]}, get().Approvals)
+
+	// The current signers can cancel the request.
+	require.NoError(t, invoke("cancel", device))
+	_, _, _, _, err = rost.GetValues(rrID.Slice())
+	require.Error(t, err)
+
+	rost.now = 1000
+	require.NoError(t, spawn(newDevice.Identity().String(), guardians[0]))
+	require.NoError(t, invoke("approve", guardians[0]))
+	require.Len(t, get().Approvals, 1)
+	rost.now += RecoveryDelay.Nanoseconds()
+	require.Error(t, invoke("execute"), "not enough approvals")
+	require.NoError(t, invoke("approve", guardians[2]))
+	require.Equal(t, []string{guardianIDs[0], guardianIDs[2]}, get().Approvals)
+	rost.now -= time.Second.Nanoseconds()
+	require.Error(t, invoke("execute"), "too early")
+	rost.now += time.Second.Nanoseconds()
+	require.NoError(t, invoke("execute"))
+
+	_, _, _, _, err = rost.GetValues(rrID.Slice())
+	require.Error(t, err)
+	recovered, err := rost.LoadDarc(d.GetBaseID())
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), recovered.Version)
+	for _, rule := range []darc.Action{"_sign", "_evolve", "invoke:darc.evolve_unrestricted",
+		"invoke:" + darc.Action(ContractRecoveryRequestID) + ".cancel"} {
+		require.Equal(t, newDevice.Identity().String(), string(recovered.Rules.Get(rule)))
+	}
+	require.False(t, recovered.Rules.Contains("invoke:darc.evolve"))
+	require.Equal(t, d.Rules.Get(RuleRecover), recovered.Rules.Get(RuleRecover))
+}
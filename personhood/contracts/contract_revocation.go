@@ -0,0 +1,185 @@
+package contracts
+
+import (
+	"bytes"
+	"errors"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// ContractRevocationListID denotes a contract that holds the keys of an
+// issuer of verifiable credentials, and the credentials it revoked.
+var ContractRevocationListID = "revocationlist"
+
+const (
+	// IssuerKeyEd25519 is the type of the Ed25519 issuer keys.
+	IssuerKeyEd25519 = "ed25519"
+	// IssuerKeyBLS is the type of the BLS issuer keys, on the bn256 curve.
+	IssuerKeyBLS = "bls"
+)
+
+// ContractRevocationListFromBytes returns a revocation list contract given a
+// slice of bytes, or an error if something went wrong.
+func ContractRevocationListFromBytes(in []byte) (byzcoin.Contract, error) {
+	c := &ContractRevocationList{}
+	err := protobuf.Decode(in, &c.RevocationListStruct)
+	if err != nil {
+		return nil, errors.New("couldn't unmarshal instance data: " + err.Error())
+	}
+	return c, nil
+}
+
+// ContractRevocationList is an issuer of verifiable credentials. As the
+// instance is guarded by the darc of the issuer, only the issuer can change
+// its keys and revoke credentials.
+type ContractRevocationList struct {
+	byzcoin.BasicContract
+	RevocationListStruct
+}
+
+// Spawn creates a new revocation list with the issuer key given in the
+// arguments "type" and "public". If "type" is missing, it is an Ed25519 key.
+func (c *ContractRevocationList) Spawn(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't get darc: %v", err)
+	}
+
+	key, err := newIssuerKey(inst.Spawn.Args)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.RevocationListStruct = RevocationListStruct{Keys: []IssuerKey{key}}
+	buf, err := protobuf.Encode(&c.RevocationListStruct)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode revocation list: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Create, inst.DeriveID(""),
+			ContractRevocationListID, buf, darcID),
+	}
+	return
+}
+
+// Invoke has the following commands:
+//  - add_key adds the key given in "type" and "public"
+//  - remove_key removes the key given in "public". All the credentials
+//    signed by this key are not valid anymore.
+//  - revoke adds the credential instance given in "credentialID" to the
+//    list of revoked credentials.
+func (c *ContractRevocationList) Invoke(rst byzcoin.ReadOnlyStateTrie, inst byzcoin.Instruction, coins []byzcoin.Coin) (sc []byzcoin.StateChange, cout []byzcoin.Coin, err error) {
+	cout = coins
+
+	var darcID darc.ID
+	_, _, _, darcID, err = rst.GetValues(inst.InstanceID.Slice())
+	if err != nil {
+		return
+	}
+
+	switch inst.Invoke.Command {
+	case "add_key":
+		key, err := newIssuerKey(inst.Invoke.Args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c.FindKey(key.Public) != nil {
+			return nil, nil, errors.New("key already in the list")
+		}
+		c.Keys = append(c.Keys, key)
+	case "remove_key":
+		public := inst.Invoke.Args.Search("public")
+		if c.FindKey(public) == nil {
+			return nil, nil, errors.New("unknown key")
+		}
+		var keys []IssuerKey
+		for _, k := range c.Keys {
+			if !bytes.Equal(k.Public, public) {
+				keys = append(keys, k)
+			}
+		}
+		c.Keys = keys
+	case "revoke":
+		credID := inst.Invoke.Args.Search("credentialID")
+		if len(credID) != len(byzcoin.InstanceID{}) {
+			return nil, nil, errors.New("wrong credentialID argument")
+		}
+		id := byzcoin.NewInstanceID(credID)
+		if c.IsRevoked(id) {
+			return nil, nil, errors.New("credential already revoked")
+		}
+		c.Revoked = append(c.Revoked, id)
+	default:
+		return nil, nil, errors.New("unknown command: " + inst.Invoke.Command)
+	}
+
+	buf, err := protobuf.Encode(&c.RevocationListStruct)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("couldn't encode revocation list: %v", err)
+	}
+	sc = []byzcoin.StateChange{
+		byzcoin.NewStateChange(byzcoin.Update, inst.InstanceID,
+			ContractRevocationListID, buf, darcID),
+	}
+	return
+}
+
+// FindKey returns the issuer key with the given public key, or nil if it is
+// not in the list.
+func (rl RevocationListStruct) FindKey(public []byte) *IssuerKey {
+	for i := range rl.Keys {
+		if bytes.Equal(rl.Keys[i].Public, public) {
+			return &rl.Keys[i]
+		}
+	}
+	return nil
+}
+
+// IsRevoked returns true if the credential instance has been revoked.
+func (rl RevocationListStruct) IsRevoked(credID byzcoin.InstanceID) bool {
+	for _, id := range rl.Revoked {
+		if id.Equal(credID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Point returns the public key as a point of the curve of its type.
+func (k IssuerKey) Point() (kyber.Point, error) {
+	var p kyber.Point
+	switch k.Type {
+	case IssuerKeyEd25519:
+		p = cothority.Suite.Point()
+	case IssuerKeyBLS:
+		p = pairing.NewSuiteBn256().G2().Point()
+	default:
+		return nil, errors.New("unknown key type: " + k.Type)
+	}
+	if err := p.UnmarshalBinary(k.Public); err != nil {
+		return nil, xerrors.Errorf("couldn't unmarshal key: %v", err)
+	}
+	return p, nil
+}
+
+func newIssuerKey(args byzcoin.Arguments) (IssuerKey, error) {
+	key := IssuerKey{
+		Type:   string(args.Search("type")),
+		Public: args.Search("public"),
+	}
+	if key.Type == "" {
+		key.Type = IssuerKeyEd25519
+	}
+	if _, err := key.Point(); err != nil {
+		return IssuerKey{}, xerrors.Errorf("invalid issuer key: %v", err)
+	}
+	return key, nil
+}
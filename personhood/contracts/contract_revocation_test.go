@@ -0,0 +1,87 @@
+package contracts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/protobuf"
+)
+
+func TestContractRevocationList(t *testing.T) {
+	rost := byzcoin.NewROSTSimul()
+	d, err := rost.CreateBasicDarc(nil, "issuer")
+	require.NoError(t, err)
+
+	pub1, err := key.NewKeyPair(cothority.Suite).Public.MarshalBinary()
+	require.NoError(t, err)
+	bn := pairing.NewSuiteBn256()
+	pub2, err := bn.G2().Point().Pick(bn.RandomStream()).MarshalBinary()
+	require.NoError(t, err)
+
+	inst := byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(d.GetBaseID()),
+		Spawn: &byzcoin.Spawn{
+			ContractID: ContractRevocationListID,
+			Args:       byzcoin.Arguments{{Name: "public", Value: pub1}},
+		},
+	}
+	c := &ContractRevocationList{}
+	scs, _, err := c.Spawn(rost, inst, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(scs))
+	_, err = rost.StoreAllToReplica(scs)
+	require.NoError(t, err)
+	listID := scs[0].InstanceID
+
+	invoke := func(cmd string, args ...byzcoin.Argument) error {
+		buf, _, _, _, err := rost.GetValues(listID)
+		require.NoError(t, err)
+		c, err := ContractRevocationListFromBytes(buf)
+		require.NoError(t, err)
+		scs, _, err := c.Invoke(rost, byzcoin.Instruction{
+			InstanceID: byzcoin.NewInstanceID(listID),
+			Invoke: &byzcoin.Invoke{
+				ContractID: ContractRevocationListID,
+				Command:    cmd,
+				Args:       args,
+			},
+		}, nil)
+		if err != nil {
+			return err
+		}
+		_, err = rost.StoreAllToReplica(scs)
+		return err
+	}
+	list := func() RevocationListStruct {
+		buf, _, _, _, err := rost.GetValues(listID)
+		require.NoError(t, err)
+		var rl RevocationListStruct
+		require.NoError(t, protobuf.Decode(buf, &rl))
+		return rl
+	}
+
+	require.Error(t, invoke("add_key", byzcoin.Argument{Name: "public", Value: pub1}))
+	require.Error(t, invoke("add_key", byzcoin.Argument{Name: "type",
+		Value: []byte(IssuerKeyEd25519)}, byzcoin.Argument{Name: "public", Value: pub2}))
+	require.NoError(t, invoke("add_key", byzcoin.Argument{Name: "type",
+		Value: []byte(IssuerKeyBLS)}, byzcoin.Argument{Name: "public", Value: pub2}))
+	require.Len(t, list().Keys, 2)
+	require.Equal(t, IssuerKeyBLS, list().FindKey(pub2).Type)
+
+	require.NoError(t, invoke("remove_key", byzcoin.Argument{Name: "public", Value: pub1}))
+	require.Nil(t, list().FindKey(pub1))
+	require.Error(t, invoke("remove_key", byzcoin.Argument{Name: "public", Value: pub1}))
+
+	credID := byzcoin.NewInstanceID([]byte("credential"))
+	require.False(t, list().IsRevoked(credID))
+	require.Error(t, invoke("revoke", byzcoin.Argument{Name: "credentialID", Value: []byte("short")}))
+	require.NoError(t, invoke("revoke", byzcoin.Argument{Name: "credentialID", Value: credID.Slice()}))
+	require.True(t, list().IsRevoked(credID))
+	require.Error(t, invoke("revoke", byzcoin.Argument{Name: "credentialID", Value: credID.Slice()}))
+
+	require.Error(t, invoke("unknown"))
+}
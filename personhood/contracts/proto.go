@@ -2,6 +2,7 @@ package contracts
 
 import (
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 )
@@ -54,6 +55,36 @@ type Attribute struct {
 	Value []byte
 }
 
+// RevocationListStruct is the data stored in a revocation list instance. It
+// defines an issuer of verifiable credentials: the keys the issuer signs
+// with, and the credentials it revoked.
+type RevocationListStruct struct {
+	Keys    []IssuerKey
+	Revoked []byzcoin.InstanceID
+}
+
+// RecoveryRequestStruct is the data stored in a recovery request instance.
+type RecoveryRequestStruct struct {
+	// Darc is the darc to recover.
+	Darc darc.ID
+	// Identity replaces the signers and the owners of the darc once the
+	// request is executed.
+	Identity string
+	// Approvals are the identities of the guardians having approved the
+	// request.
+	Approvals []string
+	// Created is the time of the block creating the request, in
+	// nanoseconds.
+	Created int64
+}
+
+// IssuerKey is a public key of an issuer of verifiable credentials.
+type IssuerKey struct {
+	// Type is either "ed25519" or "bls".
+	Type   string
+	Public []byte
+}
+
 // SpawnerStruct holds the data necessary for knowing how much spawning
 // of a certain contract costs.
 type SpawnerStruct struct {
@@ -96,6 +127,21 @@ type PopPartyStruct struct {
 	// Next is a link to the instanceID of the next party. It can be
 	// nil if there is no next party.
 	Next byzcoin.InstanceID `protobuf:"opt"`
+	// Rotations links the keys of attendees of the previous party to the
+	// new keys they use in this party.
+	Rotations []KeyRotation `protobuf:"opt"`
+	// ContinuityReward is added to the mining reward for every previous
+	// party the miner attended without interruption.
+	ContinuityReward uint64 `protobuf:"opt"`
+}
+
+// KeyRotation links the key of an attendee of the previous party to the key
+// of the same attendee in this party. Both keys sign the rotation.
+type KeyRotation struct {
+	Old          kyber.Point
+	New          kyber.Point
+	OldSignature []byte
+	NewSignature []byte
 }
 
 // PopDesc holds the name, date and a roster of all involved conodes.
@@ -128,3 +174,29 @@ type Attendees struct {
 type LRSTag struct {
 	Tag []byte
 }
+
+// PollContractStruct is the data stored in a poll instance. The voters are
+// the attendees of the pop-party.
+type PollContractStruct struct {
+	// Party is the instance of a finalized pop-party.
+	Party       byzcoin.InstanceID
+	Title       string
+	Description string
+	Choices     []string
+	// Votes holds the last vote of every attendee having voted.
+	Votes []PollVote
+	// Closed is true once the poll does not accept votes anymore.
+	Closed bool
+}
+
+// PollVote is one anonymous vote of a poll.
+type PollVote struct {
+	Choice int
+	// LRS is the linkable ring signature of the vote, and Tag its tag,
+	// the same for all the votes of an attendee.
+	LRS []byte
+	Tag []byte
+	// Count is the number of votes of the attendee before this one. It is
+	// signed with the vote, so that an old vote cannot be replayed.
+	Count int
+}
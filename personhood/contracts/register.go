@@ -14,6 +14,12 @@ func init() {
 		ContractCredentialFromBytes))
 	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractRoPaSciID,
 		ContractRoPaSciFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractRevocationListID,
+		ContractRevocationListFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractPollID,
+		ContractPollFromBytes))
+	log.ErrFatal(byzcoin.RegisterGlobalContract(ContractRecoveryRequestID,
+		ContractRecoveryRequestFromBytes))
 }
 
 func newArg(name string, val []byte) byzcoin.Argument {
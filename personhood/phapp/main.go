@@ -18,6 +18,7 @@ import (
 	byz_contracts "go.dedis.ch/cothority/v3/byzcoin/contracts"
 	"go.dedis.ch/cothority/v3/darc"
 	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/cothority/v3/keystore"
 	"go.dedis.ch/cothority/v3/personhood"
 	"go.dedis.ch/onet/v3/app"
 	"go.dedis.ch/onet/v3/cfgpath"
@@ -122,6 +123,25 @@ var cmds = cli.Commands{
 		ArgsUsage: "bc-xxx.cfg credentialIID",
 		Action:    show,
 	},
+	{
+		Name:  "party",
+		Usage: "inspect pop-parties",
+		Subcommands: cli.Commands{
+			{
+				Name:      "history",
+				Usage:     "show the chain of previous parties and the continuity scores of the attendees",
+				ArgsUsage: "bc-xxx.cfg partyIID",
+				Action:    partyHistory,
+				Flags: cli.FlagsByName{
+					cli.IntFlag{
+						Name:  "max",
+						Usage: "maximum number of parties to follow",
+						Value: contracts.MaxContinuity,
+					},
+				},
+			},
+		},
+	},
 }
 
 var cliApp = cli.NewApp()
@@ -529,6 +549,39 @@ func show(c *cli.Context) error {
 	return err
 }
 
+func partyHistory(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errors.New("please give the following arguments: bc-xxx.cfg partyIID")
+	}
+
+	_, cl, err := lib.LoadConfig(c.Args().First())
+	if err != nil {
+		return err
+	}
+	partyBuf, err := hex.DecodeString(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	if c.Int("max") < 1 {
+		return errors.New("need to follow at least one party")
+	}
+	ids, parties, err := personhood.PopPartyHistory(cl, byzcoin.NewInstanceID(partyBuf), c.Int("max"))
+	if err != nil {
+		return err
+	}
+	for i, p := range parties {
+		log.Infof("Party %x: %s - %d attendees, %d key rotations", ids[i].Slice(),
+			p.Description.Name, len(p.Attendees.Keys), len(p.Rotations))
+	}
+	scores := contracts.Continuity(parties)
+	log.Infof("Continuity of the attendees of %x", partyBuf)
+	for _, k := range parties[len(parties)-1].Attendees.Keys {
+		log.Infof("\t%s: %d", k, scores[k.String()])
+	}
+	return nil
+}
+
 func adminDarcIDsGet(c *cli.Context) error {
 	if c.NArg() != 1 {
 		return errors.New("please give the following argument: public.toml")
@@ -565,7 +618,7 @@ func adminDarcIDsSet(c *cli.Context) error {
 			" [id1 [id2...]]")
 	}
 
-	ccfg, err := app.LoadCothority(c.Args().First())
+	ccfg, err := keystore.LoadCothority(c.Args().First())
 	if err != nil {
 		return err
 	}
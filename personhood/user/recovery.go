@@ -0,0 +1,200 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/darc/expression"
+	"go.dedis.ch/cothority/v3/personhood/contracts"
+	"go.dedis.ch/protobuf"
+	"golang.org/x/xerrors"
+)
+
+// recoveryActions are the rules of a credential darc used by the recovery.
+// CreateInstructionsSpawnFromDarc gives them to the device of the user, and
+// SetGuardians to the guardians.
+var recoveryActions = []darc.Action{
+	darc.Action("spawn:" + contracts.ContractRecoveryRequestID),
+	darc.Action("invoke:" + contracts.ContractRecoveryRequestID + ".approve"),
+	darc.Action("invoke:" + contracts.ContractRecoveryRequestID + ".cancel"),
+	contracts.RuleRecover,
+}
+
+// SetGuardians evolves the credential darc of the user, so that the
+// guardians can recover it if all devices of the user are lost.
+// Any guardian can ask for a recovery, which needs the approval of threshold
+// guardians. During contracts.RecoveryDelay, the devices of the user can
+// cancel the recovery.
+// The guardians are usually the credential darcs of other users.
+// The credential darcs created by CreateInstructionsSpawnFromDarc already
+// have the recovery rules, so they are updated with invoke:darc.evolve.
+// Older credential darcs need an invoke:darc.evolve_unrestricted rule to add
+// them.
+func (u *User) SetGuardians(threshold int, guardians ...darc.Identity) error {
+	if u.Signer.Ed25519 == nil {
+		return xerrors.New("need a signer to set guardians")
+	}
+	if threshold < 1 || threshold > len(guardians) {
+		return xerrors.Errorf("threshold must be between 1 and %d",
+			len(guardians))
+	}
+	var ids []string
+	for _, g := range guardians {
+		ids = append(ids, g.String())
+	}
+	newDarc := u.CredDarc.Copy()
+	if err := newDarc.EvolveFrom(&u.CredDarc); err != nil {
+		return xerrors.Errorf("evolving credential darc: %v", err)
+	}
+	command := "evolve"
+	if !u.CredDarc.Rules.Contains(contracts.RuleRecover) {
+		command = "evolve_unrestricted"
+	}
+	exprs := []expression.Expr{
+		expression.InitOrExpr(ids...),
+		expression.InitOrExpr(ids...),
+		newDarc.Rules.GetSignExpr(),
+		expression.Expr(fmt.Sprintf("threshold<%d/%d,%s>",
+			threshold, len(ids), strings.Join(ids, ","))),
+	}
+	for i, action := range recoveryActions {
+		if err := setRule(newDarc, action, exprs[i]); err != nil {
+			return xerrors.Errorf("couldn't set rule %s: %v", action, err)
+		}
+	}
+
+	newDarcBuf, err := newDarc.ToProto()
+	if err != nil {
+		return xerrors.Errorf("while encoding darc: %v", err)
+	}
+	ctx, err := u.cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(u.CredDarc.GetBaseID()),
+		Invoke: &byzcoin.Invoke{
+			ContractID: byzcoin.ContractDarcID,
+			Command:    command,
+			Args: byzcoin.Arguments{{
+				Name:  "darc",
+				Value: newDarcBuf,
+			}},
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("creating transaction: %v", err)
+	}
+	if err := sendTransaction(u.cl, ctx, u.Signer); err != nil {
+		return err
+	}
+	u.CredDarc = *newDarc
+	return nil
+}
+
+// GetRecoveryRequest returns the pending recovery request of the user, or an
+// error if there is none.
+func (u User) GetRecoveryRequest() (rr contracts.RecoveryRequestStruct,
+	err error) {
+	id := contracts.RecoveryRequestID(u.CredDarc.GetBaseID())
+	resp, err := u.cl.GetProofFromLatest(id[:])
+	if err != nil {
+		return rr, xerrors.Errorf("while getting proof for recovery request"+
+			": %v", err)
+	}
+	buf, cid, _, err := resp.Proof.Get(id[:])
+	if err != nil {
+		return rr, xerrors.Errorf("reading proof: %v", err)
+	}
+	if cid != contracts.ContractRecoveryRequestID {
+		return rr, xerrors.Errorf("wrong contract: %s", cid)
+	}
+	if err = protobuf.Decode(buf, &rr); err != nil {
+		return rr, xerrors.Errorf("while decoding recovery request: %v", err)
+	}
+	return
+}
+
+// CancelRecovery removes the pending recovery request of the user, signing
+// with one of the devices of the user.
+func (u User) CancelRecovery() error {
+	if u.Signer.Ed25519 == nil {
+		return xerrors.New("need a signer to cancel a recovery")
+	}
+	return invokeRecovery(u.cl, u.CredDarc.GetBaseID(), "cancel", u.Signer)
+}
+
+// InitiateRecovery asks for the recovery of the credential darc, so that
+// newIdentity replaces the devices of the user. The guardian signing the
+// request approves it.
+func InitiateRecovery(cl *byzcoin.Client, credDarcID darc.ID,
+	guardian darc.Signer, newIdentity darc.Identity) error {
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: byzcoin.NewInstanceID(credDarcID),
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractRecoveryRequestID,
+			Args: byzcoin.Arguments{{
+				Name:  "identity",
+				Value: []byte(newIdentity.String()),
+			}},
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("creating transaction: %v", err)
+	}
+	return sendTransaction(cl, ctx, guardian)
+}
+
+// ApproveRecovery adds the approval of the guardian to the pending recovery
+// request of the credential darc.
+func ApproveRecovery(cl *byzcoin.Client, credDarcID darc.ID,
+	guardian darc.Signer) error {
+	return invokeRecovery(cl, credDarcID, "approve", guardian)
+}
+
+// ExecuteRecovery replaces the devices of the user with the identity of the
+// pending recovery request of the credential darc. It fails if not enough
+// guardians approved the request, or if contracts.RecoveryDelay didn't pass
+// yet.
+// The signer can be anybody, usually the new device of the user. It is not
+// verified by the contract, but its counter makes sure that a failed
+// execution can be retried with a new transaction.
+func ExecuteRecovery(cl *byzcoin.Client, credDarcID darc.ID,
+	signer darc.Signer) error {
+	return invokeRecovery(cl, credDarcID, "execute", signer)
+}
+
+// invokeRecovery sends the command to the recovery request of the
+// credential darc.
+func invokeRecovery(cl *byzcoin.Client, credDarcID darc.ID, command string,
+	signers ...darc.Signer) error {
+	ctx, err := cl.CreateTransaction(byzcoin.Instruction{
+		InstanceID: contracts.RecoveryRequestID(credDarcID),
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractRecoveryRequestID,
+			Command:    command,
+		},
+	})
+	if err != nil {
+		return xerrors.Errorf("creating transaction: %v", err)
+	}
+	return sendTransaction(cl, ctx, signers...)
+}
+
+// sendTransaction signs the transaction and waits for it to be included.
+func sendTransaction(cl *byzcoin.Client, ctx byzcoin.ClientTransaction,
+	signers ...darc.Signer) error {
+	if err := cl.SignTransaction(ctx, signers...); err != nil {
+		return xerrors.Errorf("signing transaction: %v", err)
+	}
+	if _, err := cl.AddTransactionAndWait(ctx, 10); err != nil {
+		return xerrors.Errorf("sending transaction: %v", err)
+	}
+	return nil
+}
+
+// setRule updates the rule of the darc, or adds it if it doesn't exist.
+func setRule(d *darc.Darc, action darc.Action, expr expression.Expr) error {
+	if d.Rules.Contains(action) {
+		return d.Rules.UpdateRule(action, expr)
+	}
+	return d.Rules.AddRule(action, expr)
+}
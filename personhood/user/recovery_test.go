@@ -0,0 +1,69 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/personhood/contracts"
+)
+
+func TestUser_Recovery(t *testing.T) {
+	bct := byzcoin.NewBCTestDefault(t)
+	bct.AddGenesisRules("spawn:" + contracts.ContractCredentialID)
+	bct.CreateByzCoin()
+	defer bct.CloseAll()
+
+	defer func(delay time.Duration) {
+		contracts.RecoveryDelay = delay
+	}(contracts.RecoveryDelay)
+	contracts.RecoveryDelay = 0
+
+	user, err := NewFromByzcoin(bct.Client, bct.GenesisDarc.GetBaseID(), bct.Signer,
+		"testUser")
+	require.NoError(t, err)
+	var guardians []darc.Signer
+	var guardianIDs []darc.Identity
+	for i := 0; i < 3; i++ {
+		guardians = append(guardians, darc.NewSignerEd25519(nil, nil))
+		guardianIDs = append(guardianIDs, guardians[i].Identity())
+	}
+	credDarcID := user.CredDarc.GetBaseID()
+	newDevice := darc.NewSignerEd25519(nil, nil)
+
+	// The guardians can only recover the darc once they have been set, and
+	// setting them doesn't need an unrestricted evolve.
+	require.False(t, user.CredDarc.Rules.Contains("invoke:darc.evolve_unrestricted"))
+	require.Error(t, InitiateRecovery(bct.Client, credDarcID, guardians[0],
+		newDevice.Identity()))
+	require.Error(t, user.SetGuardians(4, guardianIDs...))
+	require.NoError(t, user.SetGuardians(2, guardianIDs...))
+	require.False(t, user.CredDarc.Rules.Contains("invoke:darc.evolve_unrestricted"))
+
+	require.Error(t, InitiateRecovery(bct.Client, credDarcID, newDevice,
+		newDevice.Identity()))
+	require.NoError(t, InitiateRecovery(bct.Client, credDarcID, guardians[0],
+		newDevice.Identity()))
+	rr, err := user.GetRecoveryRequest()
+	require.NoError(t, err)
+	require.Equal(t, []string{guardianIDs[0].String()}, rr.Approvals)
+
+	// The devices of the user can cancel the recovery.
+	require.NoError(t, user.CancelRecovery())
+	_, err = user.GetRecoveryRequest()
+	require.Error(t, err)
+
+	require.NoError(t, InitiateRecovery(bct.Client, credDarcID, guardians[1],
+		newDevice.Identity()))
+	require.Error(t, ExecuteRecovery(bct.Client, credDarcID, newDevice))
+	require.NoError(t, ApproveRecovery(bct.Client, credDarcID, guardians[2]))
+	require.NoError(t, ExecuteRecovery(bct.Client, credDarcID, newDevice))
+
+	recovered, err := New(bct.Client, user.CredIID)
+	require.NoError(t, err)
+	require.Equal(t, newDevice.Identity().String(),
+		string(recovered.CredDarc.Rules.GetSignExpr()))
+	require.Error(t, user.CancelRecovery())
+}
@@ -126,6 +126,14 @@ func CreateInstructionsSpawnFromDarc(spawnerDarcID darc.ID,
 		expression.Expr(device.Identity().String())); err != nil {
 		return nil, xerrors.Errorf("couldn't add darc.evolve rule: %v", err)
 	}
+	// The recovery rules are only given to the device, so that SetGuardians
+	// can give them to the guardians with a restricted evolve.
+	for _, action := range recoveryActions {
+		if err := credRules.AddRule(action,
+			expression.Expr(device.Identity().String())); err != nil {
+			return nil, xerrors.Errorf("couldn't add %s rule: %v", action, err)
+		}
+	}
 	credDarc := darc.NewDarc(credRules, []byte("User "+name))
 	credStruct :=
 		contracts.CredentialStruct{Credentials: []contracts.Credential{
@@ -0,0 +1,201 @@
+package vc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/personhood/contracts"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/protobuf"
+)
+
+// Issuer signs verifiable credentials with one of the keys of a revocation
+// list.
+type Issuer struct {
+	client  *byzcoin.Client
+	list    byzcoin.InstanceID
+	key     contracts.IssuerKey
+	private kyber.Scalar
+}
+
+// NewIssuer returns an issuer for the revocation list. The keyType is one of
+// contracts.IssuerKeyEd25519 or contracts.IssuerKeyBLS, and the public key
+// of the private key must be in the revocation list.
+func NewIssuer(cl *byzcoin.Client, list byzcoin.InstanceID, keyType string,
+	private kyber.Scalar) (*Issuer, error) {
+	var public kyber.Point
+	switch keyType {
+	case contracts.IssuerKeyEd25519:
+		public = cothority.Suite.Point().Mul(private, nil)
+	case contracts.IssuerKeyBLS:
+		public = pairing.NewSuiteBn256().G2().Point().Mul(private, nil)
+	default:
+		return nil, errors.New("unknown key type: " + keyType)
+	}
+	buf, err := public.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{
+		client:  cl,
+		list:    list,
+		key:     contracts.IssuerKey{Type: keyType, Public: buf},
+		private: private,
+	}, nil
+}
+
+// URI returns the URI of the issuer, which is the URI of its revocation
+// list.
+func (i *Issuer) URI() string {
+	return InstanceURI(i.client.ID, i.list)
+}
+
+// Issue creates a verifiable credential for the subject with the attributes
+// of the credential instance. If names are given, only the credentials with
+// these names are included. If expiration is not zero, the credential is
+// only valid until then.
+func (i *Issuer) Issue(credID byzcoin.InstanceID, subject string,
+	expiration time.Time, names ...string) (*Credential, error) {
+	list, _, err := getRevocationList(i.client, i.list)
+	if err != nil {
+		return nil, err
+	}
+	if list.FindKey(i.key.Public) == nil {
+		return nil, errors.New("the key is not in the revocation list")
+	}
+	if list.IsRevoked(credID) {
+		return nil, errors.New("the credential is revoked")
+	}
+
+	cred, err := getCredential(i.client, credID)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]map[string][]byte)
+	for _, c := range cred.Credentials {
+		if len(names) > 0 && !contains(names, c.Name) {
+			continue
+		}
+		attrs := make(map[string][]byte)
+		for _, a := range c.Attributes {
+			attrs[a.Name] = a.Value
+		}
+		attributes[c.Name] = attrs
+	}
+	if len(attributes) == 0 {
+		return nil, errors.New("no attributes to issue")
+	}
+
+	now := time.Now().UTC()
+	vc := &Credential{
+		Context: []string{ContextCredentials, ContextDataIntegrity,
+			ContextPersonhood},
+		ID:           InstanceURI(i.client.ID, credID),
+		Type:         []string{TypeCredential, TypePersonhood},
+		Issuer:       i.URI(),
+		IssuanceDate: now.Format(time.RFC3339),
+		CredentialSubject: Subject{
+			ID:          subject,
+			Credentials: attributes,
+		},
+		CredentialStatus: Status{
+			ID:   fmt.Sprintf("%s#%x", i.URI(), credID[:]),
+			Type: TypeRevocationList,
+		},
+	}
+	if !expiration.IsZero() {
+		vc.ExpirationDate = expiration.UTC().Format(time.RFC3339)
+	}
+
+	vc.Proof = &Proof{
+		Type:               TypeProof,
+		Created:            vc.IssuanceDate,
+		VerificationMethod: fmt.Sprintf("%s#%x", i.URI(), i.key.Public),
+		ProofPurpose:       "assertionMethod",
+	}
+	switch i.key.Type {
+	case contracts.IssuerKeyEd25519:
+		vc.Proof.Cryptosuite = CryptosuiteEd25519
+	case contracts.IssuerKeyBLS:
+		vc.Proof.Cryptosuite = CryptosuiteBLS
+	}
+	msg, err := vc.signedHash()
+	if err != nil {
+		return nil, err
+	}
+	var sig []byte
+	switch i.key.Type {
+	case contracts.IssuerKeyEd25519:
+		sig, err = schnorr.Sign(cothority.Suite, i.private, msg)
+	case contracts.IssuerKeyBLS:
+		sig, err = bls.Sign(pairing.NewSuiteBn256(), i.private, msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sign credential: %v", err)
+	}
+	vc.Proof.ProofValue = multibase(sig)
+	return vc, nil
+}
+
+// getRevocationList returns the revocation list and its proof.
+func getRevocationList(cl *byzcoin.Client, id byzcoin.InstanceID) (
+	*contracts.RevocationListStruct, *byzcoin.Proof, error) {
+	list := &contracts.RevocationListStruct{}
+	proof, err := getInstance(cl, id, contracts.ContractRevocationListID, list)
+	if err != nil {
+		return nil, nil, fmt.Errorf("revocation list: %v", err)
+	}
+	return list, proof, nil
+}
+
+// getCredential returns the credential instance.
+func getCredential(cl *byzcoin.Client, id byzcoin.InstanceID) (
+	*contracts.CredentialStruct, error) {
+	cred := &contracts.CredentialStruct{}
+	if _, err := getInstance(cl, id, contracts.ContractCredentialID, cred); err != nil {
+		return nil, fmt.Errorf("credential: %v", err)
+	}
+	return cred, nil
+}
+
+// getInstance fetches a verified proof of the instance, and decodes its
+// value if it is of the given contract.
+func getInstance(cl *byzcoin.Client, id byzcoin.InstanceID, contractID string,
+	value interface{}) (*byzcoin.Proof, error) {
+	reply, err := cl.GetProofFromLatest(id.Slice())
+	if err != nil {
+		return nil, err
+	}
+	proof := &reply.Proof
+	if !proof.InclusionProof.Match(id.Slice()) {
+		return nil, errors.New("instance doesn't exist")
+	}
+	buf, cid, _, err := proof.Get(id.Slice())
+	if err != nil {
+		return nil, err
+	}
+	if cid != contractID {
+		return nil, fmt.Errorf("instance is a %s, not a %s", cid, contractID)
+	}
+	if value != nil {
+		if err := protobuf.Decode(buf, value); err != nil {
+			return nil, fmt.Errorf("couldn't decode instance: %v", err)
+		}
+	}
+	return proof, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+// Package vc issues and verifies W3C Verifiable Credentials for the
+// credential instances of personhood.
+//
+// An issuer is defined by a revocation list instance, which is guarded by the
+// darc of the issuer. The revocation list holds the public keys the issuer
+// signs with, Ed25519 or BLS, and the credentials it revoked. A verifiable
+// credential holds some of the attributes of a credential instance, and is
+// signed by one of the keys of the issuer.
+//
+// A verifier outside of the chain only needs to trust the genesis block of
+// the ByzCoin instance. Besides the signature, it checks, with proofs from
+// ByzCoin, that the key is still in the revocation list, that the darc of the
+// issuer still allows to revoke credentials, that the credential is not
+// revoked, and that the credential still holds the attributes. An issuer is
+// revoked by removing its keys from the revocation list, or by evolving its
+// darc without the revoke rule.
+//
+// The proofs are Data Integrity proofs. Ed25519 keys use the eddsa-jcs-2022
+// cryptosuite. BLS keys, on the bn256 curve, use the bls-bn256-jcs
+// cryptosuite, which only differs by signing the same hash with BLS. The
+// terms specific to personhood are defined in the context-v1.jsonld file of
+// this package, published at ContextPersonhood.
+package vc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+const (
+	// ContextCredentials is the JSON-LD context of all verifiable credentials.
+	ContextCredentials = "https://www.w3.org/2018/credentials/v1"
+	// ContextDataIntegrity is the JSON-LD context of the Data Integrity
+	// proofs.
+	ContextDataIntegrity = "https://w3id.org/security/data-integrity/v2"
+	// ContextPersonhood is the JSON-LD context defining the types and the
+	// attributes of the personhood credentials. Its document is in the
+	// context-v1.jsonld file of this package.
+	ContextPersonhood = "https://raw.githubusercontent.com/dedis/cothority/main/personhood/vc/context-v1.jsonld"
+	// TypeCredential is the type of all verifiable credentials.
+	TypeCredential = "VerifiableCredential"
+	// TypePersonhood is the type of the credentials issued from personhood
+	// credential instances.
+	TypePersonhood = "PersonhoodCredential"
+	// TypeRevocationList is the type of the credential status, pointing to
+	// the revocation list instance.
+	TypeRevocationList = "ByzCoinRevocationList"
+	// TypeProof is the type of the proofs.
+	TypeProof = "DataIntegrityProof"
+	// CryptosuiteEd25519 is the cryptosuite of the proofs signed with an
+	// Ed25519 key.
+	CryptosuiteEd25519 = "eddsa-jcs-2022"
+	// CryptosuiteBLS is the cryptosuite of the proofs signed with a BLS key.
+	CryptosuiteBLS = "bls-bn256-jcs"
+)
+
+// Credential is a W3C Verifiable Credential.
+type Credential struct {
+	Context           []string `json:"@context"`
+	ID                string   `json:"id"`
+	Type              []string `json:"type"`
+	Issuer            string   `json:"issuer"`
+	IssuanceDate      string   `json:"issuanceDate"`
+	ExpirationDate    string   `json:"expirationDate,omitempty"`
+	CredentialSubject Subject  `json:"credentialSubject"`
+	CredentialStatus  Status   `json:"credentialStatus"`
+	Proof             *Proof   `json:"proof,omitempty"`
+}
+
+// Subject holds the attributes of the credential instance. The values are
+// base64 encoded.
+type Subject struct {
+	ID          string                       `json:"id"`
+	Credentials map[string]map[string][]byte `json:"credentials"`
+}
+
+// Status points to the entry of the credential in the revocation list.
+type Status struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// Proof is the signature of the issuer. The verification method is the URI
+// of the revocation list followed by the hex encoded public key, and the
+// proof value is the multibase encoded signature. The context is only set in
+// the proof configuration, when signing.
+type Proof struct {
+	Context            []string `json:"@context,omitempty"`
+	Type               string   `json:"type"`
+	Cryptosuite        string   `json:"cryptosuite"`
+	Created            string   `json:"created"`
+	VerificationMethod string   `json:"verificationMethod"`
+	ProofPurpose       string   `json:"proofPurpose"`
+	ProofValue         string   `json:"proofValue,omitempty"`
+}
+
+// Parse decodes a verifiable credential from JSON.
+func Parse(buf []byte) (*Credential, error) {
+	c := &Credential{}
+	if err := json.Unmarshal(buf, c); err != nil {
+		return nil, fmt.Errorf("couldn't decode credential: %v", err)
+	}
+	return c, nil
+}
+
+// JSON returns the JSON encoding of the credential.
+func (c *Credential) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// Canonical returns the canonical JSON, as defined by RFC 8785, of the
+// credential without its proof.
+func (c *Credential) Canonical() ([]byte, error) {
+	cp := *c
+	cp.Proof = nil
+	return canonicalJSON(cp)
+}
+
+// signedHash returns the bytes signed by the issuer, like in the
+// eddsa-jcs-2022 cryptosuite: the SHA-256 hash of the canonical proof
+// configuration, followed by the SHA-256 hash of the canonical credential.
+func (c *Credential) signedHash() ([]byte, error) {
+	if c.Proof == nil {
+		return nil, errors.New("credential has no proof")
+	}
+	config := *c.Proof
+	config.Context = c.Context
+	config.ProofValue = ""
+	configBuf, err := canonicalJSON(config)
+	if err != nil {
+		return nil, err
+	}
+	credBuf, err := c.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	configHash, credHash := sha256.Sum256(configBuf), sha256.Sum256(credBuf)
+	return append(configHash[:], credHash[:]...), nil
+}
+
+// InstanceURI returns the URI of an instance of a ByzCoin ledger.
+func InstanceURI(bcID skipchain.SkipBlockID, id byzcoin.InstanceID) string {
+	return fmt.Sprintf("urn:byzcoin:%x:%x", []byte(bcID), id[:])
+}
+
+// ParseInstanceURI returns the ledger ID and the instance ID of the URI. A
+// fragment after '#' is ignored.
+func ParseInstanceURI(uri string) (skipchain.SkipBlockID, byzcoin.InstanceID, error) {
+	uri = strings.SplitN(uri, "#", 2)[0]
+	fields := strings.Split(uri, ":")
+	if len(fields) != 4 || fields[0] != "urn" || fields[1] != "byzcoin" {
+		return nil, byzcoin.InstanceID{}, errors.New("not a byzcoin URI: " + uri)
+	}
+	bcID, err := hex.DecodeString(fields[2])
+	if err != nil {
+		return nil, byzcoin.InstanceID{}, fmt.Errorf("wrong ledger ID: %v", err)
+	}
+	id, err := hex.DecodeString(fields[3])
+	if err != nil || len(id) != len(byzcoin.InstanceID{}) {
+		return nil, byzcoin.InstanceID{}, errors.New("wrong instance ID")
+	}
+	return bcID, byzcoin.NewInstanceID(id), nil
+}
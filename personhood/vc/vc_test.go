@@ -0,0 +1,263 @@
+package vc
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/personhood/contracts"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/util/key"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/protobuf"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+func TestInstanceURI(t *testing.T) {
+	bcID := []byte{1, 2, 3}
+	id := byzcoin.NewInstanceID([]byte("instance"))
+	uri := InstanceURI(bcID, id)
+	bcID2, id2, err := ParseInstanceURI(uri + "#key")
+	require.NoError(t, err)
+	require.True(t, bcID2.Equal(bcID))
+	require.True(t, id2.Equal(id))
+
+	_, _, err = ParseInstanceURI("did:byzcoin:1234")
+	require.Error(t, err)
+	_, _, err = ParseInstanceURI("urn:byzcoin:01:02")
+	require.Error(t, err)
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	for f, out := range map[float64]string{
+		0: "0", -1: "-1", 4.5: "4.5", 1e21: "1e+21", 1e20: "100000000000000000000",
+		0.000001: "0.000001", 1e-7: "1e-7", 1.5e-7: "1.5e-7", 333333333.3333333: "333333333.3333333",
+		-5e-324: "-5e-324", 1.7976931348623157e308: "1.7976931348623157e+308",
+	} {
+		n, err := formatNumber(f)
+		require.NoError(t, err)
+		require.Equal(t, out, n)
+	}
+
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"\ufb33":1,"\ud83d\ude00":2,
+		"\u20ac":[true,null,"<\u2028\u000f\n>"],"a":{"c":1e1,"b":0.5}}`), &v))
+	buf, err := canonicalJSON(v)
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\":{\"b\":0.5,\"c\":10},\"\u20ac\":[true,null,"+
+		"\"<\u2028\\u000f\\n>\"],\"\U0001F600\":2,\"\ufb33\":1}", string(buf))
+
+	for _, in := range [][]byte{{}, {0, 0, 1}, []byte("Hello World!")} {
+		out, err := parseMultibase(multibase(in))
+		require.NoError(t, err)
+		require.Equal(t, in, out)
+	}
+	require.Equal(t, "z2NEpo7TZRRrLZSi2U", multibase([]byte("Hello World!")))
+	_, err = parseMultibase("z0OIl")
+	require.Error(t, err)
+}
+
+// TestContextDocument makes sure the published context defines the types of
+// the credentials.
+func TestContextDocument(t *testing.T) {
+	buf, err := ioutil.ReadFile("context-v1.jsonld")
+	require.NoError(t, err)
+	var doc struct {
+		Context map[string]interface{} `json:"@context"`
+	}
+	require.NoError(t, json.Unmarshal(buf, &doc))
+	for _, term := range []string{TypePersonhood, TypeRevocationList, "credentials"} {
+		require.Contains(t, doc.Context, term)
+	}
+}
+
+// TestIssueVerify issues credentials with Ed25519 and BLS keys, and makes
+// sure they are refused once the credential changes, the key is removed, or
+// the credential is revoked.
+func TestIssueVerify(t *testing.T) {
+	b := byzcoin.NewBCTestDefault(t)
+	defer b.CloseAll()
+	b.AddGenesisRules("spawn:"+contracts.ContractCredentialID,
+		"invoke:"+contracts.ContractCredentialID+".update",
+		"spawn:"+contracts.ContractRevocationListID,
+		"invoke:"+contracts.ContractRevocationListID+".add_key",
+		"invoke:"+contracts.ContractRevocationListID+".remove_key",
+		"invoke:"+contracts.ContractRevocationListID+".revoke")
+	b.CreateByzCoin()
+	gDarcID := byzcoin.NewInstanceID(b.GenesisDarc.GetBaseID())
+
+	log.Lvl1("spawn a credential")
+	cred := contracts.CredentialStruct{Credentials: []contracts.Credential{
+		{Name: "public", Attributes: []contracts.Attribute{
+			{Name: "alias", Value: []byte("alice")}}},
+		{Name: "private", Attributes: []contracts.Attribute{
+			{Name: "email", Value: []byte("alice@example.com")}}},
+	}}
+	credBuf, err := protobuf.Encode(&cred)
+	require.NoError(t, err)
+	ctx, _ := b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: gDarcID,
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractCredentialID,
+			Args: byzcoin.Arguments{
+				{Name: "credential", Value: credBuf},
+			},
+		},
+	})
+	credID := ctx.Instructions[0].DeriveID("")
+
+	log.Lvl1("spawn a revocation list with an Ed25519 and a BLS key")
+	kp := key.NewKeyPair(cothority.Suite)
+	pub, err := kp.Public.MarshalBinary()
+	require.NoError(t, err)
+	bn := pairing.NewSuiteBn256()
+	blsPriv := bn.G2().Scalar().Pick(bn.RandomStream())
+	blsPub, err := bn.G2().Point().Mul(blsPriv, nil).MarshalBinary()
+	require.NoError(t, err)
+	ctx, _ = b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: gDarcID,
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractRevocationListID,
+			Args:       byzcoin.Arguments{{Name: "public", Value: pub}},
+		},
+	})
+	listID := ctx.Instructions[0].DeriveID("")
+	b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: listID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractRevocationListID,
+			Command:    "add_key",
+			Args: byzcoin.Arguments{
+				{Name: "type", Value: []byte(contracts.IssuerKeyBLS)},
+				{Name: "public", Value: blsPub},
+			},
+		},
+	})
+
+	issuer, err := NewIssuer(b.Client, listID, contracts.IssuerKeyEd25519,
+		kp.Private)
+	require.NoError(t, err)
+	issuerBLS, err := NewIssuer(b.Client, listID, contracts.IssuerKeyBLS,
+		blsPriv)
+	require.NoError(t, err)
+	verifier := NewVerifier(b.Genesis)
+	verifier.MaxAge = time.Hour
+
+	log.Lvl1("issue and verify")
+	vc, err := issuer.Issue(credID, "did:example:alice", time.Time{}, "public")
+	require.NoError(t, err)
+	require.Len(t, vc.CredentialSubject.Credentials, 1)
+	require.NoError(t, verifier.Verify(vc))
+	require.Equal(t, []string{ContextCredentials, ContextDataIntegrity,
+		ContextPersonhood}, vc.Context)
+	require.Equal(t, CryptosuiteEd25519, vc.Proof.Cryptosuite)
+
+	// The Ed25519 proofs can be checked by any eddsa-jcs-2022 verifier.
+	sig, err := parseMultibase(vc.Proof.ProofValue)
+	require.NoError(t, err)
+	msg, err := vc.signedHash()
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(ed25519.PublicKey(pub), msg, sig))
+
+	buf, err := vc.JSON()
+	require.NoError(t, err)
+	vc2, err := Parse(buf)
+	require.NoError(t, err)
+	require.NoError(t, verifier.Verify(vc2))
+
+	vcBLS, err := issuerBLS.Issue(credID, "did:example:alice", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, vcBLS.CredentialSubject.Credentials, 2)
+	require.NoError(t, verifier.Verify(vcBLS))
+
+	_, err = issuer.Issue(credID, "", time.Time{}, "unknown")
+	require.Error(t, err)
+	_, err = issuer.Issue(listID, "", time.Time{})
+	require.Error(t, err)
+
+	log.Lvl1("tampered and expired credentials are refused")
+	vc2.CredentialSubject.Credentials["public"]["alias"] = []byte("bob")
+	require.Error(t, verifier.Verify(vc2))
+	vc2, err = issuer.Issue(credID, "", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Contains(t, verifier.Verify(vc2).Error(), "expired")
+
+	log.Lvl1("changing the credential instance invalidates the VC")
+	cred.Credentials[1].Attributes[0].Value = []byte("alice@example.org")
+	credBuf, err = protobuf.Encode(&cred)
+	require.NoError(t, err)
+	b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: credID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractCredentialID,
+			Command:    "update",
+			Args:       byzcoin.Arguments{{Name: "credential", Value: credBuf}},
+		},
+	})
+	require.NoError(t, verifier.Verify(vc))
+	require.Error(t, verifier.Verify(vcBLS))
+
+	log.Lvl1("removing the key invalidates the VC")
+	b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: listID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractRevocationListID,
+			Command:    "remove_key",
+			Args:       byzcoin.Arguments{{Name: "public", Value: blsPub}},
+		},
+	})
+	_, err = issuerBLS.Issue(credID, "", time.Time{})
+	require.Error(t, err)
+
+	log.Lvl1("revoking the credential invalidates the VC")
+	b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: listID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: contracts.ContractRevocationListID,
+			Command:    "revoke",
+			Args: byzcoin.Arguments{
+				{Name: "credentialID", Value: credID.Slice()}},
+		},
+	})
+	require.Contains(t, verifier.Verify(vc).Error(), "credential is revoked")
+	_, err = issuer.Issue(credID, "", time.Time{})
+	require.Error(t, err)
+
+	log.Lvl1("evolving the darc of the issuer without revoke invalidates the VC")
+	ctx, _ = b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: gDarcID,
+		Spawn: &byzcoin.Spawn{
+			ContractID: contracts.ContractCredentialID,
+			Args: byzcoin.Arguments{
+				{Name: "credential", Value: credBuf},
+			},
+		},
+	})
+	vc, err = issuer.Issue(ctx.Instructions[0].DeriveID(""), "", time.Time{})
+	require.NoError(t, err)
+	require.NoError(t, verifier.Verify(vc))
+	newDarc := b.GenesisDarc.Copy()
+	require.NoError(t, newDarc.EvolveFrom(b.GenesisDarc))
+	require.NoError(t, newDarc.Rules.DeleteRules(darc.Action("invoke:"+
+		contracts.ContractRevocationListID+".revoke")))
+	darcBuf, err := newDarc.ToProto()
+	require.NoError(t, err)
+	b.SendInst(nil, byzcoin.Instruction{
+		InstanceID: gDarcID,
+		Invoke: &byzcoin.Invoke{
+			ContractID: byzcoin.ContractDarcID,
+			Command:    "evolve",
+			Args:       byzcoin.Arguments{{Name: "darc", Value: darcBuf}},
+		},
+	})
+	require.Contains(t, verifier.Verify(vc).Error(), "darc of the issuer is revoked")
+}
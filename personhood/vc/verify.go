@@ -0,0 +1,203 @@
+package vc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/darc"
+	"go.dedis.ch/cothority/v3/personhood/contracts"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"go.dedis.ch/kyber/v3/sign/schnorr"
+	"go.dedis.ch/protobuf"
+)
+
+// Verifier checks verifiable credentials against the state of a ByzCoin
+// ledger. It only trusts the genesis block of the ledger.
+type Verifier struct {
+	client *byzcoin.Client
+	// MaxAge is the maximum age of the latest block of the proofs. If it is
+	// 0, the age is not checked.
+	MaxAge time.Duration
+}
+
+// NewVerifier returns a verifier for the ledger starting at genesis.
+func NewVerifier(genesis *skipchain.SkipBlock) *Verifier {
+	cl := byzcoin.NewClient(genesis.SkipChainID(), *genesis.Roster)
+	cl.Genesis = genesis
+	return &Verifier{client: cl}
+}
+
+// Verify checks the signature of the credential, and that, in the latest
+// state of the ledger:
+//   - the signing key is still in the revocation list of the issuer
+//   - the darc guarding the revocation list still allows to revoke
+//     credentials
+//   - the credential is not revoked
+//   - the credential instance still holds all the attributes
+func (v *Verifier) Verify(vc *Credential) error {
+	if vc.Proof == nil {
+		return errors.New("credential is not signed")
+	}
+	if len(vc.Type) == 0 || vc.Type[0] != TypeCredential {
+		return errors.New("not a verifiable credential")
+	}
+	bcID, listID, err := ParseInstanceURI(vc.Issuer)
+	if err != nil {
+		return fmt.Errorf("wrong issuer: %v", err)
+	}
+	if !bcID.Equal(v.client.ID) {
+		return errors.New("credential is from another ledger")
+	}
+	credBcID, credID, err := ParseInstanceURI(vc.ID)
+	if err != nil {
+		return fmt.Errorf("wrong credential ID: %v", err)
+	}
+	if !credBcID.Equal(bcID) {
+		return errors.New("credential and issuer are on different ledgers")
+	}
+	if vc.ExpirationDate != "" {
+		expiration, err := time.Parse(time.RFC3339, vc.ExpirationDate)
+		if err != nil {
+			return fmt.Errorf("wrong expiration date: %v", err)
+		}
+		if time.Now().After(expiration) {
+			return errors.New("credential expired")
+		}
+	}
+
+	list, listProof, err := getRevocationList(v.client, listID)
+	if err != nil {
+		return err
+	}
+	if err := v.checkAge(listProof); err != nil {
+		return err
+	}
+	if err := v.checkIssuerDarc(listID, listProof); err != nil {
+		return err
+	}
+	if err := verifySignature(vc, list); err != nil {
+		return err
+	}
+	if list.IsRevoked(credID) {
+		return errors.New("credential is revoked")
+	}
+
+	cred, err := getCredential(v.client, credID)
+	if err != nil {
+		return err
+	}
+	for name, attrs := range vc.CredentialSubject.Credentials {
+		for attr, value := range attrs {
+			if !hasAttribute(cred, name, attr, value) {
+				return fmt.Errorf("credential doesn't hold %s.%s anymore",
+					name, attr)
+			}
+		}
+	}
+	return nil
+}
+
+// verifySignature checks that the credential is signed by one of the keys of
+// the revocation list.
+func verifySignature(vc *Credential, list *contracts.RevocationListStruct) error {
+	fields := strings.SplitN(vc.Proof.VerificationMethod, "#", 2)
+	if len(fields) != 2 || fields[0] != vc.Issuer {
+		return errors.New("verification method is not from the issuer")
+	}
+	public, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return fmt.Errorf("wrong verification method: %v", err)
+	}
+	key := list.FindKey(public)
+	if key == nil {
+		return errors.New("the key is not in the revocation list")
+	}
+	point, err := key.Point()
+	if err != nil {
+		return err
+	}
+	if vc.Proof.Type != TypeProof {
+		return errors.New("unknown proof type " + vc.Proof.Type)
+	}
+	sig, err := parseMultibase(vc.Proof.ProofValue)
+	if err != nil {
+		return fmt.Errorf("wrong proof value: %v", err)
+	}
+	msg, err := vc.signedHash()
+	if err != nil {
+		return err
+	}
+	switch {
+	case key.Type == contracts.IssuerKeyEd25519 && vc.Proof.Cryptosuite == CryptosuiteEd25519:
+		err = schnorr.Verify(cothority.Suite, point, msg, sig)
+	case key.Type == contracts.IssuerKeyBLS && vc.Proof.Cryptosuite == CryptosuiteBLS:
+		err = bls.Verify(pairing.NewSuiteBn256(), point, msg, sig)
+	default:
+		return errors.New("cryptosuite doesn't match the key type")
+	}
+	if err != nil {
+		return fmt.Errorf("wrong signature: %v", err)
+	}
+	return nil
+}
+
+// checkIssuerDarc makes sure the darc guarding the revocation list still
+// exists, and still gives someone the right to revoke credentials. Else the
+// credentials of the issuer could not be revoked anymore.
+func (v *Verifier) checkIssuerDarc(listID byzcoin.InstanceID, listProof *byzcoin.Proof) error {
+	_, _, darcID, err := listProof.Get(listID.Slice())
+	if err != nil {
+		return err
+	}
+	d := &darc.Darc{}
+	proof, err := getInstance(v.client, byzcoin.NewInstanceID(darcID),
+		byzcoin.ContractDarcID, d)
+	if err != nil {
+		return fmt.Errorf("darc of the issuer: %v", err)
+	}
+	if err := v.checkAge(proof); err != nil {
+		return err
+	}
+	revoke := darc.Action("invoke:" + contracts.ContractRevocationListID + ".revoke")
+	if len(d.Rules.Get(revoke)) == 0 {
+		return errors.New("the darc of the issuer is revoked")
+	}
+	return nil
+}
+
+// checkAge makes sure the proof is recent enough.
+func (v *Verifier) checkAge(proof *byzcoin.Proof) error {
+	if v.MaxAge == 0 {
+		return nil
+	}
+	var header byzcoin.DataHeader
+	if err := protobuf.Decode(proof.Latest.Data, &header); err != nil {
+		return fmt.Errorf("couldn't decode header: %v", err)
+	}
+	if time.Since(time.Unix(0, header.Timestamp)) > v.MaxAge {
+		return errors.New("the latest block of the proof is too old")
+	}
+	return nil
+}
+
+func hasAttribute(cred *contracts.CredentialStruct, name, attr string, value []byte) bool {
+	for _, c := range cred.Credentials {
+		if c.Name != name {
+			continue
+		}
+		for _, a := range c.Attributes {
+			if a.Name == attr && bytes.Equal(a.Value, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/urfave/cli"
 	"go.dedis.ch/cothority/v3"
 	"go.dedis.ch/cothority/v3/byzcoin"
+	"go.dedis.ch/cothority/v3/keystore"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/util/key"
@@ -95,7 +96,7 @@ func linkAdd(c *cli.Context) error {
 	if private == "" {
 		return errors.New("got empty private.toml file")
 	}
-	ccfg, err := app.LoadCothority(c.Args().First())
+	ccfg, err := keystore.LoadCothority(c.Args().First())
 	if err != nil {
 		return err
 	}
@@ -495,6 +496,42 @@ func scOptimize(c *cli.Context) error {
 	return nil
 }
 
+// Lists the evidence of conflicting blocks known by a conode
+func scEvidence(c *cli.Context) error {
+	if c.NArg() < 1 {
+		return errors.New("please give ip:port of the conode to query")
+	}
+	var scid skipchain.SkipBlockID
+	if c.NArg() > 1 {
+		var err error
+		scid, err = hex.DecodeString(c.Args().Get(1))
+		if err != nil {
+			return errors.New("bad skipchain ID")
+		}
+	}
+	si := network.NewServerIdentity(nil, network.NewAddress(network.PlainTCP, c.Args().First()))
+	evidence, err := skipchain.NewClient().GetEvidence(si, scid)
+	if err != nil {
+		return err
+	}
+	if len(evidence) == 0 {
+		log.Infof("Node %s has no evidence of conflicting blocks", si.Address)
+		return nil
+	}
+	for _, e := range evidence {
+		culprits, err := e.Culprits()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "skipchain %x, index %d: %x and %x from %x\n",
+			e.SkipChainID(), e.First.Index, e.First.Hash, e.Second.Hash, e.From.Hash)
+		for _, si := range culprits {
+			fmt.Fprintf(c.App.Writer, "  signed by %s - %s\n", si.Address, si.Public)
+		}
+	}
+	return nil
+}
+
 // Joins a given skipchain
 func dnsFetch(c *cli.Context) error {
 	if c.NArg() != 2 {
@@ -172,6 +172,13 @@ func getCommands() cli.Commands {
 						},
 					},
 				},
+				{
+					Name:      "evidence",
+					Usage:     "list the conflicting blocks and the nodes that signed them",
+					Aliases:   []string{"e"},
+					ArgsUsage: "ip:port [skipchain-id]",
+					Action:    scEvidence,
+				},
 				{
 					Name:    "optimize",
 					Usage:   "create missing forward link to optimize the proof of a given block",
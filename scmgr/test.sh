@@ -18,6 +18,7 @@ main(){
 	run testCreate
 	run testJoin
 	run testAdd
+	run testEvidence
 	run testIndex
 	run testFetch
 	run testLink
@@ -193,6 +194,15 @@ testAdd(){
 	testOK runSc skipchain block add --roster public.toml $ID
 }
 
+testEvidence(){
+	startCl
+	setupGenesis
+	testFail runSc skipchain evidence
+	testFail runSc skipchain evidence localhost:2002 zz
+	testGrep "no evidence" runSc skipchain evidence localhost:2002
+	testGrep "no evidence" runSc skipchain evidence localhost:2002 $ID
+}
+
 setupFour(){
 	rm -f public.toml
 	for n in $( seq 4 ); do
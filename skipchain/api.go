@@ -267,6 +267,23 @@ func (c *Client) GetUpdateChain(roster *onet.Roster, latest SkipBlockID) (reply
 func (c *Client) GetUpdateChainLevel(initRoster *onet.Roster,
 	latest SkipBlockID, maxLevel int,
 	maxBlocks int) (update []*SkipBlock, err error) {
+	return c.getUpdateChain(initRoster, latest, maxLevel, maxBlocks, false)
+}
+
+// GetUpdateChainHeaders works like GetUpdateChainLevel with the highest
+// level, but only fetches the headers of the blocks: the payload is empty,
+// only the forward link to the next block is present, and the rosters are
+// only sent when they change. This is enough to verify the chain with
+// VerifyForwardSignatures and is much smaller, as applications like ByzCoin
+// keep the transactions in the payload.
+func (c *Client) GetUpdateChainHeaders(roster *onet.Roster,
+	latest SkipBlockID, maxBlocks int) ([]*SkipBlock, error) {
+	return c.getUpdateChain(roster, latest, -1, maxBlocks, true)
+}
+
+func (c *Client) getUpdateChain(initRoster *onet.Roster,
+	latest SkipBlockID, maxLevel int,
+	maxBlocks int, headerOnly bool) (update []*SkipBlock, err error) {
 	roster := initRoster
 	for {
 		r2 := &GetUpdateChainReply{}
@@ -279,9 +296,10 @@ func (c *Client) GetUpdateChainLevel(initRoster *onet.Roster,
 			}
 		}
 		node, err := c.SendProtobufParallel(roster.List, &GetUpdateChain{
-			LatestID:  latest,
-			MaxHeight: maxLevel,
-			MaxBlocks: mb,
+			LatestID:   latest,
+			MaxHeight:  maxLevel,
+			MaxBlocks:  mb,
+			HeaderOnly: headerOnly,
 		}, r2, c.options)
 		if err != nil {
 			same, err := roster.Equal(initRoster)
@@ -295,6 +313,14 @@ func (c *Client) GetUpdateChainLevel(initRoster *onet.Roster,
 		}
 
 		log.Lvlf3("Got %d blocks from node %s", len(r2.Update), node)
+		if len(r2.Update) == 0 {
+			return nil, errors.New("got an empty update chain")
+		}
+		if headerOnly {
+			if err := restoreRosters(r2.Update); err != nil {
+				return nil, err
+			}
+		}
 
 		// Does this chain start where we expect it to?
 		if !r2.Update[0].Hash.Equal(latest) {
@@ -385,6 +411,32 @@ func (c *Client) GetUpdateChainLevel(initRoster *onet.Roster,
 	}
 }
 
+// restoreRosters sets the rosters of the blocks of a header-only update
+// chain, that are missing if they can be found in the previous block or in
+// the forward link pointing to the block. A wrong roster is detected when
+// the hash of the block is verified.
+func restoreRosters(blocks []*SkipBlock) error {
+	for j := 1; j < len(blocks); j++ {
+		sb := blocks[j]
+		if sb.Roster != nil {
+			continue
+		}
+		prev := blocks[j-1]
+		for _, fl := range prev.ForwardLink {
+			if !fl.IsEmpty() && fl.To.Equal(sb.Hash) {
+				sb.Roster = fl.NewRoster
+				if sb.Roster == nil {
+					sb.Roster = prev.Roster
+				}
+			}
+		}
+		if sb.Roster == nil {
+			return fmt.Errorf("missing roster for block %d", sb.Index)
+		}
+	}
+	return nil
+}
+
 // GetAllSkipchains is deprecated and should no longer be used. See GetAllSkipChainIDs.
 func (c *Client) GetAllSkipchains(si *network.ServerIdentity) (reply *GetAllSkipchainsReply,
 	err error) {
@@ -570,3 +622,20 @@ func (c *Client) ListFollow(si *network.ServerIdentity, clientPriv kyber.Scalar)
 	}
 	return reply, nil
 }
+
+// GetEvidence returns the evidence of conflicting blocks known by the conode.
+// If scid is nil, the evidence of all skipchains is returned. Every evidence
+// is verified before being returned.
+func (c *Client) GetEvidence(si *network.ServerIdentity, scid SkipBlockID) ([]*Evidence, error) {
+	reply := &GetEvidenceReply{}
+	err := c.SendProtobuf(si, &GetEvidence{SkipchainID: scid}, reply)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range reply.Evidence {
+		if err := e.Verify(); err != nil {
+			return nil, fmt.Errorf("got invalid evidence: %v", err)
+		}
+	}
+	return reply.Evidence, nil
+}
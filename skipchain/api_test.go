@@ -155,6 +155,76 @@ func TestClient_GetUpdateChain(t *testing.T) {
 	}
 }
 
+func TestClient_GetUpdateChainHeaders(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	servers, roster, gs := local.MakeSRS(cothority.Suite, 4, skipchainSID)
+	s := gs.(*Service)
+	c := newTestClient(local)
+
+	genesis, err := makeGenesisRosterArgs(s, onet.NewRoster(roster.List[0:3]),
+		nil, VerificationNone, 2, 3)
+	require.NoError(t, err)
+	latest := genesis
+	for i := 1; i < 8; i++ {
+		sb := NewSkipBlock()
+		sb.Roster = latest.Roster
+		if i == 4 {
+			sb.Roster = onet.NewRoster(roster.List[1:4])
+		}
+		sb.Payload = bytes.Repeat([]byte{byte(i)}, 1024)
+		// The first node of the roster of the new block is the leader.
+		leader := s
+		if i >= 4 {
+			leader = local.GetServices(servers[1:], skipchainSID)[0].(*Service)
+		}
+		reply, err := leader.StoreSkipBlock(&StoreSkipBlock{
+			TargetSkipChainID: latest.Hash, NewBlock: sb})
+		require.NoError(t, err)
+		latest = reply.Latest
+	}
+
+	full, err := c.GetUpdateChainLevel(genesis.Roster, genesis.Hash, -1, -1)
+	require.NoError(t, err)
+	headers, err := c.GetUpdateChainHeaders(genesis.Roster, genesis.Hash, 0)
+	require.NoError(t, err)
+	require.Equal(t, len(full), len(headers))
+	require.True(t, headers[len(headers)-1].Hash.Equal(latest.Hash))
+	for i, sb := range headers {
+		require.True(t, sb.Hash.Equal(full[i].Hash))
+		require.NoError(t, sb.VerifyForwardSignatures())
+		require.Empty(t, sb.Payload)
+		if i < len(headers)-1 {
+			nonEmpty := 0
+			for _, fl := range sb.ForwardLink {
+				if !fl.IsEmpty() {
+					nonEmpty++
+				}
+			}
+			require.Equal(t, 1, nonEmpty)
+		}
+	}
+
+	// The nodes only send the rosters that can't be derived.
+	s1 := local.GetServices(servers[1:], skipchainSID)[0].(*Service)
+	reply, err := s1.GetUpdateChain(&GetUpdateChain{LatestID: genesis.Hash,
+		HeaderOnly: true})
+	require.NoError(t, err)
+	require.True(t, len(reply.Update) > 2)
+	require.NotNil(t, reply.Update[0].Roster)
+	for _, sb := range reply.Update[1:] {
+		require.Nil(t, sb.Roster)
+	}
+
+	// A block with a missing roster that can't be derived is refused.
+	reply.Update[len(reply.Update)-2].ForwardLink = nil
+	require.Error(t, restoreRosters(reply.Update))
+
+	headers, err = c.GetUpdateChainHeaders(genesis.Roster, genesis.Hash, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(headers))
+}
+
 func TestClient_StoreSkipBlock(t *testing.T) {
 	nbrHosts := 3
 	l := onet.NewTCPTest(cothority.Suite)
@@ -0,0 +1,249 @@
+package skipchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"go.dedis.ch/cothority/v3/blscosi/protocol"
+	"go.dedis.ch/onet/v3/log"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// Evidence is a self-contained proof that the roster of a block co-signed
+// forward links to two different blocks with the same index. As both links
+// start from the same block, the nodes that signed both of them
+// equivocated, and can be found with Culprits.
+//
+// Anybody knowing the hash of the From block can verify the evidence
+// without access to the rest of the skipchain.
+type Evidence struct {
+	// From is the block both forward links start from. Its roster signed
+	// both links.
+	From *SkipBlock
+	// First and Second are the two conflicting blocks.
+	First  *SkipBlock
+	Second *SkipBlock
+	// FirstLink and SecondLink are the forward links from From to First,
+	// respectively to Second.
+	FirstLink  *ForwardLink
+	SecondLink *ForwardLink
+}
+
+// NewEvidence creates the evidence of two conflicting forward links
+// starting from the same block. The forward links and the payloads of the
+// blocks are removed, as they are not needed for the verification.
+func NewEvidence(from, first, second *SkipBlock, firstLink, secondLink *ForwardLink) (*Evidence, error) {
+	strip := func(sb *SkipBlock) *SkipBlock {
+		c := sb.Copy()
+		c.ForwardLink = nil
+		c.Payload = nil
+		return c
+	}
+	e := &Evidence{
+		From:       strip(from),
+		First:      strip(first),
+		Second:     strip(second),
+		FirstLink:  firstLink.Copy(),
+		SecondLink: secondLink.Copy(),
+	}
+	if err := e.Verify(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ID returns a unique identifier of the evidence. It doesn't depend on the
+// order of the two conflicting blocks.
+func (e *Evidence) ID() []byte {
+	first, second := e.First.Hash, e.Second.Hash
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+	hash := sha256.New()
+	hash.Write(e.From.Hash)
+	hash.Write(first)
+	hash.Write(second)
+	return hash.Sum(nil)
+}
+
+// SkipChainID returns the ID of the skipchain with the conflicting blocks.
+func (e *Evidence) SkipChainID() SkipBlockID {
+	return e.From.SkipChainID()
+}
+
+// Verify makes sure that the two forward links are correctly signed by the
+// roster of the From block, and that they point to two different blocks
+// with the same index in the same skipchain.
+func (e *Evidence) Verify() error {
+	if e.From == nil || e.First == nil || e.Second == nil ||
+		e.FirstLink == nil || e.SecondLink == nil {
+		return errors.New("incomplete evidence")
+	}
+	for _, sb := range []*SkipBlock{e.From, e.First, e.Second} {
+		if sb.SkipBlockFix == nil || sb.Roster == nil {
+			return errors.New("incomplete block in evidence")
+		}
+		if !sb.CalculateHash().Equal(sb.Hash) {
+			return fmt.Errorf("wrong hash for block %d", sb.Index)
+		}
+	}
+	if e.First.Hash.Equal(e.Second.Hash) {
+		return errors.New("blocks are not conflicting")
+	}
+	if e.First.Index != e.Second.Index {
+		return errors.New("blocks have different indexes")
+	}
+	if e.First.Index <= e.From.Index {
+		return errors.New("blocks are not after the source block")
+	}
+	scID := e.From.SkipChainID()
+	if !e.First.SkipChainID().Equal(scID) ||
+		!e.Second.SkipChainID().Equal(scID) {
+		return errors.New("blocks are not from the same skipchain")
+	}
+
+	publics := e.From.Roster.ServicePublics(ServiceName)
+	for _, l := range []struct {
+		fl *ForwardLink
+		to *SkipBlock
+	}{{e.FirstLink, e.First}, {e.SecondLink, e.Second}} {
+		if !l.fl.From.Equal(e.From.Hash) || !l.fl.To.Equal(l.to.Hash) {
+			return errors.New("forward link doesn't link the blocks")
+		}
+		if err := l.fl.VerifyWithScheme(suite, publics, e.From.SignatureScheme); err != nil {
+			return fmt.Errorf("wrong forward link signature: %v", err)
+		}
+	}
+	return nil
+}
+
+// Culprits returns the nodes of the From roster that signed both forward
+// links.
+func (e *Evidence) Culprits() ([]*network.ServerIdentity, error) {
+	publics := e.From.Roster.ServicePublics(ServiceName)
+	// The BDN signatures use the same mask as the BLS signatures.
+	first, err := protocol.BlsSignature(e.FirstLink.Signature.Sig).GetMask(suite, publics)
+	if err != nil {
+		return nil, err
+	}
+	second, err := protocol.BlsSignature(e.SecondLink.Signature.Sig).GetMask(suite, publics)
+	if err != nil {
+		return nil, err
+	}
+	both := second.Mask()
+	var culprits []*network.ServerIdentity
+	for i, b := range first.Mask() {
+		both[i] &= b
+	}
+	for i, si := range e.From.Roster.List {
+		if both[i/8]&(byte(1)<<uint(i&7)) != 0 {
+			culprits = append(culprits, si)
+		}
+	}
+	return culprits, nil
+}
+
+// findEquivocation searches the forward links of the source block of fl for
+// a link to another block with the same index as the block 'to'. If both
+// links are correctly signed, the evidence is returned.
+func (s *Service) findEquivocation(fl *ForwardLink, to *SkipBlock) *Evidence {
+	from := s.db.GetByID(fl.From)
+	if from == nil || to == nil || !to.Hash.Equal(fl.To) {
+		return nil
+	}
+	for _, other := range from.ForwardLink {
+		if other.IsEmpty() || other.To.Equal(fl.To) {
+			continue
+		}
+		otherTo := s.db.GetByID(other.To)
+		if otherTo == nil || otherTo.Index != to.Index {
+			continue
+		}
+		e, err := NewEvidence(from, otherTo, to, other, fl)
+		if err != nil {
+			log.Lvlf2("%s: invalid conflicting forward-link: %v",
+				s.ServerIdentity(), err)
+			continue
+		}
+		return e
+	}
+	return nil
+}
+
+// addEvidence stores the evidence if it is valid and not yet known. It
+// returns true if the evidence is new.
+func (s *Service) addEvidence(e *Evidence) (bool, error) {
+	if err := e.Verify(); err != nil {
+		return false, fmt.Errorf("invalid evidence: %v", err)
+	}
+	id := e.ID()
+	s.storageMutex.Lock()
+	for _, known := range s.Storage.Evidence {
+		if bytes.Equal(known.ID(), id) {
+			s.storageMutex.Unlock()
+			return false, nil
+		}
+	}
+	s.Storage.Evidence = append(s.Storage.Evidence, e)
+	s.storageMutex.Unlock()
+	s.save()
+	return true, nil
+}
+
+// reportEvidence stores the evidence and, if it is new, sends it to the
+// rosters of the blocks involved, so that all nodes know about the
+// equivocation. It must not be called while holding closedMutex, as it
+// registers itself as work in progress.
+func (s *Service) reportEvidence(e *Evidence) {
+	if err := s.incrementWorking(); err != nil {
+		return
+	}
+	defer s.decrementWorking()
+
+	isNew, err := s.addEvidence(e)
+	if err != nil {
+		log.Error(s.ServerIdentity(), err)
+		return
+	}
+	if !isNew {
+		return
+	}
+	culprits, _ := e.Culprits()
+	log.Warnf("%s: found conflicting blocks at index %d of skipchain %x, signed by %v",
+		s.ServerIdentity(), e.First.Index, e.SkipChainID(), culprits)
+
+	ro := e.From.Roster.Concat(e.First.Roster.List...).
+		Concat(e.Second.Roster.List...).Concat(s.ServerIdentity())
+	err = s.startPropagation(s.propagateEvidence, ro, &PropagateEvidence{e})
+	if err != nil {
+		log.Error(s.ServerIdentity(), "couldn't propagate evidence:", err)
+	}
+}
+
+// propagateEvidenceHandler stores the evidence found by another node.
+func (s *Service) propagateEvidenceHandler(msg network.Message) error {
+	pe, ok := msg.(*PropagateEvidence)
+	if !ok || pe.Evidence == nil {
+		return errors.New("couldn't convert to an Evidence propagation")
+	}
+	_, err := s.addEvidence(pe.Evidence)
+	return err
+}
+
+// GetEvidence returns all evidence of conflicting blocks this node knows
+// about. If SkipchainID is given, only the evidence for this skipchain is
+// returned.
+func (s *Service) GetEvidence(req *GetEvidence) (*GetEvidenceReply, error) {
+	s.storageMutex.Lock()
+	defer s.storageMutex.Unlock()
+	reply := &GetEvidenceReply{}
+	for _, e := range s.Storage.Evidence {
+		if len(req.SkipchainID) > 0 && !e.SkipChainID().Equal(req.SkipchainID) {
+			continue
+		}
+		reply.Evidence = append(reply.Evidence, e)
+	}
+	return reply, nil
+}
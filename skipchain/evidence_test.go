@@ -0,0 +1,157 @@
+package skipchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/byzcoinx"
+	"go.dedis.ch/kyber/v3/sign"
+	"go.dedis.ch/kyber/v3/sign/bdn"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// signBdn creates a BDN signature of the forward link with the nodes of the
+// roster at the given indexes.
+func signBdn(t *testing.T, fl *ForwardLink, ro *onet.Roster, signers ...int) {
+	msg := fl.Hash()
+	mask, err := sign.NewMask(suite, ro.ServicePublics(ServiceName), nil)
+	require.NoError(t, err)
+	var sigs [][]byte
+	for _, i := range signers {
+		sig, err := bdn.Sign(suite, ro.List[i].ServicePrivate(ServiceName), msg)
+		require.NoError(t, err)
+		sigs = append(sigs, sig)
+		require.NoError(t, mask.SetBit(i, true))
+	}
+	agg, err := bdn.AggregateSignatures(suite, sigs, mask)
+	require.NoError(t, err)
+	buf, err := agg.MarshalBinary()
+	require.NoError(t, err)
+	fl.Signature = byzcoinx.FinalSignature{
+		Msg: msg,
+		Sig: append(buf, mask.Mask()...),
+	}
+}
+
+// conflictingBlock returns a block with the same index as sb, but with
+// different data.
+func conflictingBlock(sb *SkipBlock) *SkipBlock {
+	c := sb.Copy()
+	c.ForwardLink = nil
+	c.Data = append(c.Data, 0xff)
+	c.updateHash()
+	return c
+}
+
+func TestEvidence_Verify(t *testing.T) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer local.CloseAll()
+	_, ro, _ := local.GenTree(4, true)
+
+	genesis := NewSkipBlock()
+	genesis.Roster = ro
+	genesis.SignatureScheme = BdnSignatureSchemeIndex
+	genesis.updateHash()
+	first := genesis.Copy()
+	first.Index = 1
+	first.GenesisID = genesis.Hash
+	first.BackLinkIDs = []SkipBlockID{genesis.Hash}
+	first.updateHash()
+	second := conflictingBlock(first)
+
+	fl1 := NewForwardLink(genesis, first)
+	signBdn(t, fl1, ro, 0, 1, 2)
+	fl2 := NewForwardLink(genesis, second)
+	signBdn(t, fl2, ro, 1, 2, 3)
+
+	e, err := NewEvidence(genesis, first, second, fl1, fl2)
+	require.NoError(t, err)
+	require.True(t, e.SkipChainID().Equal(genesis.Hash))
+	culprits, err := e.Culprits()
+	require.NoError(t, err)
+	require.Equal(t, []*network.ServerIdentity{ro.List[1], ro.List[2]}, culprits)
+
+	e2, err := NewEvidence(genesis, second, first, fl2, fl1)
+	require.NoError(t, err)
+	require.Equal(t, e.ID(), e2.ID())
+
+	// Same block twice is not a conflict.
+	_, err = NewEvidence(genesis, first, first, fl1, fl1)
+	require.Error(t, err)
+	// Links must point to the blocks.
+	_, err = NewEvidence(genesis, first, second, fl2, fl1)
+	require.Error(t, err)
+	// Not enough signers.
+	weak := NewForwardLink(genesis, second)
+	signBdn(t, weak, ro, 3)
+	_, err = NewEvidence(genesis, first, second, fl1, weak)
+	require.Error(t, err)
+	// Blocks must have the same index.
+	other := first.Copy()
+	other.Index = 2
+	other.updateHash()
+	fl3 := NewForwardLink(genesis, other)
+	signBdn(t, fl3, ro, 0, 1, 2, 3)
+	_, err = NewEvidence(genesis, first, other, fl1, fl3)
+	require.Error(t, err)
+	// Tampered blocks are detected.
+	e.Second.Data = []byte{}
+	require.Error(t, e.Verify())
+}
+
+// TestService_Evidence makes sure that a conflicting forward link is
+// refused, and that the evidence is sent to all nodes of the roster.
+func TestService_Evidence(t *testing.T) {
+	local := onet.NewLocalTest(cothority.Suite)
+	defer waitPropagationFinished(t, local)
+	defer local.CloseAll()
+	servers, ro, _ := local.MakeSRS(cothority.Suite, 4, skipchainSID)
+	services := make([]*Service, len(servers))
+	for i, s := range local.GetServices(servers, skipchainSID) {
+		services[i] = s.(*Service)
+	}
+
+	genesis, err := makeGenesisRoster(services[0], ro)
+	require.NoError(t, err)
+	sb := NewSkipBlock()
+	sb.Roster = ro
+	first, err := addBlockToChain(services[0], genesis.Hash, sb)
+	require.NoError(t, err)
+	require.NoError(t, waitForwardLinks(services[1], genesis, 1))
+
+	second := conflictingBlock(first)
+	fl := NewForwardLink(genesis, second)
+	signBdn(t, fl, ro, 0, 1, 2, 3)
+
+	s := services[1]
+	s.blockBuffer.add(second)
+	err = s.propagateForwardLinkHandler(&PropagateForwardLink{fl, 0})
+	require.Error(t, err)
+	stored := s.db.GetByID(genesis.Hash)
+	require.True(t, stored.ForwardLink[0].To.Equal(first.Hash))
+	require.Nil(t, s.db.GetByID(second.Hash))
+
+	cl := NewClient()
+	for _, si := range ro.List {
+		var evidence []*Evidence
+		for i := 0; i < 20; i++ {
+			evidence, err = cl.GetEvidence(si, genesis.SkipChainID())
+			require.NoError(t, err)
+			if len(evidence) > 0 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		require.Len(t, evidence, 1)
+		culprits, err := evidence[0].Culprits()
+		require.NoError(t, err)
+		require.NotEmpty(t, culprits)
+	}
+
+	evidence, err := cl.GetEvidence(ro.List[0], SkipBlockID{1, 2, 3})
+	require.NoError(t, err)
+	require.Empty(t, evidence)
+}
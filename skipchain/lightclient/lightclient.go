@@ -0,0 +1,175 @@
+// Package lightclient follows a skipchain by only fetching the headers of its
+// blocks. It keeps the latest verified block, the trusted head, in a file, so
+// that the next update only needs the blocks created since then.
+//
+// Every update is verified from the trusted head on, using the forward links
+// and their collective signatures. The light client never trusts the nodes
+// it contacts, only the block it has been started with.
+package lightclient
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3/network"
+	"go.dedis.ch/protobuf"
+)
+
+// Client holds the trusted head of a skipchain and updates it with the
+// headers of the newer blocks.
+type Client struct {
+	*skipchain.Client
+	path string
+	head *skipchain.SkipBlock
+	sync.Mutex
+}
+
+// New creates a light client for the skipchain of the trusted block, which
+// is usually the genesis block. If a trusted head from the same skipchain
+// is already stored in path, it is used instead of the given block.
+func New(path string, trusted *skipchain.SkipBlock) (*Client, error) {
+	if trusted == nil || !trusted.Hash.Equal(trusted.CalculateHash()) {
+		return nil, errors.New("invalid trusted block")
+	}
+	if _, err := os.Stat(path); err == nil {
+		c, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if !c.head.SkipChainID().Equal(trusted.SkipChainID()) {
+			return nil, errors.New("stored head is from another skipchain")
+		}
+		if c.head.Index >= trusted.Index {
+			return c, nil
+		}
+	}
+
+	c := &Client{
+		Client: skipchain.NewClient(),
+		path:   path,
+		head:   header(trusted),
+	}
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Load returns a light client with the trusted head stored in path.
+func Load(path string) (*Client, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read head: %v", err)
+	}
+	head := &skipchain.SkipBlock{}
+	err = protobuf.DecodeWithConstructors(buf, head,
+		network.DefaultConstructors(cothority.Suite))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode head: %v", err)
+	}
+	if head.SkipBlockFix == nil || !head.Hash.Equal(head.CalculateHash()) {
+		return nil, errors.New("stored head has a wrong hash")
+	}
+	return &Client{
+		Client: skipchain.NewClient(),
+		path:   path,
+		head:   head,
+	}, nil
+}
+
+// Head returns a copy of the trusted head.
+func (c *Client) Head() *skipchain.SkipBlock {
+	c.Lock()
+	defer c.Unlock()
+	return c.head.Copy()
+}
+
+// Update fetches the headers of the blocks following the trusted head from
+// the nodes of the roster of the head. The headers are verified and the
+// latest one is stored as the new trusted head, which is returned.
+func (c *Client) Update() (*skipchain.SkipBlock, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	update, err := c.GetUpdateChainHeaders(c.head.Roster, c.head.Hash, 0)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get update: %v", err)
+	}
+	if err := verify(c.head, update); err != nil {
+		return nil, err
+	}
+
+	latest := update[len(update)-1]
+	if latest.Index > c.head.Index {
+		c.head = header(latest)
+		if err := c.save(); err != nil {
+			return nil, err
+		}
+	}
+	return c.head.Copy(), nil
+}
+
+// verify makes sure that the update starts at the head, and that every
+// block is signed by the previous one.
+func verify(head *skipchain.SkipBlock, update []*skipchain.SkipBlock) error {
+	if len(update) == 0 || !update[0].Hash.Equal(head.Hash) {
+		return errors.New("update doesn't start at the trusted head")
+	}
+	for i, sb := range update {
+		if err := sb.VerifyForwardSignatures(); err != nil {
+			return fmt.Errorf("block %d: %v", sb.Index, err)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := update[i-1]
+		if !sb.SkipChainID().Equal(head.SkipChainID()) {
+			return fmt.Errorf("block %d is from another skipchain", sb.Index)
+		}
+		if sb.Index <= prev.Index {
+			return fmt.Errorf("block %d doesn't follow block %d", sb.Index,
+				prev.Index)
+		}
+		linked := false
+		for _, fl := range prev.ForwardLink {
+			if !fl.IsEmpty() && fl.To.Equal(sb.Hash) {
+				linked = true
+			}
+		}
+		if !linked {
+			return fmt.Errorf("no forward link from block %d to block %d",
+				prev.Index, sb.Index)
+		}
+	}
+	return nil
+}
+
+// header returns a copy of the block without payload and forward links.
+func header(sb *skipchain.SkipBlock) *skipchain.SkipBlock {
+	h := sb.Copy()
+	h.Payload = nil
+	h.ForwardLink = nil
+	return h
+}
+
+// save writes the head to a temporary file before replacing the old one,
+// so that an interrupted write doesn't lose the trusted head.
+func (c *Client) save() error {
+	buf, err := protobuf.Encode(c.head)
+	if err != nil {
+		return fmt.Errorf("couldn't encode head: %v", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return fmt.Errorf("couldn't write head: %v", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("couldn't write head: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package lightclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cothority/v3"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/log"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+func TestClient_Update(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(3, true)
+
+	dir, err := ioutil.TempDir("", "lightclient")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "head")
+
+	sc := skipchain.NewClient()
+	genesis, err := sc.CreateGenesis(roster, 2, 3, skipchain.VerificationNone, nil)
+	require.NoError(t, err)
+	add := func(n int) *skipchain.SkipBlock {
+		var latest *skipchain.SkipBlock
+		for i := 0; i < n; i++ {
+			reply, err := sc.StoreSkipBlock(genesis, roster,
+				bytes.Repeat([]byte{byte(i)}, 1024))
+			require.NoError(t, err)
+			latest = reply.Latest
+		}
+		return latest
+	}
+
+	_, err = New(path, nil)
+	require.Error(t, err)
+	lc, err := New(path, genesis)
+	require.NoError(t, err)
+	head, err := lc.Update()
+	require.NoError(t, err)
+	require.True(t, head.Hash.Equal(genesis.Hash))
+
+	latest := add(5)
+	head, err = lc.Update()
+	require.NoError(t, err)
+	require.True(t, head.Hash.Equal(latest.Hash))
+	require.Empty(t, head.ForwardLink)
+
+	// The trusted head is kept across restarts.
+	lc, err = Load(path)
+	require.NoError(t, err)
+	require.True(t, lc.Head().Hash.Equal(latest.Hash))
+	lc, err = New(path, genesis)
+	require.NoError(t, err)
+	require.True(t, lc.Head().Hash.Equal(latest.Hash))
+
+	// A stored head from another chain is refused.
+	other, err := sc.CreateGenesis(roster, 2, 3, skipchain.VerificationNone, nil)
+	require.NoError(t, err)
+	_, err = New(path, other)
+	require.Error(t, err)
+
+	// A corrupted head is refused.
+	require.NoError(t, ioutil.WriteFile(path, []byte("corrupted"), 0600))
+	_, err = Load(path)
+	require.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	local := onet.NewTCPTest(cothority.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(3, true)
+
+	sc := skipchain.NewClient()
+	genesis, err := sc.CreateGenesis(roster, 1, 1, skipchain.VerificationNone, nil)
+	require.NoError(t, err)
+	latest := genesis
+	for i := 0; i < 2; i++ {
+		reply, err := sc.StoreSkipBlock(latest, roster, nil)
+		require.NoError(t, err)
+		latest = reply.Latest
+	}
+	update, err := sc.GetUpdateChainHeaders(roster, genesis.Hash, 0)
+	require.NoError(t, err)
+	require.Len(t, update, 3)
+	require.NoError(t, verify(genesis, update))
+
+	require.Error(t, verify(latest, update))
+	require.Error(t, verify(genesis, nil))
+	require.Error(t, verify(genesis, []*skipchain.SkipBlock{update[0], update[2]}))
+
+	wrong := update[2].Copy()
+	wrong.Data = []byte("wrong")
+	require.Error(t, verify(genesis, []*skipchain.SkipBlock{update[0], update[1], wrong}))
+}
@@ -44,11 +44,15 @@ func init() {
 		&ListFollow{},
 		// Returns the genesis-blocks of all skipchains we follow
 		&ListFollowReply{},
+		// Request evidence of conflicting blocks
+		&GetEvidence{},
+		&GetEvidenceReply{},
 		// - Internal calls
 		// Propagation
 		&PropagateGenesis{},
 		&PropagateForwardLink{},
 		&PropagateProof{},
+		&PropagateEvidence{},
 		// Request forward-signature
 		&ForwardSignature{},
 		&ForwardSignatureReply{},
@@ -119,6 +123,11 @@ type GetUpdateChain struct {
 	// MaxBlocks is the maximum number of blocks to be returned. If it is not
 	// given, or equal to 0, all available blocks will be returned.
 	MaxBlocks int `protobuf:"opt"`
+	// HeaderOnly asks for the blocks without their payload, and only with the
+	// forward links needed to go from one block to the next. The roster is
+	// only sent for the first block, as the following ones can be found in
+	// the previous block or in the forward link pointing to them.
+	HeaderOnly bool `protobuf:"opt"`
 }
 
 // GetUpdateChainReply - returns the shortest chain to the current SkipBlock,
@@ -167,6 +176,12 @@ type PropagateProof struct {
 	Proof Proof
 }
 
+// PropagateEvidence sends the evidence of conflicting blocks to all members
+// of the Cothority
+type PropagateEvidence struct {
+	Evidence *Evidence
+}
+
 // ForwardSignature is called once a new skipblock has been accepted by
 // signing the forward-link, and then the older skipblocks need to
 // update their forward-links. Each cothority needs to get the necessary
@@ -368,3 +383,14 @@ type ListFollowReply struct {
 	Follow    *[]FollowChainType
 	FollowIDs *[]SkipBlockID
 }
+
+// GetEvidence requests the evidence of conflicting blocks a conode knows
+// about. If SkipchainID is empty, the evidence of all skipchains is returned.
+type GetEvidence struct {
+	SkipchainID SkipBlockID
+}
+
+// GetEvidenceReply returns the evidence of conflicting blocks.
+type GetEvidenceReply struct {
+	Evidence []*Evidence
+}
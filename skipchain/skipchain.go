@@ -63,6 +63,7 @@ type Service struct {
 	propagateGenesis        messaging.PropagationFunc
 	propagateForwardLink    messaging.PropagationFunc
 	propagateProof          messaging.PropagationFunc
+	propagateEvidence       messaging.PropagationFunc
 	verifiers               map[VerifierID]SkipBlockVerifier
 	storageMutex            sync.Mutex
 	Storage                 *Storage
@@ -153,6 +154,8 @@ type Storage struct {
 	// to this service. Once a client is linked to a service, only blocks signed
 	// by this client will be allowed.
 	Clients []kyber.Point
+	// Evidence holds the proofs of conflicting blocks seen by this node.
+	Evidence []*Evidence
 }
 
 // StoreSkipBlock stores a new skipblock in the system. This can be either a
@@ -670,12 +673,57 @@ func (s *Service) GetUpdateChain(guc *GetUpdateChain) (*GetUpdateChainReply, err
 		}
 	}
 
+	if guc.HeaderOnly {
+		stripHeaders(blocks, maxHeight)
+	}
+
 	log.Lvlf3("Found %d blocks", len(blocks))
 	reply := &GetUpdateChainReply{Update: blocks}
 
 	return reply, nil
 }
 
+// stripHeaders removes everything from the blocks of an update chain that is
+// not needed to verify it: the payload, the forward links not used to go to
+// the next block, and the rosters that can be found in the previous block or
+// in the forward link pointing to the block. The blocks must be copies.
+func stripHeaders(blocks []*SkipBlock, maxHeight int) {
+	for j, sb := range blocks {
+		sb.Payload = nil
+
+		pos := -1
+		if j < len(blocks)-1 {
+			for i, fl := range sb.ForwardLink {
+				if !fl.IsEmpty() && fl.To.Equal(blocks[j+1].Hash) {
+					pos = i
+				}
+			}
+		} else if n := sb.GetForwardLen(); n > 0 {
+			// Keep the link the client will follow for the next update.
+			pos = n - 1
+			if pos >= maxHeight {
+				pos = maxHeight - 1
+			}
+		}
+		links := make([]*ForwardLink, pos+1)
+		for i := 0; i < pos; i++ {
+			links[i] = &ForwardLink{}
+		}
+		if pos >= 0 {
+			links[pos] = sb.ForwardLink[pos]
+		}
+		sb.ForwardLink = links
+	}
+
+	for j := len(blocks) - 1; j > 0; j-- {
+		prev := blocks[j-1]
+		if len(prev.ForwardLink) > 0 &&
+			prev.ForwardLink[len(prev.ForwardLink)-1].To.Equal(blocks[j].Hash) {
+			blocks[j].Roster = nil
+		}
+	}
+}
+
 // RegisterStoreSkipblockCallback sets a callback function in SkipBlockDB,
 // which is called just before a skipblock is added/updated.
 func (s *Service) RegisterStoreSkipblockCallback(f func(SkipBlockID) error) {
@@ -1335,7 +1383,16 @@ func (s *Service) bftForwardLinkLevel0(msg, data []byte) bool {
 		return false
 	}
 	if len(prevSB.ForwardLink) > 0 {
-		log.Lvl2("previous block already has forward-link")
+		if !prevSB.ForwardLink[0].To.Equal(fs.Newest.Hash) {
+			// The proposal is not signed, so it cannot be used as evidence,
+			// but if the conflicting block gets co-signed by other nodes,
+			// the evidence will be collected with the forward-link.
+			log.Warnf("%s: asked to sign block %d : %x conflicting with %x",
+				s.ServerIdentity(), fs.Newest.Index, fs.Newest.Hash,
+				prevSB.ForwardLink[0].To)
+		} else {
+			log.Lvl2("previous block already has forward-link")
+		}
 		return false
 	}
 
@@ -1574,6 +1631,19 @@ func (s *Service) bftForwardLink(msg, data []byte) bool {
 			return errors.New("latest link doesn't point to newest block")
 		}
 
+		// Make sure none of the links conflicts with a block already known,
+		// else the nodes that signed both links are reported.
+		for i, fl := range fs.Links {
+			to := dst
+			if i < len(fs.Links)-1 {
+				to = s.db.GetByID(fl.To)
+			}
+			if e := s.findEquivocation(fl, to); e != nil {
+				go s.reportEvidence(e)
+				return errors.New("link list conflicts with a known block")
+			}
+		}
+
 		// Verify the forward link itself is correct before agreeing to sign
 		// it.
 		fl := NewForwardLink(src, fs.Newest)
@@ -1710,6 +1780,19 @@ func (s *Service) propagateForwardLinkHandler(msg network.Message) error {
 	log.Lvlf2("Adding Forwardlink with height %d to block %d: %x -> %x)",
 		pfl.Height, sb.Index, pfl.ForwardLink.From, pfl.ForwardLink.To)
 
+	var newBlock *SkipBlock
+	if pfl.Height == 0 {
+		newBlock = s.blockBuffer.get(sb.SkipChainID(), pfl.ForwardLink.To)
+	} else {
+		newBlock = s.db.GetByID(pfl.ForwardLink.To)
+	}
+	if e := s.findEquivocation(pfl.ForwardLink, newBlock); e != nil {
+		// Keep the forward-link already stored and report the nodes that
+		// signed both.
+		go s.reportEvidence(e)
+		return xerrors.New("forward-link conflicts with an existing one")
+	}
+
 	err := sb.AddForwardLink(pfl.ForwardLink, pfl.Height)
 	if err != nil {
 		return xerrors.Errorf("couldn't add forward-link: %v", err)
@@ -1718,7 +1801,6 @@ func (s *Service) propagateForwardLinkHandler(msg network.Message) error {
 	blocks := []*SkipBlock{sb}
 
 	if pfl.Height == 0 {
-		newBlock := s.blockBuffer.get(sb.SkipChainID(), pfl.ForwardLink.To)
 		if newBlock == nil {
 			return xerrors.New("cannot store forward-link if there is no" +
 				" corresponding block")
@@ -1996,7 +2078,7 @@ func newSkipchainService(c *onet.Context) (onet.Service, error) {
 		s.GetSingleBlock, s.GetSingleBlockByIndex, s.GetAllSkipchains,
 		s.GetAllSkipChainIDs, s.OptimizeProof,
 		s.CreateLinkPrivate, s.Unlink, s.AddFollow, s.ListFollow,
-		s.DelFollow, s.Listlink, s.ForwardLinkHandler))
+		s.DelFollow, s.Listlink, s.ForwardLinkHandler, s.GetEvidence))
 	s.ServiceProcessor.RegisterStatusReporter("Skipblock", s.db)
 	// Deprecated: the handler should be used instead
 	s.RegisterProcessorFunc(network.RegisterMessage(&ForwardSignature{}), s.forwardLink)
@@ -2018,6 +2100,10 @@ func newSkipchainService(c *onet.Context) (onet.Service, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.propagateEvidence, err = messaging.NewPropagationFunc(c, "SkipchainPropagateEvidence", s.propagateEvidenceHandler, -1)
+	if err != nil {
+		return nil, err
+	}
 	// Register ByzCoinX protocols for BLS
 	err = byzcoinx.InitBFTCoSiProtocol(suite, s.Context,
 		s.bftForwardLinkLevel0, s.bftForwardLinkLevel0Ack, bftNewBlock)
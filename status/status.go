@@ -21,6 +21,7 @@ import (
 	"time"
 
 	cli "github.com/urfave/cli"
+	"go.dedis.ch/cothority/v3/keystore"
 	status "go.dedis.ch/cothority/v3/status/service"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/app"
@@ -223,7 +224,7 @@ func connectivity(c *cli.Context) error {
 		return errors.New("duration parse error: " + err.Error())
 	}
 	ff := c.Bool("findFaulty")
-	coth, err := app.LoadCothority(c.Args().Get(1))
+	coth, err := keystore.LoadCothority(c.Args().Get(1))
 	if err != nil {
 		return errors.New("error while loading private.toml: " + err.Error())
 	}
@@ -338,7 +339,7 @@ func serve(c *cli.Context) error {
 	if err != nil {
 		return errors.New("duration parse error: " + err.Error())
 	}
-	coth, err := app.LoadCothority(c.Args().Get(1))
+	coth, err := keystore.LoadCothority(c.Args().Get(1))
 	if err != nil {
 		return errors.New("error while loading private.toml: " + err.Error())
 	}